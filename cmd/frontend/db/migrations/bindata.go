@@ -182,6 +182,10 @@
 // ../../../../migrations/1528395558_.up.sql (110B)
 // ../../../../migrations/1528395559_.down.sql (95B)
 // ../../../../migrations/1528395559_.up.sql (732B)
+// ../../../../migrations/1528395560_.down.sql (104B)
+// ../../../../migrations/1528395560_.up.sql (175B)
+// ../../../../migrations/1528395561_.down.sql (46B)
+// ../../../../migrations/1528395561_.up.sql (62B)
 
 package migrations
 
@@ -3890,6 +3894,86 @@ func _1528395559_UpSql() (*asset, error) {
 	return a, nil
 }
 
+var __1528395560_DownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x73\x09\xf2\x0f\x50\xf0\xf4\x73\x71\x8d\x50\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x28\x4a\x2d\xc8\x8f\x4f\xad\x28\x49\x2d\xca\x4b\xcc\x89\x2f\x4e\x2d\x2a\xcb\x4c\x4e\x8d\x07\x8b\x66\xa6\x54\x58\x73\x39\xfa\x84\xb8\x06\x29\x84\x38\x3a\xf9\xb8\x82\xd5\x2a\xb8\x80\x4c\x71\xf6\xf7\x09\xf5\xf5\x43\x32\x26\x25\x35\x27\xb5\x24\x35\x25\x3e\xb1\xc4\x9a\x0b\x00\x2b\x86\x39\xce\x68\x00\x00\x00")
+
+func _1528395560_DownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395560_DownSql,
+		"1528395560_.down.sql",
+	)
+}
+
+func _1528395560_DownSql() (*asset, error) {
+	bytes, err := _1528395560_DownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395560_.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x21, 0x64, 0xd, 0xff, 0xc3, 0x66, 0xb2, 0xad, 0xfa, 0x66, 0xe2, 0xd1, 0xb7, 0x61, 0xba, 0x47, 0x7e, 0x86, 0x78, 0x83, 0x15, 0xc3, 0x67, 0xb1, 0x13, 0x41, 0x14, 0xde, 0x8, 0xdf, 0x2e, 0x79}}
+	return a, nil
+}
+
+var __1528395560_UpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x65\xcc\xbd\x0a\xc2\x30\x14\xc5\xf1\xbd\x4f\x71\x46\x05\xdf\xa0\x53\x6c\x32\x08\x31\x85\x12\xc1\x2d\x04\x73\xc1\x0b\xfd\x08\xe9\x45\xab\x4f\x2f\x64\x12\x3a\x9e\x1f\x7f\x8e\xb2\xde\x0c\xf0\xea\x6c\x0d\x0a\xe5\x05\x4a\x6b\x74\xbd\xbd\x5d\x1d\x12\x8d\x24\x94\x42\x14\x08\x4f\xb4\x4a\x9c\x32\xde\x2c\xcf\x3a\xf1\x5d\x66\x6a\x9b\x6e\x30\xca\x1b\x5c\x9c\x36\xf7\xfa\x10\x68\x13\x2a\x73\x1c\xc3\x4a\xe5\xc5\x0f\x0a\x55\x39\x6d\xe8\x5d\x2d\x0e\xbb\x42\x3e\x99\x4e\xd8\x31\xa7\x3f\xe4\x74\x6c\x9b\x1f\x22\x8c\xb8\x48\xaf\x00\x00\x00")
+
+func _1528395560_UpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395560_UpSql,
+		"1528395560_.up.sql",
+	)
+}
+
+func _1528395560_UpSql() (*asset, error) {
+	bytes, err := _1528395560_UpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395560_.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xe9, 0x74, 0x47, 0xd3, 0xbc, 0xeb, 0x4c, 0x95, 0xd4, 0x25, 0x89, 0x46, 0xe7, 0x5b, 0x87, 0x0, 0x54, 0xb5, 0xe2, 0xec, 0xe3, 0x36, 0x0, 0x42, 0x60, 0x1a, 0xd9, 0x79, 0x6d, 0x5d, 0x95, 0x2d}}
+	return a, nil
+}
+
+var __1528395561_DownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x73\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x28\x4a\x2d\xc8\x57\x70\x09\xf2\x0f\x50\x70\xf6\xf7\x09\xf5\xf5\x53\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x28\x2e\x49\x2c\x2a\xb6\xe6\x02\x00\x0f\x23\x30\x50\x2e\x00\x00\x00")
+
+func _1528395561_DownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395561_DownSql,
+		"1528395561_.down.sql",
+	)
+}
+
+func _1528395561_DownSql() (*asset, error) {
+	bytes, err := _1528395561_DownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395561_.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xc3, 0x88, 0x9c, 0x2a, 0xe6, 0xf5, 0xfe, 0x67, 0xd9, 0x7b, 0x14, 0x9f, 0xdd, 0x20, 0x86, 0x8b, 0xa8, 0xb7, 0x4c, 0x27, 0xb6, 0xa5, 0x66, 0xf7, 0x98, 0xa6, 0xe4, 0x91, 0xa2, 0x1, 0x7a, 0xed}}
+	return a, nil
+}
+
+var __1528395561_UpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x73\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x28\x4a\x2d\xc8\x57\x70\x74\x71\x51\x70\xf6\xf7\x09\xf5\xf5\x53\x28\x2e\x49\x2c\x2a\x56\xc8\xcc\x2b\x49\x4d\x4f\x2d\x52\xf0\xf3\x0f\x51\xf0\x0b\xf5\xf1\x51\x70\x71\x75\x73\x0c\xf5\x09\x51\x30\xb0\xe6\x02\x00\x99\xf9\xa4\x53\x3e\x00\x00\x00")
+
+func _1528395561_UpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395561_UpSql,
+		"1528395561_.up.sql",
+	)
+}
+
+func _1528395561_UpSql() (*asset, error) {
+	bytes, err := _1528395561_UpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395561_.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x88, 0x3e, 0xce, 0x3, 0xee, 0x7c, 0xdd, 0x7b, 0xb7, 0xde, 0xfd, 0x64, 0xcb, 0x36, 0xdc, 0xe6, 0xb4, 0xcf, 0x87, 0xc, 0xc8, 0x93, 0x45, 0xc4, 0x10, 0x9e, 0xf2, 0x3d, 0xbb, 0xb2, 0x9c, 0xc}}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -4344,17 +4428,27 @@ var _bindata = map[string]func() (*asset, error){
 	"1528395559_.down.sql": _1528395559_DownSql,
 
 	"1528395559_.up.sql": _1528395559_UpSql,
+
+	"1528395560_.down.sql": _1528395560_DownSql,
+
+	"1528395560_.up.sql": _1528395560_UpSql,
+
+	"1528395561_.down.sql": _1528395561_DownSql,
+
+	"1528395561_.up.sql": _1528395561_UpSql,
 }
 
 // AssetDir returns the file names below a certain
 // directory embedded in the file by go-bindata.
 // For example if you run go-bindata on data/... and data contains the
 // following hierarchy:
-//     data/
-//       foo.txt
-//       img/
-//         a.png
-//         b.png
+//
+//	data/
+//	  foo.txt
+//	  img/
+//	    a.png
+//	    b.png
+//
 // then AssetDir("data") would return []string{"foo.txt", "img"},
 // AssetDir("data/img") would return []string{"a.png", "b.png"},
 // AssetDir("foo.txt") and AssetDir("notexist") would return an error, and
@@ -4569,6 +4663,10 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"1528395558_.up.sql":                                          &bintree{_1528395558_UpSql, map[string]*bintree{}},
 	"1528395559_.down.sql":                                        &bintree{_1528395559_DownSql, map[string]*bintree{}},
 	"1528395559_.up.sql":                                          &bintree{_1528395559_UpSql, map[string]*bintree{}},
+	"1528395560_.down.sql":                                        &bintree{_1528395560_DownSql, map[string]*bintree{}},
+	"1528395560_.up.sql":                                          &bintree{_1528395560_UpSql, map[string]*bintree{}},
+	"1528395561_.down.sql":                                        &bintree{_1528395561_DownSql, map[string]*bintree{}},
+	"1528395561_.up.sql":                                          &bintree{_1528395561_UpSql, map[string]*bintree{}},
 }}
 
 // RestoreAsset restores an asset under the given directory.
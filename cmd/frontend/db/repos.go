@@ -49,7 +49,7 @@ func (s *repos) Get(ctx context.Context, id api.RepoID) (*types.Repo, error) {
 		return Mocks.Repos.Get(ctx, id)
 	}
 
-	repos, err := s.getBySQL(ctx, sqlf.Sprintf("WHERE id=%d LIMIT 1", id))
+	repos, err := s.getBySQL(ctx, sqlf.Sprintf("WHERE id=%d AND deleted_at IS NULL LIMIT 1", id))
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +69,24 @@ func (s *repos) GetByName(ctx context.Context, name api.RepoName) (*types.Repo,
 		return Mocks.Repos.GetByName(ctx, name)
 	}
 
+	repos, err := s.getBySQL(ctx, sqlf.Sprintf("WHERE name=%s AND deleted_at IS NULL LIMIT 1", name))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(repos) == 0 {
+		return nil, &repoNotFoundErr{Name: name}
+	}
+	return repos[0], nil
+}
+
+// GetByNameIncludingDeleted is like GetByName, but it also returns the
+// repository if it was soft-deleted by Sync.
+func (s *repos) GetByNameIncludingDeleted(ctx context.Context, name api.RepoName) (*types.Repo, error) {
+	if Mocks.Repos.GetByNameIncludingDeleted != nil {
+		return Mocks.Repos.GetByNameIncludingDeleted(ctx, name)
+	}
+
 	repos, err := s.getBySQL(ctx, sqlf.Sprintf("WHERE name=%s LIMIT 1", name))
 	if err != nil {
 		return nil, err
@@ -100,7 +118,7 @@ func (s *repos) Count(ctx context.Context, opt ReposListOptions) (int, error) {
 }
 
 func (s *repos) getBySQL(ctx context.Context, querySuffix *sqlf.Query) ([]*types.Repo, error) {
-	q := sqlf.Sprintf("SELECT id, name, description, language, enabled, indexed_revision, created_at, updated_at, freeze_indexed_revision, external_id, external_service_type, external_service_id FROM repo %s", querySuffix)
+	q := sqlf.Sprintf("SELECT id, name, description, language, enabled, indexed_revision, created_at, updated_at, freeze_indexed_revision, external_id, external_service_type, external_service_id, fork, stars FROM repo %s", querySuffix)
 	rows, err := dbconn.Global.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
 	if err != nil {
 		return nil, err
@@ -112,6 +130,7 @@ func (s *repos) getBySQL(ctx context.Context, querySuffix *sqlf.Query) ([]*types
 		var repo types.Repo
 		var freezeIndexedRevision *bool
 		var spec dbExternalRepoSpec
+		var fork *bool
 
 		if err := rows.Scan(
 			&repo.ID,
@@ -124,11 +143,14 @@ func (s *repos) getBySQL(ctx context.Context, querySuffix *sqlf.Query) ([]*types
 			&repo.UpdatedAt,
 			&freezeIndexedRevision,
 			&spec.id, &spec.serviceType, &spec.serviceID,
+			&fork,
+			&repo.Stars,
 		); err != nil {
 			return nil, err
 		}
 
 		repo.FreezeIndexedRevision = freezeIndexedRevision != nil && *freezeIndexedRevision // FIXME: bad DB schema: nullable boolean
+		repo.Fork = fork != nil && *fork
 		repo.ExternalRepo = spec.toAPISpec()
 
 		repos = append(repos, &repo)
@@ -265,7 +287,7 @@ func (s *repos) List(ctx context.Context, opt ReposListOptions) (results []*type
 // indexed-search). We special case just returning enabled names so that we
 // read much less data into memory.
 func (s *repos) ListEnabledNames(ctx context.Context) ([]string, error) {
-	q := sqlf.Sprintf("SELECT name FROM repo WHERE enabled = true")
+	q := sqlf.Sprintf("SELECT name FROM repo WHERE enabled = true AND deleted_at IS NULL")
 	rows, err := dbconn.Global.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
 	if err != nil {
 		return nil, err
@@ -318,7 +340,7 @@ func parsePattern(p string) ([]*sqlf.Query, error) {
 }
 
 func (*repos) listSQL(opt ReposListOptions) (conds []*sqlf.Query, err error) {
-	conds = []*sqlf.Query{sqlf.Sprintf("TRUE")}
+	conds = []*sqlf.Query{sqlf.Sprintf("deleted_at IS NULL")}
 	if opt.Query != "" && (len(opt.IncludePatterns) > 0 || opt.ExcludePattern != "") {
 		return nil, errors.New("Repos.List: Query and IncludePatterns/ExcludePattern options are mutually exclusive")
 	}
@@ -615,7 +637,7 @@ func (s *repos) UpdateRepositoryMetadata(ctx context.Context, name api.RepoName,
 }
 
 const upsertSQL = `WITH UPSERT AS (
-	UPDATE repo SET name=$1, description=$2, fork=$3, enabled=$4, external_id=$5, external_service_type=$6, external_service_id=$7, archived=$9 WHERE name=$1 RETURNING name
+	UPDATE repo SET name=$1, description=$2, fork=$3, enabled=$4, external_id=$5, external_service_type=$6, external_service_id=$7, archived=$9, deleted_at=NULL WHERE name=$1 RETURNING name
 )
 INSERT INTO repo(name, description, fork, language, enabled, external_id, external_service_type, external_service_id, archived) (
 	SELECT $1 AS name, $2 AS description, $3 AS fork, $8 as language, $4 AS enabled,
@@ -624,7 +646,7 @@ INSERT INTO repo(name, description, fork, language, enabled, external_id, extern
 )`
 
 // Upsert updates the repository if it already exists (keyed on name) and
-// inserts it if it does not.
+// inserts it if it does not. A repository soft-deleted by Sync is restored.
 //
 // If repo exists, op.Enabled is ignored.
 func (s *repos) Upsert(ctx context.Context, op api.InsertRepoOp) error {
@@ -111,6 +111,9 @@ func TestRepos_List_fork(t *testing.T) {
 
 	mine := mustCreate(ctx, t, &types.Repo{Name: "a/r", Fork: false})
 	yours := mustCreate(ctx, t, &types.Repo{Name: "b/r", Fork: true})
+	if !yours[0].Fork {
+		t.Error("expected fork to be loaded from the DB")
+	}
 
 	{
 		repos, err := Repos.List(ctx, ReposListOptions{Enabled: true, OnlyForks: true})
@@ -0,0 +1,394 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+// errSyncDryRun rolls back the transaction of a dry run of Sync.
+var errSyncDryRun = errors.New("dry run")
+
+// syncRepo is a stored repository of the external service being synced.
+type syncRepo struct {
+	id          api.RepoID
+	name        api.RepoName
+	description string
+	fork        bool
+	archived    bool
+	stars       int
+	externalID  string
+	deleted     bool
+}
+
+// Sync reconciles the stored repositories of an external service with a
+// listing of its repositories, matching them by external ID. In a single
+// transaction, it creates the listed repositories which are not stored,
+// renames and updates the metadata of the stored repositories which differ
+// from the listing, and (if the listing is complete) soft-deletes the stored
+// repositories missing from it. A soft-deleted repository is hidden from
+// every other method of Repos until it is listed again.
+//
+// If op.DryRun is true, the changes are computed but rolled back.
+func (s *repos) Sync(ctx context.Context, op api.ReposSyncRequest) (*api.ReposSyncResponse, error) {
+	if Mocks.Repos.Sync != nil {
+		return Mocks.Repos.Sync(ctx, op)
+	}
+
+	for _, r := range op.Repos {
+		if r.ExternalRepo == nil || r.ExternalRepo.ServiceType != op.ServiceType || r.ExternalRepo.ServiceID != op.ServiceID {
+			return nil, errors.Errorf("Repos.Sync: repo %q is not on external service %s %s", r.RepoName, op.ServiceType, op.ServiceID)
+		}
+	}
+
+	var resp api.ReposSyncResponse
+	err := Transaction(ctx, dbconn.Global, func(tx *sql.Tx) (err error) {
+		resp.Diff, err = syncRepos(ctx, tx, op)
+		if err != nil {
+			return err
+		}
+		if op.DryRun {
+			return errSyncDryRun
+		}
+		resp.Repos, err = syncedRepos(ctx, tx, op)
+		return err
+	})
+	if err != nil && err != errSyncDryRun {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func syncRepos(ctx context.Context, tx *sql.Tx, op api.ReposSyncRequest) (diff api.ReposSyncDiff, err error) {
+	stored, err := syncStoredRepos(ctx, tx, op.ServiceType, op.ServiceID)
+	if err != nil {
+		return diff, err
+	}
+	byID := make(map[api.RepoID]*syncRepo, len(stored))
+	byExternalID := make(map[string][]*syncRepo, len(stored))
+	for _, r := range stored {
+		byID[r.id] = r
+		byExternalID[r.externalID] = append(byExternalID[r.externalID], r)
+	}
+
+	// Match the listing to the stored repositories. Earlier versions stored a
+	// new repository when one was renamed on its code host, so an external ID
+	// can have several stored repositories. We keep the one with the listed
+	// name (or else the oldest) and delete the others.
+	type rename struct {
+		repo *syncRepo
+		to   api.RepoName
+	}
+	type update struct {
+		repo *syncRepo
+		to   *api.RepoCreateOrUpdateRequest
+	}
+	var (
+		creates  []*api.RepoCreateOrUpdateRequest
+		renames  []*rename
+		updates  []*update
+		matched  = make(map[api.RepoID]*api.RepoCreateOrUpdateRequest)
+		seenID   = make(map[string]bool)
+		seenName = make(map[api.RepoName]bool)
+	)
+	for i := range op.Repos {
+		l := &op.Repos[i]
+		if seenID[l.ExternalRepo.ID] {
+			continue
+		}
+		seenID[l.ExternalRepo.ID] = true
+		if seenName[l.RepoName] {
+			diff.Conflicts = append(diff.Conflicts, l.RepoName)
+			continue
+		}
+		seenName[l.RepoName] = true
+
+		candidates := byExternalID[l.ExternalRepo.ID]
+		if len(candidates) == 0 {
+			creates = append(creates, l)
+			continue
+		}
+		r := candidates[0]
+		for _, c := range candidates {
+			if c.name == l.RepoName {
+				r = c
+				break
+			}
+		}
+		matched[r.id] = l
+		if r.name != l.RepoName {
+			renames = append(renames, &rename{repo: r, to: l.RepoName})
+		}
+		if r.deleted || r.description != l.Description || r.fork != l.Fork || r.archived != l.Archived || r.stars != l.Stars {
+			updates = append(updates, &update{repo: r, to: l})
+		}
+	}
+
+	deleted := make(map[api.RepoID]*syncRepo)
+	if op.Complete {
+		var ids []*sqlf.Query
+		for _, r := range stored {
+			if _, ok := matched[r.id]; !ok && !r.deleted {
+				deleted[r.id] = r
+				ids = append(ids, sqlf.Sprintf("%d", r.id))
+			}
+		}
+		if len(ids) > 0 {
+			q := sqlf.Sprintf("UPDATE repo SET deleted_at=now() WHERE id IN (%s)", sqlf.Join(ids, ","))
+			if _, err := tx.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...); err != nil {
+				return diff, err
+			}
+		}
+	}
+
+	// Renames happen in two steps, so that repositories can swap names:
+	// first every renamed repository is moved to a temporary name, then to
+	// its new name. A rename to a name held by a soft-deleted repository
+	// frees the name (see freeRepoNames). A rename to a name held by a
+	// repository without an external ID (stored by an earlier version) adopts
+	// that repository, which takes the place of the renamed one, like a
+	// create does. A rename to a name held by any other repository is a
+	// conflict, and the renamed repository keeps its name (which may in turn
+	// conflict with another rename).
+	adopted := make(map[api.RepoID]bool)
+	if len(renames) > 0 {
+		ids := make([]*sqlf.Query, 0, len(renames))
+		targets := make([]*sqlf.Query, 0, len(renames))
+		for _, rn := range renames {
+			ids = append(ids, sqlf.Sprintf("%d", rn.repo.id))
+			targets = append(targets, sqlf.Sprintf("%s", rn.to))
+		}
+		q := sqlf.Sprintf("UPDATE repo SET name='sync-rename/' || id::text WHERE id IN (%s)", sqlf.Join(ids, ","))
+		if _, err := tx.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...); err != nil {
+			return diff, err
+		}
+
+		var (
+			held     = make(map[api.RepoName]bool)
+			adoptees = make(map[api.RepoName]api.RepoID)
+			free     []api.RepoID
+		)
+		q = sqlf.Sprintf("SELECT id, name, external_service_type IS NULL, deleted_at IS NOT NULL FROM repo WHERE name IN (%s)", sqlf.Join(targets, ","))
+		rows, err := tx.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+		if err != nil {
+			return diff, err
+		}
+		for rows.Next() {
+			var (
+				id                  api.RepoID
+				name                api.RepoName
+				noExternal, deleted bool
+			)
+			if err := rows.Scan(&id, &name, &noExternal, &deleted); err != nil {
+				rows.Close()
+				return diff, err
+			}
+			switch {
+			case deleted && matched[id] == nil:
+				free = append(free, id)
+			case noExternal:
+				adoptees[name] = id
+			default:
+				held[name] = true
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return diff, err
+		}
+		if err := freeRepoNames(ctx, tx, free...); err != nil {
+			return diff, err
+		}
+
+		conflicts := make(map[*rename]bool)
+		for changed := true; changed; {
+			changed = false
+			for _, rn := range renames {
+				if !conflicts[rn] && held[rn.to] {
+					conflicts[rn] = true
+					held[rn.repo.name] = true
+					changed = true
+				}
+			}
+		}
+		for _, rn := range renames {
+			if id, ok := adoptees[rn.to]; ok {
+				l := matched[rn.repo.id]
+				spec := (&dbExternalRepoSpec{}).fromAPISpec(l.ExternalRepo)
+				_, err := tx.ExecContext(ctx, "UPDATE repo SET description=$1, fork=$2, archived=$3, stars=$4, external_id=$5, external_service_type=$6, external_service_id=$7, deleted_at=NULL WHERE id=$8",
+					l.Description, l.Fork, l.Archived, l.Stars, spec.id, spec.serviceType, spec.serviceID, id)
+				if err != nil {
+					return diff, err
+				}
+				// The renamed repository is replaced by the adopted one.
+				if _, err := tx.ExecContext(ctx, "UPDATE repo SET deleted_at=now() WHERE id=$1", rn.repo.id); err != nil {
+					return diff, err
+				}
+				if err := freeRepoNames(ctx, tx, rn.repo.id); err != nil {
+					return diff, err
+				}
+				adopted[rn.repo.id] = true
+				diff.Renamed = append(diff.Renamed, api.ReposSyncRename{From: rn.repo.name, To: rn.to})
+				continue
+			}
+
+			to := rn.to
+			if conflicts[rn] {
+				to = rn.repo.name
+				diff.Conflicts = append(diff.Conflicts, rn.to)
+			} else {
+				diff.Renamed = append(diff.Renamed, api.ReposSyncRename{From: rn.repo.name, To: rn.to})
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE repo SET name=$1 WHERE id=$2", to, rn.repo.id); err != nil {
+				return diff, err
+			}
+		}
+	}
+
+	for _, l := range creates {
+		spec := (&dbExternalRepoSpec{}).fromAPISpec(l.ExternalRepo)
+
+		// A stored repository with the listed name is adopted if it has no
+		// external ID (it was stored by an earlier version) or is a deleted
+		// repository of this external service (such as one that was deleted
+		// and created again on the code host). Otherwise the name conflicts.
+		var (
+			id          api.RepoID
+			serviceType *string
+			rowDeleted  bool
+		)
+		err := tx.QueryRowContext(ctx, "SELECT id, external_service_type, deleted_at IS NOT NULL FROM repo WHERE name=$1", l.RepoName).Scan(&id, &serviceType, &rowDeleted)
+		if err == nil && serviceType != nil && byID[id] == nil && rowDeleted {
+			// A soft-deleted repository of another external service doesn't
+			// hold its name.
+			if err := freeRepoNames(ctx, tx, id); err != nil {
+				return diff, err
+			}
+			err = sql.ErrNoRows
+		}
+		switch {
+		case err == sql.ErrNoRows:
+			_, err = tx.ExecContext(ctx, "INSERT INTO repo(name, description, fork, language, enabled, external_id, external_service_type, external_service_id, archived, stars) VALUES($1, $2, $3, '', $4, $5, $6, $7, $8, $9)",
+				l.RepoName, l.Description, l.Fork, l.Enabled, spec.id, spec.serviceType, spec.serviceID, l.Archived, l.Stars)
+			if err != nil {
+				return diff, err
+			}
+			diff.Created = append(diff.Created, l.RepoName)
+
+		case err != nil:
+			return diff, err
+
+		case serviceType != nil && (byID[id] == nil || !(byID[id].deleted || deleted[id] != nil)):
+			diff.Conflicts = append(diff.Conflicts, l.RepoName)
+
+		default:
+			_, err = tx.ExecContext(ctx, "UPDATE repo SET description=$1, fork=$2, archived=$3, stars=$4, external_id=$5, external_service_type=$6, external_service_id=$7, deleted_at=NULL WHERE id=$8",
+				l.Description, l.Fork, l.Archived, l.Stars, spec.id, spec.serviceType, spec.serviceID, id)
+			if err != nil {
+				return diff, err
+			}
+			delete(deleted, id)
+			diff.Updated = append(diff.Updated, l.RepoName)
+		}
+	}
+
+	for _, u := range updates {
+		if adopted[u.repo.id] {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE repo SET description=$1, fork=$2, archived=$3, stars=$4, deleted_at=NULL WHERE id=$5", u.to.Description, u.to.Fork, u.to.Archived, u.to.Stars, u.repo.id)
+		if err != nil {
+			return diff, err
+		}
+		diff.Updated = append(diff.Updated, u.to.RepoName)
+	}
+
+	for _, r := range stored {
+		if _, ok := deleted[r.id]; ok {
+			diff.Deleted = append(diff.Deleted, r.name)
+		}
+	}
+
+	return diff, nil
+}
+
+// freeRepoNames renames the soft-deleted repositories with the given IDs out
+// of the way, so that other repositories can take their names. If one is
+// listed again, it is renamed back to its listed name like any renamed
+// repository.
+func freeRepoNames(ctx context.Context, tx *sql.Tx, ids ...api.RepoID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	qs := make([]*sqlf.Query, 0, len(ids))
+	for _, id := range ids {
+		qs = append(qs, sqlf.Sprintf("%d", id))
+	}
+	q := sqlf.Sprintf("UPDATE repo SET name='sync-deleted/' || id::text WHERE id IN (%s)", sqlf.Join(qs, ","))
+	_, err := tx.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	return err
+}
+
+// syncStoredRepos returns the stored repositories of the external service,
+// including soft-deleted ones, and locks them for the rest of the
+// transaction.
+func syncStoredRepos(ctx context.Context, tx *sql.Tx, serviceType, serviceID string) ([]*syncRepo, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, name, description, fork, archived, stars, external_id, deleted_at IS NOT NULL FROM repo WHERE external_service_type=$1 AND external_service_id=$2 ORDER BY id FOR UPDATE", serviceType, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repos []*syncRepo
+	for rows.Next() {
+		var (
+			r           syncRepo
+			description *string
+			fork        *bool
+		)
+		if err := rows.Scan(&r.id, &r.name, &description, &fork, &r.archived, &r.stars, &r.externalID, &r.deleted); err != nil {
+			return nil, err
+		}
+		if description != nil {
+			r.description = *description
+		}
+		r.fork = fork != nil && *fork // FIXME: bad DB schema: nullable boolean
+		repos = append(repos, &r)
+	}
+	return repos, rows.Err()
+}
+
+// syncedRepos returns the stored repositories of the listing after a sync.
+func syncedRepos(ctx context.Context, tx *sql.Tx, op api.ReposSyncRequest) ([]*api.Repo, error) {
+	listed := make(map[string]bool, len(op.Repos))
+	for _, r := range op.Repos {
+		listed[r.ExternalRepo.ID] = true
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, name, enabled, external_id FROM repo WHERE external_service_type=$1 AND external_service_id=$2 AND deleted_at IS NULL ORDER BY id", op.ServiceType, op.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repos []*api.Repo
+	for rows.Next() {
+		var (
+			repo       api.Repo
+			externalID string
+		)
+		if err := rows.Scan(&repo.ID, &repo.Name, &repo.Enabled, &externalID); err != nil {
+			return nil, err
+		}
+		if !listed[externalID] {
+			continue
+		}
+		repo.ExternalRepo = &api.ExternalRepoSpec{ID: externalID, ServiceType: op.ServiceType, ServiceID: op.ServiceID}
+		repos = append(repos, &repo)
+	}
+	return repos, rows.Err()
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	dbtesting "github.com/sourcegraph/sourcegraph/cmd/frontend/db/testing"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+)
+
+func TestRepos_Sync(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	ctx := dbtesting.TestContext(t)
+
+	listed := func(name api.RepoName, id, description string) api.RepoCreateOrUpdateRequest {
+		return api.RepoCreateOrUpdateRequest{
+			RepoName:     name,
+			Description:  description,
+			Enabled:      true,
+			ExternalRepo: &api.ExternalRepoSpec{ID: id, ServiceType: "github", ServiceID: "https://github.com/"},
+		}
+	}
+	sync := func(complete, dryRun bool, repos ...api.RepoCreateOrUpdateRequest) api.ReposSyncDiff {
+		t.Helper()
+		resp, err := Repos.Sync(ctx, api.ReposSyncRequest{
+			ServiceType: "github",
+			ServiceID:   "https://github.com/",
+			Repos:       repos,
+			Complete:    complete,
+			DryRun:      dryRun,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !dryRun && len(resp.Diff.Conflicts) == 0 && len(resp.Repos) != len(repos) {
+			t.Errorf("got %d synced repos, want %d", len(resp.Repos), len(repos))
+		}
+		return resp.Diff
+	}
+	assertDiff := func(got, want api.ReposSyncDiff) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got diff %+v, want %+v", got, want)
+		}
+	}
+	assertExists := func(name api.RepoName, want bool) {
+		t.Helper()
+		_, err := Repos.GetByName(ctx, name)
+		if exists := !errcode.IsNotFound(err); exists != want {
+			t.Errorf("repo %s: got exists %v, want %v (error: %v)", name, exists, want, err)
+		}
+	}
+
+	// A repository stored before external IDs were recorded is adopted.
+	if err := Repos.Upsert(ctx, api.InsertRepoOp{Name: "github.com/a/legacy", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertDiff(sync(true, false,
+		listed("github.com/a/a", "1", ""),
+		listed("github.com/a/b", "2", ""),
+		listed("github.com/a/legacy", "3", ""),
+	), api.ReposSyncDiff{
+		Created: []api.RepoName{"github.com/a/a", "github.com/a/b"},
+		Updated: []api.RepoName{"github.com/a/legacy"},
+	})
+
+	// Nothing changed.
+	assertDiff(sync(true, false,
+		listed("github.com/a/a", "1", ""),
+		listed("github.com/a/b", "2", ""),
+		listed("github.com/a/legacy", "3", ""),
+	), api.ReposSyncDiff{})
+
+	// a is renamed, b is deleted, legacy is updated and c is created.
+	assertDiff(sync(true, false,
+		listed("github.com/a/a2", "1", ""),
+		listed("github.com/a/legacy", "3", "d"),
+		listed("github.com/a/c", "4", ""),
+	), api.ReposSyncDiff{
+		Created: []api.RepoName{"github.com/a/c"},
+		Renamed: []api.ReposSyncRename{{From: "github.com/a/a", To: "github.com/a/a2"}},
+		Updated: []api.RepoName{"github.com/a/legacy"},
+		Deleted: []api.RepoName{"github.com/a/b"},
+	})
+	assertExists("github.com/a/a", false)
+	assertExists("github.com/a/a2", true)
+	assertExists("github.com/a/b", false)
+	if _, err := Repos.GetByNameIncludingDeleted(ctx, "github.com/a/b"); err != nil {
+		t.Errorf("deleted repo github.com/a/b: %v", err)
+	}
+
+	// An incomplete listing doesn't delete anything.
+	assertDiff(sync(false, false, listed("github.com/a/a2", "1", "")), api.ReposSyncDiff{})
+	assertExists("github.com/a/c", true)
+
+	// A dry run doesn't change anything.
+	assertDiff(sync(true, true, listed("github.com/a/b", "2", "")), api.ReposSyncDiff{
+		Updated: []api.RepoName{"github.com/a/b"},
+		Deleted: []api.RepoName{"github.com/a/legacy", "github.com/a/a2", "github.com/a/c"},
+	})
+	assertExists("github.com/a/b", false)
+	assertExists("github.com/a/a2", true)
+
+	// Repositories can swap names, and a deleted repository is restored.
+	assertDiff(sync(true, false,
+		listed("github.com/a/c", "1", ""),
+		listed("github.com/a/a2", "4", ""),
+		listed("github.com/a/legacy", "3", "d"),
+		listed("github.com/a/b", "2", ""),
+	), api.ReposSyncDiff{
+		Renamed: []api.ReposSyncRename{
+			{From: "github.com/a/a2", To: "github.com/a/c"},
+			{From: "github.com/a/c", To: "github.com/a/a2"},
+		},
+		Updated: []api.RepoName{"github.com/a/b"},
+	})
+	assertExists("github.com/a/b", true)
+
+	// The number of stars is stored.
+	starred := listed("github.com/a/b", "2", "")
+	starred.Stars = 10
+	assertDiff(sync(false, false, starred), api.ReposSyncDiff{
+		Updated: []api.RepoName{"github.com/a/b"},
+	})
+	if repo, err := Repos.GetByName(ctx, "github.com/a/b"); err != nil {
+		t.Fatal(err)
+	} else if repo.Stars != 10 {
+		t.Errorf("got %d stars, want 10", repo.Stars)
+	}
+
+	// A name held by a repository of another external service conflicts.
+	if err := Repos.Upsert(ctx, api.InsertRepoOp{
+		Name:         "github.com/a/other",
+		Enabled:      true,
+		ExternalRepo: &api.ExternalRepoSpec{ID: "1", ServiceType: "gitlab", ServiceID: "https://gitlab.com/"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assertDiff(sync(false, false, listed("github.com/a/other", "5", "")), api.ReposSyncDiff{
+		Conflicts: []api.RepoName{"github.com/a/other"},
+	})
+
+	// A rename to the name of a deleted repository frees the name.
+	assertDiff(sync(true, false,
+		listed("github.com/a/c", "1", ""),
+		listed("github.com/a/a2", "4", ""),
+		starred,
+	), api.ReposSyncDiff{
+		Deleted: []api.RepoName{"github.com/a/legacy"},
+	})
+	renamed := listed("github.com/a/legacy", "2", "")
+	renamed.Stars = 10
+	assertDiff(sync(false, false, renamed), api.ReposSyncDiff{
+		Renamed: []api.ReposSyncRename{{From: "github.com/a/b", To: "github.com/a/legacy"}},
+	})
+	assertExists("github.com/a/b", false)
+	assertExists("github.com/a/legacy", true)
+
+	// A rename to the name of a repository stored before external IDs were
+	// recorded adopts that repository.
+	if err := Repos.Upsert(ctx, api.InsertRepoOp{Name: "github.com/a/legacy2", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := Repos.GetByName(ctx, "github.com/a/legacy2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDiff(sync(false, false, listed("github.com/a/legacy2", "1", "")), api.ReposSyncDiff{
+		Renamed: []api.ReposSyncRename{{From: "github.com/a/c", To: "github.com/a/legacy2"}},
+	})
+	assertExists("github.com/a/c", false)
+	if repo, err := Repos.GetByName(ctx, "github.com/a/legacy2"); err != nil {
+		t.Fatal(err)
+	} else if repo.ID != legacy.ID || repo.ExternalRepo == nil || repo.ExternalRepo.ID != "1" {
+		t.Errorf("got repo %d with external repo %+v, want repo %d with external ID 1", repo.ID, repo.ExternalRepo, legacy.ID)
+	}
+}
@@ -0,0 +1,106 @@
+package graphqlbackend
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+type repositoryComparisonSearchArgs struct {
+	Pattern         string
+	IsRegExp        bool
+	IsCaseSensitive bool
+	IncludePattern  *string
+	First           *int32
+}
+
+// Search searches the comparison's head and the merge base of its base and
+// head, and returns only the matches that were added or removed between them.
+// It lets reviewers audit a branch for e.g. new TODOs before merging it.
+func (r *repositoryComparisonResolver) Search(ctx context.Context, args *repositoryComparisonSearchArgs) (*comparisonSearchResultsResolver, error) {
+	if r.base == nil || r.head == nil {
+		return nil, &badRequestError{errors.New("searching a comparison requires both a base and a head commit")}
+	}
+
+	info := &search.PatternInfo{
+		Pattern:                args.Pattern,
+		IsRegExp:               args.IsRegExp,
+		IsCaseSensitive:        args.IsCaseSensitive,
+		PathPatternsAreRegExps: true,
+		FileMatchLimit:         defaultMaxSearchResults,
+		PatternMatchesContent:  true,
+	}
+	if args.IncludePattern != nil {
+		info.IncludePatterns = []string{*args.IncludePattern}
+	}
+	if args.First != nil {
+		info.FileMatchLimit = *args.First
+	}
+	if err := info.Validate(); err != nil {
+		return nil, &badRequestError{err}
+	}
+
+	grepo := backend.CachedGitRepo(r.repo.repo)
+	head := api.CommitID(r.head.oid)
+	mergeBase, err := git.MergeBase(ctx, grepo, api.CommitID(r.base.oid), head)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like searches of a single repository, give searcher the remaining
+	// deadline to fetch the archives.
+	fetchTimeout := time.Minute
+	if deadline, ok := ctx.Deadline(); ok {
+		fetchTimeout = time.Until(deadline)
+	}
+	matches, limitHit, err := textSearch(ctx, grepo, head, mergeBase, info, fetchTimeout, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fm := range matches {
+		rev := r.headRevspec
+		fm.commitID = head
+		if fm.JRemoved {
+			// Removed matches only exist at the merge base.
+			rev = string(mergeBase)
+			fm.commitID = mergeBase
+		}
+		fm.uri = "git://" + string(r.repo.repo.Name) + "?" + url.QueryEscape(rev) + "#" + fm.JPath
+		fm.repo = r.repo.repo
+		fm.inputRev = &rev
+	}
+	return &comparisonSearchResultsResolver{matches: matches, limitHit: limitHit}, nil
+}
+
+// comparisonSearchResultsResolver is a resolver for the GraphQL type
+// `ComparisonSearchResults`.
+type comparisonSearchResultsResolver struct {
+	matches  []*fileMatchResolver
+	limitHit bool
+}
+
+func (r *comparisonSearchResultsResolver) Matches() []*comparisonFileMatchResolver {
+	resolvers := make([]*comparisonFileMatchResolver, len(r.matches))
+	for i, fm := range r.matches {
+		resolvers[i] = &comparisonFileMatchResolver{fm}
+	}
+	return resolvers
+}
+
+func (r *comparisonSearchResultsResolver) LimitHit() bool { return r.limitHit }
+
+// comparisonFileMatchResolver is a resolver for the GraphQL type
+// `ComparisonFileMatch`.
+type comparisonFileMatchResolver struct {
+	fm *fileMatchResolver
+}
+
+func (r *comparisonFileMatchResolver) FileMatch() *fileMatchResolver { return r.fm }
+func (r *comparisonFileMatchResolver) Removed() bool                 { return r.fm.JRemoved }
@@ -116,6 +116,117 @@ func (r *repositoryMirrorInfoResolver) UpdatedAt(ctx context.Context) (*string,
 	return &s, nil
 }
 
+func (r *repositoryMirrorInfoResolver) DiskUsage(ctx context.Context) (*mirrorRepositoryDiskUsageResolver, error) {
+	info, err := r.gitserverRepoInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size == nil {
+		return nil, nil
+	}
+	return &mirrorRepositoryDiskUsageResolver{size: info.Size}, nil
+}
+
+func (r *repositoryMirrorInfoResolver) QuotaExceeded(ctx context.Context) (*string, error) {
+	info, err := r.gitserverRepoInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if info.QuotaExceeded == "" {
+		return nil, nil
+	}
+	return &info.QuotaExceeded, nil
+}
+
+func (r *repositoryMirrorInfoResolver) FetchHistory(ctx context.Context) ([]*mirrorRepositoryFetchResolver, error) {
+	// 🚨 SECURITY: gitserver redacts the credentials and the remote URL from
+	// the output of the fetches.
+	fetches, err := gitserver.DefaultClient.FetchHistory(ctx, r.repository.repo.Name)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*mirrorRepositoryFetchResolver, len(fetches))
+	for i, fetch := range fetches {
+		resolvers[i] = &mirrorRepositoryFetchResolver{fetch: fetch}
+	}
+	return resolvers, nil
+}
+
+type mirrorRepositoryFetchResolver struct {
+	fetch *protocol.RepoFetch
+}
+
+func (r *mirrorRepositoryFetchResolver) StartedAt() string {
+	return r.fetch.Start.Format(time.RFC3339)
+}
+
+func (r *mirrorRepositoryFetchResolver) DurationMs() int32 {
+	return int32(r.fetch.Duration / time.Millisecond)
+}
+
+func (r *mirrorRepositoryFetchResolver) ExitCode() int32 {
+	return int32(r.fetch.ExitCode)
+}
+
+func (r *mirrorRepositoryFetchResolver) Error() *string {
+	if r.fetch.Error == "" {
+		return nil
+	}
+	return &r.fetch.Error
+}
+
+func (r *mirrorRepositoryFetchResolver) Output() string {
+	return r.fetch.Output
+}
+
+func (r *mirrorRepositoryFetchResolver) RefsChanged() int32 {
+	return int32(r.fetch.RefsChanged)
+}
+
+func (r *mirrorRepositoryFetchResolver) HeadMoved() bool {
+	return r.fetch.HeadMoved
+}
+
+type mirrorRepositoryDiskUsageResolver struct {
+	size *protocol.RepoSize
+}
+
+func (r *mirrorRepositoryDiskUsageResolver) TotalKiB() int32 {
+	return int32(r.size.TotalBytes >> 10)
+}
+
+func (r *mirrorRepositoryDiskUsageResolver) LooseObjects() int32 {
+	return int32(r.size.LooseObjects)
+}
+
+func (r *mirrorRepositoryDiskUsageResolver) LooseObjectsKiB() int32 {
+	return int32(r.size.LooseBytes >> 10)
+}
+
+func (r *mirrorRepositoryDiskUsageResolver) PackedObjects() int32 {
+	return int32(r.size.PackedObjects)
+}
+
+func (r *mirrorRepositoryDiskUsageResolver) Packs() int32 {
+	return int32(r.size.Packs)
+}
+
+func (r *mirrorRepositoryDiskUsageResolver) PacksKiB() int32 {
+	return int32(r.size.PackedBytes >> 10)
+}
+
+func (r *mirrorRepositoryDiskUsageResolver) GarbageKiB() int32 {
+	return int32(r.size.GarbageBytes >> 10)
+}
+
+func (r *mirrorRepositoryDiskUsageResolver) LastGCAt() *string {
+	if r.size.LastGC == nil {
+		return nil
+	}
+	s := r.size.LastGC.Format(time.RFC3339)
+	return &s
+}
+
 func (r *repositoryMirrorInfoResolver) UpdateSchedule(ctx context.Context) (*updateScheduleResolver, error) {
 	info, err := r.repoUpdateSchedulerInfo(ctx)
 	if err != nil {
@@ -791,8 +791,9 @@ type Search {
     stats: SearchResultsStats!
 }
 
-# A search result.
-union SearchResult = FileMatch | CommitSearchResult | Repository
+# A search result. Commit authors (Person) are only returned when the query projects commit results
+# with select:commit.author.
+union SearchResult = FileMatch | CommitSearchResult | Repository | Person
 
 # Search results.
 type SearchResults {
@@ -1156,6 +1157,51 @@ type MirrorRepositoryInfo {
     updateSchedule: UpdateSchedule
     # The state of this repository in the update queue.
     updateQueue: UpdateQueue
+    # The disk usage of the repository's clone, or null if it is not cloned.
+    diskUsage: MirrorRepositoryDiskUsage
+    # If the repository is not cloned or updated because it is larger than the site's limit
+    # (gitMaxRepoSizeMB in site configuration), an explanation of that. Otherwise null.
+    quotaExceeded: String
+    # The most recent fetches of the repository from the remote source repository, newest first.
+    fetchHistory: [MirrorRepositoryFetch!]!
+}
+
+# A fetch of a mirrored repository from its remote source repository.
+type MirrorRepositoryFetch {
+    # When the fetch started.
+    startedAt: String!
+    # How long the fetch took, in milliseconds.
+    durationMs: Int!
+    # The exit code of git fetch (0 if it succeeded, -1 if it was killed, e.g. because it timed out).
+    exitCode: Int!
+    # The error of the fetch, or null if it succeeded.
+    error: String
+    # The end of the output of git fetch, with credentials and the remote URL redacted.
+    output: String!
+    # The number of refs that the fetch created, updated or deleted.
+    refsChanged: Int!
+    # Whether the update changed the commit of the default branch (HEAD).
+    headMoved: Boolean!
+}
+
+# The disk usage of the clone of a mirrored repository, as reported by git count-objects.
+type MirrorRepositoryDiskUsage {
+    # The disk usage of the whole clone, in KiB.
+    totalKiB: Int!
+    # The number of loose (unpacked) objects.
+    looseObjects: Int!
+    # The disk usage of the loose objects, in KiB.
+    looseObjectsKiB: Int!
+    # The number of packed objects.
+    packedObjects: Int!
+    # The number of packs.
+    packs: Int!
+    # The disk usage of the packs, in KiB.
+    packsKiB: Int!
+    # The disk usage of files in the object directory that are neither objects nor packs, in KiB.
+    garbageKiB: Int!
+    # When the objects were last repacked (e.g. by git gc), if known.
+    lastGCAt: String
 }
 
 # The state of a repository in the update schedule.
@@ -1263,6 +1309,37 @@ type RepositoryComparison {
         # Return the first n file diffs from the list.
         first: Int
     ): FileDiffConnection!
+    # Searches the head and the merge base of the base and head, and returns only the matches that were
+    # added or removed between them. Files are compared by path, and lines by their content.
+    search(
+        # The search pattern.
+        pattern: String!
+        # Whether the pattern is a regular expression.
+        isRegExp: Boolean = false
+        # Whether the pattern is case sensitive.
+        isCaseSensitive: Boolean = false
+        # Only search files whose path matches this regular expression.
+        includePattern: String
+        # Return at most this many file matches.
+        first: Int
+    ): ComparisonSearchResults!
+}
+
+# The results of searching a repository comparison.
+type ComparisonSearchResults {
+    # The added and removed matches.
+    matches: [ComparisonFileMatch!]!
+    # Whether the search stopped before finding all matches.
+    limitHit: Boolean!
+}
+
+# Matches in a file that were added or removed in a repository comparison.
+type ComparisonFileMatch {
+    # The matches. For added matches, the file is at the head of the comparison. For removed matches, it is at
+    # the merge base of the base and head.
+    fileMatch: FileMatch!
+    # Whether the matches were removed (true) or added (false).
+    removed: Boolean!
 }
 
 # A list of file diffs.
@@ -1920,6 +1997,16 @@ type FileMatch {
     lineMatches: [LineMatch!]!
     # Whether or not the limit was hit.
     limitHit: Boolean!
+    # The revisions of the repository in which the file has the matched contents. When the search specified
+    # multiple revisions (e.g., with a ref glob such as repo:foo@*refs/heads/), each distinct version of a file is
+    # searched once, and this lists every searched revision (e.g., "refs/heads/release-1.0") in which the file is
+    # the same. Otherwise, it is the searched revision, or empty for the default branch.
+    revisions: [String!]!
+    # The relevance score of the file match, which determines the order of the search results (higher scores
+    # first). It combines the search index's score for the match, the number of matching lines and symbols,
+    # penalties for vendored, test and generated files and for forks, and the repository's popularity and
+    # boost (see the "search.ranking" setting). Null if search result ranking is disabled.
+    score: Float
 }
 
 # A line match.
@@ -206,6 +206,22 @@ func (r *searchResolver) resolveRepositories(ctx context.Context, effectiveRepoF
 		}
 	}
 
+	tr.LazyPrintf("resolveRepositories - start")
+	repoRevs, missingRepoRevs, repoResults, overLimit, err = resolveRepositories(ctx, r.resolveRepoOp(effectiveRepoFieldValues))
+	tr.LazyPrintf("resolveRepositories - done")
+	if effectiveRepoFieldValues == nil {
+		r.repoRevs = repoRevs
+		r.missingRepoRevs = missingRepoRevs
+		r.repoResults = repoResults
+		r.repoOverLimit = overLimit
+		r.repoErr = err
+	}
+	return repoRevs, missingRepoRevs, repoResults, overLimit, err
+}
+
+// resolveRepoOp returns the options for resolving the repositories which the
+// query matches (see resolveRepositories).
+func (r *searchResolver) resolveRepoOp(effectiveRepoFieldValues []string) resolveRepoOp {
 	repoFilters, minusRepoFilters := r.query.RegexpPatterns(query.FieldRepo)
 	if effectiveRepoFieldValues != nil {
 		repoFilters = effectiveRepoFieldValues
@@ -218,8 +234,7 @@ func (r *searchResolver) resolveRepositories(ctx context.Context, effectiveRepoF
 	archivedStr, _ := r.query.StringValue(query.FieldArchived)
 	archived := parseYesNoOnly(archivedStr)
 
-	tr.LazyPrintf("resolveRepositories - start")
-	repoRevs, missingRepoRevs, repoResults, overLimit, err = resolveRepositories(ctx, resolveRepoOp{
+	return resolveRepoOp{
 		repoFilters:      repoFilters,
 		minusRepoFilters: minusRepoFilters,
 		repoGroupFilters: repoGroupFilters,
@@ -227,16 +242,7 @@ func (r *searchResolver) resolveRepositories(ctx context.Context, effectiveRepoF
 		noForks:          fork == No || fork == False,
 		onlyArchived:     archived == Only || archived == True,
 		noArchived:       archived == No || archived == False,
-	})
-	tr.LazyPrintf("resolveRepositories - done")
-	if effectiveRepoFieldValues == nil {
-		r.repoRevs = repoRevs
-		r.missingRepoRevs = missingRepoRevs
-		r.repoResults = repoResults
-		r.repoOverLimit = overLimit
-		r.repoErr = err
 	}
-	return repoRevs, missingRepoRevs, repoResults, overLimit, err
 }
 
 // a patternRevspec maps an include pattern to a list of revisions
@@ -340,6 +346,11 @@ type resolveRepoOp struct {
 	onlyForks        bool
 	noArchived       bool
 	onlyArchived     bool
+
+	// limitOffset, if set, is the page of the matching repositories to
+	// resolve, instead of the first maxReposToSearch(). overLimit then
+	// reports whether there may be more pages.
+	limitOffset *db.LimitOffset
 }
 
 func resolveRepositories(ctx context.Context, op resolveRepoOp) (repoRevisions, missingRepoRevisions []*search.RepositoryRevisions, repoResolvers []*searchSuggestionResolver, overLimit bool, err error) {
@@ -387,8 +398,7 @@ func resolveRepositories(ctx context.Context, op resolveRepoOp) (repoRevisions,
 		return nil, nil, nil, false, err
 	}
 
-	tr.LazyPrintf("Repos.List - start")
-	repos, err := backend.Repos.List(ctx, db.ReposListOptions{
+	listOpt := db.ReposListOptions{
 		IncludePatterns: includePatterns,
 		ExcludePattern:  unionRegExps(excludePatterns),
 		Enabled:         true,
@@ -398,12 +408,29 @@ func resolveRepositories(ctx context.Context, op resolveRepoOp) (repoRevisions,
 		OnlyForks:    op.onlyForks,
 		NoArchived:   op.noArchived,
 		OnlyArchived: op.onlyArchived,
-	})
+	}
+	if op.limitOffset != nil {
+		listOpt.LimitOffset = op.limitOffset
+	}
+
+	tr.LazyPrintf("Repos.List - start")
+	repos, err := backend.Repos.List(ctx, listOpt)
 	tr.LazyPrintf("Repos.List - done")
 	if err != nil {
 		return nil, nil, nil, false, err
 	}
-	overLimit = len(repos) >= maxRepoListSize
+	if op.limitOffset != nil {
+		// Repos.List omits the repos on the page which the user may not
+		// read, so count all of the repos to see if there are more pages.
+		listOpt.LimitOffset = nil
+		count, err := db.Repos.Count(ctx, listOpt)
+		if err != nil {
+			return nil, nil, nil, false, err
+		}
+		overLimit = op.limitOffset.Offset+op.limitOffset.Limit < count
+	} else {
+		overLimit = len(repos) >= maxRepoListSize
+	}
 
 	repoRevisions = make([]*search.RepositoryRevisions, 0, len(repos))
 	repoResolvers = make([]*searchSuggestionResolver, 0, len(repos))
@@ -500,6 +527,9 @@ func (r *searchResolver) suggestFilePaths(ctx context.Context, limit int) ([]*se
 	// Treat all default terms as though they had `file:` before them (to make it easy for users to
 	// jump to files by just typing their name).
 	for _, v := range r.query.Values(query.FieldDefault) {
+		if v.Not() {
+			continue
+		}
 		includePatterns = append(includePatterns, asString(v))
 	}
 
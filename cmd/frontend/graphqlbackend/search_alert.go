@@ -293,7 +293,7 @@ outer:
 		newExpr := addQueryRegexpField(r.query, query.FieldRepo, repoParentPattern)
 		alert.proposedQueries = append(alert.proposedQueries, &searchQueryDescription{
 			description: "in repositories under " + repoParent + more,
-			query:       syntax.ExprString(newExpr),
+			query:       queryString(r.query, newExpr),
 		})
 	}
 	if len(alert.proposedQueries) == 0 || ctx.Err() == context.DeadlineExceeded {
@@ -311,7 +311,7 @@ outer:
 			newExpr := addQueryRegexpField(r.query, query.FieldRepo, "^"+regexp.QuoteMeta(pathToPropose)+"$")
 			alert.proposedQueries = append(alert.proposedQueries, &searchQueryDescription{
 				description: "in the repository " + strings.TrimPrefix(pathToPropose, "github.com/"),
-				query:       syntax.ExprString(newExpr),
+				query:       queryString(r.query, newExpr),
 			})
 		}
 	}
@@ -341,7 +341,28 @@ func (r *searchResolver) alertForMissingRepoRevs(missingRepoRevs []*search.Repos
 }
 
 func omitQueryFields(r *searchResolver, field string) string {
-	return syntax.ExprString(omitQueryExprWithField(r.query, field))
+	return queryString(r.query, omitQueryExprWithField(r.query, field))
+}
+
+// queryString returns the query string of expr, which are the expressions of
+// q with some fields added, removed or changed. The patterns of a boolean
+// query are kept in a group, so that its AND, OR and NOT operators are
+// preserved.
+func queryString(q *query.Query, expr []*syntax.Expr) string {
+	if !q.IsBoolean() {
+		return syntax.ExprString(expr)
+	}
+	var fieldExpr []*syntax.Expr
+	for _, e := range expr {
+		if e.Field != query.FieldDefault {
+			fieldExpr = append(fieldExpr, e)
+		}
+	}
+	pattern := q.Syntax.Tree.Without(func(e *syntax.Expr) bool { return e.Field != query.FieldDefault })
+	if len(fieldExpr) == 0 {
+		return pattern.String()
+	}
+	return syntax.ExprString(fieldExpr) + " (" + pattern.String() + ")"
 }
 
 func omitQueryExprWithField(query *query.Query, field string) []*syntax.Expr {
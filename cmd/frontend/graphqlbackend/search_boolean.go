@@ -0,0 +1,416 @@
+package graphqlbackend
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/goroutine"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/syntax"
+	searchquerytypes "github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+// booleanResultTypes are the result types which boolean queries support.
+var booleanResultTypes = map[string]struct{}{
+	"file":   {},
+	"path":   {},
+	"diff":   {},
+	"commit": {},
+}
+
+// booleanSearch evaluates a boolean query (see (*query.Query).IsBoolean).
+//
+// Each leaf of the boolean expression of the patterns is searched on its own,
+// for each result type: file contents and paths with searcher and Zoekt,
+// commits and diffs with git log. The results are then combined per file (or
+// per commit): an AND matches the files which all of its operands match, an
+// OR the files which any of its operands match, and a negated operand removes
+// the files which it matches from the results of an AND.
+//
+// The leaves whose results are intersected or removed must be searched in
+// full, because a file missing from their results (e.g., due to the result
+// limit) would make the combined results wrong. They are searched without a
+// result limit, and the results in the repositories where they are incomplete
+// (e.g., due to a timeout) are dropped.
+type booleanSearch struct {
+	args        *search.Args
+	resultTypes []string
+
+	mu              sync.Mutex
+	leaves          map[*searchquerytypes.PatternNode]booleanResults
+	complete        map[*searchquerytypes.PatternNode]bool // leaves which must be searched in full
+	incompleteRepos map[api.RepoName]struct{}              // repos where a complete leaf's results are incomplete
+	common          searchResultsCommon
+	multiErr        *multierror.Error
+}
+
+// booleanResults are the results of an operand of a boolean query, by file
+// (or commit).
+type booleanResults map[string]*searchResultResolver
+
+func newBooleanSearch(args *search.Args, resultTypes []string) *booleanSearch {
+	return &booleanSearch{
+		args:            args,
+		resultTypes:     resultTypes,
+		leaves:          make(map[*searchquerytypes.PatternNode]booleanResults),
+		complete:        make(map[*searchquerytypes.PatternNode]bool),
+		incompleteRepos: make(map[api.RepoName]struct{}),
+	}
+}
+
+func (s *booleanSearch) run(ctx context.Context) ([]*searchResultResolver, *searchResultsCommon, error) {
+	pattern := s.args.Query.Pattern
+	if !isPositive(pattern, false) {
+		return nil, nil, &badRequestError{errors.New("the query only excludes results (e.g., NOT a); combine it with a pattern which is not negated (e.g., b AND NOT a)")}
+	}
+
+	// type:file and type:path use the same searchFilesInRepos, so don't
+	// call it 2x.
+	var resultTypes []string
+	searchedFileContentsOrPaths := false
+	for _, resultType := range s.resultTypes {
+		if resultType == "file" || resultType == "path" {
+			if searchedFileContentsOrPaths {
+				continue
+			}
+			searchedFileContentsOrPaths = true
+		}
+		resultTypes = append(resultTypes, resultType)
+	}
+
+	markCompleteLeaves(pattern, false, false, s.complete)
+
+	// Add all of the leaves before searching any of them, since searchLeaf
+	// reads s.leaves concurrently.
+	leaves := patternLeaves(pattern)
+	for _, leaf := range leaves {
+		s.leaves[leaf] = booleanResults{}
+	}
+
+	var wg sync.WaitGroup
+	for _, leaf := range leaves {
+		for _, resultType := range resultTypes {
+			leaf, resultType := leaf, resultType // shadow so they don't change in the goroutine
+			wg.Add(1)
+			goroutine.Go(func() {
+				defer wg.Done()
+				s.searchLeaf(ctx, leaf, resultType)
+			})
+		}
+	}
+	wg.Wait()
+
+	var results []*searchResultResolver
+	for _, result := range s.eval(pattern, false) {
+		if _, ok := s.incompleteRepos[booleanResultRepo(result)]; ok {
+			// The result may be wrong, because a leaf's results are
+			// incomplete in its repository.
+			s.common.limitHit = true
+			continue
+		}
+		results = append(results, result)
+	}
+	sortBooleanResults(results)
+	if limit := int(searchFileMatchLimit(s.args)); len(results) > limit {
+		results = results[:limit]
+		s.common.limitHit = true
+	}
+
+	// The result counts of the leaves overlap, so count the combined results.
+	s.common.resultCount = 0
+	for _, result := range results {
+		s.common.resultCount += result.resultCount()
+	}
+	return results, &s.common, s.multiErr.ErrorOrNil()
+}
+
+// searchLeaf searches for the patterns of leaf, and records the results.
+func (s *booleanSearch) searchLeaf(ctx context.Context, leaf *searchquerytypes.PatternNode, resultType string) {
+	var patterns []string
+	for _, v := range leaf.Values {
+		if pattern := defaultFieldPattern(v, query.PatternTypeRegexp); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	patternInfo := *s.args.Pattern
+	patternInfo.Pattern = regexpPatternMatchingExprsInOrder(patterns)
+	complete := s.complete[leaf]
+	if complete {
+		patternInfo.FileMatchLimit = math.MaxInt32
+	}
+	args := *s.args
+	args.Pattern = &patternInfo
+
+	var (
+		results []*searchResultResolver
+		common  *searchResultsCommon
+		err     error
+	)
+	switch resultType {
+	case "file", "path":
+		// The results of the leaves are combined, so they can't be sent
+		// before all of them are known.
+		var fileResults []*fileMatchResolver
+		fileResults, common, err = searchFilesInRepos(withFileMatchSender(ctx, nil), &args)
+		for _, fm := range fileResults {
+			results = append(results, &searchResultResolver{fileMatch: fm})
+		}
+	case "diff":
+		results, common, err = searchCommitDiffsInRepos(ctx, &args)
+	case "commit":
+		results, common, err = searchCommitLogInRepos(ctx, &args)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Timeouts are reported through searchResultsCommon so don't report an error for them
+	if err != nil && !isContextError(ctx, err) {
+		s.multiErr = multierror.Append(s.multiErr, errors.Wrapf(err, "%s search for %q failed", resultType, leaf))
+	}
+	if common != nil {
+		s.common.update(*common)
+		if complete {
+			for _, repo := range common.timedout {
+				s.incompleteRepos[repo.Name] = struct{}{}
+			}
+			for repo := range common.partial {
+				s.incompleteRepos[repo] = struct{}{}
+			}
+		}
+	}
+	s.leaves[leaf].union(results...)
+}
+
+// markCompleteLeaves marks the leaves of n (or of its negation if neg is
+// true) which must be searched in full: those whose results are intersected
+// with or removed from the results of other operands. If complete is true,
+// all of the leaves of n must be searched in full.
+func markCompleteLeaves(n *searchquerytypes.PatternNode, neg, complete bool, leaves map[*searchquerytypes.PatternNode]bool) {
+	if n.IsLeaf() {
+		if complete {
+			leaves[n] = true
+		}
+		return
+	}
+
+	if (n.Op == syntax.OpOr) != neg {
+		for _, o := range n.Operands {
+			markCompleteLeaves(o, neg, complete, leaves)
+		}
+		return
+	}
+
+	positive := 0
+	for _, o := range n.Operands {
+		if isPositive(o, neg) {
+			positive++
+		}
+	}
+	for _, o := range n.Operands {
+		if isPositive(o, neg) {
+			// The results of the positive operands are intersected.
+			markCompleteLeaves(o, neg, complete || positive > 1, leaves)
+		} else {
+			// The results of the negated operands are removed.
+			markCompleteLeaves(o, !neg, true, leaves)
+		}
+	}
+}
+
+// eval returns the results of n, or of its negation if neg is true. The
+// result must be positive (see isPositive).
+func (s *booleanSearch) eval(n *searchquerytypes.PatternNode, neg bool) booleanResults {
+	if n.IsLeaf() {
+		return s.leaves[n]
+	}
+
+	if (n.Op == syntax.OpOr) != neg {
+		res := booleanResults{}
+		for _, o := range n.Operands {
+			for _, result := range s.eval(o, neg) {
+				res.union(result)
+			}
+		}
+		return res
+	}
+
+	var res booleanResults
+	for _, o := range n.Operands {
+		if !isPositive(o, neg) {
+			continue
+		}
+		if operandRes := s.eval(o, neg); res == nil {
+			res = booleanResults{}
+			for _, result := range operandRes {
+				res.union(result)
+			}
+		} else {
+			res = res.intersect(operandRes)
+		}
+	}
+	// Remove the results of the negated operands, which match the files
+	// that the positive negation of the operand does not match.
+	for _, o := range n.Operands {
+		if !isPositive(o, neg) {
+			for key := range s.eval(o, !neg) {
+				delete(res, key)
+			}
+		}
+	}
+	return res
+}
+
+// isPositive reports whether n (or its negation if neg is true) matches a set
+// of files which can be searched for, as opposed to excluding files from the
+// results of other patterns. A negated leaf is not positive, an AND is
+// positive if any of its operands are, and an OR if all of its operands are.
+func isPositive(n *searchquerytypes.PatternNode, neg bool) bool {
+	if n.IsLeaf() {
+		return n.Not == neg
+	}
+	or := (n.Op == syntax.OpOr) != neg
+	for _, o := range n.Operands {
+		if positive := isPositive(o, neg); positive != or {
+			return positive
+		}
+	}
+	return or
+}
+
+// patternLeaves returns the leaves of n.
+func patternLeaves(n *searchquerytypes.PatternNode) []*searchquerytypes.PatternNode {
+	if n.IsLeaf() {
+		return []*searchquerytypes.PatternNode{n}
+	}
+	var leaves []*searchquerytypes.PatternNode
+	for _, o := range n.Operands {
+		leaves = append(leaves, patternLeaves(o)...)
+	}
+	return leaves
+}
+
+// booleanResultKey returns the file (or commit) of a result.
+func booleanResultKey(result *searchResultResolver) string {
+	switch {
+	case result.fileMatch != nil:
+		return "file:" + result.fileMatch.uri
+	case result.diff != nil:
+		return "commit:" + string(result.diff.commit.repo.repo.Name) + "@" + string(result.diff.commit.oid)
+	default:
+		return ""
+	}
+}
+
+// booleanResultRepo returns the repository of a result.
+func booleanResultRepo(result *searchResultResolver) api.RepoName {
+	switch {
+	case result.fileMatch != nil:
+		return result.fileMatch.repo.Name
+	case result.diff != nil:
+		return result.diff.commit.repo.repo.Name
+	default:
+		return ""
+	}
+}
+
+// union adds results to r, merging the results for the same file (or commit).
+func (r booleanResults) union(results ...*searchResultResolver) {
+	for _, result := range results {
+		key := booleanResultKey(result)
+		if existing, ok := r[key]; ok {
+			result = mergeSearchResults(existing, result)
+		}
+		r[key] = result
+	}
+}
+
+// intersect returns the results for the files (or commits) which are in r
+// and other, merged.
+func (r booleanResults) intersect(other booleanResults) booleanResults {
+	res := booleanResults{}
+	for key, result := range r {
+		if otherResult, ok := other[key]; ok {
+			res[key] = mergeSearchResults(result, otherResult)
+		}
+	}
+	return res
+}
+
+// mergeSearchResults returns the combination of a and b, which are results
+// for the same file (or commit). It does not modify a or b.
+func mergeSearchResults(a, b *searchResultResolver) *searchResultResolver {
+	switch {
+	case a.fileMatch != nil && b.fileMatch != nil:
+		fm := *a.fileMatch
+		fm.JLineMatches = mergeLineMatches(a.fileMatch.JLineMatches, b.fileMatch.JLineMatches)
+		fm.JLimitHit = a.fileMatch.JLimitHit || b.fileMatch.JLimitHit
+		fm.zoektScore = math.Max(a.fileMatch.zoektScore, b.fileMatch.zoektScore)
+		return &searchResultResolver{fileMatch: &fm}
+	case a.diff != nil && b.diff != nil:
+		diff := *a.diff
+		if diff.messagePreview == nil {
+			diff.messagePreview = b.diff.messagePreview
+		}
+		if diff.diffPreview == nil {
+			diff.diffPreview = b.diff.diffPreview
+		}
+		return &searchResultResolver{diff: &diff}
+	default:
+		return a
+	}
+}
+
+// mergeLineMatches returns the line matches of a and b, ordered by line. The
+// matches on the same line are combined.
+func mergeLineMatches(a, b []*lineMatch) []*lineMatch {
+	byLine := make(map[int32]*lineMatch, len(a)+len(b))
+	var lines []*lineMatch
+	for _, lm := range append(append([]*lineMatch{}, a...), b...) {
+		existing, ok := byLine[lm.JLineNumber]
+		if !ok {
+			lm2 := *lm
+			byLine[lm.JLineNumber] = &lm2
+			lines = append(lines, &lm2)
+			continue
+		}
+		existing.JLimitHit = existing.JLimitHit || lm.JLimitHit
+		for _, offsetAndLength := range lm.JOffsetAndLengths {
+			seen := false
+			for _, o := range existing.JOffsetAndLengths {
+				if o == offsetAndLength {
+					seen = true
+					break
+				}
+			}
+			if !seen {
+				existing.JOffsetAndLengths = append(existing.JOffsetAndLengths, offsetAndLength)
+			}
+		}
+		sort.Slice(existing.JOffsetAndLengths, func(i, j int) bool {
+			return existing.JOffsetAndLengths[i][0] < existing.JOffsetAndLengths[j][0]
+		})
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].JLineNumber < lines[j].JLineNumber })
+	return lines
+}
+
+// sortBooleanResults sorts file matches like sortResults, followed by the
+// commits, most recent first.
+func sortBooleanResults(results []*searchResultResolver) {
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.diff != nil && b.diff != nil {
+			if a.diff.commit.author.Date() != b.diff.commit.author.Date() {
+				return a.diff.commit.author.Date() > b.diff.commit.author.Date()
+			}
+			return booleanResultKey(a) < booleanResultKey(b)
+		}
+		return compareSearchResults(a, b)
+	})
+}
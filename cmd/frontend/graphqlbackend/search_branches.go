@@ -0,0 +1,107 @@
+package graphqlbackend
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/goroutine"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// doBranchResults searches a query whose repo: and file: filters are combined
+// with OR (see (*query.Query).FilterBranches). Each alternative of the filters
+// is searched separately, and the results are merged.
+func (r *searchResolver) doBranchResults(ctx context.Context, forceOnlyResultType string, branches []*query.Query) (*searchResultsResolver, error) {
+	// The same file may be matched by several alternatives, so the results
+	// are not streamed (see withFileMatchSender).
+	ctx = withFileMatchSender(ctx, nil)
+
+	var (
+		wg        sync.WaitGroup
+		resolvers = make([]*searchResultsResolver, len(branches))
+		errs      = make([]error, len(branches))
+	)
+	for i, branch := range branches {
+		i, branch := i, branch // shadow so they don't change in the goroutine
+		wg.Add(1)
+		goroutine.Go(func() {
+			defer wg.Done()
+			resolvers[i], errs[i] = (&searchResolver{root: r.root, query: branch}).doResults(ctx, forceOnlyResultType)
+		})
+	}
+	wg.Wait()
+
+	merged := &searchResultsResolver{searchResultsCommon: searchResultsCommon{maxResultsCount: r.maxResults()}}
+	var (
+		byKey    = map[string]*searchResultResolver{}
+		multiErr *multierror.Error
+	)
+	for i, resolver := range resolvers {
+		if errs[i] != nil {
+			multiErr = multierror.Append(multiErr, errs[i])
+		}
+		if resolver == nil {
+			continue
+		}
+		if merged.start.IsZero() || resolver.start.Before(merged.start) {
+			merged.start = resolver.start
+		}
+		if merged.alert == nil {
+			merged.alert = resolver.alert
+		}
+		merged.searchResultsCommon.update(resolver.searchResultsCommon)
+		for _, result := range resolver.results {
+			// The same file (or repository or commit) may be matched by
+			// several alternatives of the filters.
+			key := branchResultKey(result)
+			if existing, ok := byKey[key]; ok {
+				*existing = *mergeSearchResults(existing, result)
+				continue
+			}
+			byKey[key] = result
+			merged.results = append(merged.results, result)
+		}
+	}
+	if len(merged.results) > 0 {
+		// The alert (e.g., that an alternative matches no repositories) is
+		// only shown if there are no results.
+		merged.alert = nil
+		if multiErr != nil {
+			// Only log the error, so that the client receives the partial results.
+			log15.Error("Errors during search", "error", multiErr)
+			multiErr = nil
+		}
+	} else if multiErr != nil {
+		return nil, multiErr
+	}
+
+	ranking, err := getSearchRanking(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rankResults(merged.results, ranking)
+	if limit := int(r.maxResults()); len(merged.results) > limit {
+		merged.results = merged.results[:limit]
+		merged.limitHit = true
+	}
+	merged.resultCount = 0
+	for _, result := range merged.results {
+		merged.resultCount += result.resultCount()
+	}
+	return merged, nil
+}
+
+// branchResultKey returns the file, repository, commit or person of a result.
+func branchResultKey(result *searchResultResolver) string {
+	switch {
+	case result.repo != nil:
+		return "repo:" + string(result.repo.repo.Name)
+	case result.person != nil:
+		return "person:" + strings.ToLower(result.person.email) + "\x00" + result.person.name
+	default:
+		return booleanResultKey(result)
+	}
+}
@@ -0,0 +1,202 @@
+package graphqlbackend
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+const (
+	// searchExportPageSize is the number of repositories which ExportSearch
+	// searches at a time.
+	searchExportPageSize = 100
+
+	// searchExportPageTimeout is the timeout for searching a page of
+	// repositories.
+	searchExportPageTimeout = 5 * time.Minute
+)
+
+// SearchExportMatch is a line which matches a search query.
+type SearchExportMatch struct {
+	Repo   api.RepoName `json:"repository"`
+	Commit api.CommitID `json:"commit"`
+	Path   string       `json:"path"`
+	// LineNumber is the 1-based number of the line in the file.
+	LineNumber int32  `json:"lineNumber"`
+	Preview    string `json:"preview"`
+}
+
+// SearchExportStats describes the repositories which ExportSearch searched.
+type SearchExportStats struct {
+	// Repositories is the number of repositories searched.
+	Repositories int
+	// Incomplete are the repositories whose matches may not all have been
+	// exported, because they could not be searched (e.g., they are still
+	// being cloned or the search timed out) or their matches exceeded a limit
+	// of the search backends.
+	Incomplete []api.RepoName
+}
+
+// ExportSearch searches for the lines which match rawQuery in all of the
+// repositories which the query matches, and calls fn with the matches in each
+// page of repositories.
+//
+// Unlike the search GraphQL API, the results and the number of repositories
+// are not limited (by count: or the maxReposToSearch site configuration): the
+// repositories are searched searchExportPageSize at a time, until all of them
+// have been searched, ctx is canceled, or fn returns an error. Only file
+// contents are searched.
+//
+// 🚨 SECURITY: The repositories are listed with Repos.List, which only returns
+// the repositories that the current user is allowed to read.
+func ExportSearch(ctx context.Context, rawQuery string, fn func([]*SearchExportMatch) error) (*SearchExportStats, error) {
+	r, err := (&schemaResolver{}).Search(&struct{ Query string }{Query: rawQuery})
+	if err != nil {
+		return nil, &badRequestError{err}
+	}
+	if resultTypes, _ := r.query.StringValues(query.FieldType); len(resultTypes) > 1 || (len(resultTypes) == 1 && resultTypes[0] != "file") {
+		return nil, &badRequestError{errors.New("only file content searches (type:file) can be exported")}
+	}
+	if selects, _ := r.query.StringValues(query.FieldSelect); len(selects) > 0 {
+		return nil, &badRequestError{errors.New("searches with select: can't be exported")}
+	}
+	if len(r.query.FilterBranches()) > 0 {
+		return nil, &badRequestError{errors.New("searches which combine repo: or file: filters with OR can't be exported")}
+	}
+	p, err := r.getPatternInfo()
+	if err != nil {
+		return nil, &badRequestError{err}
+	}
+	p.FileMatchLimit = math.MaxInt32
+	p.PatternMatchesContent = true
+	if err := p.Validate(); err != nil {
+		return nil, &badRequestError{err}
+	}
+
+	stats := &SearchExportStats{}
+	incomplete := map[api.RepoName]struct{}{}
+	op := r.resolveRepoOp(nil)
+	for offset := 0; ; offset += searchExportPageSize {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		op.limitOffset = &db.LimitOffset{Limit: searchExportPageSize, Offset: offset}
+		repos, missingRepoRevs, _, more, err := resolveRepositories(ctx, op)
+		if err != nil {
+			return stats, err
+		}
+		for _, repoRev := range missingRepoRevs {
+			incomplete[repoRev.Repo.Name] = struct{}{}
+		}
+		if len(repos) > 0 {
+			stats.Repositories += len(repos)
+			matches, err := exportSearchPage(ctx, &search.Args{
+				Pattern:         p,
+				Repos:           repos,
+				Query:           r.query,
+				UseFullDeadline: true,
+			}, incomplete)
+			if err != nil {
+				return stats, err
+			}
+			if err := fn(matches); err != nil {
+				return stats, err
+			}
+		}
+		if !more {
+			break
+		}
+	}
+
+	for name := range incomplete {
+		stats.Incomplete = append(stats.Incomplete, name)
+	}
+	sort.Slice(stats.Incomplete, func(i, j int) bool { return stats.Incomplete[i] < stats.Incomplete[j] })
+	return stats, nil
+}
+
+// exportSearchPage returns the lines which match args, ordered by repository,
+// path and line. The repositories whose matches may be incomplete are added to
+// incomplete.
+func exportSearchPage(ctx context.Context, args *search.Args, incomplete map[api.RepoName]struct{}) ([]*SearchExportMatch, error) {
+	pageCtx, cancel := context.WithTimeout(ctx, searchExportPageTimeout)
+	defer cancel()
+
+	var (
+		results []*searchResultResolver
+		common  *searchResultsCommon
+		err     error
+	)
+	if args.Query.IsBoolean() {
+		results, common, err = newBooleanSearch(args, []string{"file"}).run(pageCtx)
+	} else {
+		var fileMatches []*fileMatchResolver
+		fileMatches, common, err = searchFilesInRepos(pageCtx, args)
+		for _, fm := range fileMatches {
+			results = append(results, &searchResultResolver{fileMatch: fm})
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil || !isContextError(pageCtx, err) {
+			return nil, err
+		}
+		// The page timed out, so any of its repositories may be incomplete.
+		for _, repoRev := range args.Repos {
+			incomplete[repoRev.Repo.Name] = struct{}{}
+		}
+	}
+	if common != nil {
+		for _, repos := range [][]*types.Repo{common.cloning, common.missing, common.timedout} {
+			for _, repo := range repos {
+				incomplete[repo.Name] = struct{}{}
+			}
+		}
+		for name := range common.partial {
+			incomplete[name] = struct{}{}
+		}
+	}
+	sortResults(results)
+
+	// Matches in the default branch are searched with Zoekt, which does not
+	// report the commit, so resolve it (once per repository).
+	defaultBranchCommits := map[api.RepoName]api.CommitID{}
+	var matches []*SearchExportMatch
+	for _, result := range results {
+		fm := result.fileMatch
+		if fm == nil {
+			continue
+		}
+		if fm.JLimitHit {
+			incomplete[fm.repo.Name] = struct{}{}
+		}
+		commitID := fm.commitID
+		if commitID == "" {
+			var ok bool
+			if commitID, ok = defaultBranchCommits[fm.repo.Name]; !ok {
+				// Leave the commit empty if it can't be resolved.
+				commitID, _ = git.ResolveRevision(ctx, search.RepositoryRevisions{Repo: fm.repo}.GitserverRepo(), nil, "HEAD", &git.ResolveRevisionOptions{NoEnsureRevision: true})
+				defaultBranchCommits[fm.repo.Name] = commitID
+			}
+		}
+		for _, lm := range fm.JLineMatches {
+			matches = append(matches, &SearchExportMatch{
+				Repo:       fm.repo.Name,
+				Commit:     commitID,
+				Path:       fm.JPath,
+				LineNumber: lm.JLineNumber + 1,
+				Preview:    lm.JPreview,
+			})
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,110 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+func TestExportSearch(t *testing.T) {
+	// There are more repositories than fit on a page.
+	var repos []*types.Repo
+	for i := 0; i < searchExportPageSize+2; i++ {
+		repos = append(repos, &types.Repo{ID: api.RepoID(i), Name: api.RepoName(fmt.Sprintf("r%03d", i))})
+	}
+	db.Mocks.Repos.List = func(_ context.Context, op db.ReposListOptions) ([]*types.Repo, error) {
+		if op.LimitOffset == nil {
+			t.Fatal("repositories were not paginated")
+		}
+		var page []*types.Repo
+		for i := op.Offset; i < op.Offset+op.Limit && i < len(repos); i++ {
+			// The user may not read the first repository.
+			if i > 0 {
+				page = append(page, repos[i])
+			}
+		}
+		return page, nil
+	}
+	db.Mocks.Repos.Count = func(context.Context, db.ReposListOptions) (int, error) { return len(repos), nil }
+	defer func() { db.Mocks = db.MockStores{} }()
+
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		return "c0", nil
+	}
+	defer git.ResetMocks()
+
+	mockSearchFilesInRepos = func(args *search.Args) ([]*fileMatchResolver, *searchResultsCommon, error) {
+		if args.Pattern.FileMatchLimit < 1000000 {
+			t.Errorf("got FileMatchLimit %d, want no limit", args.Pattern.FileMatchLimit)
+		}
+		var (
+			results []*fileMatchResolver
+			common  searchResultsCommon
+		)
+		for _, repoRev := range args.Repos {
+			switch repoRev.Repo.Name {
+			case "r001":
+				// Searched with Zoekt, so the commit is resolved.
+				results = append(results, &fileMatchResolver{repo: repoRev.Repo, JPath: "b", JLineMatches: []*lineMatch{{JLineNumber: 0, JPreview: "foo"}, {JLineNumber: 4, JPreview: "foo()"}}})
+			case "r101":
+				results = append(results, &fileMatchResolver{repo: repoRev.Repo, commitID: "c1", JPath: "a", JLineMatches: []*lineMatch{{JLineNumber: 1, JPreview: "x foo"}}})
+			case "r002":
+				common.cloning = append(common.cloning, repoRev.Repo)
+			}
+		}
+		return results, &common, nil
+	}
+	defer func() { mockSearchFilesInRepos = nil }()
+
+	var (
+		pages   int
+		matches []SearchExportMatch
+	)
+	stats, err := ExportSearch(context.Background(), "foo", func(page []*SearchExportMatch) error {
+		pages++
+		for _, m := range page {
+			matches = append(matches, *m)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages != 2 {
+		t.Errorf("got %d pages, want 2", pages)
+	}
+	if want := []SearchExportMatch{
+		{Repo: "r001", Commit: "c0", Path: "b", LineNumber: 1, Preview: "foo"},
+		{Repo: "r001", Commit: "c0", Path: "b", LineNumber: 5, Preview: "foo()"},
+		{Repo: "r101", Commit: "c1", Path: "a", LineNumber: 2, Preview: "x foo"},
+	}; !reflect.DeepEqual(matches, want) {
+		t.Errorf("got matches %+v, want %+v", matches, want)
+	}
+	if want := (&SearchExportStats{Repositories: len(repos) - 1, Incomplete: []api.RepoName{"r002"}}); !reflect.DeepEqual(stats, want) {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := ExportSearch(ctx, "foo", func([]*SearchExportMatch) error {
+			cancel()
+			return nil
+		})
+		if err != context.Canceled {
+			t.Errorf("got error %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("unsupported result type", func(t *testing.T) {
+		if _, err := ExportSearch(context.Background(), "type:diff foo", func([]*SearchExportMatch) error { return nil }); err == nil {
+			t.Error("got no error for type:diff")
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package graphqlbackend
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/schema"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// Default weights of the signals which make up the score of a file match. See
+// the "search.ranking" setting.
+const (
+	defaultRankingIndexScore  = 1
+	defaultRankingLineMatches = 2
+	defaultRankingSymbols     = 4
+	defaultRankingPopularity  = 0.5
+	defaultRankingForkPenalty = 3
+)
+
+// When results are ranked, searches collect rankingCandidatesFactor times as
+// many file matches as requested (but at most maxRankingCandidates, unless
+// more are requested), so that the most relevant of them can be returned
+// instead of the first ones found.
+const (
+	rankingCandidatesFactor = 5
+	maxRankingCandidates    = 1000
+)
+
+// defaultRankingPathPenalties are the penalties for matches in vendored, test
+// and generated files, by the regexp which matches their path.
+var defaultRankingPathPenalties = map[string]float64{
+	`(^|/)(vendor|third_party|node_modules)/`:                     10,
+	`(^|/)(tests?|testdata|__tests__)/`:                           5,
+	`(_test\.go|\.(test|spec)\.[a-z]+)$`:                          5,
+	`(^|/)generated/|(\.pb\.go|\.pb\.gw\.go|_generated\.[a-z]+)$`: 10,
+	`\.min\.(js|css)$`:                                            10,
+}
+
+// searchRanking scores search results, so that the most relevant results are
+// shown first.
+type searchRanking struct {
+	indexScore  float64
+	lineMatches float64
+	symbols     float64
+	popularity  float64
+	forkPenalty float64
+
+	pathPenalties    []weightedPattern
+	repositoryBoosts []weightedPattern
+}
+
+// weightedPattern is a regexp and the amount to add to (or subtract from) the
+// score of a match which it matches.
+type weightedPattern struct {
+	pattern *regexp.Regexp
+	weight  float64
+}
+
+var mockSearchRanking func() (*searchRanking, error)
+
+// getSearchRanking returns the ranking configured in the viewer's settings, or
+// nil if ranking is disabled.
+func getSearchRanking(ctx context.Context) (*searchRanking, error) {
+	if mockSearchRanking != nil {
+		return mockSearchRanking()
+	}
+
+	merged, err := viewerFinalSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var settings schema.Settings
+	if err := json.Unmarshal([]byte(merged.Contents()), &settings); err != nil {
+		return nil, err
+	}
+	return newSearchRanking(settings.SearchRanking), nil
+}
+
+// newSearchRanking returns the ranking configured by c (which may be nil to use
+// the defaults), or nil if ranking is disabled.
+func newSearchRanking(c *schema.SearchRanking) *searchRanking {
+	if c == nil {
+		c = &schema.SearchRanking{}
+	}
+	if c.Enabled != nil && !*c.Enabled {
+		return nil
+	}
+
+	weight := func(v *float64, defaultWeight float64) float64 {
+		if v == nil {
+			return defaultWeight
+		}
+		return *v
+	}
+	pathPenalties := make(map[string]float64, len(defaultRankingPathPenalties)+len(c.PathPenalties))
+	for pattern, penalty := range defaultRankingPathPenalties {
+		pathPenalties[pattern] = penalty
+	}
+	for pattern, penalty := range c.PathPenalties {
+		pathPenalties[pattern] = penalty
+	}
+	return &searchRanking{
+		indexScore:       weight(c.IndexScore, defaultRankingIndexScore),
+		lineMatches:      weight(c.LineMatches, defaultRankingLineMatches),
+		symbols:          weight(c.Symbols, defaultRankingSymbols),
+		popularity:       weight(c.Popularity, defaultRankingPopularity),
+		forkPenalty:      weight(c.ForkPenalty, defaultRankingForkPenalty),
+		pathPenalties:    compileWeightedPatterns(pathPenalties),
+		repositoryBoosts: compileWeightedPatterns(c.RepositoryBoosts),
+	}
+}
+
+// compileWeightedPatterns compiles the regexps of weights, ordered by regexp.
+// Invalid regexps and zero weights are skipped.
+func compileWeightedPatterns(weights map[string]float64) []weightedPattern {
+	var patterns []weightedPattern
+	for pattern, weight := range weights {
+		if weight == 0 {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log15.Warn("Ignoring invalid regexp in search.ranking setting.", "pattern", pattern, "error", err)
+			continue
+		}
+		patterns = append(patterns, weightedPattern{pattern: re, weight: weight})
+	}
+	sort.Slice(patterns, func(i, j int) bool { return patterns[i].pattern.String() < patterns[j].pattern.String() })
+	return patterns
+}
+
+// fileMatchScore returns the score of fm. The counts of matches are
+// logarithmic, so that a file with many matches does not outrank all others.
+func (r *searchRanking) fileMatchScore(fm *fileMatchResolver) float64 {
+	symbols := fm.symbolHits
+	if len(fm.symbols) > symbols {
+		symbols = len(fm.symbols)
+	}
+	score := r.indexScore*math.Log2(1+fm.zoektScore) +
+		r.lineMatches*math.Log2(1+float64(len(fm.JLineMatches))) +
+		r.symbols*math.Log2(1+float64(symbols))
+	for _, p := range r.pathPenalties {
+		if p.pattern.MatchString(fm.JPath) {
+			score -= p.weight
+		}
+	}
+	return score + r.repositoryScore(fm.repo)
+}
+
+// repositoryScore returns the amount added to the score of the matches in
+// repo. Like the counts of matches, the number of stars is logarithmic.
+func (r *searchRanking) repositoryScore(repo *types.Repo) float64 {
+	if repo == nil {
+		return 0
+	}
+	score := r.popularity * math.Log2(1+float64(repo.Stars))
+	for _, p := range r.repositoryBoosts {
+		if p.pattern.MatchString(string(repo.Name)) {
+			score += p.weight
+		}
+	}
+	if repo.Fork {
+		score -= r.forkPenalty
+	}
+	return score
+}
+
+// rankResults sorts results by ranking, and sets the scores of the file
+// matches. Repository matches come first (ordered by the score of their
+// repository), followed by the file matches, highest score first, and the
+// diff and commit matches in their original order. Results with the same
+// score are ordered like sortResults does.
+//
+// If ranking is nil, the results are only sorted by sortResults.
+func rankResults(results []*searchResultResolver, ranking *searchRanking) {
+	if ranking == nil {
+		sortResults(results)
+		return
+	}
+
+	scores := make(map[*searchResultResolver]float64, len(results))
+	for _, result := range results {
+		switch {
+		case result.repo != nil:
+			scores[result] = ranking.repositoryScore(result.repo.repo)
+		case result.fileMatch != nil:
+			score := ranking.fileMatchScore(result.fileMatch)
+			result.fileMatch.score = &score
+			scores[result] = score
+		}
+	}
+
+	// kind returns the position of the group of results which result is in.
+	kind := func(result *searchResultResolver) int {
+		switch {
+		case result.repo != nil:
+			return 0
+		case result.fileMatch != nil:
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if kind(a) != kind(b) {
+			return kind(a) < kind(b)
+		}
+		if a.diff != nil {
+			return false
+		}
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return compareSearchResults(a, b)
+	})
+}
+
+// rankingFileMatchLimit returns the number of file matches which a search
+// collects to rank them, when maxResults are requested.
+func rankingFileMatchLimit(maxResults int32) int32 {
+	limit := maxResults * rankingCandidatesFactor
+	if limit > maxRankingCandidates || limit < maxResults {
+		limit = maxRankingCandidates
+	}
+	if limit < maxResults {
+		limit = maxResults
+	}
+	return limit
+}
+
+// truncateFileMatches removes the file matches after the first limit ones
+// from results (keeping the other results), and reports whether it removed
+// any.
+func truncateFileMatches(results []*searchResultResolver, limit int) ([]*searchResultResolver, bool) {
+	var (
+		kept      = results[:0]
+		n         int
+		truncated bool
+	)
+	for _, result := range results {
+		if result.fileMatch != nil {
+			if n >= limit {
+				truncated = true
+				continue
+			}
+			n++
+		}
+		kept = append(kept, result)
+	}
+	return kept, truncated
+}
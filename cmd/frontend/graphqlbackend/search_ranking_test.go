@@ -0,0 +1,195 @@
+package graphqlbackend
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestNewSearchRanking(t *testing.T) {
+	disabled, zero := false, 0.0
+	if r := newSearchRanking(&schema.SearchRanking{Enabled: &disabled}); r != nil {
+		t.Errorf("got %+v, want nil for disabled ranking", r)
+	}
+
+	r := newSearchRanking(&schema.SearchRanking{
+		LineMatches:      &zero,
+		PathPenalties:    map[string]float64{`(^|/)vendor/`: 1, `(^|/)(tests?|testdata|__tests__)/`: 0, `(`: 1},
+		RepositoryBoosts: map[string]float64{"^github.com/a/": 2},
+	})
+	if r.indexScore != defaultRankingIndexScore || r.lineMatches != 0 {
+		t.Errorf("got weights indexScore=%v lineMatches=%v, want %v and 0", r.indexScore, r.lineMatches, defaultRankingIndexScore)
+	}
+	var pathPenalties []string
+	for _, p := range r.pathPenalties {
+		pathPenalties = append(pathPenalties, p.pattern.String())
+	}
+	// The invalid regexp and the disabled default are skipped.
+	if want := []string{
+		`(^|/)(vendor|third_party|node_modules)/`,
+		`(^|/)generated/|(\.pb\.go|\.pb\.gw\.go|_generated\.[a-z]+)$`,
+		`(^|/)vendor/`,
+		`(_test\.go|\.(test|spec)\.[a-z]+)$`,
+		`\.min\.(js|css)$`,
+	}; !reflect.DeepEqual(pathPenalties, want) {
+		t.Errorf("got path penalties %q, want %q", pathPenalties, want)
+	}
+	if len(r.repositoryBoosts) != 1 {
+		t.Errorf("got %d repository boosts, want 1", len(r.repositoryBoosts))
+	}
+}
+
+func TestRankResults(t *testing.T) {
+	zero := 0.0
+	repo := &types.Repo{Name: "github.com/a/b"}
+	fork := &types.Repo{Name: "github.com/c/b", Fork: true}
+	fileMatch := func(repo *types.Repo, path string, lines int, zoektScore float64) *searchResultResolver {
+		return &searchResultResolver{fileMatch: &fileMatchResolver{
+			repo:         repo,
+			JPath:        path,
+			JLineMatches: make([]*lineMatch, lines),
+			zoektScore:   zoektScore,
+		}}
+	}
+	description := func(result *searchResultResolver) string {
+		switch {
+		case result.repo != nil:
+			return "repo:" + string(result.repo.repo.Name)
+		case result.fileMatch != nil:
+			return string(result.fileMatch.repo.Name) + "/" + result.fileMatch.JPath
+		default:
+			return "diff:" + string(result.diff.commit.oid)
+		}
+	}
+	diff := func(oid string) *searchResultResolver {
+		return &searchResultResolver{diff: &commitSearchResultResolver{commit: &gitCommitResolver{oid: gitObjectID(oid)}}}
+	}
+	newResults := func() []*searchResultResolver {
+		return []*searchResultResolver{
+			diff("2"),
+			fileMatch(repo, "a.go", 1, 0),
+			fileMatch(repo, "vendor/x/a.go", 10, 0),
+			diff("1"),
+			fileMatch(fork, "a.go", 1, 0),
+			fileMatch(repo, "b.go", 3, 0),
+			fileMatch(repo, "c.go", 1, 1000),
+			{repo: &repositoryResolver{repo: &types.Repo{Name: api.RepoName("github.com/c/b")}}},
+			fileMatch(repo, "a_test.go", 1, 0),
+		}
+	}
+
+	tests := map[string]struct {
+		ranking *schema.SearchRanking
+		want    []string
+	}{
+		"default": {
+			want: []string{
+				"repo:github.com/c/b",
+				"github.com/a/b/c.go",
+				"github.com/a/b/b.go",
+				"github.com/a/b/a.go",
+				"github.com/c/b/a.go",
+				"github.com/a/b/a_test.go",
+				"github.com/a/b/vendor/x/a.go",
+				"diff:2",
+				"diff:1",
+			},
+		},
+		"boosts": {
+			ranking: &schema.SearchRanking{
+				IndexScore:       &zero,
+				ForkPenalty:      &zero,
+				PathPenalties:    map[string]float64{`(^|/)(vendor|third_party|node_modules)/`: 0},
+				RepositoryBoosts: map[string]float64{"^github.com/c/": 10},
+			},
+			want: []string{
+				"repo:github.com/c/b",
+				"github.com/c/b/a.go",
+				"github.com/a/b/vendor/x/a.go",
+				"github.com/a/b/b.go",
+				"github.com/a/b/a.go",
+				"github.com/a/b/c.go",
+				"github.com/a/b/a_test.go",
+				"diff:2",
+				"diff:1",
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			results := newResults()
+			rankResults(results, newSearchRanking(test.ranking))
+			var got []string
+			for _, result := range results {
+				got = append(got, description(result))
+				if result.fileMatch != nil && result.fileMatch.Score() == nil {
+					t.Errorf("%s: got nil score", description(result))
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got  %q\nwant %q", got, test.want)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		results := []*searchResultResolver{fileMatch(repo, "vendor/x/a.go", 10, 0), fileMatch(repo, "a.go", 1, 0)}
+		rankResults(results, nil)
+		if got, want := description(results[0]), "github.com/a/b/a.go"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if results[0].fileMatch.Score() != nil {
+			t.Error("got a score, want nil when ranking is disabled")
+		}
+	})
+
+	t.Run("symbols and popularity", func(t *testing.T) {
+		popular := &types.Repo{Name: "github.com/d/b", Stars: 1023}
+		symbols := fileMatch(repo, "s.go", 1, 0)
+		symbols.fileMatch.symbolHits = 2
+		results := []*searchResultResolver{fileMatch(repo, "a.go", 1, 0), fileMatch(popular, "a.go", 1, 0), symbols}
+		rankResults(results, newSearchRanking(nil))
+		var got []string
+		for _, result := range results {
+			got = append(got, description(result))
+		}
+		if want := []string{"github.com/a/b/s.go", "github.com/d/b/a.go", "github.com/a/b/a.go"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got  %q\nwant %q", got, want)
+		}
+	})
+}
+
+func TestRankingFileMatchLimit(t *testing.T) {
+	for _, test := range []struct{ maxResults, want int32 }{
+		{30, 150},
+		{500, 1000},
+		{5000, 5000},
+		{math.MaxInt32, math.MaxInt32},
+	} {
+		if got := rankingFileMatchLimit(test.maxResults); got != test.want {
+			t.Errorf("rankingFileMatchLimit(%d) = %d, want %d", test.maxResults, got, test.want)
+		}
+	}
+}
+
+func TestTruncateFileMatches(t *testing.T) {
+	repo := &repositoryResolver{repo: &types.Repo{Name: "github.com/a/b"}}
+	fileMatch := func(path string) *searchResultResolver {
+		return &searchResultResolver{fileMatch: &fileMatchResolver{JPath: path}}
+	}
+	results, truncated := truncateFileMatches([]*searchResultResolver{{repo: repo}, fileMatch("a"), fileMatch("b"), fileMatch("c")}, 2)
+	if !truncated {
+		t.Error("got truncated false, want true")
+	}
+	if len(results) != 3 || results[0].repo != repo || results[2].fileMatch.JPath != "b" {
+		t.Errorf("got %d results, want the repository and the first 2 file matches", len(results))
+	}
+
+	if _, truncated := truncateFileMatches([]*searchResultResolver{fileMatch("a")}, 2); truncated {
+		t.Error("got truncated true, want false")
+	}
+}
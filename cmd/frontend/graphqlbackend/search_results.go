@@ -24,6 +24,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/goroutine"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	searchquerytypes "github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/types"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
@@ -398,8 +399,8 @@ loop:
 	for _, r := range sr.results {
 		r := r // shadow so it doesn't change in the goroutine
 		switch {
-		case r.repo != nil:
-			// We don't care about repo results here.
+		case r.repo != nil, r.person != nil:
+			// We don't care about repo or commit author results here.
 			continue
 		case r.diff != nil:
 			// Diff searches are cheap, because we implicitly have author date info.
@@ -550,20 +551,21 @@ func (r *searchResolver) Stats(ctx context.Context) (stats *searchResultsStats,
 }
 
 func (r *searchResolver) getPatternInfo() (*search.PatternInfo, error) {
+	patternType, err := r.query.PatternType()
+	if err != nil {
+		return nil, err
+	}
+
 	var patternsToCombine []string
 	for _, v := range r.query.Values(query.FieldDefault) {
-		// Treat quoted strings as literal strings to match, not regexps.
-		var pattern string
-		switch {
-		case v.String != nil:
-			pattern = regexp.QuoteMeta(*v.String)
-		case v.Regexp != nil:
-			pattern = v.Regexp.String()
-		}
-		if pattern == "" {
+		if v.Not() {
+			// Negated patterns are only matched by boolean queries (see
+			// booleanSearch).
 			continue
 		}
-		patternsToCombine = append(patternsToCombine, pattern)
+		if pattern := defaultFieldPattern(v, patternType); pattern != "" {
+			patternsToCombine = append(patternsToCombine, pattern)
+		}
 	}
 
 	// Handle file: and -file: filters.
@@ -586,12 +588,35 @@ func (r *searchResolver) getPatternInfo() (*search.PatternInfo, error) {
 		PathPatternsAreRegExps:       true,
 		PathPatternsAreCaseSensitive: r.query.IsCaseSensitive(),
 	}
+	if patternType == query.PatternTypeStructural {
+		// Structural patterns are matched by searcher, which treats
+		// whitespace in the pattern as matching any whitespace. So the terms
+		// can just be joined.
+		patternInfo.IsRegExp = false
+		patternInfo.IsStructuralPat = true
+		patternInfo.Pattern = strings.Join(patternsToCombine, " ")
+	}
 	if len(excludePatterns) > 0 {
 		patternInfo.ExcludePattern = unionRegExps(excludePatterns)
 	}
 	return patternInfo, nil
 }
 
+// defaultFieldPattern returns the pattern to match for a value of the default
+// field.
+func defaultFieldPattern(v *searchquerytypes.Value, patternType string) string {
+	// Treat quoted strings as literal strings to match, not regexps.
+	switch {
+	case v.String != nil && patternType == query.PatternTypeStructural:
+		return *v.String
+	case v.String != nil:
+		return regexp.QuoteMeta(*v.String)
+	case v.Regexp != nil:
+		return v.Regexp.String()
+	}
+	return ""
+}
+
 var (
 	// The default timeout to use for queries.
 	defaultTimeout = 10 * time.Second
@@ -632,6 +657,10 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 		tr.Finish()
 	}()
 
+	if branches := r.query.FilterBranches(); len(branches) > 0 {
+		return r.doBranchResults(ctx, forceOnlyResultType, branches)
+	}
+
 	start := time.Now()
 
 	ctx, cancel, err := r.withTimeout(ctx)
@@ -674,14 +703,62 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 		return nil, &badRequestError{err}
 	}
 
-	// Determine which types of results to return.
-	var resultTypes []string
+	ranking, err := getSearchRanking(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Determine which types of results to return, and the entity type to
+	// which they are projected (if any). Forced result types (e.g., for
+	// suggestions) are never projected.
+	var (
+		resultTypes []string
+		selectType  string
+	)
 	if forceOnlyResultType != "" {
 		resultTypes = []string{forceOnlyResultType}
 	} else {
+		selectType, err = r.query.Select()
+		if err != nil {
+			return nil, &badRequestError{err}
+		}
 		resultTypes, _ = r.query.StringValues(query.FieldType)
 		if len(resultTypes) == 0 {
 			resultTypes = []string{"file", "path", "repo", "ref"}
+			if selectType != "" {
+				resultTypes = selectResultTypes[selectType].defaults
+			} else if args.Pattern.IsStructuralPat {
+				resultTypes = []string{"file"}
+			} else if r.query.IsBoolean() {
+				resultTypes = []string{"file", "path"}
+			}
+		}
+	}
+	if selectType != "" {
+		if err := checkSelectResultTypes(selectType, resultTypes); err != nil {
+			return nil, &badRequestError{err}
+		}
+	}
+	if ranking != nil && selectType == "" {
+		// Collect more file matches than requested, and return the most
+		// relevant ones once they are ranked.
+		args.RankingFileMatchLimit = rankingFileMatchLimit(r.maxResults())
+	}
+	if r.query.IsBoolean() {
+		if args.Pattern.IsStructuralPat {
+			return nil, &badRequestError{fmt.Errorf("AND, OR and negated terms are not supported with patterntype:%s", query.PatternTypeStructural)}
+		}
+		for _, resultType := range resultTypes {
+			if _, ok := booleanResultTypes[resultType]; !ok {
+				return nil, &badRequestError{fmt.Errorf("type:%s is not supported with AND, OR and negated terms", resultType)}
+			}
+		}
+	}
+	if args.Pattern.IsStructuralPat {
+		for _, resultType := range resultTypes {
+			if resultType != "file" && resultType != "path" {
+				return nil, &badRequestError{fmt.Errorf("type:%s is not supported with patterntype:%s", resultType, query.PatternTypeStructural)}
+			}
 		}
 	}
 	seenResultTypes := make(map[string]struct{}, len(resultTypes))
@@ -694,6 +771,48 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 	}
 	tr.LazyPrintf("resultTypes: %v", resultTypes)
 
+	// alert is a potential alert shown to the user
+	var alert *searchAlert
+
+	if len(missingRepoRevs) > 0 {
+		alert = r.alertForMissingRepoRevs(missingRepoRevs)
+	}
+
+	if r.query.IsBoolean() {
+		results, common, err := newBooleanSearch(&args, resultTypes).run(ctx)
+		if err != nil {
+			if len(results) == 0 {
+				return nil, err
+			}
+			// Only log the error, so that the client receives the partial results.
+			log15.Error("Errors during search", "error", err)
+		}
+		tr.LazyPrintf("results=%d limitHit=%v cloning=%d missing=%d timedout=%d", len(results), common.limitHit, len(common.cloning), len(common.missing), len(common.timedout))
+		if selectType != "" {
+			selector := newResultSelector(selectType)
+			selector.add(results...)
+			results = selector.results
+		}
+		if ranking != nil {
+			// Otherwise, keep the order of sortBooleanResults.
+			rankResults(results, ranking)
+			if args.RankingFileMatchLimit > 0 {
+				var truncated bool
+				results, truncated = truncateFileMatches(results, int(r.maxResults()))
+				if truncated {
+					common.limitHit = true
+				}
+			}
+		}
+		common.maxResultsCount = r.maxResults()
+		return &searchResultsResolver{
+			start:               start,
+			searchResultsCommon: *common,
+			results:             results,
+			alert:               alert,
+		}, nil
+	}
+
 	var (
 		requiredWg sync.WaitGroup
 		optionalWg sync.WaitGroup
@@ -707,7 +826,28 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 		// to merge multiple results of different types for the same file
 		fileMatches   = make(map[string]*fileMatchResolver)
 		fileMatchesMu sync.Mutex
+		// selector projects the results with select:, and stops the search
+		// once there are more distinct entities than requested.
+		selector       *resultSelector
+		selectLimitHit bool
 	)
+	if selectType != "" {
+		selector = newResultSelector(selectType)
+	}
+
+	// addResults adds results. The caller must hold resultsMu.
+	addResults := func(rs ...*searchResultResolver) {
+		results = append(results, rs...)
+		if selector == nil {
+			return
+		}
+		selector.add(rs...)
+		if !selectLimitHit && selector.count > int(r.maxResults()) {
+			selectLimitHit = true
+			tr.LazyPrintf("select:%s limit hit, cancelling remaining searches", selectType)
+			cancel()
+		}
+	}
 
 	waitGroup := func(required bool) *sync.WaitGroup {
 		if args.UseFullDeadline {
@@ -744,7 +884,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 				}
 				if repoResults != nil {
 					resultsMu.Lock()
-					results = append(results, repoResults...)
+					addResults(repoResults...)
 					resultsMu.Unlock()
 				}
 				if repoCommon != nil {
@@ -774,7 +914,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 					} else {
 						fileMatches[key] = symbolFileMatch
 						resultsMu.Lock()
-						results = append(results, &searchResultResolver{fileMatch: symbolFileMatch})
+						addResults(&searchResultResolver{fileMatch: symbolFileMatch})
 						resultsMu.Unlock()
 					}
 					fileMatchesMu.Unlock()
@@ -811,10 +951,11 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 						// merge line match results with an existing symbol result
 						m.JLimitHit = m.JLimitHit || r.JLimitHit
 						m.JLineMatches = r.JLineMatches
+						m.zoektScore = r.zoektScore
 					} else {
 						fileMatches[key] = r
 						resultsMu.Lock()
-						results = append(results, &searchResultResolver{fileMatch: r})
+						addResults(&searchResultResolver{fileMatch: r})
 						resultsMu.Unlock()
 					}
 					fileMatchesMu.Unlock()
@@ -839,7 +980,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 				}
 				if diffResults != nil {
 					resultsMu.Lock()
-					results = append(results, diffResults...)
+					addResults(diffResults...)
 					resultsMu.Unlock()
 				}
 				if diffCommon != nil {
@@ -863,7 +1004,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 				}
 				if commitResults != nil {
 					resultsMu.Lock()
-					results = append(results, commitResults...)
+					addResults(commitResults...)
 					resultsMu.Unlock()
 				}
 				if commitCommon != nil {
@@ -875,6 +1016,34 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 		}
 	}
 
+	// The number of symbols which match the query in a file is a ranking
+	// signal. Unless symbols are searched for anyway, look them up for the
+	// file matches, without adding symbol results. Searches with a forced
+	// result type (suggestions and streamed searches) don't need the best
+	// order, so they skip this.
+	var (
+		symbolHits   = make(map[string]int)
+		symbolHitsMu sync.Mutex
+	)
+	if _, searchesSymbols := seenResultTypes["symbol"]; ranking != nil && ranking.symbols != 0 && args.Pattern.PatternMatchesContent && !args.Pattern.IsStructuralPat && selectType == "" && forceOnlyResultType == "" && !searchesSymbols {
+		wg := waitGroup(false)
+		wg.Add(1)
+		goroutine.Go(func() {
+			defer wg.Done()
+
+			symbolFileMatches, _, err := searchSymbols(ctx, &args, int(searchFileMatchLimit(&args)))
+			if err != nil && !isContextError(ctx, err) {
+				// Ranking without this signal is better than failing.
+				tr.LazyPrintf("symbol search for ranking failed: %v", err)
+			}
+			symbolHitsMu.Lock()
+			defer symbolHitsMu.Unlock()
+			for _, symbolFileMatch := range symbolFileMatches {
+				symbolHits[symbolFileMatch.uri] += len(symbolFileMatch.symbols)
+			}
+		})
+	}
+
 	// Wait for required searches.
 	requiredWg.Wait()
 
@@ -891,13 +1060,6 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 
 	tr.LazyPrintf("results=%d limitHit=%v cloning=%d missing=%d timedout=%d", len(results), common.limitHit, len(common.cloning), len(common.missing), len(common.timedout))
 
-	// alert is a potential alert shown to the user
-	var alert *searchAlert
-
-	if len(missingRepoRevs) > 0 {
-		alert = r.alertForMissingRepoRevs(missingRepoRevs)
-	}
-
 	// If we have some results, only log the error instead of returning it,
 	// because otherwise the client would not receive the partial results
 	if len(results) > 0 && multiErr != nil {
@@ -905,7 +1067,26 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 		multiErr = nil
 	}
 
-	sortResults(results)
+	if selector != nil {
+		results = selector.results
+		if selectLimitHit {
+			common.limitHit = true
+		}
+	}
+
+	for _, result := range results {
+		if result.fileMatch != nil {
+			result.fileMatch.symbolHits = symbolHits[result.fileMatch.uri]
+		}
+	}
+	rankResults(results, ranking)
+	if args.RankingFileMatchLimit > 0 {
+		var truncated bool
+		results, truncated = truncateFileMatches(results, int(r.maxResults()))
+		if truncated {
+			common.limitHit = true
+		}
+	}
 
 	resultsResolver := searchResultsResolver{
 		start:               start,
@@ -930,6 +1111,7 @@ type searchResultResolver struct {
 	repo      *repositoryResolver         // repo name match
 	fileMatch *fileMatchResolver          // text match
 	diff      *commitSearchResultResolver // diff or commit match
+	person    *personResolver             // commit author (with select:commit.author)
 }
 
 // getSearchResultURIs returns the repo name and file uri respectiveley
@@ -972,6 +1154,9 @@ func (g *searchResultResolver) ToFileMatch() (*fileMatchResolver, bool) {
 func (g *searchResultResolver) ToCommitSearchResult() (*commitSearchResultResolver, bool) {
 	return g.diff, g.diff != nil
 }
+func (g *searchResultResolver) ToPerson() (*personResolver, bool) {
+	return g.person, g.person != nil
+}
 
 func (g *searchResultResolver) resultCount() int32 {
 	switch {
@@ -3,7 +3,11 @@ package graphqlbackend
 import (
 	"context"
 	"fmt"
+	"math"
 	"reflect"
+	"regexp"
+	"sort"
+	"sync"
 	"testing"
 
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
@@ -16,6 +20,9 @@ import (
 func TestSearchResults(t *testing.T) {
 	limitOffset := &db.LimitOffset{Limit: maxReposToSearch() + 1}
 
+	mockSearchRanking = func() (*searchRanking, error) { return newSearchRanking(nil), nil }
+	defer func() { mockSearchRanking = nil }()
+
 	createSearchResolver := func(t *testing.T, query string) *searchResolver {
 		r, err := (&schemaResolver{}).Search(&struct{ Query string }{Query: query})
 		if err != nil {
@@ -91,14 +98,16 @@ func TestSearchResults(t *testing.T) {
 		}
 		defer func() { mockSearchRepositories = nil }()
 
+		// Symbols are only looked up for ranking, so they are not results.
 		calledSearchSymbols := false
 		mockSearchSymbols = func(ctx context.Context, args *search.Args, limit int) (res []*fileMatchResolver, common *searchResultsCommon, err error) {
 			calledSearchSymbols = true
 			if want := `(foo\d).*?(bar\*)`; args.Pattern.Pattern != want {
 				t.Errorf("got %q, want %q", args.Pattern.Pattern, want)
 			}
-			// TODO return mock results here and assert that they are output as results
-			return nil, nil, nil
+			return []*fileMatchResolver{
+				{uri: "git://repo?rev#dir/other", JPath: "dir/other", symbols: []*symbolResolver{{}}},
+			}, nil, nil
 		}
 		defer func() { mockSearchSymbols = nil }()
 
@@ -124,9 +133,145 @@ func TestSearchResults(t *testing.T) {
 		if !calledSearchFilesInRepos {
 			t.Error("!calledSearchFilesInRepos")
 		}
-		if calledSearchSymbols {
-			t.Error("calledSearchSymbols")
+		if !calledSearchSymbols {
+			t.Error("!calledSearchSymbols")
+		}
+	})
+
+	t.Run("boolean query", func(t *testing.T) {
+		db.Mocks.Repos.List = func(_ context.Context, op db.ReposListOptions) ([]*types.Repo, error) {
+			return []*types.Repo{{Name: "repo"}}, nil
+		}
+		defer func() { db.Mocks = db.MockStores{} }()
+		db.Mocks.Repos.MockGetByName(t, "repo", 1)
+
+		fileMatch := func(path string, line int32) *fileMatchResolver {
+			return &fileMatchResolver{uri: "git://repo?rev#" + path, JPath: path, JLineMatches: []*lineMatch{{JLineNumber: line}}, repo: &types.Repo{Name: "repo"}}
 		}
+		var (
+			limitsMu sync.Mutex
+			limits   = map[string]int32{} // FileMatchLimit by pattern
+		)
+		mockSearchFilesInRepos = func(args *search.Args) ([]*fileMatchResolver, *searchResultsCommon, error) {
+			limitsMu.Lock()
+			limits[args.Pattern.Pattern] = args.Pattern.FileMatchLimit
+			limitsMu.Unlock()
+			switch args.Pattern.Pattern {
+			case "foo":
+				return []*fileMatchResolver{fileMatch("a", 1), fileMatch("b", 2)}, &searchResultsCommon{}, nil
+			case "bar":
+				return []*fileMatchResolver{fileMatch("b", 3), fileMatch("c", 4)}, &searchResultsCommon{}, nil
+			case "baz":
+				return []*fileMatchResolver{fileMatch("a", 5)}, &searchResultsCommon{}, nil
+			case `(foo).*?(baz)`:
+				return []*fileMatchResolver{fileMatch("d", 6)}, &searchResultsCommon{}, nil
+			case "qux":
+				return nil, &searchResultsCommon{timedout: []*types.Repo{{Name: "repo"}}}, nil
+			}
+			t.Errorf("unexpected pattern %q", args.Pattern.Pattern)
+			return nil, &searchResultsCommon{}, nil
+		}
+		defer func() { mockSearchFilesInRepos = nil }()
+		mockSearchRepositories = func(args *search.Args) ([]*searchResultResolver, *searchResultsCommon, error) {
+			t.Error("unexpected repository search")
+			return nil, &searchResultsCommon{}, nil
+		}
+		defer func() { mockSearchRepositories = nil }()
+
+		testCallResults(t, `foo AND bar`, []string{"b:2"})
+		testCallResults(t, `foo OR bar`, []string{"a:1", "b:2", "c:4"})
+		testCallResults(t, `foo -baz`, []string{"b:2"})
+		testCallResults(t, `NOT baz AND foo OR bar`, []string{"b:2", "c:4"})
+		testCallResults(t, `foo baz OR (bar AND NOT (foo OR baz))`, []string{"c:4", "d:6"})
+
+		// The leaves whose results are intersected or removed are searched
+		// without a result limit.
+		for query, wantComplete := range map[string][]string{
+			`foo OR bar`:  nil,
+			`foo AND bar`: {"bar", "foo"},
+			`foo -baz`:    {"baz"},
+		} {
+			limits = map[string]int32{}
+			getResults(t, query)
+			var complete []string
+			for pattern, limit := range limits {
+				if limit == math.MaxInt32 {
+					complete = append(complete, pattern)
+				}
+			}
+			sort.Strings(complete)
+			if !reflect.DeepEqual(complete, wantComplete) {
+				t.Errorf("%s: got complete leaves %q, want %q", query, complete, wantComplete)
+			}
+		}
+
+		// The results in repositories where an excluded leaf timed out may
+		// be wrong, so they are dropped.
+		results, err := createSearchResolver(t, `foo -qux`).Results(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results.results) != 0 || !results.limitHit {
+			t.Errorf("got %d results (limitHit=%v), want none and limitHit", len(results.results), results.limitHit)
+		}
+
+		r := createSearchResolver(t, `foo AND bar`)
+		results, err = r.Results(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := results.results[0].fileMatch.JLineMatches; len(got) != 2 || got[0].JLineNumber != 2 || got[1].JLineNumber != 3 {
+			t.Errorf("got line matches %+v, want lines 2 and 3", got)
+		}
+
+		if _, err := createSearchResolver(t, `-foo`).Results(context.Background()); err == nil {
+			t.Error("expected an error for a query which only excludes results")
+		}
+	})
+
+	t.Run("filter alternatives", func(t *testing.T) {
+		db.Mocks.Repos.List = func(_ context.Context, op db.ReposListOptions) ([]*types.Repo, error) {
+			if reflect.DeepEqual(op.IncludePatterns, []string{"a"}) {
+				return []*types.Repo{{ID: 1, Name: "a"}}, nil
+			}
+			return []*types.Repo{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}, nil
+		}
+		db.Mocks.Repos.GetByName = func(_ context.Context, name api.RepoName) (*types.Repo, error) {
+			return &types.Repo{Name: name}, nil
+		}
+		defer func() { db.Mocks = db.MockStores{} }()
+
+		// Each repository has the files x and y, which match on the line
+		// numbered after the repository's ID.
+		mockSearchFilesInRepos = func(args *search.Args) ([]*fileMatchResolver, *searchResultsCommon, error) {
+			var matches []*fileMatchResolver
+			for _, repoRev := range args.Repos {
+			paths:
+				for _, path := range []string{"x", "y"} {
+					for _, pattern := range args.Pattern.IncludePatterns {
+						if ok, _ := regexp.MatchString(pattern, path); !ok {
+							continue paths
+						}
+					}
+					matches = append(matches, &fileMatchResolver{
+						uri:          "git://" + string(repoRev.Repo.Name) + "#" + path,
+						JPath:        path,
+						JLineMatches: []*lineMatch{{JLineNumber: int32(repoRev.Repo.ID)}},
+						repo:         repoRev.Repo,
+					})
+				}
+			}
+			return matches, &searchResultsCommon{}, nil
+		}
+		defer func() { mockSearchFilesInRepos = nil }()
+		mockSearchSymbols = func(ctx context.Context, args *search.Args, limit int) ([]*fileMatchResolver, *searchResultsCommon, error) {
+			return nil, nil, nil
+		}
+		defer func() { mockSearchSymbols = nil }()
+
+		// a/y is matched by both alternatives, but returned once.
+		testCallResults(t, `(repo:a OR file:y) foo type:file`, []string{"x:1", "y:1", "y:2"})
+		testCallResults(t, `repo:a foo OR bar type:file`, []string{"x:1", "y:1"})
 	})
 }
 
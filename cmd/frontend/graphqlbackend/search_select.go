@@ -0,0 +1,192 @@
+package graphqlbackend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+)
+
+// selectResultTypes are the result types which can be projected to each
+// entity type of the select: field, and the result types searched by
+// default.
+var selectResultTypes = map[string]struct {
+	supported map[string]struct{}
+	defaults  []string
+}{
+	query.SelectRepo: {
+		supported: map[string]struct{}{"file": {}, "path": {}, "repo": {}, "symbol": {}, "diff": {}, "commit": {}},
+		defaults:  []string{"file", "path", "repo"},
+	},
+	query.SelectFile: {
+		supported: map[string]struct{}{"file": {}, "path": {}, "symbol": {}},
+		defaults:  []string{"file", "path"},
+	},
+	query.SelectSymbol: {
+		supported: map[string]struct{}{"symbol": {}},
+		defaults:  []string{"symbol"},
+	},
+	query.SelectCommitAuthor: {
+		supported: map[string]struct{}{"diff": {}, "commit": {}},
+		defaults:  []string{"commit"},
+	},
+}
+
+// checkSelectResultTypes returns an error if a result type can't be projected
+// to the entity type selectType.
+func checkSelectResultTypes(selectType string, resultTypes []string) error {
+	for _, resultType := range resultTypes {
+		if _, ok := selectResultTypes[selectType].supported[resultType]; !ok {
+			return fmt.Errorf("type:%s is not supported with select:%s", resultType, selectType)
+		}
+	}
+	return nil
+}
+
+// A resultSelector projects search results to the entity type selected with
+// the select: field (e.g., the repositories of the results with select:repo),
+// and deduplicates them.
+type resultSelector struct {
+	selectType string
+
+	results []*searchResultResolver
+	seen    map[string]struct{}           // the keys of the added entities
+	files   map[string]*fileMatchResolver // projected file matches by repository and path
+	count   int                           // the number of distinct entities
+}
+
+func newResultSelector(selectType string) *resultSelector {
+	return &resultSelector{
+		selectType: selectType,
+		seen:       map[string]struct{}{},
+		files:      map[string]*fileMatchResolver{},
+	}
+}
+
+// markSeen records the entity with the given key, and reports whether it is
+// new.
+func (s *resultSelector) markSeen(key string) bool {
+	if _, ok := s.seen[key]; ok {
+		return false
+	}
+	s.seen[key] = struct{}{}
+	s.count++
+	return true
+}
+
+// add adds the projection of each result, unless its entity was already
+// added. Results which have no projection (e.g., repository matches with
+// select:file) are dropped.
+func (s *resultSelector) add(results ...*searchResultResolver) {
+	for _, result := range results {
+		switch s.selectType {
+		case query.SelectRepo:
+			s.addRepo(result)
+		case query.SelectFile:
+			s.addFile(result)
+		case query.SelectSymbol:
+			s.addSymbols(result)
+		case query.SelectCommitAuthor:
+			s.addCommitAuthor(result)
+		}
+	}
+}
+
+func (s *resultSelector) addRepo(result *searchResultResolver) {
+	var repo *repositoryResolver
+	switch {
+	case result.repo != nil:
+		repo = result.repo
+	case result.fileMatch != nil:
+		repo = &repositoryResolver{repo: result.fileMatch.repo}
+	case result.diff != nil:
+		repo = result.diff.commit.repo
+	default:
+		return
+	}
+	if s.markSeen("repo:" + string(repo.repo.Name)) {
+		s.results = append(s.results, &searchResultResolver{repo: repo})
+	}
+}
+
+// projectedFileMatch returns the file match (without line matches and
+// symbols) for the file of fm, which is created on first use. Files are
+// distinct per path (not revision), so the revisions of a file's matches are
+// merged.
+func (s *resultSelector) projectedFileMatch(fm *fileMatchResolver) *fileMatchResolver {
+	key := string(fm.repo.Name) + "\x00" + fm.JPath
+	if projected, ok := s.files[key]; ok {
+		projected.revs = mergeRevs(projected.revs, fm.revs)
+		return projected
+	}
+	projected := &fileMatchResolver{
+		JPath:        fm.JPath,
+		JLineMatches: []*lineMatch{},
+		symbols:      []*symbolResolver{},
+		uri:          fm.uri,
+		repo:         fm.repo,
+		commitID:     fm.commitID,
+		inputRev:     fm.inputRev,
+		revs:         fm.revs,
+		zoektScore:   fm.zoektScore,
+	}
+	s.files[key] = projected
+	s.results = append(s.results, &searchResultResolver{fileMatch: projected})
+	return projected
+}
+
+func (s *resultSelector) addFile(result *searchResultResolver) {
+	if result.fileMatch == nil {
+		return
+	}
+	projected := s.projectedFileMatch(result.fileMatch)
+	s.markSeen("file:" + string(projected.repo.Name) + "\x00" + projected.JPath)
+}
+
+func (s *resultSelector) addSymbols(result *searchResultResolver) {
+	if result.fileMatch == nil || len(result.fileMatch.symbols) == 0 {
+		return
+	}
+	projected := s.projectedFileMatch(result.fileMatch)
+	for _, sym := range result.fileMatch.symbols {
+		key := "symbol:" + string(projected.repo.Name) + "\x00" + projected.JPath + "\x00" + sym.symbol.Name + "\x00" + sym.symbol.ContainerName + "\x00" + sym.Kind()
+		if s.markSeen(key) {
+			projected.symbols = append(projected.symbols, sym)
+		}
+	}
+}
+
+func (s *resultSelector) addCommitAuthor(result *searchResultResolver) {
+	if result.diff == nil {
+		return
+	}
+	// Authors are identified by their email address, if they have one.
+	author := result.diff.commit.author.person
+	key := "author-email:" + strings.ToLower(author.email)
+	if author.email == "" {
+		key = "author-name:" + author.name
+	}
+	if s.markSeen(key) {
+		s.results = append(s.results, &searchResultResolver{person: author})
+	}
+}
+
+// mergeRevs returns the sorted union of the revisions a and b.
+func mergeRevs(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	seen := make(map[string]struct{}, len(a)+len(b))
+	var merged []string
+	for _, revs := range [][]string{a, b} {
+		for _, rev := range revs {
+			if _, ok := seen[rev]; !ok {
+				seen[rev] = struct{}{}
+				merged = append(merged, rev)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
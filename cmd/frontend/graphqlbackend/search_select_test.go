@@ -0,0 +1,112 @@
+package graphqlbackend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+)
+
+func TestResultSelector(t *testing.T) {
+	repoA := &types.Repo{Name: "a"}
+	repoB := &types.Repo{Name: "b"}
+	fileMatch := func(repo *types.Repo, path string, revs []string, symbols ...string) *searchResultResolver {
+		fm := &fileMatchResolver{
+			JPath:        path,
+			JLineMatches: []*lineMatch{{JPreview: "x"}},
+			repo:         repo,
+			revs:         revs,
+		}
+		for _, name := range symbols {
+			fm.symbols = append(fm.symbols, &symbolResolver{symbol: lsp.SymbolInformation{Name: name, Kind: lsp.SKFunction}})
+		}
+		return &searchResultResolver{fileMatch: fm}
+	}
+	commit := func(repo *types.Repo, name, email string) *searchResultResolver {
+		return &searchResultResolver{diff: &commitSearchResultResolver{commit: &gitCommitResolver{
+			repo:   &repositoryResolver{repo: repo},
+			author: signatureResolver{person: &personResolver{name: name, email: email}},
+		}}}
+	}
+	results := []*searchResultResolver{
+		{repo: &repositoryResolver{repo: repoB}},
+		fileMatch(repoA, "x.go", []string{"v2"}, "f"),
+		fileMatch(repoA, "x.go", []string{"v1"}, "f", "g"),
+		fileMatch(repoA, "y.go", nil),
+		fileMatch(repoB, "x.go", nil, "f"),
+		commit(repoA, "Alice", "alice@example.com"),
+		commit(repoB, "alice", "Alice@example.com"),
+		commit(repoB, "Bob", ""),
+	}
+
+	// describe returns a description of each result, to compare them.
+	describe := func(results []*searchResultResolver) (descs []string) {
+		for _, result := range results {
+			switch {
+			case result.repo != nil:
+				descs = append(descs, "repo "+string(result.repo.repo.Name))
+			case result.fileMatch != nil:
+				fm := result.fileMatch
+				desc := fmt.Sprintf("file %s/%s %v lines=%d", fm.repo.Name, fm.JPath, fm.revs, len(fm.JLineMatches))
+				for _, sym := range fm.symbols {
+					desc += " " + sym.symbol.Name
+				}
+				descs = append(descs, desc)
+			case result.person != nil:
+				descs = append(descs, "person "+result.person.name)
+			}
+		}
+		return descs
+	}
+
+	tests := []struct {
+		selectType string
+		want       []string
+		wantCount  int
+	}{
+		{
+			selectType: query.SelectRepo,
+			want:       []string{"repo b", "repo a"},
+			wantCount:  2,
+		},
+		{
+			selectType: query.SelectFile,
+			want:       []string{"file a/x.go [v1 v2] lines=0", "file a/y.go [] lines=0", "file b/x.go [] lines=0"},
+			wantCount:  3,
+		},
+		{
+			selectType: query.SelectSymbol,
+			want:       []string{"file a/x.go [v1 v2] lines=0 f g", "file b/x.go [] lines=0 f"},
+			wantCount:  3,
+		},
+		{
+			selectType: query.SelectCommitAuthor,
+			want:       []string{"person Alice", "person Bob"},
+			wantCount:  2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.selectType, func(t *testing.T) {
+			selector := newResultSelector(test.selectType)
+			selector.add(results...)
+			if got := describe(selector.results); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got  %q\nwant %q", got, test.want)
+			}
+			if selector.count != test.wantCount {
+				t.Errorf("got count %d, want %d", selector.count, test.wantCount)
+			}
+		})
+	}
+}
+
+func TestCheckSelectResultTypes(t *testing.T) {
+	if err := checkSelectResultTypes(query.SelectFile, []string{"file", "symbol"}); err != nil {
+		t.Errorf("got error %q, want none", err)
+	}
+	if err := checkSelectResultTypes(query.SelectSymbol, []string{"symbol", "file"}); err == nil {
+		t.Error("got no error for type:file with select:symbol")
+	}
+}
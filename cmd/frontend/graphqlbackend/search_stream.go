@@ -0,0 +1,145 @@
+package graphqlbackend
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+// SearchStreamFileMatch is a file which matches a streamed search query.
+type SearchStreamFileMatch struct {
+	Repo api.RepoName `json:"repository"`
+	// Commit is empty for the matches in the default branch of indexed
+	// repositories.
+	Commit      api.CommitID            `json:"commit,omitempty"`
+	Path        string                  `json:"path"`
+	LineMatches []SearchStreamLineMatch `json:"lineMatches"`
+	// LimitHit is whether the file has more matching lines than
+	// LineMatches.
+	LimitHit bool `json:"limitHit,omitempty"`
+}
+
+// SearchStreamLineMatch is a line of a SearchStreamFileMatch which matches
+// the query.
+type SearchStreamLineMatch struct {
+	// LineNumber is the 1-based number of the line in the file.
+	LineNumber       int32      `json:"lineNumber"`
+	Preview          string     `json:"preview"`
+	OffsetAndLengths [][2]int32 `json:"offsetAndLengths"`
+}
+
+// SearchStreamStats describes a streamed search once it is done.
+type SearchStreamStats struct {
+	// LimitHit is whether there are more matches than were sent.
+	LimitHit bool           `json:"limitHit"`
+	Cloning  []api.RepoName `json:"cloning"`
+	Missing  []api.RepoName `json:"missing"`
+	Timedout []api.RepoName `json:"timedout"`
+	// Alert explains why there are no results (e.g., the query matches no
+	// repositories), if there are none.
+	Alert string `json:"alert,omitempty"`
+}
+
+// StreamSearch searches for the files which match rawQuery, like the search
+// GraphQL API (with the same limits), and calls send with the file matches as
+// soon as searcher or Zoekt find them, before the search is done. send is
+// not called concurrently. The matches are sent in the order in which they
+// are found, not ranked.
+//
+// Only file content and path searches (type:file and type:path) can be
+// streamed. Queries whose results must be combined before any of them are
+// known (boolean queries, repo: or file: filters combined with OR, and
+// select:) are not supported.
+func StreamSearch(ctx context.Context, rawQuery string, send func([]*SearchStreamFileMatch)) (*SearchStreamStats, error) {
+	r, err := (&schemaResolver{}).Search(&struct{ Query string }{Query: rawQuery})
+	if err != nil {
+		return nil, &badRequestError{err}
+	}
+	resultType := "file"
+	if resultTypes, _ := r.query.StringValues(query.FieldType); len(resultTypes) > 1 || (len(resultTypes) == 1 && resultTypes[0] != "file" && resultTypes[0] != "path") {
+		return nil, &badRequestError{errors.New("only file content and path searches (type:file and type:path) can be streamed")}
+	} else if len(resultTypes) == 1 {
+		resultType = resultTypes[0]
+	}
+	if selects, _ := r.query.StringValues(query.FieldSelect); len(selects) > 0 {
+		return nil, &badRequestError{errors.New("searches with select: can't be streamed")}
+	}
+	if r.query.IsBoolean() || len(r.query.FilterBranches()) > 0 {
+		return nil, &badRequestError{errors.New("searches with AND, OR or negated terms can't be streamed")}
+	}
+
+	var (
+		mu       sync.Mutex
+		sent     int
+		limit    = int(r.maxResults())
+		limitHit bool
+		done     bool
+	)
+	sendFileMatches := func(fms []*fileMatchResolver) {
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			// Don't send anything after StreamSearch returned.
+			return
+		}
+		var matches []*SearchStreamFileMatch
+		for _, fm := range fms {
+			if sent >= limit {
+				// searchFilesInRepos drops the matches past the limit, too.
+				limitHit = true
+				break
+			}
+			sent++
+			matches = append(matches, toSearchStreamFileMatch(fm))
+		}
+		if len(matches) > 0 {
+			send(matches)
+		}
+	}
+
+	rr, err := r.doResults(withFileMatchSender(ctx, sendFileMatches), resultType)
+	mu.Lock()
+	defer mu.Unlock()
+	done = true
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &SearchStreamStats{LimitHit: limitHit || rr.LimitHit()}
+	for _, repo := range rr.cloning {
+		stats.Cloning = append(stats.Cloning, repo.Name)
+	}
+	for _, repo := range rr.missing {
+		stats.Missing = append(stats.Missing, repo.Name)
+	}
+	for _, repo := range rr.timedout {
+		stats.Timedout = append(stats.Timedout, repo.Name)
+	}
+	if rr.alert != nil && len(rr.results) == 0 {
+		stats.Alert = rr.alert.title
+	}
+	return stats, nil
+}
+
+func toSearchStreamFileMatch(fm *fileMatchResolver) *SearchStreamFileMatch {
+	m := &SearchStreamFileMatch{
+		Commit:      fm.commitID,
+		Path:        fm.JPath,
+		LineMatches: make([]SearchStreamLineMatch, len(fm.JLineMatches)),
+		LimitHit:    fm.JLimitHit,
+	}
+	if fm.repo != nil {
+		m.Repo = fm.repo.Name
+	}
+	for i, lm := range fm.JLineMatches {
+		m.LineMatches[i] = SearchStreamLineMatch{
+			LineNumber:       lm.JLineNumber + 1,
+			Preview:          lm.JPreview,
+			OffsetAndLengths: lm.JOffsetAndLengths,
+		}
+	}
+	return m
+}
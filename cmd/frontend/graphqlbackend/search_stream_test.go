@@ -0,0 +1,68 @@
+package graphqlbackend
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+func TestStreamSearch(t *testing.T) {
+	db.Mocks.Repos.List = func(context.Context, db.ReposListOptions) ([]*types.Repo, error) {
+		return []*types.Repo{{ID: 1, Name: "r1"}, {ID: 2, Name: "r2"}}, nil
+	}
+	defer func() { db.Mocks = db.MockStores{} }()
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		return "c1", nil
+	}
+	defer git.ResetMocks()
+	mockSearchRanking = func() (*searchRanking, error) { return newSearchRanking(nil), nil }
+	defer func() { mockSearchRanking = nil }()
+
+	// searcher streams the matches in r1, and r2 is still being cloned.
+	mockSearchFilesInRepo = func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) ([]*fileMatchResolver, bool, error) {
+		if repo.Name == "r2" {
+			return nil, false, &vcs.RepoNotExistError{Repo: repo.Name, CloneInProgress: true}
+		}
+		var matches []*fileMatchResolver
+		for _, path := range []string{"a", "b", "c"} {
+			fm := &fileMatchResolver{repo: repo, commitID: "c1", JPath: path, uri: "git://r1#" + path, JLineMatches: []*lineMatch{{JLineNumber: 0, JPreview: "foo"}}}
+			fileMatchSenderFromContext(ctx)([]*fileMatchResolver{fm})
+			matches = append(matches, fm)
+		}
+		return matches, false, nil
+	}
+	defer func() { mockSearchFilesInRepo = nil }()
+
+	var sent []*SearchStreamFileMatch
+	stats, err := StreamSearch(context.Background(), "foo count:2", func(matches []*SearchStreamFileMatch) {
+		sent = append(sent, matches...)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*SearchStreamFileMatch{
+		{Repo: "r1", Commit: "c1", Path: "a", LineMatches: []SearchStreamLineMatch{{LineNumber: 1, Preview: "foo"}}},
+		{Repo: "r1", Commit: "c1", Path: "b", LineMatches: []SearchStreamLineMatch{{LineNumber: 1, Preview: "foo"}}},
+	}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("got sent %+v, want %+v", sent, want)
+	}
+	if want := (&SearchStreamStats{LimitHit: true, Cloning: []api.RepoName{"r2"}}); !reflect.DeepEqual(stats, want) {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+
+	for _, q := range []string{"type:diff foo", "select:repo foo", "foo AND bar", "(repo:a OR file:b) foo"} {
+		if _, err := StreamSearch(context.Background(), q, func([]*SearchStreamFileMatch) {}); err == nil {
+			t.Errorf("%s: got no error", q)
+		}
+	}
+}
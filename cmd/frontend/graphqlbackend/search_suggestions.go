@@ -103,11 +103,19 @@ func (r *searchResolver) Suggestions(ctx context.Context, args *searchSuggestion
 		if err != nil {
 			return nil, err
 		}
+		if p.IsStructuralPat {
+			// Symbol names can't be matched with a structural pattern.
+			return nil, nil
+		}
 
 		ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 		defer cancel()
 
-		fileMatches, _, err := searchSymbols(ctx, &search.Args{Pattern: p, Repos: repoRevs, Query: r.query}, 7)
+		// Match plain words fuzzily, since users often type abbreviations of
+		// the symbol they are looking for (e.g. "NRC" for "NewRepoCache").
+		fuzzy := p.Pattern != "" && regexp.QuoteMeta(p.Pattern) == p.Pattern
+
+		fileMatches, _, err := searchSymbols(ctx, &search.Args{Pattern: p, Repos: repoRevs, Query: r.query, FuzzySymbols: fuzzy}, 7)
 		if err != nil {
 			return nil, err
 		}
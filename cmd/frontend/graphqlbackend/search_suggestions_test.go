@@ -17,6 +17,9 @@ import (
 func TestSearchSuggestions(t *testing.T) {
 	limitOffset := &db.LimitOffset{Limit: maxReposToSearch() + 1}
 
+	mockSearchRanking = func() (*searchRanking, error) { return newSearchRanking(nil), nil }
+	defer func() { mockSearchRanking = nil }()
+
 	createSearchResolver := func(t *testing.T, query string) *searchResolver {
 		t.Helper()
 		r, err := (&schemaResolver{}).Search(&struct{ Query string }{Query: query})
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
 
@@ -66,7 +67,7 @@ func searchSymbols(ctx context.Context, args *search.Args, limit int) (res []*fi
 		run.Acquire()
 		goroutine.Go(func() {
 			defer run.Release()
-			repoSymbols, repoErr := searchSymbolsInRepo(ctx, repoRevs, args.Pattern, args.Query, limit)
+			repoSymbols, repoErr := searchSymbolsInRepo(ctx, repoRevs, args.Pattern, args.Query, args.FuzzySymbols, limit)
 			if repoErr != nil {
 				tr.LogFields(otlog.String("repo", string(repoRevs.Repo.Name)), otlog.String("repoErr", repoErr.Error()), otlog.Bool("timeout", errcode.IsTimeout(repoErr)), otlog.Bool("temporary", errcode.IsTemporary(repoErr)))
 			}
@@ -92,6 +93,11 @@ func searchSymbols(ctx context.Context, args *search.Args, limit int) (res []*fi
 	}
 	err = run.Wait()
 
+	// The symbols of each repository are ranked by the symbols service, and
+	// their scores are comparable across repositories. Merge them by rank,
+	// so the best symbols are kept when we truncate to limit.
+	sort.SliceStable(res, func(i, j int) bool { return maxSymbolScore(res[i]) > maxSymbolScore(res[j]) })
+
 	if len(res) > limit {
 		common.limitHit = true
 		res = res[:limit]
@@ -99,7 +105,18 @@ func searchSymbols(ctx context.Context, args *search.Args, limit int) (res []*fi
 	return res, common, err
 }
 
-func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisions, patternInfo *search.PatternInfo, query *query.Query, limit int) (res []*fileMatchResolver, err error) {
+// maxSymbolScore returns the score of the best ranked symbol in fm.
+func maxSymbolScore(fm *fileMatchResolver) float64 {
+	var max float64
+	for i, s := range fm.symbols {
+		if i == 0 || s.score > max {
+			max = s.score
+		}
+	}
+	return max
+}
+
+func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisions, patternInfo *search.PatternInfo, query *query.Query, fuzzy bool, limit int) (res []*fileMatchResolver, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Search symbols in repo")
 	defer func() {
 		if err != nil {
@@ -126,6 +143,10 @@ func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisio
 		return nil, err
 	}
 
+	var matchMode protocol.MatchMode
+	if fuzzy {
+		matchMode = protocol.MatchFuzzy
+	}
 	symbols, err := backend.Symbols.ListTags(ctx, protocol.SearchArgs{
 		Repo:            repoRevs.Repo.Name,
 		CommitID:        commitID,
@@ -135,6 +156,7 @@ func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisio
 		IncludePatterns: patternInfo.IncludePatterns,
 		ExcludePattern:  patternInfo.ExcludePattern,
 		First:           limit,
+		MatchMode:       matchMode,
 	})
 	fileMatchesByURI := make(map[string]*fileMatchResolver)
 	fileMatches := make([]*fileMatchResolver, 0)
@@ -149,6 +171,7 @@ func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisio
 			commit.inputRev = &inputRev
 		}
 		symbolRes := toSymbolResolver(symbolToLSPSymbolInformation(symbol, baseURI), strings.ToLower(symbol.Language), commit)
+		symbolRes.score = symbol.Score
 		uri := makeFileMatchURIFromSymbol(symbolRes, inputRev)
 		if fileMatch, ok := fileMatchesByURI[uri]; ok {
 			fileMatch.symbols = append(fileMatch.symbols, symbolRes)
@@ -1,5 +1,14 @@
 package graphqlbackend
 
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+)
+
 func testStringResult(result *searchSuggestionResolver) string {
 	var name string
 	switch r := result.result.(type) {
@@ -15,3 +24,15 @@ func testStringResult(result *searchSuggestionResolver) string {
 	}
 	return name
 }
+
+func TestHandleRepoSearchResult_quotaExceeded(t *testing.T) {
+	repo := &types.Repo{Name: "r"}
+	common := &searchResultsCommon{}
+	err := errors.Wrap(&gitserver.RepoQuotaExceededErr{Repo: repo.Name, Reason: "too large"}, "search")
+	if fatalErr := handleRepoSearchResult(common, search.RepositoryRevisions{Repo: repo}, false, false, err); fatalErr != nil {
+		t.Fatalf("got fatal error %v, want the repository to be skipped", fatalErr)
+	}
+	if len(common.missing) != 1 || common.missing[0] != repo {
+		t.Errorf("got missing %v, want [r]", common.missing)
+	}
+}
@@ -122,6 +122,9 @@ type symbolResolver struct {
 	symbol   lsp.SymbolInformation
 	language string
 	location *locationResolver
+
+	// score is the rank of the symbol as a search result, higher is better.
+	score float64
 }
 
 func (r *symbolResolver) Name() string { return r.symbol.Name }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -61,6 +62,7 @@ type fileMatchResolver struct {
 	JPath        string       `json:"Path"`
 	JLineMatches []*lineMatch `json:"LineMatches"`
 	JLimitHit    bool         `json:"LimitHit"`
+	JRemoved     bool         `json:"Removed"` // only set for diff searches, see textSearch
 	symbols      []*symbolResolver
 	uri          string
 	repo         *types.Repo
@@ -70,6 +72,23 @@ type fileMatchResolver struct {
 	// preserve the original revision specifier from the user instead of navigating them to the
 	// absolute commit ID when they select a result.
 	inputRev *string
+
+	// revs are the revisions which contain the file with the same contents
+	// (so the same matches), if multiple revisions of the repository were
+	// searched (see searchFilesInRepoRevs).
+	revs []string
+
+	// zoektScore is the score which Zoekt assigned to the match, or 0 if the
+	// file was not searched with Zoekt.
+	zoektScore float64
+
+	// symbolHits is the number of symbols in the file which match the query,
+	// which is looked up for ranking (see doResults).
+	symbolHits int
+
+	// score is the ranking score of the match (see rankResults), or nil if
+	// the results are not ranked.
+	score *float64
 }
 
 func (fm *fileMatchResolver) Key() string {
@@ -96,6 +115,20 @@ func (fm *fileMatchResolver) Resource() string {
 	return fm.uri
 }
 
+func (fm *fileMatchResolver) Revisions() []string {
+	if fm.revs != nil {
+		return fm.revs
+	}
+	if fm.inputRev != nil && *fm.inputRev != "" {
+		return []string{*fm.inputRev}
+	}
+	return []string{}
+}
+
+func (fm *fileMatchResolver) Score() *float64 {
+	return fm.score
+}
+
 func (fm *fileMatchResolver) Symbols() []*symbolResolver {
 	return fm.symbols
 }
@@ -136,9 +169,34 @@ func (lm *lineMatch) LimitHit() bool {
 	return lm.JLimitHit
 }
 
-// textSearch searches repo@commit with p.
+// fileMatchSender receives file matches from searchFilesInRepos as soon as
+// they are found, before the full result set has been collected. It may be
+// called concurrently. Matches sent to it may be dropped from the final result
+// set returned by searchFilesInRepos if the result limit is hit.
+type fileMatchSender func(matches []*fileMatchResolver)
+
+type fileMatchSenderKey struct{}
+
+// withFileMatchSender returns a context which makes searchFilesInRepos stream
+// results from searcher and forward them to send as they arrive. If send is
+// nil, results are not forwarded.
+func withFileMatchSender(ctx context.Context, send fileMatchSender) context.Context {
+	return context.WithValue(ctx, fileMatchSenderKey{}, send)
+}
+
+// fileMatchSenderFromContext returns the fileMatchSender set on ctx by
+// withFileMatchSender, or nil if there is none.
+func fileMatchSenderFromContext(ctx context.Context) fileMatchSender {
+	send, _ := ctx.Value(fileMatchSenderKey{}).(fileMatchSender)
+	return send
+}
+
+// textSearch searches repo@commit with p. If onMatch is non-nil, searcher is
+// asked to stream its results and onMatch is called with each match as soon
+// as it is received. If baseCommit is non-empty, only the matches added or
+// removed since baseCommit are returned (see protocol.Request.BaseCommit).
 // Note: the returned matches do not set fileMatch.uri
-func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error) {
+func textSearch(ctx context.Context, repo gitserver.Repo, commit, baseCommit api.CommitID, p *search.PatternInfo, fetchTimeout time.Duration, onMatch func(*fileMatchResolver)) (matches []*fileMatchResolver, limitHit bool, err error) {
 	if searcherURLs == nil {
 		return nil, false, errors.New("a searcher service has not been configured")
 	}
@@ -165,12 +223,16 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 		"Repo":            []string{string(repo.Name)},
 		"URL":             []string{repo.URL},
 		"Commit":          []string{string(commit)},
+		"BaseCommit":      []string{string(baseCommit)},
 		"Pattern":         []string{p.Pattern},
 		"ExcludePattern":  []string{p.ExcludePattern},
 		"IncludePatterns": includePatterns,
 		"IncludePattern":  []string{p.IncludePattern},
 		"FetchTimeout":    []string{fetchTimeout.String()},
 	}
+	if len(p.Paths) > 0 {
+		q["Paths"] = p.Paths
+	}
 	if deadline, ok := ctx.Deadline(); ok {
 		t, err := deadline.MarshalText()
 		if err != nil {
@@ -182,6 +244,9 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	if p.IsRegExp {
 		q.Set("IsRegExp", "true")
 	}
+	if p.IsStructuralPat {
+		q.Set("IsStructuralPat", "true")
+	}
 	if p.IsWordMatch {
 		q.Set("IsWordMatch", "true")
 	}
@@ -198,6 +263,9 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	// these fields from old frontends that do not (and provide a default in the latter case).
 	q.Set("PatternMatchesContent", strconv.FormatBool(p.PatternMatchesContent))
 	q.Set("PatternMatchesPath", strconv.FormatBool(p.PatternMatchesPath))
+	if onMatch != nil {
+		q.Set("Stream", "true")
+	}
 	rawQuery := q.Encode()
 
 	// Searcher caches the file contents for repo@commit since it is
@@ -231,7 +299,7 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 
 		url := searcherURL + "?" + rawQuery
 		tr.LazyPrintf("attempt %d: %s", attempt, url)
-		matches, limitHit, err = textSearchURL(ctx, url)
+		matches, limitHit, err = textSearchURL(ctx, url, onMatch)
 		// Useful trace for debugging:
 		//
 		// tr.LazyPrintf("%d matches, limitHit=%v, err=%v, ctx.Err()=%v", len(matches), limitHit, err, ctx.Err())
@@ -239,6 +307,11 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 			return matches, limitHit, err
 		}
 
+		// Retrying would send results we have already streamed again.
+		if len(matches) > 0 {
+			return matches, limitHit, err
+		}
+
 		// If we are canceled, return that error.
 		if err := ctx.Err(); err != nil {
 			return nil, false, err
@@ -255,7 +328,12 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	}
 }
 
-func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool, error) {
+// textSearchURL requests a search from searcher at url. If onMatch is
+// non-nil, url must request a streaming response (see
+// protocol.Request.Stream), and onMatch is called with each match as it
+// arrives. If the search fails after some matches have been received, those
+// matches are returned along with the error.
+func textSearchURL(ctx context.Context, url string, onMatch func(*fileMatchResolver)) ([]*fileMatchResolver, bool, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, false, err
@@ -291,6 +369,10 @@ func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool,
 		return nil, false, errors.WithStack(&searcherError{StatusCode: resp.StatusCode, Message: string(body)})
 	}
 
+	if onMatch != nil {
+		return decodeTextSearchStream(ctx, resp.Body, onMatch)
+	}
+
 	r := struct {
 		Matches     []*fileMatchResolver
 		LimitHit    bool
@@ -306,6 +388,43 @@ func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool,
 	return r.Matches, r.LimitHit, err
 }
 
+// decodeTextSearchStream decodes a streaming response of searcher (a sequence
+// of protocol.StreamEvents), and calls onMatch with each match.
+func decodeTextSearchStream(ctx context.Context, body io.Reader, onMatch func(*fileMatchResolver)) (matches []*fileMatchResolver, limitHit bool, err error) {
+	dec := json.NewDecoder(body)
+	for {
+		var ev struct {
+			Match       *fileMatchResolver
+			Done        bool
+			LimitHit    bool
+			DeadlineHit bool
+			Error       string
+		}
+		if err := dec.Decode(&ev); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return matches, false, errors.Wrap(err, "searcher response invalid")
+		}
+		if ev.Done {
+			if ev.Error != "" {
+				return matches, ev.LimitHit, errors.WithStack(&searcherError{StatusCode: http.StatusInternalServerError, Message: ev.Error})
+			}
+			if ev.DeadlineHit {
+				err = context.DeadlineExceeded
+			}
+			return matches, ev.LimitHit, err
+		}
+		if ev.Match != nil {
+			matches = append(matches, ev.Match)
+			onMatch(ev.Match)
+		}
+	}
+}
+
 type searcherError struct {
 	StatusCode int
 	Message    string
@@ -339,25 +458,42 @@ func searchFilesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gits
 		return nil, false, err
 	}
 
-	matches, limitHit, err = textSearch(ctx, gitserverRepo, commit, info, fetchTimeout)
-
 	var workspace string
 	if rev != "" {
 		workspace = "git://" + string(repo.Name) + "?" + url.QueryEscape(rev) + "#"
 	} else {
 		workspace = "git://" + string(repo.Name) + "#"
 	}
-	for _, fm := range matches {
+	setFields := func(fm *fileMatchResolver) {
 		fm.uri = workspace + fm.JPath
 		fm.repo = repo
 		fm.commitID = commit
 		fm.inputRev = &rev
 	}
 
+	var onMatch func(*fileMatchResolver)
+	if send := fileMatchSenderFromContext(ctx); send != nil {
+		onMatch = func(fm *fileMatchResolver) {
+			setFields(fm)
+			send([]*fileMatchResolver{fm})
+		}
+	}
+
+	matches, limitHit, err = textSearch(ctx, gitserverRepo, commit, "", info, fetchTimeout, onMatch)
+	for _, fm := range matches {
+		setFields(fm)
+	}
+
 	return matches, limitHit, err
 }
 
-func zoektSearchHEAD(ctx context.Context, query *search.PatternInfo, repos []*search.RepositoryRevisions, useFullDeadline bool) (fm []*fileMatchResolver, limitHit bool, reposLimitHit map[string]struct{}, err error) {
+// zoektSearchHEAD searches the default branch of the indexed repos. It returns
+// at most fileMatchLimit file matches, which may be more than
+// query.FileMatchLimit (on which the time budget of the search is based) when
+// the matches are ranked afterwards. If repoFileMatchLimit is greater than
+// 0, only the first repoFileMatchLimit file matches of each repo are kept
+// (before applying fileMatchLimit).
+func zoektSearchHEAD(ctx context.Context, query *search.PatternInfo, repos []*search.RepositoryRevisions, useFullDeadline bool, fileMatchLimit, repoFileMatchLimit int) (fm []*fileMatchResolver, limitHit bool, reposLimitHit map[string]struct{}, err error) {
 	if len(repos) == 0 {
 		return nil, false, nil, nil
 	}
@@ -424,6 +560,9 @@ func zoektSearchHEAD(ctx context.Context, query *search.PatternInfo, repos []*se
 	if searchOpts.MaxDocDisplayCount < 2000 {
 		searchOpts.MaxDocDisplayCount = 2000
 	}
+	if searchOpts.MaxDocDisplayCount < fileMatchLimit {
+		searchOpts.MaxDocDisplayCount = fileMatchLimit
+	}
 
 	if userProbablyWantsToWaitLonger := query.FileMatchLimit > defaultMaxSearchResults; userProbablyWantsToWaitLonger {
 		searchOpts.MaxWallTime *= time.Duration(3 * float64(query.FileMatchLimit) / float64(defaultMaxSearchResults))
@@ -476,13 +615,19 @@ func zoektSearchHEAD(ctx context.Context, query *search.PatternInfo, repos []*se
 		return nil, false, nil, nil
 	}
 
+	if repoFileMatchLimit > 0 {
+		// The other matches of a repository are not needed, so they must not
+		// count towards fileMatchLimit.
+		resp.Files = limitFilesPerRepo(resp.Files, repoFileMatchLimit)
+	}
+
 	maxLineMatches := 25 + k
 	maxLineFragmentMatches := 3 + k
-	if len(resp.Files) > int(query.FileMatchLimit) {
+	if len(resp.Files) > fileMatchLimit {
 		// List of files we cut out from the Zoekt response because they exceed the file match limit on the Sourcegraph end.
 		// We use this to get a list of repositories that do not have complete results.
-		fileMatchesInSkippedRepos := resp.Files[int(query.FileMatchLimit):]
-		resp.Files = resp.Files[:int(query.FileMatchLimit)]
+		fileMatchesInSkippedRepos := resp.Files[fileMatchLimit:]
+		resp.Files = resp.Files[:fileMatchLimit]
 
 		if !limitHit {
 			// Zoekt evaluated all files and repositories, but Zoekt returned more file matches
@@ -532,12 +677,27 @@ func zoektSearchHEAD(ctx context.Context, query *search.PatternInfo, repos []*se
 			uri:          fmt.Sprintf("git://%s#%s", file.Repository, file.FileName),
 			repo:         repoMap[api.RepoName(strings.ToLower(string(file.Repository)))],
 			commitID:     "", // zoekt only searches default branch
+			zoektScore:   file.Score,
 		}
 	}
 
 	return matches, limitHit, reposLimitHit, nil
 }
 
+// limitFilesPerRepo returns the first limit file matches of each repository
+// in files.
+func limitFilesPerRepo(files []zoekt.FileMatch, limit int) []zoekt.FileMatch {
+	counts := map[string]int{}
+	var limited []zoekt.FileMatch
+	for _, file := range files {
+		if counts[file.Repository] < limit {
+			counts[file.Repository]++
+			limited = append(limited, file)
+		}
+	}
+	return limited
+}
+
 func noOpAnyChar(re *syntax.Regexp) {
 	if re.Op == syntax.OpAnyChar {
 		re.Op = syntax.OpAnyCharNotNL
@@ -624,13 +784,13 @@ func zoektIndexedRepos(ctx context.Context, repos []*search.RepositoryRevisions)
 	}
 	for _, repoRev := range repos {
 		// We search HEAD using zoekt
-		if revspecs := repoRev.RevSpecs(); len(revspecs) > 0 {
-			// TODO(sqs): search all revspecs
-			if revspecs[0] == "" {
-				indexed = append(indexed, repoRev)
-			} else {
-				unindexed = append(unindexed, repoRev)
-			}
+		if len(repoRev.Revs) == 0 {
+			continue
+		}
+		if len(repoRev.Revs) == 1 && repoRev.Revs[0] == (search.RevisionSpecifier{}) {
+			indexed = append(indexed, repoRev)
+		} else {
+			unindexed = append(unindexed, repoRev)
 		}
 	}
 
@@ -731,6 +891,15 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		}
 	}
 
+	if args.Pattern.IsStructuralPat && len(zoektRepos) > 0 {
+		// Indexed search does not support structural patterns.
+		tr.LazyPrintf("structural pattern, bypassing zoekt (using searcher) for %d indexed repos", len(zoektRepos))
+		searcherRepos = append(searcherRepos, zoektRepos...)
+		zoektRepos = nil
+	}
+
+	fileMatchLimit := searchFileMatchLimit(args)
+
 	var (
 		wg                sync.WaitGroup
 		mu                sync.Mutex
@@ -753,8 +922,8 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 			// Stop searching once we have found enough matches. This does
 			// lead to potentially unstable result ordering, but is worth
 			// it for the performance benefit.
-			if flattenedSize > int(args.Pattern.FileMatchLimit) {
-				tr.LazyPrintf("cancel due to result size: %d > %d", flattenedSize, args.Pattern.FileMatchLimit)
+			if flattenedSize > int(fileMatchLimit) {
+				tr.LazyPrintf("cancel due to result size: %d > %d", flattenedSize, fileMatchLimit)
 				overLimitCanceled = true
 				common.limitHit = true
 				cancel()
@@ -778,19 +947,50 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		fetchTimeout = 500 * time.Millisecond
 	}
 
+	// With select:repo, a repository's answer is complete once it has a file
+	// match, so only the first file match in each repository is needed. This
+	// doesn't hold for boolean queries, whose matches are combined per file.
+	var selectRepo bool
+	if args.Query != nil && !args.Query.IsBoolean() {
+		selectType, _ := args.Query.Select()
+		selectRepo = selectType == query.SelectRepo
+	}
+	repoPattern := args.Pattern
+	var zoektRepoFileMatchLimit int
+	if selectRepo {
+		p := *args.Pattern
+		p.FileMatchLimit = 1
+		repoPattern = &p
+		zoektRepoFileMatchLimit = 1
+	} else if fileMatchLimit != args.Pattern.FileMatchLimit {
+		p := *args.Pattern
+		p.FileMatchLimit = fileMatchLimit
+		repoPattern = &p
+	}
+
 	for _, repoRev := range searcherRepos {
 		if len(repoRev.Revs) == 0 {
 			continue
 		}
-		if len(repoRev.Revs) >= 2 {
-			return nil, common, errMultipleRevsNotSupported
-		}
 
 		wg.Add(1)
 		go func(repoRev search.RepositoryRevisions) {
 			defer wg.Done()
-			rev := repoRev.RevSpecs()[0] // TODO(sqs): search multiple revs
-			matches, repoLimitHit, searchErr := searchFilesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo(), rev, args.Pattern, fetchTimeout)
+			var (
+				matches      []*fileMatchResolver
+				repoLimitHit bool
+				searchErr    error
+			)
+			if hasMultipleRevs(&repoRev) {
+				matches, repoLimitHit, searchErr = searchFilesInRepoRevs(ctx, repoRev, repoPattern, fetchTimeout)
+			} else {
+				rev := repoRev.RevSpecs()[0]
+				matches, repoLimitHit, searchErr = searchFilesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo(), rev, repoPattern, fetchTimeout)
+			}
+			if selectRepo {
+				// The repository's other matches are not needed.
+				repoLimitHit = false
+			}
 			if searchErr != nil {
 				tr.LogFields(otlog.String("repo", string(repoRev.Repo.Name)), otlog.String("searchErr", searchErr.Error()), otlog.Bool("timeout", errcode.IsTimeout(searchErr)), otlog.Bool("temporary", errcode.IsTemporary(searchErr)))
 			}
@@ -825,7 +1025,7 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 	go func() {
 		// TODO limitHit, handleRepoSearchResult
 		defer wg.Done()
-		matches, limitHit, reposLimitHit, searchErr := zoektSearchHEAD(ctx, args.Pattern, zoektRepos, args.UseFullDeadline)
+		matches, limitHit, reposLimitHit, searchErr := zoektSearchHEAD(ctx, args.Pattern, zoektRepos, args.UseFullDeadline, int(fileMatchLimit), zoektRepoFileMatchLimit)
 		mu.Lock()
 		defer mu.Unlock()
 		if ctx.Err() == nil {
@@ -849,6 +1049,10 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 			tr.LazyPrintf("cancel indexed search due to error: %v", err)
 			cancel()
 		}
+		if send := fileMatchSenderFromContext(ctx); send != nil && len(matches) > 0 {
+			// Indexed search does not stream, so send all its matches at once.
+			send(matches)
+		}
 		addMatches(matches)
 	}()
 
@@ -857,10 +1061,19 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		return nil, common, err
 	}
 
-	flattened := flattenFileMatches(unflattened, int(args.Pattern.FileMatchLimit))
+	flattened := flattenFileMatches(unflattened, int(fileMatchLimit))
 	return flattened, common, nil
 }
 
+// searchFileMatchLimit returns the number of file matches which
+// searchFilesInRepos returns for args.
+func searchFileMatchLimit(args *search.Args) int32 {
+	if args.RankingFileMatchLimit > args.Pattern.FileMatchLimit {
+		return args.RankingFileMatchLimit
+	}
+	return args.Pattern.FileMatchLimit
+}
+
 func flattenFileMatches(unflattened [][]*fileMatchResolver, fileMatchLimit int) []*fileMatchResolver {
 	// Return early so we don't have to worry about empty lists in later
 	// calculations.
@@ -0,0 +1,238 @@
+package graphqlbackend
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neelance/parallel"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/trace"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+// maxRestrictedPaths is the maximum number of paths to which the search of a
+// commit is restricted, to skip the files already searched in other commits.
+// Searcher then only fetches and searches those files. A commit with more new
+// files is searched in full (and the matches in the other files are
+// discarded), so that the searcher request stays small.
+const maxRestrictedPaths = 1000
+
+// hasMultipleRevs reports whether repoRev specifies multiple revisions to
+// search, either explicitly or with ref globs.
+func hasMultipleRevs(repoRev *search.RepositoryRevisions) bool {
+	if len(repoRev.Revs) >= 2 {
+		return true
+	}
+	for _, rev := range repoRev.Revs {
+		if rev.RefGlob != "" || rev.ExcludeRefGlob != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// A resolvedRev is a revision of a repository and the commit it points to.
+type resolvedRev struct {
+	rev    string
+	commit api.CommitID
+}
+
+// resolveRevs returns the revisions which repoRev specifies, with ref globs
+// expanded to the full names of the matching refs (e.g.,
+// "refs/heads/master"). Revspecs which exclude commits (e.g., "^master") only
+// apply to commit searches, so they are ignored.
+func resolveRevs(ctx context.Context, repoRev search.RepositoryRevisions) ([]resolvedRev, error) {
+	var (
+		revs     []resolvedRev
+		seen     = map[string]bool{}
+		hasGlobs bool
+	)
+	for _, rev := range repoRev.Revs {
+		if rev.RefGlob != "" {
+			hasGlobs = true
+		}
+		if rev.RefGlob != "" || rev.ExcludeRefGlob != "" || strings.HasPrefix(rev.RevSpec, "^") || seen[rev.RevSpec] {
+			continue
+		}
+		seen[rev.RevSpec] = true
+		commit, err := git.ResolveRevision(ctx, repoRev.GitserverRepo(), nil, rev.RevSpec, nil)
+		if err != nil {
+			return nil, err
+		}
+		revs = append(revs, resolvedRev{rev: rev.RevSpec, commit: commit})
+	}
+
+	if hasGlobs {
+		globs, err := search.CompileRefGlobs(repoRev.Revs)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := git.ListRefs(ctx, repoRev.GitserverRepo())
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			if globs.Match(ref.Name) && !seen[ref.Name] {
+				seen[ref.Name] = true
+				revs = append(revs, resolvedRev{rev: ref.Name, commit: ref.CommitID})
+			}
+		}
+	}
+	return revs, nil
+}
+
+// A blobKey identifies the contents of a file at a path.
+type blobKey struct {
+	path string
+	oid  git.OID
+}
+
+// searchFilesInRepoRevs searches the revisions of a repository which repoRev
+// specifies (see hasMultipleRevs).
+//
+// Revisions often share most of their files, so each file is searched only
+// once per distinct contents (blob), in the first commit which contains it.
+// The file's matches are the same in every revision where it has these
+// contents, which are listed in the match's revs.
+func searchFilesInRepoRevs(ctx context.Context, repoRev search.RepositoryRevisions, info *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error) {
+	tr, ctx := trace.New(ctx, "searchFilesInRepoRevs", repoRev.String())
+	defer func() {
+		tr.SetError(err)
+		tr.Finish()
+	}()
+
+	revs, err := resolveRevs(ctx, repoRev)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Many revisions (e.g., merged branches) point to the same commit.
+	var commits []api.CommitID
+	commitRevs := map[api.CommitID][]string{}
+	for _, rev := range revs {
+		if _, ok := commitRevs[rev.commit]; !ok {
+			commits = append(commits, rev.commit)
+		}
+		commitRevs[rev.commit] = append(commitRevs[rev.commit], rev.rev)
+	}
+	tr.LazyPrintf("%d revisions, %d commits", len(revs), len(commits))
+
+	trees := make([][]*git.BlobEntry, len(commits))
+	run := parallel.NewRun(10)
+	for i, commit := range commits {
+		i, commit := i, commit
+		run.Acquire()
+		go func() {
+			defer run.Release()
+			blobs, err := git.ListBlobs(ctx, repoRev.GitserverRepo(), commit)
+			if err != nil {
+				run.Error(err)
+				return
+			}
+			trees[i] = blobs
+		}()
+	}
+	if err := run.Wait(); err != nil {
+		return nil, false, err
+	}
+
+	// Assign each blob to the first commit which contains it, and collect the
+	// revisions which contain it.
+	var (
+		blobRevs = map[blobKey][]string{}
+		newBlobs = make([]map[string]git.OID, len(commits)) // path -> OID
+	)
+	for i, commit := range commits {
+		newBlobs[i] = map[string]git.OID{}
+		for _, blob := range trees[i] {
+			key := blobKey{path: blob.Path, oid: blob.OID}
+			if _, ok := blobRevs[key]; !ok {
+				newBlobs[i][blob.Path] = blob.OID
+			}
+			blobRevs[key] = append(blobRevs[key], commitRevs[commit]...)
+		}
+	}
+
+	// The matches are sent once they are all known (not streamed), because
+	// the matches in each commit must be filtered.
+	send := fileMatchSenderFromContext(ctx)
+	searchCtx := withFileMatchSender(ctx, nil)
+
+	var mu sync.Mutex
+	run = parallel.NewRun(10)
+	for i, commit := range commits {
+		paths := newBlobs[i]
+		if len(paths) == 0 {
+			// All of the commit's files were searched in other commits.
+			continue
+		}
+		commitInfo := info
+		if len(paths) < len(trees[i]) {
+			p := *info
+			if len(paths) <= maxRestrictedPaths {
+				p.Paths = make([]string, 0, len(paths))
+				for path := range paths {
+					p.Paths = append(p.Paths, path)
+				}
+				sort.Strings(p.Paths)
+			} else {
+				// The files searched in other commits may match too, and
+				// their matches are discarded. Raise the limit so that they
+				// can't use it up.
+				limit := int64(info.FileMatchLimit) + int64(len(trees[i])-len(paths))
+				if limit > math.MaxInt32 {
+					limit = math.MaxInt32
+				}
+				p.FileMatchLimit = int32(limit)
+			}
+			commitInfo = &p
+		}
+
+		rev := commitRevs[commit][0]
+		workspace := "git://" + string(repoRev.Repo.Name) + "?" + url.QueryEscape(rev) + "#"
+		commit := commit
+		run.Acquire()
+		go func() {
+			defer run.Release()
+			commitMatches, commitLimitHit, err := searchFilesInRepo(searchCtx, repoRev.Repo, repoRev.GitserverRepo(), string(commit), commitInfo, fetchTimeout)
+			if err != nil {
+				run.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			limitHit = limitHit || commitLimitHit
+			var n int32
+			for _, fm := range commitMatches {
+				oid, ok := paths[fm.JPath]
+				if !ok {
+					// Searched in another commit.
+					continue
+				}
+				if n++; n > info.FileMatchLimit {
+					limitHit = true
+					break
+				}
+				fm.uri = workspace + fm.JPath
+				fm.inputRev = &rev
+				fm.revs = blobRevs[blobKey{path: fm.JPath, oid: oid}]
+				sort.Strings(fm.revs)
+				matches = append(matches, fm)
+			}
+		}()
+	}
+	if err := run.Wait(); err != nil {
+		return nil, false, err
+	}
+
+	if send != nil && len(matches) > 0 {
+		send(matches)
+	}
+	return matches, limitHit, nil
+}
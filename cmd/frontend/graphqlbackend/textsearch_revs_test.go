@@ -0,0 +1,114 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+func TestSearchFilesInRepoRevs(t *testing.T) {
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		if spec != "master" {
+			t.Errorf("got ResolveRevision(%q), want only master", spec)
+		}
+		return "c1", nil
+	}
+	git.Mocks.ListRefs = func() ([]*git.Ref, error) {
+		return []*git.Ref{
+			{Name: "refs/heads/master", CommitID: "c1"},
+			{Name: "refs/heads/release-0.9", CommitID: "c0"},
+			{Name: "refs/heads/release-1.0", CommitID: "c2"},
+			{Name: "refs/heads/release-1.1", CommitID: "c2"},
+			{Name: "refs/heads/release-2.0", CommitID: "c3"},
+			{Name: "refs/tags/v1.0", CommitID: "c2"},
+		}, nil
+	}
+	blob := func(path string, oid byte) *git.BlobEntry {
+		return &git.BlobEntry{Path: path, OID: git.OID{oid}}
+	}
+	trees := map[api.CommitID][]*git.BlobEntry{
+		"c1": {blob("a.go", 1), blob("b.go", 2)},
+		"c2": {blob("a.go", 1), blob("b.go", 3)},
+		// c.go has the same contents as b.go in c1, but a different path.
+		"c3": {blob("a.go", 4), blob("b.go", 3), blob("c.go", 2)},
+	}
+	git.Mocks.ListBlobs = func(commit api.CommitID) ([]*git.BlobEntry, error) {
+		if _, ok := trees[commit]; !ok {
+			t.Errorf("listed the files of unexpected commit %s", commit)
+		}
+		return trees[commit], nil
+	}
+	defer git.ResetMocks()
+
+	var (
+		mu    sync.Mutex
+		paths = map[string][]string{}
+	)
+	mockSearchFilesInRepo = func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error) {
+		mu.Lock()
+		paths[rev] = info.Paths
+		mu.Unlock()
+		// All files match, even those which the search is not restricted
+		// to, to check that they are filtered.
+		for _, blob := range trees[api.CommitID(rev)] {
+			matches = append(matches, &fileMatchResolver{JPath: blob.Path, repo: repo, commitID: api.CommitID(rev)})
+		}
+		return matches, false, nil
+	}
+	defer func() { mockSearchFilesInRepo = nil }()
+
+	q, err := query.ParseAndCheck("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := &search.Args{
+		Pattern: &search.PatternInfo{
+			FileMatchLimit:         defaultMaxSearchResults,
+			Pattern:                "foo",
+			PathPatternsAreRegExps: true,
+		},
+		Repos: makeRepositoryRevisions("foo@master:*refs/heads/release-*:*!refs/heads/release-0*"),
+		Query: q,
+	}
+	results, _, err := searchFilesInRepos(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, fm := range results {
+		got = append(got, fmt.Sprintf("%s:%s %s (%s)", fm.commitID, fm.JPath, *fm.inputRev, strings.Join(fm.Revisions(), " ")))
+	}
+	sort.Strings(got)
+	if want := []string{
+		"c1:a.go master (master refs/heads/release-1.0 refs/heads/release-1.1)",
+		"c1:b.go master (master)",
+		"c2:b.go refs/heads/release-1.0 (refs/heads/release-1.0 refs/heads/release-1.1 refs/heads/release-2.0)",
+		"c3:a.go refs/heads/release-2.0 (refs/heads/release-2.0)",
+		"c3:c.go refs/heads/release-2.0 (refs/heads/release-2.0)",
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	// The search of each commit is restricted to the files which were not
+	// searched in other commits.
+	if want := map[string][]string{
+		"c1": nil,
+		"c2": {"b.go"},
+		"c3": {"a.go", "c.go"},
+	}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("got paths %q, want %q", paths, want)
+	}
+}
@@ -2,11 +2,15 @@ package graphqlbackend
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"sort"
 	"testing"
 	"time"
 
+	"github.com/google/zoekt"
 	zoektquery "github.com/google/zoekt/query"
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
@@ -125,6 +129,23 @@ func queryEqual(a zoektquery.Q, b zoektquery.Q) bool {
 	return zoektquery.Map(a, sortChildren).String() == zoektquery.Map(b, sortChildren).String()
 }
 
+func TestLimitFilesPerRepo(t *testing.T) {
+	files := []zoekt.FileMatch{
+		{Repository: "a", FileName: "1"},
+		{Repository: "a", FileName: "2"},
+		{Repository: "b", FileName: "1"},
+		{Repository: "a", FileName: "3"},
+		{Repository: "c", FileName: "1"},
+	}
+	var got []string
+	for _, file := range limitFilesPerRepo(files, 1) {
+		got = append(got, file.Repository+"/"+file.FileName)
+	}
+	if want := []string{"a/1", "b/1", "c/1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 func TestSearchFilesInRepos(t *testing.T) {
 	mockSearchFilesInRepo = func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error) {
 		repoName := repo.Name
@@ -217,3 +238,49 @@ func makeRepositoryRevisions(repos ...string) []*search.RepositoryRevisions {
 	}
 	return r
 }
+
+func TestTextSearchURL_stream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("Stream") != "true" {
+			t.Errorf("expected Stream=true, got %q", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"Match":{"Path":"a.go","LineMatches":[{"Preview":"foo","LineNumber":1}]}}`)
+		fmt.Fprintln(w, `{"Match":{"Path":"b.go"}}`)
+		fmt.Fprintln(w, `{"Done":true,"LimitHit":true}`)
+	}))
+	defer ts.Close()
+
+	var streamed []string
+	matches, limitHit, err := textSearchURL(context.Background(), ts.URL+"?Stream=true", func(fm *fileMatchResolver) {
+		streamed = append(streamed, fm.JPath)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !limitHit {
+		t.Error("expected limitHit")
+	}
+	if want := []string{"a.go", "b.go"}; !reflect.DeepEqual(streamed, want) {
+		t.Errorf("got streamed %v, want %v", streamed, want)
+	}
+	if len(matches) != 2 || len(matches[0].JLineMatches) != 1 || matches[0].JLineMatches[0].JPreview != "foo" {
+		t.Errorf("unexpected matches %+v", matches)
+	}
+}
+
+func TestTextSearchURL_streamError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"Match":{"Path":"a.go"}}`)
+		fmt.Fprintln(w, `{"Done":true,"Error":"boom"}`)
+	}))
+	defer ts.Close()
+
+	matches, _, err := textSearchURL(context.Background(), ts.URL+"?Stream=true", func(*fileMatchResolver) {})
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("got error %v, want boom", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected partial results to be returned, got %d matches", len(matches))
+	}
+}
@@ -153,6 +153,11 @@ func newCommon(w http.ResponseWriter, r *http.Request, title string, serveError
 				dangerouslyServeError(w, r, errors.New("repository could not be cloned"), http.StatusInternalServerError)
 				return nil, nil
 			}
+			if _, ok := errors.Cause(err).(*gitserver.RepoQuotaExceededErr); ok {
+				// Repository is too large to clone.
+				dangerouslyServeError(w, r, errors.New("repository is too large to be cloned (see gitMaxRepoSizeMB in site configuration)"), http.StatusInternalServerError)
+				return nil, nil
+			}
 			if vcs.IsRepoNotExist(err) {
 				if vcs.IsCloneInProgress(err) {
 					// Repo is cloning.
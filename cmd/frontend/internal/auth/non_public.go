@@ -96,6 +96,11 @@ func AllowAnonymousRequest(req *http.Request) bool {
 		return true
 	}
 
+	// Code host webhooks are authenticated by repo-updater with their secrets.
+	if strings.HasPrefix(req.URL.Path, "/.api/webhooks/") {
+		return true
+	}
+
 	apiRouteName := matchedRouteName(req, router.Router())
 	if apiRouteName == router.UI {
 		// Test against UI router. (Some of its handlers inject private data into the title or meta tags.)
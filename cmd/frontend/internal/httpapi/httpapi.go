@@ -37,8 +37,16 @@ func NewHandler(m *mux.Router) http.Handler {
 
 	m.Get(apirouter.RepoRefresh).Handler(trace.TraceRoute(handler(serveRepoRefresh)))
 
+	m.Get(apirouter.RepoGitInfoRefs).Handler(trace.TraceRoute(handler(serveRepoGitInfoRefs)))
+	m.Get(apirouter.RepoGitUploadPack).Handler(trace.TraceRoute(handler(serveRepoGitUploadPack)))
+
+	m.Get(apirouter.SearchExport).Handler(trace.TraceRoute(handler(serveSearchExport)))
+	m.Get(apirouter.SearchStream).Handler(trace.TraceRoute(handler(serveSearchStream)))
+
 	m.Get(apirouter.Telemetry).Handler(trace.TraceRoute(telemetryHandler))
 
+	m.Get(apirouter.Webhooks).Handler(trace.TraceRoute(webhooksHandler))
+
 	m.Get(apirouter.XLang).Handler(trace.TraceRoute(handler(serveXLang)))
 
 	if envvar.SourcegraphDotComMode() {
@@ -71,6 +79,8 @@ func NewInternalHandler(m *mux.Router) http.Handler {
 
 	m.Get(apirouter.PhabricatorRepoCreate).Handler(trace.TraceRoute(handler(servePhabricatorRepoCreate)))
 	m.Get(apirouter.ReposCreateIfNotExists).Handler(trace.TraceRoute(handler(serveReposCreateIfNotExists)))
+	m.Get(apirouter.ReposDelete).Handler(trace.TraceRoute(handler(serveReposDelete)))
+	m.Get(apirouter.ReposSync).Handler(trace.TraceRoute(handler(serveReposSync)))
 	m.Get(apirouter.ReposUpdateMetadata).Handler(trace.TraceRoute(handler(serveReposUpdateMetadata)))
 	m.Get(apirouter.ReposUpdateIndex).Handler(trace.TraceRoute(handler(serveReposUpdateIndex)))
 	m.Get(apirouter.ReposInventory).Handler(trace.TraceRoute(handler(serveReposInventory)))
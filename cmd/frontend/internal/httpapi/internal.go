@@ -16,6 +16,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/jsonc"
 	"github.com/sourcegraph/sourcegraph/pkg/txemail"
@@ -79,6 +80,37 @@ func serveReposUpdateMetadata(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// serveReposDelete deletes the repository from the repo table, even if Sync
+// soft-deleted it. It responds with whether the repository existed.
+func serveReposDelete(w http.ResponseWriter, r *http.Request) error {
+	var repoName api.RepoName
+	if err := json.NewDecoder(r.Body).Decode(&repoName); err != nil {
+		return err
+	}
+	repo, err := db.Repos.GetByNameIncludingDeleted(r.Context(), repoName)
+	if errcode.IsNotFound(err) {
+		return json.NewEncoder(w).Encode(false)
+	} else if err != nil {
+		return err
+	}
+	if err := db.Repos.Delete(r.Context(), repo.ID); err != nil {
+		return errors.Wrap(err, "Repos.Delete failed")
+	}
+	return json.NewEncoder(w).Encode(true)
+}
+
+func serveReposSync(w http.ResponseWriter, r *http.Request) error {
+	var req api.ReposSyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	resp, err := db.Repos.Sync(r.Context(), req)
+	if err != nil {
+		return errors.Wrap(err, "Repos.Sync failed")
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
 func serveReposUpdateIndex(w http.ResponseWriter, r *http.Request) error {
 	var repo api.RepoUpdateIndexRequest
 	err := json.NewDecoder(r.Body).Decode(&repo)
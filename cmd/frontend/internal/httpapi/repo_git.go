@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/handlerutil"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
+)
+
+// serveRepoGitInfoRefs serves the ref advertisement of git's smart HTTP
+// protocol, which starts a clone or fetch of a repository from Sourcegraph.
+// Only fetching (git-upload-pack) is supported.
+func serveRepoGitInfoRefs(w http.ResponseWriter, r *http.Request) error {
+	if service := r.URL.Query().Get("service"); service != "git-upload-pack" {
+		return &errcode.HTTPErr{Status: http.StatusForbidden, Err: errors.Errorf("unsupported service %q (repositories on Sourcegraph are read-only)", service)}
+	}
+
+	repo, err := getGitRepo(w, r)
+	if err != nil {
+		return err
+	}
+
+	cloned, err := gitserver.DefaultClient.IsRepoCloned(r.Context(), repo.Name)
+	if err != nil {
+		return err
+	}
+	if !cloned {
+		// Clone it, so that the client can retry later.
+		if err := repoupdater.DefaultClient.EnqueueRepoUpdate(r.Context(), gitserver.Repo{Name: repo.Name}); err != nil {
+			return err
+		}
+		return &errcode.HTTPErr{Status: http.StatusServiceUnavailable, Err: errors.Errorf("repo is being cloned: %s", repo.Name)}
+	}
+
+	gitserver.DefaultClient.UploadPack(repo.Name, w, r)
+	return nil
+}
+
+// serveRepoGitUploadPack serves the git-upload-pack requests of git's smart
+// HTTP protocol, which fetch the objects of a repository from Sourcegraph.
+func serveRepoGitUploadPack(w http.ResponseWriter, r *http.Request) error {
+	repo, err := getGitRepo(w, r)
+	if err != nil {
+		return err
+	}
+
+	gitserver.DefaultClient.UploadPack(repo.Name, w, r)
+	return nil
+}
+
+// getGitRepo returns the repository of a request of git's smart HTTP
+// protocol.
+//
+// 🚨 SECURITY: Repos.GetByName only returns repositories that the current user
+// is allowed to read (see repos_perm.go in package db), so this must be
+// called for every request.
+func getGitRepo(w http.ResponseWriter, r *http.Request) (*types.Repo, error) {
+	repo, err := handlerutil.GetRepo(r.Context(), mux.Vars(r))
+	if err != nil {
+		if errcode.IsNotFound(err) && !actor.FromContext(r.Context()).IsAuthenticated() {
+			// The repository may be visible to a signed-in user, so ask git
+			// for credentials (an access token).
+			w.Header().Set("WWW-Authenticate", `Basic realm="Sourcegraph"`)
+			return nil, &errcode.HTTPErr{Status: http.StatusUnauthorized, Err: err}
+		}
+		return nil, err
+	}
+
+	if !repo.Enabled {
+		return nil, &errcode.HTTPErr{Status: http.StatusNotFound, Err: errors.Errorf("repo is not enabled: %s", repo.Name)}
+	}
+	return repo, nil
+}
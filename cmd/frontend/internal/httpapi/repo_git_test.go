@@ -0,0 +1,57 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+)
+
+func TestRepoGit(t *testing.T) {
+	c := newTest()
+
+	backend.Mocks.Repos.GetByName = func(ctx context.Context, name api.RepoName) (*types.Repo, error) {
+		switch name {
+		case "github.com/foo/disabled":
+			return &types.Repo{ID: 1, Name: name}, nil
+		default:
+			return nil, &errcode.Mock{Message: "repo not found", IsNotFound: true}
+		}
+	}
+	defer func() { backend.Mocks.Repos.GetByName = nil }()
+
+	tests := []struct {
+		method, url   string
+		wantStatus    int
+		wantChallenge bool
+	}{
+		// Pushing is not supported.
+		{"GET", "/git/github.com/foo/disabled/info/refs?service=git-receive-pack", http.StatusForbidden, false},
+		{"POST", "/git/github.com/foo/disabled/git-receive-pack", http.StatusNotFound, false},
+
+		// A repository that the anonymous user can't see may be visible to
+		// a signed-in user.
+		{"GET", "/git/github.com/foo/private/info/refs?service=git-upload-pack", http.StatusUnauthorized, true},
+		{"POST", "/git/github.com/foo/private/git-upload-pack", http.StatusUnauthorized, true},
+
+		{"GET", "/git/github.com/foo/disabled/info/refs?service=git-upload-pack", http.StatusNotFound, false},
+		{"POST", "/git/github.com/foo/disabled/git-upload-pack", http.StatusNotFound, false},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, test.url, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != test.wantStatus {
+			t.Errorf("%s %s: got status %d, want %d", test.method, test.url, resp.StatusCode, test.wantStatus)
+		}
+		if got := resp.Header.Get("WWW-Authenticate") != ""; got != test.wantChallenge {
+			t.Errorf("%s %s: got WWW-Authenticate %v, want %v", test.method, test.url, got, test.wantChallenge)
+		}
+	}
+}
@@ -12,9 +12,14 @@ const (
 
 	Registry = "registry"
 
-	RepoShield  = "repo.shield"
-	RepoRefresh = "repo.refresh"
-	Telemetry   = "telemetry"
+	RepoShield        = "repo.shield"
+	RepoRefresh       = "repo.refresh"
+	RepoGitInfoRefs   = "repo.git.info-refs"
+	RepoGitUploadPack = "repo.git.upload-pack"
+	SearchExport      = "search.export"
+	SearchStream      = "search.stream"
+	Telemetry         = "telemetry"
+	Webhooks          = "webhooks"
 
 	SavedQueriesListAll    = "internal.saved-queries.list-all"
 	SavedQueriesGetInfo    = "internal.saved-queries.get-info"
@@ -38,11 +43,13 @@ const (
 	GitUploadPack          = "internal.git.upload-pack"
 	PhabricatorRepoCreate  = "internal.phabricator.repo.create"
 	ReposCreateIfNotExists = "internal.repos.create-if-not-exists"
+	ReposDelete            = "internal.repos.delete"
 	ReposGetByName         = "internal.repos.get-by-name"
 	ReposInventoryUncached = "internal.repos.inventory-uncached"
 	ReposInventory         = "internal.repos.inventory"
 	ReposList              = "internal.repos.list"
 	ReposListEnabled       = "internal.repos.list-enabled"
+	ReposSync              = "internal.repos.sync"
 	ReposUpdateIndex       = "internal.repos.update-index"
 	ReposUpdateMetadata    = "internal.repos.update-metadata"
 	ConfigurationRawJSON   = "internal.configuration.raw-json"
@@ -62,6 +69,21 @@ func New(base *mux.Router) *mux.Router {
 	addGraphQLRoute(base)
 	addTelemetryRoute(base)
 
+	// Git's smart HTTP protocol, for cloning (but not pushing to) repositories
+	// from Sourcegraph, such as https://sourcegraph.example.com/.api/git/github.com/foo/bar.
+	base.Path("/git/" + routevar.Repo + "/info/refs").Methods("GET").Name(RepoGitInfoRefs)
+	base.Path("/git/" + routevar.Repo + "/git-upload-pack").Methods("POST").Name(RepoGitUploadPack)
+
+	// Exports all of the results of a search query (as CSV or JSON lines).
+	base.Path("/search/export").Methods("GET").Name(SearchExport)
+
+	// Streams the file matches of a search query as they are found (as JSON
+	// lines).
+	base.Path("/search/stream").Methods("GET").Name(SearchStream)
+
+	// Push webhooks of code hosts, which are proxied to repo-updater.
+	base.Path("/webhooks/{Kind:github|gitlab|bitbucket-server}").Methods("POST").Name(Webhooks)
+
 	// repo contains routes that are NOT specific to a revision. In these routes, the URL may not contain a revspec after the repo (that is, no "github.com/foo/bar@myrevspec").
 	repoPath := `/repos/` + routevar.Repo
 
@@ -104,6 +126,8 @@ func NewInternal(base *mux.Router) *mux.Router {
 	base.Path("/git/{RepoName:.*}/git-upload-pack").Methods("POST").Name(GitUploadPack)
 	base.Path("/phabricator/repo-create").Methods("POST").Name(PhabricatorRepoCreate)
 	base.Path("/repos/create-if-not-exists").Methods("POST").Name(ReposCreateIfNotExists)
+	base.Path("/repos/delete").Methods("POST").Name(ReposDelete)
+	base.Path("/repos/sync").Methods("POST").Name(ReposSync)
 	base.Path("/repos/inventory-uncached").Methods("POST").Name(ReposInventoryUncached)
 	base.Path("/repos/inventory").Methods("POST").Name(ReposInventory)
 	base.Path("/repos/list").Methods("POST").Name(ReposList)
@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+const (
+	// searchExportErrorTrailer is the HTTP trailer which reports an error
+	// that occurred after the export started. The export is then incomplete.
+	searchExportErrorTrailer = "X-Sourcegraph-Export-Error"
+
+	// searchExportIncompleteTrailer is the HTTP trailer which lists (comma
+	// separated) the repositories whose matches may not all have been
+	// exported.
+	searchExportIncompleteTrailer = "X-Sourcegraph-Export-Incomplete-Repositories"
+)
+
+var mockExportSearch func(ctx context.Context, rawQuery string, fn func([]*graphqlbackend.SearchExportMatch) error) (*graphqlbackend.SearchExportStats, error)
+
+// serveSearchExport streams every line which matches a search query (the "q"
+// URL query parameter), as CSV or (if the "format" URL query parameter is
+// "jsonl") as JSON lines. Unlike the GraphQL API, the number of results is not
+// limited.
+func serveSearchExport(w http.ResponseWriter, r *http.Request) error {
+	// 🚨 SECURITY: Only signed-in users may export search results (which
+	// are only from the repositories that they are allowed to read, see
+	// graphqlbackend.ExportSearch).
+	if !actor.FromContext(r.Context()).IsAuthenticated() {
+		return &errcode.HTTPErr{Status: http.StatusUnauthorized, Err: errors.New("must be signed in to export search results")}
+	}
+
+	rawQuery := r.URL.Query().Get("q")
+	if strings.TrimSpace(rawQuery) == "" {
+		return &errcode.HTTPErr{Status: http.StatusBadRequest, Err: errors.New("missing search query (q)")}
+	}
+
+	var (
+		contentType, filename string
+		writeHeader           func() error
+		writeMatch            func(*graphqlbackend.SearchExportMatch) error
+		flush                 func() error
+	)
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "csv":
+		contentType, filename = "text/csv; charset=utf-8", "search-results.csv"
+		cw := csv.NewWriter(w)
+		writeHeader = func() error {
+			return cw.Write([]string{"repository", "commit", "path", "line", "preview"})
+		}
+		writeMatch = func(m *graphqlbackend.SearchExportMatch) error {
+			return cw.Write([]string{string(m.Repo), string(m.Commit), m.Path, strconv.Itoa(int(m.LineNumber)), m.Preview})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case "jsonl":
+		contentType, filename = "application/x-ndjson", "search-results.jsonl"
+		enc := json.NewEncoder(w)
+		writeHeader = func() error { return nil }
+		writeMatch = func(m *graphqlbackend.SearchExportMatch) error { return enc.Encode(m) }
+		flush = func() error { return nil }
+	default:
+		return &errcode.HTTPErr{Status: http.StatusBadRequest, Err: errors.Errorf("unsupported format %q (supported formats: csv, jsonl)", format)}
+	}
+
+	// Errors which occur before the first page of results are returned as
+	// usual. After that, the response status has been sent, so they are
+	// reported in a trailer.
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.Header().Set("Trailer", searchExportErrorTrailer+", "+searchExportIncompleteTrailer)
+		w.WriteHeader(http.StatusOK)
+		return writeHeader()
+	}
+	writePage := func(matches []*graphqlbackend.SearchExportMatch) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		for _, m := range matches {
+			if err := writeMatch(m); err != nil {
+				return err
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+
+	exportSearch := graphqlbackend.ExportSearch
+	if mockExportSearch != nil {
+		exportSearch = mockExportSearch
+	}
+	stats, err := exportSearch(r.Context(), rawQuery, writePage)
+	if err == nil && !started {
+		err = writePage(nil)
+	}
+	if err != nil {
+		if !started {
+			return err
+		}
+		if r.Context().Err() == nil {
+			log15.Error("Search export failed.", "query", rawQuery, "error", err)
+		}
+		w.Header().Set(searchExportErrorTrailer, err.Error())
+	}
+	if stats != nil && len(stats.Incomplete) > 0 {
+		names := make([]string, len(stats.Incomplete))
+		for i, name := range stats.Incomplete {
+			names[i] = string(name)
+		}
+		w.Header().Set(searchExportIncompleteTrailer, strings.Join(names, ","))
+	}
+	return nil
+}
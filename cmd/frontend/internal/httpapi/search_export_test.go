@@ -0,0 +1,102 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+func TestSearchExport(t *testing.T) {
+	c := newTest()
+
+	mockExportSearch = func(ctx context.Context, rawQuery string, fn func([]*graphqlbackend.SearchExportMatch) error) (*graphqlbackend.SearchExportStats, error) {
+		switch rawQuery {
+		case "fail":
+			return nil, errors.New("fail")
+		case "fail later":
+			if err := fn(nil); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("fail later")
+		}
+		pages := [][]*graphqlbackend.SearchExportMatch{
+			{{Repo: "r1", Commit: "c1", Path: "a", LineNumber: 1, Preview: "foo, bar"}},
+			{{Repo: "r2", Commit: "c2", Path: "b", LineNumber: 2, Preview: `"foo"`}},
+		}
+		for _, page := range pages {
+			if err := fn(page); err != nil {
+				return nil, err
+			}
+		}
+		return &graphqlbackend.SearchExportStats{Repositories: 3, Incomplete: []api.RepoName{"r3", "r4"}}, nil
+	}
+	defer func() { mockExportSearch = nil }()
+
+	tests := []struct {
+		url            string
+		anonymous      bool
+		wantStatus     int
+		wantBody       string
+		wantError      string
+		wantIncomplete string
+	}{
+		{url: "/search/export?q=foo", anonymous: true, wantStatus: http.StatusUnauthorized},
+		{url: "/search/export", wantStatus: http.StatusBadRequest},
+		{url: "/search/export?q=foo&format=xml", wantStatus: http.StatusBadRequest},
+		{url: "/search/export?q=fail", wantStatus: http.StatusInternalServerError},
+		{
+			url:        "/search/export?q=foo",
+			wantStatus: http.StatusOK,
+			wantBody: `repository,commit,path,line,preview
+r1,c1,a,1,"foo, bar"
+r2,c2,b,2,"""foo"""
+`,
+			wantIncomplete: "r3,r4",
+		},
+		{
+			url:        "/search/export?q=foo&format=jsonl",
+			wantStatus: http.StatusOK,
+			wantBody: `{"repository":"r1","commit":"c1","path":"a","lineNumber":1,"preview":"foo, bar"}
+{"repository":"r2","commit":"c2","path":"b","lineNumber":2,"preview":"\"foo\""}
+`,
+			wantIncomplete: "r3,r4",
+		},
+		{
+			url:        "/search/export?q=fail+later&format=jsonl",
+			wantStatus: http.StatusOK,
+			wantError:  "fail later",
+		},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", test.url, nil)
+		if !test.anonymous {
+			req = req.WithContext(actor.WithActor(context.Background(), &actor.Actor{UID: 1}))
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != test.wantStatus {
+			t.Errorf("%s: got status %d, want %d", test.url, resp.StatusCode, test.wantStatus)
+		}
+		if resp.StatusCode != http.StatusOK {
+			continue
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		if string(body) != test.wantBody {
+			t.Errorf("%s: got body %q, want %q", test.url, body, test.wantBody)
+		}
+		if got := resp.Trailer.Get(searchExportErrorTrailer); got != test.wantError {
+			t.Errorf("%s: got error trailer %q, want %q", test.url, got, test.wantError)
+		}
+		if got := resp.Trailer.Get(searchExportIncompleteTrailer); got != test.wantIncomplete {
+			t.Errorf("%s: got incomplete repositories trailer %q, want %q", test.url, got, test.wantIncomplete)
+		}
+	}
+}
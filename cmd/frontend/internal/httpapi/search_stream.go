@@ -0,0 +1,76 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// searchStreamEvent is a line of the response of serveSearchStream. Every
+// event but the last has FileMatches. The last has Done, and the stats of the
+// search (or the error which stopped it).
+type searchStreamEvent struct {
+	FileMatches []*graphqlbackend.SearchStreamFileMatch `json:"fileMatches,omitempty"`
+	Done        bool                                    `json:"done,omitempty"`
+	*graphqlbackend.SearchStreamStats
+	Error string `json:"error,omitempty"`
+}
+
+var mockStreamSearch func(ctx context.Context, rawQuery string, send func([]*graphqlbackend.SearchStreamFileMatch)) (*graphqlbackend.SearchStreamStats, error)
+
+// serveSearchStream searches for the files which match a search query (the
+// "q" URL query parameter), and streams the file matches as JSON lines (see
+// searchStreamEvent) as soon as they are found.
+func serveSearchStream(w http.ResponseWriter, r *http.Request) error {
+	rawQuery := r.URL.Query().Get("q")
+	if strings.TrimSpace(rawQuery) == "" {
+		return &errcode.HTTPErr{Status: http.StatusBadRequest, Err: errors.New("missing search query (q)")}
+	}
+
+	// Errors which occur before the first file match are returned as usual.
+	// After that, the response status has been sent, so they are reported in
+	// the last event.
+	var (
+		enc     = json.NewEncoder(w)
+		started = false
+	)
+	write := func(ev *searchStreamEvent) {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+		}
+		// The only reasonable error is the client going away, which cancels
+		// the search.
+		_ = enc.Encode(ev)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	streamSearch := graphqlbackend.StreamSearch
+	if mockStreamSearch != nil {
+		streamSearch = mockStreamSearch
+	}
+	stats, err := streamSearch(r.Context(), rawQuery, func(matches []*graphqlbackend.SearchStreamFileMatch) {
+		write(&searchStreamEvent{FileMatches: matches})
+	})
+	if err != nil {
+		if !started {
+			return err
+		}
+		if r.Context().Err() == nil {
+			log15.Error("Search stream failed.", "query", rawQuery, "error", err)
+		}
+		write(&searchStreamEvent{Done: true, Error: err.Error()})
+		return nil
+	}
+	write(&searchStreamEvent{Done: true, SearchStreamStats: stats})
+	return nil
+}
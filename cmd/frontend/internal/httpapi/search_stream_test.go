@@ -0,0 +1,71 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+func TestSearchStream(t *testing.T) {
+	c := newTest()
+
+	mockStreamSearch = func(ctx context.Context, rawQuery string, send func([]*graphqlbackend.SearchStreamFileMatch)) (*graphqlbackend.SearchStreamStats, error) {
+		switch rawQuery {
+		case "fail":
+			return nil, errors.New("fail")
+		case "fail later":
+			send([]*graphqlbackend.SearchStreamFileMatch{{Repo: "r1", Path: "a"}})
+			return nil, errors.New("fail later")
+		}
+		send([]*graphqlbackend.SearchStreamFileMatch{{Repo: "r1", Commit: "c1", Path: "a", LineMatches: []graphqlbackend.SearchStreamLineMatch{{LineNumber: 1, Preview: "foo", OffsetAndLengths: [][2]int32{{0, 3}}}}}})
+		send([]*graphqlbackend.SearchStreamFileMatch{{Repo: "r2", Path: "b"}})
+		return &graphqlbackend.SearchStreamStats{LimitHit: true, Cloning: []api.RepoName{"r3"}}, nil
+	}
+	defer func() { mockStreamSearch = nil }()
+
+	tests := []struct {
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{url: "/search/stream", wantStatus: http.StatusBadRequest},
+		{url: "/search/stream?q=fail", wantStatus: http.StatusInternalServerError},
+		{
+			url:        "/search/stream?q=foo",
+			wantStatus: http.StatusOK,
+			wantBody: `{"fileMatches":[{"repository":"r1","commit":"c1","path":"a","lineMatches":[{"lineNumber":1,"preview":"foo","offsetAndLengths":[[0,3]]}]}]}
+{"fileMatches":[{"repository":"r2","path":"b","lineMatches":null}]}
+{"done":true,"limitHit":true,"cloning":["r3"],"missing":null,"timedout":null}
+`,
+		},
+		{
+			url:        "/search/stream?q=fail+later",
+			wantStatus: http.StatusOK,
+			wantBody: `{"fileMatches":[{"repository":"r1","path":"a","lineMatches":null}]}
+{"done":true,"error":"fail later"}
+`,
+		},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", test.url, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != test.wantStatus {
+			t.Errorf("%s: got status %d, want %d", test.url, resp.StatusCode, test.wantStatus)
+		}
+		if resp.StatusCode != http.StatusOK {
+			continue
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		if string(body) != test.wantBody {
+			t.Errorf("%s: got body %q, want %q", test.url, body, test.wantBody)
+		}
+	}
+}
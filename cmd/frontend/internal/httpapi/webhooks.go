@@ -0,0 +1,17 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
+)
+
+// webhooksHandler serves the push webhooks of code hosts by proxying them to
+// repo-updater, which must not be reachable from code hosts itself.
+//
+// 🚨 SECURITY: Webhooks are sent by anonymous code hosts. repo-updater
+// authenticates them with the webhook secrets of the code host connections.
+var webhooksHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	repoupdater.DefaultClient.Webhook(mux.Vars(r)["Kind"], w, r)
+})
@@ -0,0 +1,67 @@
+package httpapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
+)
+
+func TestWebhooks(t *testing.T) {
+	c := newTest()
+
+	var gotPath, gotSignature, gotBody, gotCookie string
+	repoUpdater := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotSignature = r.Header.Get("X-Hub-Signature")
+		gotCookie = r.Header.Get("Cookie")
+		body, _ := ioutil.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer repoUpdater.Close()
+
+	orig := repoupdater.DefaultClient
+	repoupdater.DefaultClient = &repoupdater.Client{URL: repoUpdater.URL}
+	defer func() { repoupdater.DefaultClient = orig }()
+
+	req, _ := http.NewRequest("POST", "/webhooks/github", strings.NewReader(`{"ref":"refs/heads/master"}`))
+	req.Header.Set("X-Hub-Signature", "sha1=abc")
+	req.Header.Set("Cookie", "sgs=secret")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	if gotPath != "/webhooks/github" {
+		t.Errorf("got path %q, want /webhooks/github", gotPath)
+	}
+	if gotSignature != "sha1=abc" {
+		t.Errorf("got signature %q, want sha1=abc", gotSignature)
+	}
+	if gotBody != `{"ref":"refs/heads/master"}` {
+		t.Errorf("got body %q", gotBody)
+	}
+	if gotCookie != "" {
+		t.Errorf("got cookie %q, want none", gotCookie)
+	}
+
+	// Only the webhooks of known code hosts are proxied.
+	gotPath = ""
+	req, _ = http.NewRequest("POST", "/webhooks/enqueue-repo-update", strings.NewReader("{}"))
+	resp, err = c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if gotPath != "" {
+		t.Errorf("got request to repo-updater %q, want none", gotPath)
+	}
+}
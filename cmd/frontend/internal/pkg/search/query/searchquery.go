@@ -3,6 +3,9 @@
 package query
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/syntax"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/types"
 )
@@ -19,6 +22,14 @@ const (
 	FieldLang      = "lang"
 	FieldType      = "type"
 
+	// FieldPatternType selects how the terms of the default field are
+	// interpreted. See PatternType.
+	FieldPatternType = "patterntype"
+
+	// FieldSelect selects the type of entity (e.g., repositories) to which
+	// the results are projected. See Select.
+	FieldSelect = "select"
+
 	// For diff and commit search only:
 	FieldBefore    = "before"
 	FieldAfter     = "after"
@@ -35,20 +46,24 @@ const (
 
 var (
 	regexpNegatableFieldType = types.FieldType{Literal: types.RegexpType, Quoted: types.RegexpType, Negatable: true}
+	regexpFilterFieldType    = types.FieldType{Literal: types.RegexpType, Quoted: types.RegexpType, Negatable: true, Disjunctive: true}
 	stringFieldType          = types.FieldType{Literal: types.StringType, Quoted: types.StringType}
 
 	conf = types.Config{
 		FieldTypes: map[string]types.FieldType{
-			FieldDefault:   {Literal: types.RegexpType, Quoted: types.StringType},
+			FieldDefault:   {Literal: types.RegexpType, Quoted: types.StringType, Negatable: true},
 			FieldCase:      {Literal: types.BoolType, Quoted: types.BoolType, Singular: true},
-			FieldRepo:      regexpNegatableFieldType,
+			FieldRepo:      regexpFilterFieldType,
 			FieldRepoGroup: types.FieldType{Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldFile:      regexpNegatableFieldType,
+			FieldFile:      regexpFilterFieldType,
 			FieldFork:      {Literal: types.StringType, Quoted: types.StringType, Singular: true},
 			FieldArchived:  {Literal: types.StringType, Quoted: types.StringType, Singular: true},
 			FieldLang:      types.FieldType{Literal: types.StringType, Quoted: types.StringType, Negatable: true},
 			FieldType:      stringFieldType,
 
+			FieldPatternType: {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldSelect:      {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+
 			FieldBefore:    stringFieldType,
 			FieldAfter:     stringFieldType,
 			FieldAuthor:    regexpNegatableFieldType,
@@ -93,6 +108,9 @@ func parseAndCheck(conf *types.Config, input string) (*Query, error) {
 	if err != nil {
 		return nil, err
 	}
+	if isStructural(syntaxQuery) {
+		conf = withStringDefaultField(conf)
+	}
 	checkedQuery, err := conf.Check(syntaxQuery)
 	if err != nil {
 		return nil, err
@@ -100,6 +118,102 @@ func parseAndCheck(conf *types.Config, input string) (*Query, error) {
 	return &Query{conf: conf, Query: checkedQuery}, nil
 }
 
+// FilterBranches returns the alternatives of a query whose repo: and file:
+// filters are combined with OR (e.g., "repo:a OR repo:b"), which are searched
+// separately. It returns nil if the filters are not combined with OR.
+func (q *Query) FilterBranches() []*Query {
+	var branches []*Query
+	for _, branch := range q.Branches {
+		branches = append(branches, &Query{conf: q.conf, Query: branch})
+	}
+	return branches
+}
+
+// Pattern types (values of the patterntype: field).
+const (
+	PatternTypeRegexp     = "regexp"
+	PatternTypeStructural = "structural"
+)
+
+// isStructural reports whether q contains patterntype:structural anywhere in
+// its expression tree (e.g., in an operand of OR).
+func isStructural(q *syntax.Query) bool {
+	return q.Tree != nil && isStructuralNode(q.Tree)
+}
+
+func isStructuralNode(n *syntax.Node) bool {
+	if n.Expr != nil {
+		return n.Expr.Field == FieldPatternType && strings.Trim(n.Expr.Value, `"'`) == PatternTypeStructural
+	}
+	for _, o := range n.Operands {
+		if isStructuralNode(o) {
+			return true
+		}
+	}
+	return false
+}
+
+// withStringDefaultField returns a copy of conf in which unquoted terms of
+// the default field are strings, not regexps. Structural patterns often
+// contain characters (such as unbalanced parentheses) that are not valid in
+// a regexp.
+func withStringDefaultField(conf *types.Config) *types.Config {
+	fieldType, ok := conf.FieldTypes[FieldDefault]
+	if !ok {
+		return conf
+	}
+	fieldTypes := make(map[string]types.FieldType, len(conf.FieldTypes))
+	for field, typ := range conf.FieldTypes {
+		fieldTypes[field] = typ
+	}
+	fieldType.Literal = types.StringType
+	fieldTypes[FieldDefault] = fieldType
+	return &types.Config{FieldTypes: fieldTypes, FieldAliases: conf.FieldAliases}
+}
+
+// PatternType returns the pattern type selected by the patterntype: field.
+// It defaults to PatternTypeRegexp.
+func (q *Query) PatternType() (string, error) {
+	patternType, _ := q.StringValue(FieldPatternType)
+	switch patternType {
+	case "", PatternTypeRegexp:
+		return PatternTypeRegexp, nil
+	case PatternTypeStructural:
+		return PatternTypeStructural, nil
+	}
+	return "", fmt.Errorf("invalid patterntype:%q (valid values are: %s, %s)", patternType, PatternTypeRegexp, PatternTypeStructural)
+}
+
+// Select types (values of the select: field).
+const (
+	SelectRepo         = "repo"
+	SelectFile         = "file"
+	SelectSymbol       = "symbol"
+	SelectCommitAuthor = "commit.author"
+)
+
+// Select returns the type of entity selected by the select: field, to which
+// the results are projected (e.g., with select:repo, the repositories which
+// contain matches instead of the matches). It is empty if the results are not
+// projected.
+func (q *Query) Select() (string, error) {
+	selectType, _ := q.StringValue(FieldSelect)
+	switch selectType {
+	case "", SelectRepo, SelectFile, SelectSymbol, SelectCommitAuthor:
+		return selectType, nil
+	}
+	return "", fmt.Errorf("invalid select:%q (valid values are: %s, %s, %s, %s)", selectType, SelectRepo, SelectFile, SelectSymbol, SelectCommitAuthor)
+}
+
+// IsBoolean reports whether the patterns (the default field values) of the
+// query form a boolean expression, with AND, OR or negated patterns. The
+// operands of a boolean expression match at the file level: "a AND b"
+// matches files which contain a and b anywhere. Otherwise, the patterns
+// match in order on a single line.
+func (q *Query) IsBoolean() bool {
+	return q.Pattern != nil && (!q.Pattern.IsLeaf() || q.Pattern.Not)
+}
+
 // BoolValue returns the last boolean value (yes/no) for the field. For example, if the query is
 // "foo:yes foo:no foo:yes", then the last boolean value for the "foo" field is true ("yes"). The
 // default boolean value is false.
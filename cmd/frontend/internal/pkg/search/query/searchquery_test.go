@@ -45,6 +45,77 @@ func TestQuery_IsCaseSensitive(t *testing.T) {
 	})
 }
 
+func TestQuery_IsBoolean(t *testing.T) {
+	tests := map[string]bool{
+		"":                 false,
+		"repo:a":           false,
+		"a":                false,
+		"a b":              false,
+		"repo:a -file:b a": false,
+		"a AND b":          true,
+		"a OR b":           true,
+		"a -b":             true,
+		"-a":               true,
+		"NOT (a b)":        true,
+		"(a OR b) repo:a":  true,
+		"repo:a OR repo:b": false,
+	}
+	for input, want := range tests {
+		t.Run(input, func(t *testing.T) {
+			query, err := ParseAndCheck(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := query.IsBoolean(); got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestQuery_FilterBranches(t *testing.T) {
+	tests := map[string]struct {
+		wantPattern  string
+		wantBranches [][]string // repo: and file: values of each branch
+	}{
+		"repo:x foo OR bar":      {wantPattern: "foo OR bar"},
+		"lang:go foo OR bar":     {wantPattern: "foo OR bar"},
+		"foo OR bar case:yes":    {wantPattern: "foo OR bar"},
+		"repo:a OR repo:b foo":   {wantPattern: "foo", wantBranches: [][]string{{"a"}, {"b"}}},
+		"(repo:a OR file:b) foo": {wantPattern: "foo", wantBranches: [][]string{{"a"}, {"file:b"}}},
+		"(repo:a OR repo:b) (foo OR bar) -file:c": {
+			wantPattern:  "foo OR bar",
+			wantBranches: [][]string{{"a", "-file:c"}, {"b", "-file:c"}},
+		},
+	}
+	for input, test := range tests {
+		t.Run(input, func(t *testing.T) {
+			query, err := ParseAndCheck(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := query.Pattern.String(); got != test.wantPattern {
+				t.Errorf("got pattern %q, want %q", got, test.wantPattern)
+			}
+			var branches [][]string
+			for _, branch := range query.FilterBranches() {
+				repos, _ := branch.RegexpPatterns(FieldRepo)
+				files, negatedFiles := branch.RegexpPatterns(FieldFile)
+				for _, file := range files {
+					repos = append(repos, "file:"+file)
+				}
+				for _, file := range negatedFiles {
+					repos = append(repos, "-file:"+file)
+				}
+				branches = append(branches, repos)
+			}
+			if !reflect.DeepEqual(branches, test.wantBranches) {
+				t.Errorf("got branches %q, want %q", branches, test.wantBranches)
+			}
+		})
+	}
+}
+
 func TestQuery_RegexpPatterns(t *testing.T) {
 	conf := types.Config{
 		FieldTypes: map[string]types.FieldType{
@@ -142,3 +213,74 @@ func checkPanic(t *testing.T, msg string, f func()) {
 	}()
 	f()
 }
+
+func TestQuery_PatternType(t *testing.T) {
+	tests := map[string]struct {
+		want        string
+		wantErr     bool
+		wantDefault []string
+	}{
+		"foo":                              {want: PatternTypeRegexp, wantDefault: []string{"foo"}},
+		"patterntype:regexp foo":           {want: PatternTypeRegexp, wantDefault: []string{"foo"}},
+		"patterntype:structural foo(:[x]":  {want: PatternTypeStructural, wantDefault: []string{"foo(:[x]"}},
+		"patterntype:structural a.b(:[x])": {want: PatternTypeStructural, wantDefault: []string{"a.b(:[x])"}},
+		"patterntype:other":                {wantErr: true},
+		"(repo:a OR repo:b) (patterntype:structural foo(:[x]))": {want: PatternTypeStructural, wantDefault: []string{"foo(:[x])"}},
+	}
+	for input, test := range tests {
+		t.Run(input, func(t *testing.T) {
+			query, err := ParseAndCheck(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := query.PatternType()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if test.wantDefault == nil {
+				return
+			}
+			var values []string
+			for _, v := range query.Values(FieldDefault) {
+				if v.String != nil {
+					values = append(values, *v.String)
+				} else {
+					values = append(values, v.Regexp.String())
+				}
+			}
+			if !reflect.DeepEqual(values, test.wantDefault) {
+				t.Errorf("got default values %q, want %q", values, test.wantDefault)
+			}
+		})
+	}
+}
+
+func TestQuery_Select(t *testing.T) {
+	tests := map[string]struct {
+		want    string
+		wantErr bool
+	}{
+		"foo":                      {want: ""},
+		"select:repo foo":          {want: SelectRepo},
+		"select:commit.author foo": {want: SelectCommitAuthor},
+		"select:author foo":        {wantErr: true},
+	}
+	for input, test := range tests {
+		t.Run(input, func(t *testing.T) {
+			query, err := ParseAndCheck(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := query.Select()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
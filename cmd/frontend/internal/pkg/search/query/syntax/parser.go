@@ -25,22 +25,45 @@ type context struct {
 // Parse parses the query and returns its parse tree. Returned errors are of
 // type *ParseError, which includes the error position and message.
 //
+// A query which is not a valid boolean expression (such as "foo AND") is
+// parsed with its operators as literal patterns instead, as it was before
+// boolean operators were supported.
+//
 // BNF-ish query syntax:
 //
-//   exprList  := {exprSign} | exprSign (sep exprSign)*
-//   exprSign  := {"-"} expr
-//   expr      := fieldExpr | lit | quoted | pattern
-//   fieldExpr := lit ":" value
-//   value     := lit | quoted
+//   orExpr     := andExpr ("OR" andExpr)*
+//   andExpr    := concatExpr ("AND" concatExpr)*
+//   concatExpr := unaryExpr (sep unaryExpr)*
+//   unaryExpr  := "NOT" unaryExpr | {"-"} "(" orExpr ")" | exprSign
+//   exprSign   := {"-"} expr
+//   expr       := fieldExpr | lit | quoted | pattern
+//   fieldExpr  := lit ":" value
+//   value      := lit | quoted
 func Parse(input string) (*Query, error) {
-	tokens := Scan(input)
+	q, err := parse(input, Scan(input))
+	if err != nil {
+		if literalQuery, literalErr := parse(input, scan(input, false)); literalErr == nil {
+			return literalQuery, nil
+		}
+	}
+	return q, err
+}
+
+func parse(input string, tokens []Token) (*Query, error) {
 	p := parser{tokens: tokens}
 	ctx := context{field: ""}
-	exprs, err := p.parseExprList(ctx)
+	p.skipSeps()
+	if p.peek().Type == TokenEOF {
+		return &Query{Input: input}, nil
+	}
+	tree, err := p.parseOr(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &Query{Expr: exprs, Input: input}, nil
+	if tok := p.next(); tok.Type != TokenEOF {
+		return nil, &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("got %s, want EOF", tok.Type)}
+	}
+	return &Query{Expr: tree.leaves(), Tree: tree, Input: input}, nil
 }
 
 // peek returns the next token without consuming it. Peeking beyond the end of
@@ -68,30 +91,107 @@ func (p *parser) next() Token {
 	return Token{Type: TokenEOF}
 }
 
-// exprList := {exprSign} | exprSign (sep exprSign)*
-func (p *parser) parseExprList(ctx context) (exprList []*Expr, err error) {
-	if p.peek().Type == TokenEOF {
-		return nil, nil
+// skipSeps consumes the separators at the current position.
+func (p *parser) skipSeps() {
+	for p.peek().Type == TokenSep {
+		p.next()
 	}
+}
 
+// orExpr := andExpr ("OR" andExpr)*
+func (p *parser) parseOr(ctx context) (*Node, error) {
+	var operands []*Node
 	for {
-		tok := p.peek()
-		if tok.Type == TokenEOF {
-			break
+		n, err := p.parseAnd(ctx)
+		if err != nil {
+			return nil, err
 		}
-		if tok.Type == TokenSep {
-			p.next()
-			continue
+		operands = append(operands, n)
+		if p.peek().Type != TokenOr {
+			return newNode(OpOr, operands), nil
 		}
+		p.next()
+	}
+}
 
-		expr, err := p.parseExprSign(ctx)
+// andExpr := concatExpr ("AND" concatExpr)*
+func (p *parser) parseAnd(ctx context) (*Node, error) {
+	var operands []*Node
+	for {
+		n, err := p.parseConcat(ctx)
 		if err != nil {
 			return nil, err
 		}
-		exprList = append(exprList, expr)
+		operands = append(operands, n)
+		if p.peek().Type != TokenAnd {
+			return newNode(OpAnd, operands), nil
+		}
+		p.next()
 	}
+}
 
-	return exprList, nil
+// concatExpr := unaryExpr (sep unaryExpr)*
+func (p *parser) parseConcat(ctx context) (*Node, error) {
+	var operands []*Node
+	for {
+		p.skipSeps()
+		switch tok := p.peek(); tok.Type {
+		case TokenEOF, TokenRParen, TokenAnd, TokenOr:
+			if len(operands) == 0 {
+				return nil, &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("got %s, want expr", tok.Type)}
+			}
+			return newNode(OpConcat, operands), nil
+		}
+		n, err := p.parseUnary(ctx)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, n)
+	}
+}
+
+// unaryExpr := "NOT" unaryExpr | {"-"} "(" orExpr ")" | exprSign
+func (p *parser) parseUnary(ctx context) (*Node, error) {
+	switch tok := p.peek(); tok.Type {
+	case TokenNot:
+		p.next()
+		p.skipSeps()
+		n, err := p.parseUnary(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return negate(n), nil
+	case TokenLParen:
+		return p.parseGroup(ctx)
+	case TokenMinus:
+		if p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Type == TokenLParen {
+			p.next()
+			n, err := p.parseGroup(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return negate(n), nil
+		}
+	}
+
+	expr, err := p.parseExprSign(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{Pos: expr.Pos, Expr: expr}, nil
+}
+
+// parseGroup parses "(" orExpr ")".
+func (p *parser) parseGroup(ctx context) (*Node, error) {
+	lparen := p.next()
+	n, err := p.parseOr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.next(); tok.Type != TokenRParen {
+		return nil, &ParseError{Pos: lparen.Pos, Msg: "unclosed parenthesis"}
+	}
+	return n, nil
 }
 
 // exprSign := {"-"} expr
@@ -129,29 +229,39 @@ func (p *parser) parseExpr(ctx context) (*Expr, error) {
 			valueTok := p.next()
 			switch valueTok.Type {
 			case TokenLiteral, TokenQuoted:
-				if tok3 := p.next(); tok3.Type != TokenSep && tok3.Type != TokenEOF {
-					return nil, &ParseError{Pos: tok3.Pos, Msg: fmt.Sprintf("got %s, want separator or EOF", tok3.Type)}
+				if err := p.parseExprEnd(); err != nil {
+					return nil, err
 				}
 				return &Expr{Pos: tok.Pos, Field: tok.Value, Value: valueTok.Value, ValueType: valueTok.Type}, nil
-			case TokenSep, TokenEOF:
+			case TokenSep, TokenEOF, TokenRParen:
+				p.backup()
 				return &Expr{Pos: tok.Pos, Field: tok.Value, Value: "", ValueType: TokenLiteral}, nil
 			default:
 				return nil, &ParseError{Pos: valueTok.Pos, Msg: fmt.Sprintf("got %s, want value", valueTok.Type)}
 			}
-		case TokenSep, TokenEOF:
+		case TokenSep, TokenEOF, TokenRParen:
+			p.backup()
 			return &Expr{Pos: tok.Pos, Value: tok.Value, ValueType: tok.Type}, nil
 		default:
 			panic("unreachable")
 		}
 	case TokenQuoted, TokenPattern:
-		tok2 := p.next()
-		switch tok2.Type {
-		case TokenSep, TokenEOF:
-			return &Expr{Pos: tok.Pos, Value: tok.Value, ValueType: tok.Type}, nil
-		default:
-			return nil, &ParseError{Pos: tok2.Pos, Msg: fmt.Sprintf("got %s, want separator or EOF", tok2.Type)}
+		if err := p.parseExprEnd(); err != nil {
+			return nil, err
 		}
+		return &Expr{Pos: tok.Pos, Value: tok.Value, ValueType: tok.Type}, nil
 	}
 
 	return nil, &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("got %s, want expr", tok.Type)}
 }
+
+// parseExprEnd checks that an expression is followed by a separator, the end
+// of a group or EOF, without consuming it.
+func (p *parser) parseExprEnd() error {
+	switch tok := p.peek(); tok.Type {
+	case TokenSep, TokenEOF, TokenRParen:
+		return nil
+	default:
+		return &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("got %s, want separator or EOF", tok.Type)}
+	}
+}
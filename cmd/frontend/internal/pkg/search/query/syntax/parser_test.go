@@ -34,6 +34,9 @@ func TestParser(t *testing.T) {
 		"a:b": {
 			wantExpr: []*Expr{{Field: "a", Value: "b", ValueType: TokenLiteral}},
 		},
+		"aB:c": {
+			wantExpr: []*Expr{{Value: "aB:c", ValueType: TokenLiteral}},
+		},
 		"a:b-:": {
 			wantExpr: []*Expr{{Field: "a", Value: "b-:", ValueType: TokenLiteral}},
 		},
@@ -79,6 +82,18 @@ func TestParser(t *testing.T) {
 		`"a":b`: {
 			wantErr: &ParseError{Pos: 3, Msg: "got TokenColon, want separator or EOF"},
 		},
+		"(a OR b) -c": {
+			wantExpr: []*Expr{
+				{Value: "a", ValueType: TokenLiteral},
+				{Value: "b", ValueType: TokenLiteral},
+				{Not: true, Value: "c", ValueType: TokenLiteral},
+			},
+			wantString: "a b -c",
+		},
+		"NOT a:b": {
+			wantExpr:   []*Expr{{Not: true, Field: "a", Value: "b", ValueType: TokenLiteral}},
+			wantString: "-a:b",
+		},
 	}
 	for input, test := range tests {
 		t.Run(input, func(t *testing.T) {
@@ -111,3 +126,83 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_tree(t *testing.T) {
+	tests := map[string]struct {
+		wantTree string
+		wantErr  *ParseError
+	}{
+		"a":                  {wantTree: "a"},
+		"a b":                {wantTree: "a b"},
+		"a AND b":            {wantTree: "a AND b"},
+		"a OR b":             {wantTree: "a OR b"},
+		"a b OR c AND d":     {wantTree: "a b OR c AND d"},
+		"a OR b OR c":        {wantTree: "a OR b OR c"},
+		"(a OR b) c":         {wantTree: "(a OR b) c"},
+		"(a OR b) AND (c d)": {wantTree: "(a OR b) AND c d"},
+		"((a b))":            {wantTree: "a b"},
+		"((a))":              {wantTree: "((a))"},
+		"NOT a":              {wantTree: "-a"},
+		"NOT -a":             {wantTree: "a"},
+		"NOT (a OR b)":       {wantTree: "NOT (a OR b)"},
+		"-(a b)":             {wantTree: "NOT (a b)"},
+		"NOT NOT (a b)":      {wantTree: "a b"},
+		"r:x (a:b OR c:d) e": {wantTree: "r:x (a:b OR c:d) e"},
+		`("a b" OR /c d/)`:   {wantTree: `"a b" OR /c d/`},
+		"(a|b) (?i)c":        {wantTree: "(a|b) (?i)c"},
+
+		// Queries which are not valid boolean expressions are parsed with
+		// their operators as literals.
+		"a OR":       {wantTree: "a OR"},
+		"foo AND":    {wantTree: "foo AND"},
+		"f(x) OR":    {wantTree: "f(x) OR"},
+		"AND a":      {wantTree: "AND a"},
+		"(a OR b":    {wantTree: "(a OR b"},
+		"( )":        {wantTree: "( )"},
+		"NOT":        {wantTree: "NOT"},
+		`(a OR b:"c`: {wantErr: &ParseError{Pos: 8, Msg: "got TokenError, want value"}},
+	}
+	for input, test := range tests {
+		t.Run(input, func(t *testing.T) {
+			query, err := Parse(input)
+			if err != nil && test.wantErr == nil {
+				t.Fatal(err)
+			} else if err == nil && test.wantErr != nil {
+				t.Fatalf("got err == nil, want %q", test.wantErr)
+			} else if test.wantErr != nil && !reflect.DeepEqual(err, test.wantErr) {
+				t.Fatalf("got err == %q, want %q", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tree := query.Tree.String(); tree != test.wantTree {
+				t.Errorf("tree: %s\ngot  %s\nwant %s", input, tree, test.wantTree)
+			}
+		})
+	}
+}
+
+func TestNode_Without(t *testing.T) {
+	tests := map[string]string{
+		"a":                  "a",
+		"r:x":                "<nil>",
+		"r:x a r:y b":        "a b",
+		"r:x (a OR b) AND c": "(a OR b) AND c",
+		"r:x AND -(a OR b)":  "NOT (a OR b)",
+	}
+	for input, want := range tests {
+		t.Run(input, func(t *testing.T) {
+			query, err := Parse(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := "<nil>"
+			if n := query.Tree.Without(func(e *Expr) bool { return e.Field != "" }); n != nil {
+				got = n.String()
+			}
+			if got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
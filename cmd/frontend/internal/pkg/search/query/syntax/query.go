@@ -8,7 +8,131 @@ import (
 // A Query contains the parse tree of a query.
 type Query struct {
 	Input string  // the original input query string
-	Expr  []*Expr // expressions in this query
+	Expr  []*Expr // expressions in this query (the leaves of Tree, in order)
+	Tree  *Node   // the boolean expression tree of this query (nil if empty)
+}
+
+// An Operator is a boolean operator in a query.
+type Operator int
+
+// All Operator values.
+const (
+	OpConcat Operator = iota // expressions separated by whitespace
+	OpAnd                    // a AND b
+	OpOr                     // a OR b
+	OpNot                    // NOT (a) or -(a)
+)
+
+// precedence returns the binding strength of op. Operators with a higher
+// precedence bind more tightly.
+func (op Operator) precedence() int {
+	switch op {
+	case OpOr:
+		return 1
+	case OpAnd:
+		return 2
+	case OpConcat:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// A Node is a node in the expression tree of a query. It is either a leaf,
+// which holds an expression, or an operator applied to its operands. The
+// negation of a single expression is a negated leaf (Expr.Not), not an OpNot
+// node.
+type Node struct {
+	Pos      int      // the starting character position of the node
+	Expr     *Expr    // the expression, if a leaf
+	Op       Operator // the operator, if not a leaf
+	Operands []*Node  // the operands, if not a leaf
+}
+
+func (n *Node) String() string {
+	if n.Expr != nil {
+		return n.Expr.String()
+	}
+	s := make([]string, len(n.Operands))
+	for i, o := range n.Operands {
+		s[i] = o.String()
+		if o.Expr == nil && (n.Op == OpNot || o.Op.precedence() <= n.Op.precedence()) {
+			s[i] = "(" + s[i] + ")"
+		}
+	}
+	switch n.Op {
+	case OpAnd:
+		return strings.Join(s, " AND ")
+	case OpOr:
+		return strings.Join(s, " OR ")
+	case OpNot:
+		return "NOT " + s[0]
+	default:
+		return strings.Join(s, " ")
+	}
+}
+
+// Without returns a copy of n without the leaves whose expressions omit
+// returns true for, or nil if no leaves remain.
+func (n *Node) Without(omit func(*Expr) bool) *Node {
+	if n.Expr != nil {
+		if omit(n.Expr) {
+			return nil
+		}
+		return n
+	}
+	var operands []*Node
+	for _, o := range n.Operands {
+		if o2 := o.Without(omit); o2 != nil {
+			operands = append(operands, o2)
+		}
+	}
+	if len(operands) == 0 {
+		return nil
+	}
+	return newNode(n.Op, operands)
+}
+
+// leaves returns the expressions of the leaves of n, in order.
+func (n *Node) leaves() []*Expr {
+	if n.Expr != nil {
+		return []*Expr{n.Expr}
+	}
+	var exprs []*Expr
+	for _, o := range n.Operands {
+		exprs = append(exprs, o.leaves()...)
+	}
+	return exprs
+}
+
+// newNode returns the node which applies op to operands. Operands which apply
+// the same operator are merged, and a single operand is returned as is.
+func newNode(op Operator, operands []*Node) *Node {
+	if len(operands) == 1 && op != OpNot {
+		return operands[0]
+	}
+	n := &Node{Pos: operands[0].Pos, Op: op}
+	for _, o := range operands {
+		if o.Expr == nil && o.Op == op && op != OpNot {
+			n.Operands = append(n.Operands, o.Operands...)
+		} else {
+			n.Operands = append(n.Operands, o)
+		}
+	}
+	return n
+}
+
+// negate returns the negation of n.
+func negate(n *Node) *Node {
+	switch {
+	case n.Expr != nil:
+		n.Expr.Not = !n.Expr.Not
+		return n
+	case n.Op == OpNot:
+		return n.Operands[0]
+	default:
+		return newNode(OpNot, []*Node{n})
+	}
 }
 
 // An Expr describes an expression in a query.
@@ -21,7 +21,12 @@ const (
 	TokenPattern
 	TokenColon
 	TokenMinus
-	TokenSep // separator (like a semicolon)
+	TokenSep    // separator (like a semicolon)
+	TokenLParen // "(" starting a group
+	TokenRParen // ")" ending a group
+	TokenAnd    // AND keyword
+	TokenOr     // OR keyword
+	TokenNot    // NOT keyword
 )
 
 var singleCharTokens = map[rune]TokenType{
@@ -29,6 +34,12 @@ var singleCharTokens = map[rune]TokenType{
 	'-': TokenMinus,
 }
 
+var keywordTokens = map[string]TokenType{
+	"AND": TokenAnd,
+	"OR":  TokenOr,
+	"NOT": TokenNot,
+}
+
 // Token is a token in a query.
 type Token struct {
 	Type  TokenType // type of token
@@ -38,7 +49,13 @@ type Token struct {
 
 // Scan scans the query and returns a list of tokens.
 func Scan(input string) []Token {
-	s := &scanner{input: input}
+	return scan(input, true)
+}
+
+// scan scans the query. If operators is false, the boolean operators (AND, OR
+// and NOT) and groups are scanned as literals.
+func scan(input string, operators bool) []Token {
+	s := &scanner{input: input, operators: operators}
 
 	for state := scanDefault; state != nil; {
 		state = state(s)
@@ -54,6 +71,9 @@ type scanner struct {
 	pos     int
 	prevPos int
 	start   int
+	depth   int // number of open groups
+
+	operators bool // whether to scan boolean operators and groups
 }
 
 func (s *scanner) next() rune {
@@ -106,6 +126,18 @@ func scanDefault(s *scanner) stateFn {
 	if !unicode.IsSpace(r) {
 		s.backup()
 		s.ignore()
+		if r == '(' && s.operators && s.opensGroup() {
+			s.next()
+			s.emit(TokenLParen)
+			s.depth++
+			return scanDefault
+		}
+		if r == ')' && s.depth > 0 {
+			s.next()
+			s.emit(TokenRParen)
+			s.depth--
+			return scanDefault
+		}
 		if typ, ok := singleCharTokens[r]; ok {
 			s.next()
 			s.emit(typ)
@@ -128,6 +160,9 @@ func scanText(s *scanner) stateFn {
 	// Characters that may come before a ':' (TokenColon) in a TokenLiteral.
 	preColonChars := "abcdefghijklmnopqrstuvwxyz0123456789"
 
+	// Field names are lowercase, so a word with uppercase letters (such as
+	// "TODO:") is not a field. It may be a keyword, though.
+	upper := false
 	for {
 		if s.eof() {
 			break
@@ -138,6 +173,9 @@ func scanText(s *scanner) stateFn {
 			break
 		}
 		if r == ':' {
+			if upper {
+				return scanLiteral
+			}
 			// Start of value.
 			s.backup()
 			s.emit(TokenLiteral)
@@ -146,11 +184,19 @@ func scanText(s *scanner) stateFn {
 			return scanValue
 		}
 		if !strings.ContainsRune(preColonChars, r) {
+			if 'A' <= r && r <= 'Z' {
+				upper = true
+				continue
+			}
 			return scanLiteral
 		}
 	}
 
-	s.emit(TokenLiteral)
+	if typ, ok := keywordTokens[s.input[s.start:s.pos]]; ok && s.operators {
+		s.emit(typ)
+		return scanDefault
+	}
+	s.emitLiteral()
 	return scanDefault
 }
 
@@ -180,10 +226,47 @@ func scanLiteral(s *scanner) stateFn {
 		}
 	}
 
-	s.emit(TokenLiteral)
+	s.emitLiteral()
 	return scanDefault
 }
 
+// emitLiteral emits the literal ending at the current position. The
+// parentheses at its end which close open groups, and which are not matched
+// by a parenthesis within the literal, are emitted as TokenRParen.
+func (s *scanner) emitLiteral() {
+	lit := s.input[s.start:s.pos]
+	closing := strings.Count(lit, ")") - strings.Count(lit, "(")
+	if closing > s.depth {
+		closing = s.depth
+	}
+	n := 0
+	for n < closing && n < len(lit) && lit[len(lit)-1-n] == ')' {
+		n++
+	}
+	end := s.pos
+	s.pos -= n
+	if s.pos > s.start {
+		s.emit(TokenLiteral)
+	}
+	for s.pos < end {
+		s.pos++
+		s.emit(TokenRParen)
+		s.depth--
+	}
+}
+
+// opensGroup reports whether the "(" at the current position starts a group,
+// which is the case if the term it starts has more opening than closing
+// parentheses. Otherwise it is part of a literal, such as "(a|b)" or
+// "(?i)a".
+func (s *scanner) opensGroup() bool {
+	term := s.input[s.pos:]
+	if i := strings.IndexFunc(term, unicode.IsSpace); i >= 0 {
+		term = term[:i]
+	}
+	return strings.Count(term, "(") > strings.Count(term, ")")
+}
+
 func scanQuoted(s *scanner) stateFn {
 	q := s.next()
 	escaped := false
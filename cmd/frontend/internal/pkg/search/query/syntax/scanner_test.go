@@ -10,63 +10,83 @@ func TestScanner(t *testing.T) {
 		wantTypes  []TokenType /* + implicit TokenEOF */
 		wantValues []string
 	}{
-		"":         {wantTypes: []TokenType{}},
-		" ":        {wantTypes: []TokenType{}},
-		"\n":       {wantTypes: []TokenType{}},
-		"a":        {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"a"}},
-		":":        {wantTypes: []TokenType{TokenColon}, wantValues: []string{":"}},
-		"-":        {wantTypes: []TokenType{TokenMinus}, wantValues: []string{"-"}},
-		"a:b":      {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"a", ":", "b"}},
-		"a : b":    {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenColon, TokenSep, TokenLiteral}, wantValues: []string{"a", " ", ":", " ", "b"}},
-		"a: b":     {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenSep, TokenLiteral}, wantValues: []string{"a", ":", " ", "b"}},
-		`a:" b"`:   {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}, wantValues: []string{"a", ":", `" b"`}},
-		"a :b":     {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenColon, TokenLiteral}, wantValues: []string{"a", " ", ":", "b"}},
-		"-a":       {wantTypes: []TokenType{TokenMinus, TokenLiteral}, wantValues: []string{"-", "a"}},
-		"-a:b":     {wantTypes: []TokenType{TokenMinus, TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"-", "a", ":", "b"}},
-		"- a":      {wantTypes: []TokenType{TokenMinus, TokenSep, TokenLiteral}, wantValues: []string{"-", " ", "a"}},
-		"- a:b":    {wantTypes: []TokenType{TokenMinus, TokenSep, TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"-", " ", "a", ":", "b"}},
-		"--a":      {wantTypes: []TokenType{TokenMinus, TokenMinus, TokenLiteral}, wantValues: []string{"-", "-", "a"}},
-		"^a":       {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"^a"}},
-		"^a .b":    {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenLiteral}, wantValues: []string{"^a", " ", ".b"}},
-		"a:b c:d":  {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenLiteral, TokenSep, TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"a", ":", "b", " ", "c", ":", "d"}},
-		"a:b:c":    {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"a", ":", "b:c"}},
-		`a:""`:     {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}},
-		`a:"b"`:    {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}, wantValues: []string{"a", ":", `"b"`}},
-		`a:'b'`:    {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}},
-		`a:"b:c"`:  {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}},
-		"a:'b:c'":  {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}},
-		`a:b"c"`:   {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenLiteral}},
-		`"a"`:      {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"a"`}},
-		"'a'":      {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{"'a'"}},
-		`"a\"b"`:   {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"a\"b"`}},
-		`"a\\"`:    {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"a\\"`}},
-		`'a\'b'`:   {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`'a\'b'`}},
-		`"\u0033"`: {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"\u0033"`}},
-		`"\x21"`:   {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"\x21"`}},
-		`"a`:       {wantTypes: []TokenType{TokenError}},
-		"'a":       {wantTypes: []TokenType{TokenError}},
-		`"a\`:      {wantTypes: []TokenType{TokenError}},
-		`a"`:       {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{`a"`}},
-		"a'":       {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"a'"}},
-		`"a:b"`:    {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"a:b"`}},
-		`a"b`:      {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{`a"b`}},
-		`a:"b`:     {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenError}, wantValues: []string{"a", ":", `unclosed quoted string`}},
-		`a"b"c`:    {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{`a"b"c`}},
-		`a"b:c"d`:  {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{`a"b:c"d`}},
-		"/":        {wantTypes: []TokenType{TokenPattern}, wantValues: []string{""}},
-		"//":       {wantTypes: []TokenType{TokenPattern}, wantValues: []string{""}},
-		"///":      {wantTypes: []TokenType{TokenPattern, TokenPattern}, wantValues: []string{"", ""}},
-		"/a":       {wantTypes: []TokenType{TokenPattern}, wantValues: []string{"a"}},
-		"-/a":      {wantTypes: []TokenType{TokenMinus, TokenPattern}, wantValues: []string{"-", "a"}},
-		"a:/b":     {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"a", ":", "/b"}},
-		`/a\`:      {wantTypes: []TokenType{TokenError}},
-		`/a\/`:     {wantTypes: []TokenType{TokenPattern}, wantValues: []string{`a\/`}},
-		`/a\\/`:    {wantTypes: []TokenType{TokenPattern}, wantValues: []string{`a\\`}},
-		`/a\/b`:    {wantTypes: []TokenType{TokenPattern}, wantValues: []string{`a\/b`}},
-		"/a/ b":    {wantTypes: []TokenType{TokenPattern, TokenSep, TokenLiteral}, wantValues: []string{"a", " ", "b"}},
-		"a /b/ c":  {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenPattern, TokenSep, TokenLiteral}, wantValues: []string{"a", " ", "b", " ", "c"}},
-		"a /b c":   {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenPattern}, wantValues: []string{"a", " ", "b c"}},
-		"a /b c/":  {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenPattern}, wantValues: []string{"a", " ", "b c"}},
+		"":              {wantTypes: []TokenType{}},
+		" ":             {wantTypes: []TokenType{}},
+		"\n":            {wantTypes: []TokenType{}},
+		"a":             {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"a"}},
+		":":             {wantTypes: []TokenType{TokenColon}, wantValues: []string{":"}},
+		"-":             {wantTypes: []TokenType{TokenMinus}, wantValues: []string{"-"}},
+		"a:b":           {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"a", ":", "b"}},
+		"a : b":         {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenColon, TokenSep, TokenLiteral}, wantValues: []string{"a", " ", ":", " ", "b"}},
+		"a: b":          {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenSep, TokenLiteral}, wantValues: []string{"a", ":", " ", "b"}},
+		`a:" b"`:        {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}, wantValues: []string{"a", ":", `" b"`}},
+		"a :b":          {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenColon, TokenLiteral}, wantValues: []string{"a", " ", ":", "b"}},
+		"-a":            {wantTypes: []TokenType{TokenMinus, TokenLiteral}, wantValues: []string{"-", "a"}},
+		"-a:b":          {wantTypes: []TokenType{TokenMinus, TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"-", "a", ":", "b"}},
+		"- a":           {wantTypes: []TokenType{TokenMinus, TokenSep, TokenLiteral}, wantValues: []string{"-", " ", "a"}},
+		"- a:b":         {wantTypes: []TokenType{TokenMinus, TokenSep, TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"-", " ", "a", ":", "b"}},
+		"--a":           {wantTypes: []TokenType{TokenMinus, TokenMinus, TokenLiteral}, wantValues: []string{"-", "-", "a"}},
+		"^a":            {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"^a"}},
+		"^a .b":         {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenLiteral}, wantValues: []string{"^a", " ", ".b"}},
+		"a:b c:d":       {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenLiteral, TokenSep, TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"a", ":", "b", " ", "c", ":", "d"}},
+		"a:b:c":         {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"a", ":", "b:c"}},
+		"TODO:fix":      {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"TODO:fix"}},
+		"Foo:bar":       {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"Foo:bar"}},
+		"FIXME: a":      {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenLiteral}, wantValues: []string{"FIXME:", " ", "a"}},
+		"patternType:a": {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"patternType:a"}},
+		`a:""`:          {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}},
+		`a:"b"`:         {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}, wantValues: []string{"a", ":", `"b"`}},
+		`a:'b'`:         {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}},
+		`a:"b:c"`:       {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}},
+		"a:'b:c'":       {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenQuoted}},
+		`a:b"c"`:        {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenLiteral}},
+		`"a"`:           {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"a"`}},
+		"'a'":           {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{"'a'"}},
+		`"a\"b"`:        {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"a\"b"`}},
+		`"a\\"`:         {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"a\\"`}},
+		`'a\'b'`:        {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`'a\'b'`}},
+		`"\u0033"`:      {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"\u0033"`}},
+		`"\x21"`:        {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"\x21"`}},
+		`"a`:            {wantTypes: []TokenType{TokenError}},
+		"'a":            {wantTypes: []TokenType{TokenError}},
+		`"a\`:           {wantTypes: []TokenType{TokenError}},
+		`a"`:            {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{`a"`}},
+		"a'":            {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"a'"}},
+		`"a:b"`:         {wantTypes: []TokenType{TokenQuoted}, wantValues: []string{`"a:b"`}},
+		`a"b`:           {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{`a"b`}},
+		`a:"b`:          {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenError}, wantValues: []string{"a", ":", `unclosed quoted string`}},
+		`a"b"c`:         {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{`a"b"c`}},
+		`a"b:c"d`:       {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{`a"b:c"d`}},
+		"/":             {wantTypes: []TokenType{TokenPattern}, wantValues: []string{""}},
+		"//":            {wantTypes: []TokenType{TokenPattern}, wantValues: []string{""}},
+		"///":           {wantTypes: []TokenType{TokenPattern, TokenPattern}, wantValues: []string{"", ""}},
+		"/a":            {wantTypes: []TokenType{TokenPattern}, wantValues: []string{"a"}},
+		"-/a":           {wantTypes: []TokenType{TokenMinus, TokenPattern}, wantValues: []string{"-", "a"}},
+		"a:/b":          {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"a", ":", "/b"}},
+		`/a\`:           {wantTypes: []TokenType{TokenError}},
+		`/a\/`:          {wantTypes: []TokenType{TokenPattern}, wantValues: []string{`a\/`}},
+		`/a\\/`:         {wantTypes: []TokenType{TokenPattern}, wantValues: []string{`a\\`}},
+		`/a\/b`:         {wantTypes: []TokenType{TokenPattern}, wantValues: []string{`a\/b`}},
+		"/a/ b":         {wantTypes: []TokenType{TokenPattern, TokenSep, TokenLiteral}, wantValues: []string{"a", " ", "b"}},
+		"a /b/ c":       {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenPattern, TokenSep, TokenLiteral}, wantValues: []string{"a", " ", "b", " ", "c"}},
+		"a /b c":        {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenPattern}, wantValues: []string{"a", " ", "b c"}},
+		"a /b c/":       {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenPattern}, wantValues: []string{"a", " ", "b c"}},
+		"(a b)":         {wantTypes: []TokenType{TokenLParen, TokenLiteral, TokenSep, TokenLiteral, TokenRParen}, wantValues: []string{"(", "a", " ", "b", ")"}},
+		"( a )":         {wantTypes: []TokenType{TokenLParen, TokenSep, TokenLiteral, TokenSep, TokenRParen}, wantValues: []string{"(", " ", "a", " ", ")"}},
+		"((a b))":       {wantTypes: []TokenType{TokenLParen, TokenLParen, TokenLiteral, TokenSep, TokenLiteral, TokenRParen, TokenRParen}, wantValues: []string{"(", "(", "a", " ", "b", ")", ")"}},
+		"-(a b)":        {wantTypes: []TokenType{TokenMinus, TokenLParen, TokenLiteral, TokenSep, TokenLiteral, TokenRParen}, wantValues: []string{"-", "(", "a", " ", "b", ")"}},
+		`("a" /b/)`:     {wantTypes: []TokenType{TokenLParen, TokenQuoted, TokenSep, TokenPattern, TokenRParen}, wantValues: []string{"(", `"a"`, " ", "b", ")"}},
+		"(a:b c:d)":     {wantTypes: []TokenType{TokenLParen, TokenLiteral, TokenColon, TokenLiteral, TokenSep, TokenLiteral, TokenColon, TokenLiteral, TokenRParen}, wantValues: []string{"(", "a", ":", "b", " ", "c", ":", "d", ")"}},
+		"(a f())":       {wantTypes: []TokenType{TokenLParen, TokenLiteral, TokenSep, TokenLiteral, TokenRParen}, wantValues: []string{"(", "a", " ", "f()", ")"}},
+		"(a|b)":         {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"(a|b)"}},
+		"(?i)a":         {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"(?i)a"}},
+		"f(":            {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"f("}},
+		"a)":            {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"a)"}},
+		"a AND b":       {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenAnd, TokenSep, TokenLiteral}, wantValues: []string{"a", " ", "AND", " ", "b"}},
+		"a OR b":        {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenOr, TokenSep, TokenLiteral}, wantValues: []string{"a", " ", "OR", " ", "b"}},
+		"NOT a":         {wantTypes: []TokenType{TokenNot, TokenSep, TokenLiteral}, wantValues: []string{"NOT", " ", "a"}},
+		"or":            {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"or"}},
+		"a:OR":          {wantTypes: []TokenType{TokenLiteral, TokenColon, TokenLiteral}, wantValues: []string{"a", ":", "OR"}},
 	}
 	for input, test := range tests {
 		t.Run(input, func(t *testing.T) {
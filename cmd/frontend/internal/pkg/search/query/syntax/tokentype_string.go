@@ -4,9 +4,9 @@ package syntax
 
 import "strconv"
 
-const _TokenType_name = "TokenEOFTokenErrorTokenLiteralTokenQuotedTokenPatternTokenColonTokenMinusTokenSep"
+const _TokenType_name = "TokenEOFTokenErrorTokenLiteralTokenQuotedTokenPatternTokenColonTokenMinusTokenSepTokenLParenTokenRParenTokenAndTokenOrTokenNot"
 
-var _TokenType_index = [...]uint8{0, 8, 18, 30, 41, 53, 63, 73, 81}
+var _TokenType_index = [...]uint8{0, 8, 18, 30, 41, 53, 63, 73, 81, 92, 103, 111, 118, 126}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
@@ -33,6 +33,10 @@ type FieldType struct {
 	Singular  bool      // whether the field may only be used 0 or 1 times
 	Negatable bool      // whether the field can be matched negated (i.e., -field:value)
 
+	// Disjunctive is whether the field's values may be combined with OR
+	// (e.g., "repo:a OR repo:b").
+	Disjunctive bool
+
 	// FeatureFlagEnabled returns true if this field is enabled.
 	// The field is always enabled if this is nil.
 	FeatureFlagEnabled func() bool
@@ -44,6 +48,8 @@ func (c *Config) Check(query *syntax.Query) (*Query, error) {
 		Syntax: query,
 		Fields: map[string][]*Value{},
 	}
+	fields := make(map[*syntax.Expr]string, len(query.Expr))
+	values := make(map[*syntax.Expr]*Value, len(query.Expr))
 	for _, expr := range query.Expr {
 		field, fieldType, value, err := c.checkExpr(expr)
 		if err != nil {
@@ -53,10 +59,205 @@ func (c *Config) Check(query *syntax.Query) (*Query, error) {
 			return nil, &TypeError{Pos: expr.Pos, Err: fmt.Errorf("field %q may not be used more than once", field)}
 		}
 		checkedQuery.Fields[field] = append(checkedQuery.Fields[field], value)
+		fields[expr], values[expr] = field, value
+	}
+	if query.Tree == nil {
+		return &checkedQuery, nil
+	}
+
+	// The patterns and the other fields (filters) of the query are combined
+	// separately, so that filters apply to the whole query: "repo:x a OR b"
+	// searches for a or b in x.
+	pattern, err := c.checkNode(query.Tree, fields, values)
+	if err != nil {
+		return nil, err
+	}
+	checkedQuery.Pattern = pattern
+
+	filters := query.Tree.Without(func(expr *syntax.Expr) bool { return fields[expr] == "" })
+	if filters == nil || isConjunction(filters) {
+		return &checkedQuery, nil
+	}
+	branches, err := c.filterBranches(filters, false, false, fields, values)
+	if err != nil {
+		return nil, err
+	}
+	if len(branches) > maxFilterBranches {
+		return nil, &TypeError{Pos: filters.Pos, Err: fmt.Errorf("too many alternative filters (more than %d)", maxFilterBranches)}
+	}
+	// The query's Fields hold the filters of all branches.
+	allFields := map[string][]*Value{}
+	if patterns := checkedQuery.Fields[""]; len(patterns) > 0 {
+		allFields[""] = patterns
+	}
+	seen := map[string]bool{}
+	for _, branch := range branches {
+		branchQuery := &Query{
+			Syntax:  query,
+			Fields:  map[string][]*Value{},
+			Pattern: pattern,
+		}
+		if patterns := checkedQuery.Fields[""]; len(patterns) > 0 {
+			branchQuery.Fields[""] = patterns
+		}
+		for _, f := range branch {
+			branchQuery.Fields[f.field] = append(branchQuery.Fields[f.field], f.value)
+			if key := f.field + ":" + f.value.syntax.String(); !seen[key] {
+				seen[key] = true
+				allFields[f.field] = append(allFields[f.field], f.value)
+			}
+		}
+		checkedQuery.Branches = append(checkedQuery.Branches, branchQuery)
+	}
+	checkedQuery.Fields = allFields
+	if len(checkedQuery.Branches) == 1 {
+		// The filters were negated (e.g., "NOT (repo:a OR repo:b)"), but
+		// not combined with OR.
+		checkedQuery.Branches = nil
 	}
 	return &checkedQuery, nil
 }
 
+// A filter is a value of a field other than the default field.
+type filter struct {
+	field string
+	value *Value
+}
+
+// maxFilterBranches is the maximum number of alternative filters of a query,
+// each of which is searched separately.
+const maxFilterBranches = 10
+
+// isConjunction reports whether n only combines its leaves with AND.
+func isConjunction(n *syntax.Node) bool {
+	if n.Expr != nil {
+		return true
+	}
+	if n.Op != syntax.OpConcat && n.Op != syntax.OpAnd {
+		return false
+	}
+	for _, o := range n.Operands {
+		if !isConjunction(o) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterBranches returns the alternative sets of filters (values of fields
+// other than the default field) which the filters in n (or their negation if
+// neg is true) match, i.e. its disjunctive normal form. The values of negated
+// filters are negated. inOr is whether n is an operand of an OR.
+func (c *Config) filterBranches(n *syntax.Node, neg, inOr bool, fields map[*syntax.Expr]string, values map[*syntax.Expr]*Value) ([][]filter, error) {
+	if n.Expr != nil {
+		field := fields[n.Expr]
+		if inOr && !c.FieldTypes[field].Disjunctive {
+			return nil, &TypeError{Pos: n.Pos, Err: fmt.Errorf("field %q may not be used in an OR expression", field)}
+		}
+		v := values[n.Expr]
+		if neg {
+			if _, _, err := c.resolveField(field, !v.Not()); err != nil {
+				return nil, &TypeError{Pos: n.Pos, Err: err}
+			}
+			expr := *v.syntax
+			expr.Not = !expr.Not
+			negated := *v
+			negated.syntax = &expr
+			v = &negated
+		}
+		return [][]filter{{{field: field, value: v}}}, nil
+	}
+
+	if n.Op == syntax.OpNot {
+		return c.filterBranches(n.Operands[0], !neg, inOr, fields, values)
+	}
+
+	if (n.Op == syntax.OpOr) != neg {
+		var branches [][]filter
+		for _, o := range n.Operands {
+			operandBranches, err := c.filterBranches(o, neg, true, fields, values)
+			if err != nil {
+				return nil, err
+			}
+			branches = append(branches, operandBranches...)
+		}
+		return branches, nil
+	}
+
+	branches := [][]filter{nil}
+	for _, o := range n.Operands {
+		operandBranches, err := c.filterBranches(o, neg, inOr, fields, values)
+		if err != nil {
+			return nil, err
+		}
+		var product [][]filter
+		for _, branch := range branches {
+			for _, operandBranch := range operandBranches {
+				product = append(product, append(append([]filter(nil), branch...), operandBranch...))
+			}
+		}
+		if len(product) > maxFilterBranches {
+			return nil, &TypeError{Pos: n.Pos, Err: fmt.Errorf("too many alternative filters (more than %d)", maxFilterBranches)}
+		}
+		branches = product
+	}
+	return branches, nil
+}
+
+// checkNode returns the boolean expression of the default field values in
+// the syntax tree n. The other fields are combined separately (see
+// filterBranches).
+func (c *Config) checkNode(n *syntax.Node, fields map[*syntax.Expr]string, values map[*syntax.Expr]*Value) (*PatternNode, error) {
+	if n.Expr != nil {
+		if fields[n.Expr] != "" {
+			return nil, nil
+		}
+		v := values[n.Expr]
+		return &PatternNode{Not: v.Not(), Values: []*Value{v}}, nil
+	}
+
+	if n.Op == syntax.OpNot {
+		// Negating a group negates the default field values in it.
+		if _, _, err := c.resolveField("", true); err != nil {
+			return nil, &TypeError{Pos: n.Pos, Err: err}
+		}
+	}
+	var (
+		operands []*PatternNode
+		seq      []*Value // patterns which match in order on a line
+	)
+	for _, o := range n.Operands {
+		operand, err := c.checkNode(o, fields, values)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case operand == nil:
+		case n.Op == syntax.OpConcat && o.Expr != nil && !operand.Not:
+			// Patterns separated by whitespace match in order on a line, as
+			// one pattern.
+			seq = append(seq, operand.Values...)
+		default:
+			operands = append(operands, operand)
+		}
+	}
+	if len(seq) > 0 {
+		operands = append([]*PatternNode{{Values: seq}}, operands...)
+	}
+
+	switch n.Op {
+	case syntax.OpOr:
+		return newPatternNode(syntax.OpOr, operands), nil
+	case syntax.OpNot:
+		if len(operands) == 0 {
+			return nil, nil
+		}
+		return operands[0].negate(), nil
+	default:
+		return newPatternNode(syntax.OpAnd, operands), nil
+	}
+}
+
 func (c *Config) resolveField(field string, not bool) (resolvedField string, typ FieldType, err error) {
 	// Resolve field alias, if any.
 	if resolvedField, ok := c.FieldAliases[field]; ok {
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"reflect"
 	"regexp"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/syntax"
@@ -93,6 +95,7 @@ func TestCheck(t *testing.T) {
 			},
 		},
 		`-a`:         {wantErr: &TypeError{Pos: 1, Err: errors.New(`negated terms (-term) are not yet supported`)}},
+		`-(a b)`:     {wantErr: &TypeError{Pos: 2, Err: errors.New(`negated terms (-term) are not yet supported`)}},
 		`-b:yes`:     {wantErr: &TypeError{Pos: 1, Err: errors.New(`field "b" does not support negation`)}},
 		"b:yes b:no": {wantErr: &TypeError{Pos: 6, Err: errors.New(`field "b" may not be used more than once`)}},
 		`/a\x/`:      {wantErr: &TypeError{Pos: 1, Err: errors.New("error parsing regexp: invalid escape sequence: `\\x`")}},
@@ -125,6 +128,142 @@ func TestCheck(t *testing.T) {
 	}
 }
 
+func TestCheck_pattern(t *testing.T) {
+	conf := Config{
+		FieldTypes: map[string]FieldType{
+			"":  {Literal: RegexpType, Quoted: StringType, Negatable: true},
+			"r": {Literal: RegexpType, Quoted: RegexpType, Negatable: true},
+		},
+	}
+	tests := map[string]struct {
+		want    string
+		wantErr *TypeError
+	}{
+		"":                     {want: "<nil>"},
+		"r:x":                  {want: "<nil>"},
+		"a":                    {want: "a"},
+		"a b":                  {want: "a b"},
+		"r:x a -r:y b":         {want: "a b"},
+		"a -b c":               {want: "a c AND -b"},
+		"a AND b":              {want: "a AND b"},
+		"a b OR c":             {want: "a b OR c"},
+		"a (b OR c) d":         {want: "a d AND (b OR c)"},
+		"NOT (a b)":            {want: "-(a b)"},
+		"NOT (a OR -b)":        {want: "-a AND b"},
+		"a -(b OR (c AND -d))": {want: "a AND -b AND (-c OR d)"},
+		"r:x (a OR b)":         {want: "a OR b"},
+		"(r:x a) AND (r:y b)":  {want: "a AND b"},
+		"a OR r:x":             {want: "a"},
+		"r:x a OR b":           {want: "a OR b"},
+		"NOT (a r:x)":          {want: "-a"},
+	}
+	for input, test := range tests {
+		t.Run(input, func(t *testing.T) {
+			syntaxQuery, err := syntax.Parse(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := conf.Check(syntaxQuery)
+			if err != nil && test.wantErr == nil {
+				t.Fatal(err)
+			} else if err == nil && test.wantErr != nil {
+				t.Fatalf("got err == nil, want %q", test.wantErr)
+			} else if test.wantErr != nil && err.Error() != test.wantErr.Error() {
+				t.Fatalf("got err == %q, want %q", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := "<nil>"
+			if query.Pattern != nil {
+				got = query.Pattern.String()
+			}
+			if got != test.want {
+				t.Errorf("pattern\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheck_branches(t *testing.T) {
+	conf := Config{
+		FieldTypes: map[string]FieldType{
+			"":  {Literal: RegexpType, Quoted: StringType, Negatable: true},
+			"r": {Literal: RegexpType, Quoted: RegexpType, Negatable: true, Disjunctive: true},
+			"f": {Literal: RegexpType, Quoted: RegexpType, Negatable: true, Disjunctive: true},
+			"l": {Literal: StringType, Quoted: StringType, Negatable: true},
+			"c": {Literal: BoolType, Quoted: BoolType, Singular: true},
+		},
+	}
+	// describe returns the filters of q, sorted.
+	describe := func(q *Query) string {
+		var filters []string
+		for field, values := range q.Fields {
+			if field == "" {
+				continue
+			}
+			for _, v := range values {
+				filters = append(filters, v.syntax.String())
+			}
+		}
+		sort.Strings(filters)
+		return strings.Join(filters, " ")
+	}
+	tests := map[string]struct {
+		wantFields   string
+		wantBranches []string
+		wantErr      *TypeError
+	}{
+		"r:x a":                     {wantFields: "r:x"},
+		"l:go a OR b":               {wantFields: "l:go"},
+		"a OR b c:yes":              {wantFields: "c:yes"},
+		"r:x l:go (a OR b)":         {wantFields: "l:go r:x"},
+		"NOT (r:x OR r:y) a":        {wantFields: "-r:x -r:y"},
+		"r:a OR r:b foo":            {wantFields: "r:a r:b", wantBranches: []string{"r:a", "r:b"}},
+		"(r:a OR f:b) foo":          {wantFields: "f:b r:a", wantBranches: []string{"r:a", "f:b"}},
+		"l:go (r:a OR r:b) f:c foo": {wantFields: "f:c l:go r:a r:b", wantBranches: []string{"f:c l:go r:a", "f:c l:go r:b"}},
+		"(r:a OR r:b) NOT f:c foo":  {wantFields: "-f:c r:a r:b", wantBranches: []string{"-f:c r:a", "-f:c r:b"}},
+		"NOT (r:a f:b) foo":         {wantFields: "-f:b -r:a", wantBranches: []string{"-r:a", "-f:b"}},
+		"l:go OR l:js foo":          {wantErr: &TypeError{Pos: 0, Err: errors.New(`field "l" may not be used in an OR expression`)}},
+		"NOT c:yes foo":             {wantErr: &TypeError{Pos: 4, Err: errors.New(`field "c" does not support negation`)}},
+		"(r:a OR r:b) (r:c OR r:d) (r:e OR r:f) (r:g OR r:h) foo": {
+			wantErr: &TypeError{Pos: 1, Err: errors.New(`too many alternative filters (more than 10)`)},
+		},
+	}
+	for input, test := range tests {
+		t.Run(input, func(t *testing.T) {
+			syntaxQuery, err := syntax.Parse(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := conf.Check(syntaxQuery)
+			if err != nil && test.wantErr == nil {
+				t.Fatal(err)
+			} else if err == nil && test.wantErr != nil {
+				t.Fatalf("got err == nil, want %q", test.wantErr)
+			} else if test.wantErr != nil && err.Error() != test.wantErr.Error() {
+				t.Fatalf("got err == %q, want %q", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := describe(query); got != test.wantFields {
+				t.Errorf("fields\ngot  %s\nwant %s", got, test.wantFields)
+			}
+			var branches []string
+			for _, branch := range query.Branches {
+				branches = append(branches, describe(branch))
+				if branch.Pattern != query.Pattern {
+					t.Errorf("branch %q has a different pattern", describe(branch))
+				}
+			}
+			if !reflect.DeepEqual(branches, test.wantBranches) {
+				t.Errorf("branches\ngot  %q\nwant %q", branches, test.wantBranches)
+			}
+		})
+	}
+}
+
 func TestUnquoteString(t *testing.T) {
 	tests := map[string]string{
 		`"ab"`:    "ab",
@@ -2,14 +2,107 @@ package types
 
 import (
 	"regexp"
+	"strings"
 
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/syntax"
 )
 
 // A Query is the typechecked representation of a search query.
 type Query struct {
-	Syntax *syntax.Query       // the query syntax
-	Fields map[string][]*Value // map of field name -> values
+	Syntax  *syntax.Query       // the query syntax
+	Fields  map[string][]*Value // map of field name -> values
+	Pattern *PatternNode        // boolean expression of the default field values (nil if there are none)
+
+	// Branches are the alternatives of a query whose filters are combined
+	// with OR (e.g., "repo:a OR repo:b"), each with one alternative of the
+	// filters in its Fields. The query matches what any branch matches. It
+	// is nil if the filters are not combined with OR, and then Fields
+	// holds the filters.
+	Branches []*Query
+}
+
+// A PatternNode is a node in the boolean expression of the values of the
+// default field (the patterns) of a query. It is either a leaf, which holds
+// patterns that match in order on a single line, or an AND or OR of its
+// operands. Negations are pushed down to the leaves.
+type PatternNode struct {
+	Op       syntax.Operator // syntax.OpAnd or syntax.OpOr, if not a leaf
+	Operands []*PatternNode  // the operands, if not a leaf
+
+	Not    bool     // whether the leaf is negated
+	Values []*Value // the patterns of the leaf
+}
+
+// IsLeaf reports whether n is a leaf.
+func (n *PatternNode) IsLeaf() bool {
+	return len(n.Values) > 0
+}
+
+func (n *PatternNode) String() string {
+	if n.IsLeaf() {
+		s := make([]string, len(n.Values))
+		for i, v := range n.Values {
+			expr := *v.syntax
+			expr.Not = false
+			s[i] = expr.String()
+		}
+		switch {
+		case !n.Not:
+			return strings.Join(s, " ")
+		case len(s) == 1:
+			return "-" + s[0]
+		default:
+			return "-(" + strings.Join(s, " ") + ")"
+		}
+	}
+	s := make([]string, len(n.Operands))
+	for i, o := range n.Operands {
+		s[i] = o.String()
+		if !o.IsLeaf() {
+			s[i] = "(" + s[i] + ")"
+		}
+	}
+	if n.Op == syntax.OpOr {
+		return strings.Join(s, " OR ")
+	}
+	return strings.Join(s, " AND ")
+}
+
+// negate returns the negation of n.
+func (n *PatternNode) negate() *PatternNode {
+	if n.IsLeaf() {
+		return &PatternNode{Not: !n.Not, Values: n.Values}
+	}
+	op := syntax.OpAnd
+	if n.Op == syntax.OpAnd {
+		op = syntax.OpOr
+	}
+	operands := make([]*PatternNode, len(n.Operands))
+	for i, o := range n.Operands {
+		operands[i] = o.negate()
+	}
+	return newPatternNode(op, operands)
+}
+
+// newPatternNode returns the node which applies op to operands. Operands
+// which apply the same operator are merged, and a single operand is returned
+// as is.
+func newPatternNode(op syntax.Operator, operands []*PatternNode) *PatternNode {
+	switch len(operands) {
+	case 0:
+		return nil
+	case 1:
+		return operands[0]
+	}
+	n := &PatternNode{Op: op}
+	for _, o := range operands {
+		if !o.IsLeaf() && o.Op == op {
+			n.Operands = append(n.Operands, o.Operands...)
+		} else {
+			n.Operands = append(n.Operands, o)
+		}
+	}
+	return n
 }
 
 // ValueType is the set of types of values in queries.
@@ -53,6 +53,111 @@ func (r1 RevisionSpecifier) Less(r2 RevisionSpecifier) bool {
 	return r1.ExcludeRefGlob < r2.ExcludeRefGlob
 }
 
+// RefGlobs matches Git ref names against the ref globs of revision specifiers.
+type RefGlobs struct {
+	include, exclude []*regexp.Regexp
+}
+
+// CompileRefGlobs compiles the RefGlob and ExcludeRefGlob fields of revs. A ref
+// matches if its full name matches any RefGlob and no ExcludeRefGlob.
+//
+// As with the "--glob" flag of git-log, "refs/" is prepended to a RefGlob that
+// does not start with it, and "/*" is appended to a RefGlob which has no glob
+// characters (so "heads/release" matches all refs in "refs/heads/release/"). In
+// globs, "*" matches any characters, including "/".
+func CompileRefGlobs(revs []RevisionSpecifier) (*RefGlobs, error) {
+	var g RefGlobs
+	for _, rev := range revs {
+		if rev.RefGlob != "" {
+			glob := rev.RefGlob
+			if !strings.HasPrefix(glob, "refs/") {
+				glob = "refs/" + glob
+			}
+			if !strings.ContainsAny(glob, "*?[") {
+				if !strings.HasSuffix(glob, "/") {
+					glob += "/"
+				}
+				glob += "*"
+			}
+			re, err := compileRefGlob(glob)
+			if err != nil {
+				return nil, err
+			}
+			g.include = append(g.include, re)
+		}
+		if rev.ExcludeRefGlob != "" {
+			re, err := compileRefGlob(rev.ExcludeRefGlob)
+			if err != nil {
+				return nil, err
+			}
+			g.exclude = append(g.exclude, re)
+		}
+	}
+	return &g, nil
+}
+
+// compileRefGlob converts a glob (see gitglossary(7)) to an anchored regexp.
+func compileRefGlob(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == 0 || (end == 1 && glob[i+1] == '!') {
+				// A "]" right after "[" or "[!" is part of the class.
+				if next := strings.IndexByte(glob[i+end+2:], ']'); next != -1 {
+					end += next + 1
+				} else {
+					end = -1
+				}
+			}
+			if end == -1 {
+				return nil, errors.Errorf("invalid ref glob %q: missing closing ]", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid ref glob %q", glob)
+	}
+	return re, nil
+}
+
+// Match reports whether the full ref name (e.g., "refs/heads/master") matches
+// g.
+func (g *RefGlobs) Match(ref string) bool {
+	for _, re := range g.exclude {
+		if re.MatchString(ref) {
+			return false
+		}
+	}
+	for _, re := range g.include {
+		if re.MatchString(ref) {
+			return true
+		}
+	}
+	return false
+}
+
 // RepositoryRevisions specifies a repository and 0 or more revspecs and ref
 // globs.  If no revspecs and no ref globs are specified, then the
 // repository's default branch is used.
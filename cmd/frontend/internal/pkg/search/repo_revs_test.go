@@ -50,6 +50,55 @@ func TestParseRepositoryRevisions(t *testing.T) {
 	}
 }
 
+func TestRefGlobs(t *testing.T) {
+	tests := []struct {
+		revs  []RevisionSpecifier
+		match []string
+		skip  []string
+	}{
+		{
+			revs:  []RevisionSpecifier{{RefGlob: "refs/heads/"}},
+			match: []string{"refs/heads/master", "refs/heads/release/1.0"},
+			skip:  []string{"refs/tags/v1.0", "refs/headsx/y"},
+		},
+		{
+			revs:  []RevisionSpecifier{{RefGlob: "heads/release"}},
+			match: []string{"refs/heads/release/1.0"},
+			skip:  []string{"refs/heads/release", "refs/heads/release-1.0"},
+		},
+		{
+			revs:  []RevisionSpecifier{{RefGlob: "refs/heads/release-*"}, {RefGlob: "refs/tags/v[0-9]*"}, {ExcludeRefGlob: "refs/heads/release-1.?"}},
+			match: []string{"refs/heads/release-2.0", "refs/heads/release-1.10", "refs/tags/v1.0"},
+			skip:  []string{"refs/heads/release-1.0", "refs/heads/master", "refs/tags/vx"},
+		},
+		{
+			revs:  []RevisionSpecifier{{RefGlob: "refs/tags/[!v]*"}, {RevSpec: "master"}},
+			match: []string{"refs/tags/1.0"},
+			skip:  []string{"refs/tags/v1.0", "refs/heads/master"},
+		},
+	}
+	for _, test := range tests {
+		g, err := CompileRefGlobs(test.revs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ref := range test.match {
+			if !g.Match(ref) {
+				t.Errorf("%+v: %q did not match, want match", test.revs, ref)
+			}
+		}
+		for _, ref := range test.skip {
+			if g.Match(ref) {
+				t.Errorf("%+v: %q matched, want no match", test.revs, ref)
+			}
+		}
+	}
+
+	if _, err := CompileRefGlobs([]RevisionSpecifier{{RefGlob: "refs/heads/[a"}}); err == nil {
+		t.Error("got no error for an unterminated character class")
+	}
+}
+
 func TestRepoRevisionsQuery(t *testing.T) {
 	repos := []*types.Repo{{Name: "foo"}, {Name: "bar"}, {Name: "baz"}}
 	cases := map[string]string{
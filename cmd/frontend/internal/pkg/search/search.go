@@ -12,6 +12,7 @@ import (
 type PatternInfo struct {
 	Pattern         string
 	IsRegExp        bool
+	IsStructuralPat bool
 	IsWordMatch     bool
 	IsCaseSensitive bool
 	FileMatchLimit  int32
@@ -25,6 +26,10 @@ type PatternInfo struct {
 	PathPatternsAreRegExps       bool
 	PathPatternsAreCaseSensitive bool
 
+	// Paths if non-empty restricts a searcher search to exactly these files,
+	// so that searcher only fetches them.
+	Paths []string
+
 	PatternMatchesContent bool
 	PatternMatchesPath    bool
 }
@@ -35,7 +40,7 @@ func (p *PatternInfo) IsEmpty() bool {
 
 // Validate returns a non-nil error if PatternInfo is not valid.
 func (p *PatternInfo) Validate() error {
-	if p.IsRegExp {
+	if p.IsRegExp && !p.IsStructuralPat {
 		if _, err := syntax.Parse(p.Pattern, syntax.Perl); err != nil {
 			return err
 		}
@@ -82,4 +87,16 @@ type Args struct {
 	// repository if this field is true. Another example is we set this field
 	// to true if the user requests a specific timeout or maximum result size.
 	UseFullDeadline bool
+
+	// FuzzySymbols indicates that symbol names should be matched fuzzily
+	// against the pattern (e.g. "NRC" matches "NewRepoCache") instead of as a
+	// substring or regexp.
+	FuzzySymbols bool
+
+	// RankingFileMatchLimit, if greater than Pattern.FileMatchLimit, is the
+	// number of file matches to return, so that the most relevant
+	// Pattern.FileMatchLimit of them can be picked once they are ranked. The
+	// effort which the search backends spend (such as Zoekt's time budget) is
+	// still based on Pattern.FileMatchLimit.
+	RankingFileMatchLimit int32
 }
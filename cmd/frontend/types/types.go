@@ -29,6 +29,9 @@ type Repo struct {
 	Enabled bool
 	// Fork is whether this repository is a fork of another repository.
 	Fork bool
+	// Stars is the number of stars of this repository on its code host, or 0 if
+	// unknown.
+	Stars int
 	// CreatedAt is when this repository was created on Sourcegraph.
 	CreatedAt time.Time
 	// UpdatedAt is when this repository's metadata was last updated on Sourcegraph.
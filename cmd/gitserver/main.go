@@ -5,12 +5,15 @@
 package main // import "github.com/sourcegraph/sourcegraph/cmd/gitserver"
 
 import (
+	"context"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,16 +22,31 @@ import (
 	log15 "gopkg.in/inconshreveable/log15.v2"
 
 	"github.com/sourcegraph/sourcegraph/cmd/gitserver/server"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/debugserver"
 	"github.com/sourcegraph/sourcegraph/pkg/env"
+	gitserverclient "github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/tracer"
 )
 
 const janitorInterval = 24 * time.Hour
 
+// The list of gitservers is checked for changes every rebalanceInterval. Repos
+// are rebalanced once it has not changed for rebalanceSettle, so that we don't
+// move repos back and forth while gitservers are being rolled out. Meanwhile,
+// the new owners of a repo fetch it from its previous owner instead of cloning
+// it from the code host. Repos which failed to transfer are retried after
+// rebalanceRetry.
+const (
+	rebalanceInterval = time.Minute
+	rebalanceSettle   = 5 * time.Minute
+	rebalanceRetry    = 15 * time.Minute
+)
+
 var (
-	reposDir          = env.Get("SRC_REPOS_DIR", "/data/repos", "Root dir containing repos.")
-	runRepoCleanup, _ = strconv.ParseBool(env.Get("SRC_RUN_REPO_CLEANUP", "", "Periodically remove inactive repositories."))
+	reposDir            = env.Get("SRC_REPOS_DIR", "/data/repos", "Root dir containing repos.")
+	runRepoCleanup, _   = strconv.ParseBool(env.Get("SRC_RUN_REPO_CLEANUP", "", "Periodically remove inactive repositories."))
+	maintenanceLimit, _ = strconv.Atoi(env.Get("SRC_REPO_MAINTENANCE_LIMIT", "1000", "Maximum number of repositories to maintain (git gc, commit-graph and bitmaps) per daily janitor run. 0 disables maintenance."))
 )
 
 func main() {
@@ -46,7 +64,11 @@ func main() {
 	gitserver := server.Server{
 		ReposDir:                reposDir,
 		DeleteStaleRepositories: runRepoCleanup,
+		MaintenanceLimit:        maintenanceLimit,
 	}
+	gitserver.BelongsOnShard = belongsOnShard
+	gitserver.RepoAddrs = gitserverclient.DefaultClient.AddrsForRepo
+	gitserver.PreviousRepoAddrs = previousRepoAddrs
 	gitserver.RegisterMetrics()
 
 	if tmpDir, err := gitserver.SetupAndClearTmp(); err != nil {
@@ -69,6 +91,34 @@ func main() {
 		}
 	}()
 
+	go func() {
+		var rebalanced, last string
+		var changedAt, retryAt time.Time
+		for {
+			addrs := strings.Join(gitserverclient.DefaultClient.Addrs(context.Background()), " ")
+			if addrs != last {
+				last, changedAt = addrs, time.Now()
+				// Until the repos are rebalanced, they are still on the
+				// gitservers they were rebalanced onto last.
+				if addrs != rebalanced && rebalanced != "" {
+					previousAddrs.Store(strings.Fields(rebalanced))
+				} else {
+					previousAddrs.Store([]string(nil))
+				}
+			}
+			retry := !retryAt.IsZero() && time.Now().After(retryAt)
+			if (addrs != rebalanced || retry) && time.Since(changedAt) >= rebalanceSettle {
+				failed := gitserver.Rebalance()
+				rebalanced, retryAt = addrs, time.Time{}
+				previousAddrs.Store([]string(nil))
+				if failed > 0 {
+					retryAt = time.Now().Add(rebalanceRetry)
+				}
+			}
+			time.Sleep(rebalanceInterval)
+		}
+	}()
+
 	port := "3178"
 	host := ""
 	if env.InsecureDev {
@@ -102,3 +152,58 @@ func main() {
 	// shutdown they will be orphaned and continue running.
 	gitserver.Stop()
 }
+
+// previousAddrs is the list of gitservers before it last changed, until the
+// repos have been rebalanced onto the new list.
+var previousAddrs atomic.Value // []string
+
+// previousRepoAddrs returns the addresses of the gitservers repo belonged on
+// according to previousAddrs.
+func previousRepoAddrs(ctx context.Context, repo api.RepoName) []string {
+	addrs, _ := previousAddrs.Load().([]string)
+	if len(addrs) == 0 {
+		return nil
+	}
+	c := *gitserverclient.DefaultClient
+	c.Addrs = func(context.Context) []string { return addrs }
+	return c.AddrsForRepo(ctx, repo)
+}
+
+// belongsOnShard reports whether repo belongs on this gitserver, i.e. whether
+// this gitserver is one of the gitservers the repo is cloned onto. This
+// gitserver is identified by its hostname, which is the first label of its
+// address in SRC_GIT_SERVERS in our deployments (e.g. gitserver-0 is
+// gitserver-0.gitserver:3178).
+func belongsOnShard(ctx context.Context, repo api.RepoName) (belongs, ok bool) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return false, false
+	}
+	isSelf := func(addr string) bool {
+		host := addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		return host == hostname || strings.HasPrefix(host, hostname+".")
+	}
+
+	// If we can't find ourselves in the list of gitservers, we can't tell
+	// which repos belong here.
+	found := false
+	for _, addr := range gitserverclient.DefaultClient.Addrs(ctx) {
+		if isSelf(addr) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false, false
+	}
+
+	for _, addr := range gitserverclient.DefaultClient.AddrsForRepo(ctx, repo) {
+		if isSelf(addr) {
+			return true, true
+		}
+	}
+	return false, true
+}
@@ -50,7 +50,9 @@ var reposRecloned = prometheus.NewCounter(prometheus.CounterOpts{
 // 1. Remove corrupt repos.
 // 2. Remove stale lock files.
 // 3. Remove inactive repos on sourcegraph.com
-// 4. Reclone repos after a while. (simulate git gc)
+// 4. Remove repos which no longer belong on this shard.
+// 5. Compute the disk usage of repos.
+// 6. Reclone repos after a while. (simulate git gc)
 func (s *Server) cleanupRepos() {
 	bCtx, bCancel := s.serverContext()
 	defer bCancel()
@@ -95,6 +97,37 @@ func (s *Server) cleanupRepos() {
 		return true, nil
 	}
 
+	maybeRemoveForeign := func(gitDir string) (done bool, err error) {
+		repo := s.repoNameFromDir(gitDir)
+		belongs, ok := s.BelongsOnShard(bCtx, repo)
+		if !ok || belongs {
+			return false, nil
+		}
+
+		if s.RepoAddrs != nil {
+			// Only remove the repo once the gitservers it now belongs on
+			// have it. Otherwise Rebalance transfers it to them, so that
+			// they don't need to clone it from the code host.
+			addrs := s.RepoAddrs(bCtx, repo)
+			if len(addrs) == 0 {
+				return false, nil
+			}
+			for _, addr := range addrs {
+				cloned, err := peerRepoCloned(bCtx, addr, repo)
+				if err != nil || !cloned {
+					return false, err
+				}
+			}
+		}
+
+		log15.Info("removing repo which does not belong on this shard", "repo", repo)
+		if err := s.removeRepoDirectory(gitDir); err != nil {
+			return true, err
+		}
+		reposRemoved.Inc()
+		return true, nil
+	}
+
 	ensureGitAttributes := func(gitDir string) (done bool, err error) {
 		return false, setGitAttributes(gitDir)
 	}
@@ -114,8 +147,7 @@ func (s *Server) cleanupRepos() {
 		ctx, cancel := context.WithTimeout(bCtx, longGitCommandTimeout)
 		defer cancel()
 
-		// name is the relative path to ReposDir, but without the .git suffix.
-		repo := protocol.NormalizeRepo(api.RepoName(strings.TrimPrefix(filepath.Dir(gitDir), s.ReposDir+"/")))
+		repo := s.repoNameFromDir(gitDir)
 		log15.Info("recloning expired repo", "repo", repo)
 
 		remoteURL, err := repoRemoteURL(ctx, gitDir)
@@ -166,6 +198,17 @@ func (s *Server) cleanupRepos() {
 		return false, multi
 	}
 
+	sizes := make(map[api.RepoName]*protocol.RepoSize)
+	computeSize := func(gitDir string) (done bool, err error) {
+		size, err := repoSize(bCtx, gitDir)
+		if err != nil {
+			return false, err
+		}
+		size.Repo = s.repoNameFromDir(gitDir)
+		sizes[size.Repo] = size
+		return false, nil
+	}
+
 	type cleanupFn struct {
 		Name string
 		Do   func(string) (bool, error)
@@ -187,10 +230,20 @@ func (s *Server) cleanupRepos() {
 		// sourcegraph.com.
 		cleanups = append(cleanups, cleanupFn{"maybe remove inactive", maybeRemoveInactive})
 	}
+	if s.BelongsOnShard != nil {
+		// When gitservers are added or removed, or the replication factor
+		// is lowered, repos (in particular replicas) are left behind on
+		// shards they no longer belong on. Nothing would update them, so
+		// they would be served stale after a failover.
+		cleanups = append(cleanups, cleanupFn{"maybe remove foreign", maybeRemoveForeign})
+	}
+	// Track which repos use the disk, served by /repo-sizes.
+	cleanups = append(cleanups, cleanupFn{"compute size", computeSize})
 	// Old git clones accumulate loose git objects that waste space and
 	// slow down git operations. Periodically do a fresh clone to avoid
-	// these problems. git gc is slow and resource intensive. It is
-	// cheaper and faster to just reclone the repository.
+	// these problems. A full git gc is slow and resource intensive. It is
+	// cheaper and faster to just reclone the repository. (maintainRepos
+	// does the cheaper, incremental parts of git gc in between.)
 	cleanups = append(cleanups, cleanupFn{"maybe reclone", maybeReclone})
 
 	filepath.Walk(s.ReposDir, func(gitDir string, fi os.FileInfo, fileErr error) error {
@@ -221,6 +274,14 @@ func (s *Server) cleanupRepos() {
 		}
 		return filepath.SkipDir
 	})
+
+	s.repoSizes.set(sizes)
+}
+
+// repoNameFromDir returns the name of the repo in gitDir. The name is the
+// path relative to ReposDir, but without the .git suffix.
+func (s *Server) repoNameFromDir(gitDir string) api.RepoName {
+	return protocol.NormalizeRepo(api.RepoName(strings.TrimPrefix(filepath.Dir(gitDir), s.ReposDir+"/")))
 }
 
 // removeRepoDirectory atomically removes a directory from s.ReposDir.
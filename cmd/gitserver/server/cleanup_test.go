@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"io/ioutil"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path"
@@ -11,6 +12,9 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
 )
 
 const (
@@ -124,6 +128,86 @@ func TestCleanupExpired(t *testing.T) {
 	}
 }
 
+func TestCleanupForeign(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitserver-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	repoA := path.Join(root, testRepoA, ".git")
+	cmd := exec.Command("git", "--bare", "init", repoA)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	repoB := path.Join(root, testRepoB, ".git")
+	cmd = exec.Command("git", "--bare", "init", repoB)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	repoC := path.Join(root, testRepoC, ".git")
+	cmd = exec.Command("git", "--bare", "init", repoC)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		ReposDir: root,
+		BelongsOnShard: func(ctx context.Context, repo api.RepoName) (belongs, ok bool) {
+			switch repo {
+			case protocol.NormalizeRepo(testRepoA):
+				return true, true
+			case protocol.NormalizeRepo(testRepoB):
+				return false, true
+			}
+			return false, false // unknown
+		},
+	}
+	s.Handler() // Handler as a side-effect sets up Server
+	s.cleanupRepos()
+
+	if _, err := os.Stat(repoA); err != nil {
+		t.Error("expected repoA not to be removed")
+	}
+	if _, err := os.Stat(repoB); !os.IsNotExist(err) {
+		t.Error("expected repoB to be removed during clean up")
+	}
+	if _, err := os.Stat(repoC); err != nil {
+		t.Error("expected repoC not to be removed, since we don't know where it belongs")
+	}
+}
+
+func TestCleanupForeign_notTransferred(t *testing.T) {
+	root, cleanup := tmpDir(t)
+	defer cleanup()
+	dstRoot, cleanup := tmpDir(t)
+	defer cleanup()
+
+	repoA := filepath.Join(root, testRepoA, ".git")
+	initRepoWithCommit(t, repoA, "https://example.com/"+testRepoA)
+
+	// The new owner doesn't have repoA yet, so it is kept for Rebalance to
+	// transfer.
+	ts := httptest.NewServer((&Server{ReposDir: dstRoot}).Handler())
+	defer ts.Close()
+
+	s := &Server{
+		ReposDir: root,
+		BelongsOnShard: func(ctx context.Context, repo api.RepoName) (belongs, ok bool) {
+			return false, true
+		},
+		RepoAddrs: func(ctx context.Context, repo api.RepoName) []string {
+			return []string{strings.TrimPrefix(ts.URL, "http://")}
+		},
+	}
+	s.Handler()
+	s.cleanupRepos()
+
+	if _, err := os.Stat(repoA); err != nil {
+		t.Error("expected repoA not to be removed, since the new owner does not have it")
+	}
+}
+
 func TestCleanupOldLocks(t *testing.T) {
 	root, cleanup := tmpDir(t)
 	defer cleanup()
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/schema"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// repoCloneOptions returns the options of the first entry of the site
+// configuration's git.cloneOptions whose pattern matches repo, or nil if
+// none do.
+func repoCloneOptions(repo api.RepoName) *schema.GitCloneOptions {
+	for _, opts := range conf.Get().GitCloneOptions {
+		pattern, err := regexp.Compile(opts.Pattern)
+		if err != nil {
+			log15.Warn("Invalid git.cloneOptions pattern", "pattern", opts.Pattern, "error", err)
+			continue
+		}
+		if pattern.MatchString(string(repo)) {
+			return opts
+		}
+	}
+	return nil
+}
+
+// cloneArgs returns the arguments to git which clone url into dir with the
+// given options.
+func cloneArgs(opts *schema.GitCloneOptions, url, dir string) []string {
+	args := []string{"clone", "--mirror", "--progress"}
+	if opts != nil && opts.Filter != "" {
+		args = append(args, "--filter="+opts.Filter)
+	}
+	if opts != nil && opts.Depth > 0 {
+		// --depth implies --single-branch, but we want every branch.
+		args = append(args, "--depth="+strconv.Itoa(opts.Depth), "--no-single-branch")
+	}
+	return append(args, url, dir)
+}
+
+// fetchArgs returns the arguments to git which fetch the branches, tags and
+// pull requests of the repository in dir from url with the given options.
+//
+// A partial clone must fetch from its promisor remote (origin), which
+// remembers its filter, instead of from url. Otherwise the fetch would
+// download every omitted object reachable from the fetched commits.
+func fetchArgs(opts *schema.GitCloneOptions, dir, url string, partial bool) []string {
+	args := []string{"fetch", "--prune"}
+	if opts != nil && opts.Depth > 0 {
+		args = append(args, "--depth="+strconv.Itoa(opts.Depth))
+	} else if repoIsShallow(dir) {
+		args = append(args, "--unshallow")
+	}
+	if partial {
+		url = "origin"
+	}
+	return append(args, url, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*", "+refs/pull/*:refs/pull/*")
+}
+
+// repoIsShallow returns whether dir (or `${dir}/.git`) is a shallow clone.
+func repoIsShallow(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "shallow")); err == nil {
+		return true
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git", "shallow")); err == nil {
+		return true
+	}
+	return false
+}
+
+// repoIsPartial returns whether the repository in dir is a partial clone,
+// i.e. whether it fetches the objects it omitted from origin on demand.
+func repoIsPartial(ctx context.Context, dir string) bool {
+	cmd := exec.CommandContext(ctx, "git", "config", "--bool", "--get", "remote.origin.promisor")
+	cmd.Dir = dir
+	out, _ := cmd.Output()
+	return strings.TrimSpace(string(out)) == "true"
+}
+
+// prefetchHEAD fetches the objects of HEAD's tree which the partial clone in
+// dir omitted. Most reads (such as the archives which search uses) are of
+// HEAD, and git would otherwise fetch each file's contents separately when
+// it is first read.
+func (s *Server) prefetchHEAD(ctx context.Context, dir string) error {
+	cmd := exec.CommandContext(ctx, "git", "rev-list", "--objects", "--missing=print", "--no-walk", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return errors.Wrap(err, "failed to list missing objects")
+	}
+
+	var missing bytes.Buffer
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("?")) {
+			missing.Write(line[1:])
+			missing.WriteByte('\n')
+		}
+	}
+	if missing.Len() == 0 {
+		return nil
+	}
+
+	// This is how git itself fetches missing objects from a promisor remote.
+	cmd = exec.CommandContext(ctx, "git", "-c", "fetch.negotiationAlgorithm=noop", "fetch", "origin", "--no-tags", "--no-write-fetch-head", "--recurse-submodules=no", "--filter=blob:none", "--stdin")
+	cmd.Dir = dir
+	cmd.Stdin = &missing
+	if output, err := s.runWithRemoteOpts(ctx, cmd, nil); err != nil {
+		return errors.Wrapf(err, "failed to fetch missing objects. Output: %s", string(output))
+	}
+	return nil
+}
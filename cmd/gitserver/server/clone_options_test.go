@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestRepoCloneOptions(t *testing.T) {
+	conf.Mock(&schema.SiteConfiguration{
+		GitCloneOptions: []*schema.GitCloneOptions{
+			{Pattern: "("}, // invalid, skipped
+			{Pattern: `^github\.com/a/`, Depth: 1},
+			{Pattern: `^github\.com/`, Filter: "blob:none"},
+		},
+	})
+	defer conf.Mock(nil)
+
+	for repo, want := range map[string]string{
+		"github.com/a/b": `^github\.com/a/`,
+		"github.com/b/b": `^github\.com/`,
+		"gitlab.com/a/b": "",
+	} {
+		var got string
+		if opts := repoCloneOptions(api.RepoName(repo)); opts != nil {
+			got = opts.Pattern
+		}
+		if got != want {
+			t.Errorf("%s: got pattern %q, want %q", repo, got, want)
+		}
+	}
+}
+
+func TestCloneRepo_partialShallow(t *testing.T) {
+	remote, cleanup1 := tmpDir(t)
+	defer cleanup1()
+
+	dir := remote
+	cmd := func(name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		c.Env = []string{
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		}
+		b, err := c.Output()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s", name, strings.Join(arg, " "), err)
+		}
+		return string(b)
+	}
+
+	// Setup a repo with a few commits on two branches, which allows partial
+	// clones.
+	cmd("git", "init", ".")
+	cmd("git", "symbolic-ref", "HEAD", "refs/heads/master")
+	cmd("git", "config", "uploadpack.allowFilter", "true")
+	cmd("git", "config", "uploadpack.allowAnySHA1InWant", "true")
+	for _, c := range []string{"a", "b", "c"} {
+		cmd("sh", "-c", "echo "+c+" > file.txt")
+		cmd("git", "add", "file.txt")
+		cmd("git", "commit", "-m", c)
+	}
+	cmd("git", "checkout", "-b", "other")
+	cmd("sh", "-c", "echo other > other.txt")
+	cmd("git", "add", "other.txt")
+	cmd("git", "commit", "-m", "other")
+	cmd("git", "checkout", "master")
+
+	reposDir, cleanup2 := tmpDir(t)
+	defer cleanup2()
+
+	conf.Mock(&schema.SiteConfiguration{
+		GitCloneOptions: []*schema.GitCloneOptions{{Pattern: "^example\\.com/", Filter: "blob:none", Depth: 2}},
+	})
+	defer conf.Mock(nil)
+
+	s := &Server{
+		ReposDir:         reposDir,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+		cloneLimiter:     mutablelimiter.New(1),
+		cloneableLimiter: mutablelimiter.New(1),
+	}
+	// Depth is ignored for local paths, so use a file:// URL.
+	url := "file://" + remote
+	if _, err := s.cloneRepo(context.Background(), "example.com/foo/bar", url, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(reposDir, "example.com/foo/bar")
+	dir = dst
+	if !repoIsShallow(dst) {
+		t.Error("expected a shallow clone")
+	}
+	if !repoIsPartial(context.Background(), dst) {
+		t.Error("expected a partial clone")
+	}
+	if got := cmd("git", "rev-list", "--count", "master"); got != "2\n" {
+		t.Errorf("got %q commits on master, want 2", got)
+	}
+	if got := cmd("git", "branch", "--list", "other"); got == "" {
+		t.Error("expected branch other to be cloned")
+	}
+
+	// HEAD's tree is prefetched, and other objects are fetched when they
+	// are read.
+	if got := cmd("git", "rev-list", "--objects", "--missing=print", "--no-walk", "HEAD"); strings.Contains(got, "?") {
+		t.Errorf("expected no missing objects in HEAD, got %q", got)
+	}
+	if got := cmd("git", "rev-list", "--objects", "--missing=print", "--no-walk", "other"); !strings.Contains(got, "?") {
+		t.Errorf("expected missing objects in other, got %q", got)
+	}
+	if got := cmd("git", "cat-file", "-p", "other:other.txt"); got != "other\n" {
+		t.Errorf("got other.txt %q, want %q", got, "other\n")
+	}
+
+	// Updates stay shallow, until the depth is removed.
+	dir = remote
+	cmd("sh", "-c", "echo d > file.txt")
+	cmd("git", "commit", "-am", "d")
+	if err := s.doRepoUpdate2("example.com/foo/bar", url); err != nil {
+		t.Fatal(err)
+	}
+	dir = dst
+	if got := cmd("git", "rev-list", "--count", "master"); got != "2\n" {
+		t.Errorf("got %q commits on master after update, want 2", got)
+	}
+
+	conf.Mock(&schema.SiteConfiguration{})
+	if err := s.doRepoUpdate2("example.com/foo/bar", url); err != nil {
+		t.Fatal(err)
+	}
+	if got := cmd("git", "rev-list", "--count", "master"); got != "4\n" {
+		t.Errorf("got %q commits on master after unshallowing, want 4", got)
+	}
+	if repoIsShallow(dst) {
+		t.Error("expected a complete clone")
+	}
+}
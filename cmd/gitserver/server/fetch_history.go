@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+)
+
+// fetchHistorySize is the number of fetches kept in the fetch history of a
+// repository.
+const fetchHistorySize = 10
+
+// fetchOutputLimit is the number of bytes kept of the end of the output of a
+// fetch.
+const fetchOutputLimit = 1024
+
+func (s *Server) handleFetchHistory(w http.ResponseWriter, r *http.Request) {
+	var req protocol.FetchHistoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dir := path.Join(s.ReposDir, string(protocol.NormalizeRepo(req.Repo)))
+
+	var resp protocol.FetchHistoryResponse
+	if repoCloned(dir) {
+		fetches, err := readFetchHistory(dir)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Newest first.
+		for i := len(fetches) - 1; i >= 0; i-- {
+			resp.Fetches = append(resp.Fetches, fetches[i])
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// fetchHistoryFile returns the path of the file which stores the fetch
+// history of the repository in dir (or `${dir}/.git`). It is a JSON array of
+// the last fetchHistorySize fetches, oldest first.
+func fetchHistoryFile(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		dir = filepath.Join(dir, ".git")
+	}
+	return filepath.Join(dir, "sg_fetch_history")
+}
+
+// readFetchHistory returns the fetch history of the repository in dir,
+// oldest first.
+func readFetchHistory(dir string) ([]*protocol.RepoFetch, error) {
+	b, err := ioutil.ReadFile(fetchHistoryFile(dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var fetches []*protocol.RepoFetch
+	if err := json.Unmarshal(b, &fetches); err != nil {
+		return nil, errors.Wrap(err, "invalid fetch history")
+	}
+	return fetches, nil
+}
+
+// appendFetchHistory adds fetch to the fetch history of the repository in
+// dir, dropping the oldest fetch if it is full.
+func appendFetchHistory(dir string, fetch *protocol.RepoFetch) error {
+	fetches, err := readFetchHistory(dir)
+	if err != nil {
+		// Start over rather than never recording again.
+		fetches = nil
+	}
+	fetches = append(fetches, fetch)
+	if len(fetches) > fetchHistorySize {
+		fetches = fetches[len(fetches)-fetchHistorySize:]
+	}
+	b, err := json.Marshal(fetches)
+	if err != nil {
+		return err
+	}
+	_, err = updateFileIfDifferent(fetchHistoryFile(dir), b)
+	return err
+}
+
+// newRepoFetch returns the record of the fetch which cmd ran, with its
+// output redacted by redactor.
+func newRepoFetch(start time.Time, cmd *exec.Cmd, output []byte, err error, redactor *urlRedactor) *protocol.RepoFetch {
+	fetch := &protocol.RepoFetch{
+		Start:    start,
+		Duration: time.Since(start),
+		ExitCode: -1,
+	}
+	if cmd.ProcessState != nil {
+		fetch.ExitCode = cmd.ProcessState.Sys().(syscall.WaitStatus).ExitStatus()
+	}
+	if err != nil {
+		fetch.Error = redactor.redact(err.Error())
+	}
+	// 🚨 SECURITY: The output could include the remote URL, which may
+	// contain credentials. Redact it before truncating it, which could
+	// otherwise cut off the start of a secret.
+	fetch.Output = redactor.redact(string(output))
+	if len(fetch.Output) > fetchOutputLimit {
+		fetch.Output = fetch.Output[len(fetch.Output)-fetchOutputLimit:]
+	}
+	return fetch
+}
+
+// repoRefs returns the commit (or other object) of each ref of the
+// repository in dir.
+func repoRefs(ctx context.Context, dir string) (map[string]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "for-each-ref", "--format=%(objectname) %(refname)")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(wrapCmdError(cmd, err), "failed to list refs")
+	}
+	refs := make(map[string]string)
+	for _, line := range bytes.Split(bytes.TrimSpace(out), []byte("\n")) {
+		if fields := bytes.SplitN(line, []byte(" "), 2); len(fields) == 2 {
+			refs[string(fields[1])] = string(fields[0])
+		}
+	}
+	return refs, nil
+}
+
+// countChangedRefs returns the number of refs which were created, updated or
+// deleted between before and after.
+func countChangedRefs(before, after map[string]string) int {
+	n := 0
+	for ref, oid := range after {
+		if before[ref] != oid {
+			n++
+		}
+	}
+	for ref := range before {
+		if _, ok := after[ref]; !ok {
+			n++
+		}
+	}
+	return n
+}
@@ -68,6 +68,20 @@ func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
 			repos = append(repos, rp...)
 		}
 
+	case query("other"):
+		otherURL := q.Get("other")
+		for _, c := range conf.Get().Other {
+			if c.Url != otherURL {
+				continue
+			}
+			rp, err := listOtherRepos(ctx, c)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			repos = append(repos, rp...)
+		}
+
 	case query("cloned"):
 		err := filepath.Walk(s.ReposDir, func(path string, info os.FileInfo, err error) error {
 			if ctx.Err() != nil {
@@ -122,6 +136,11 @@ func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+	case query("rebalancing"):
+		// Not repo names, but the progress of the rebalancing of repos
+		// which now belong on other gitservers.
+		repos = append(repos, s.rebalance.lines()...)
+
 	default:
 		// empty list response for unrecognized URL query
 	}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/schema"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// maxOtherListSize is the maximum size of a repository index page or export
+// list fetched when discovering the repositories of another Git host.
+const maxOtherListSize = 10 * 1024 * 1024
+
+// listOtherRepos discovers the repositories on the other Git host c. It
+// returns their paths relative to c.Url, such as "myteam/myrepo.git".
+func listOtherRepos(ctx context.Context, c *schema.OtherExternalServiceConnection) ([]string, error) {
+	base, err := url.Parse(c.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	switch c.Discovery {
+	case "cgit":
+		paths, err = listCgitRepos(ctx, base)
+	case "exportList":
+		paths, err = listExportListRepos(ctx, c.ExportList)
+	case "ssh":
+		paths, err = listSSHRepos(ctx, base, c.SshCommand)
+	default:
+		return nil, fmt.Errorf("unknown discovery %q for Git host %s", c.Discovery, c.Url)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "discovering repositories on %s", c.Url)
+	}
+
+	// Make the paths relative to the base URL and remove duplicates.
+	seen := make(map[string]bool, len(paths))
+	repos := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = strings.TrimPrefix(p, strings.TrimSuffix(base.Path, "/"))
+		p = strings.Trim(path.Clean("/"+p), "/")
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		repos = append(repos, p)
+	}
+	sort.Strings(repos)
+	return repos, nil
+}
+
+var (
+	// cgitRepoLink matches the links to repositories in a cgit index page,
+	// such as <td class='sublevel-repo'><a title='team/repo.git' href='/cgit/team/repo.git/'>.
+	cgitRepoLink = regexp.MustCompile(`<td class=['"](?:toplevel|sublevel)-repo['"]><a [^>]*href=['"]([^'"]+)['"]`)
+
+	// cgitPageLink matches the links to further index pages, which cgit
+	// paginates with the ofs query parameter.
+	cgitPageLink = regexp.MustCompile(`href=['"][^'"]*[?&](?:amp;)?ofs=(\d+)['"]`)
+)
+
+// listCgitRepos crawls the cgit repository index at base (including all its
+// pages).
+func listCgitRepos(ctx context.Context, base *url.URL) ([]string, error) {
+	index, err := fetchOtherList(ctx, base.String())
+	if err != nil {
+		return nil, err
+	}
+
+	pages := [][]byte{index}
+	seenOffsets := map[int]bool{0: true}
+	for _, m := range cgitPageLink.FindAllSubmatch(index, -1) {
+		ofs, err := strconv.Atoi(string(m[1]))
+		if err != nil || seenOffsets[ofs] {
+			continue
+		}
+		seenOffsets[ofs] = true
+
+		u := *base
+		q := u.Query()
+		q.Set("ofs", strconv.Itoa(ofs))
+		u.RawQuery = q.Encode()
+		page, err := fetchOtherList(ctx, u.String())
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+
+	var paths []string
+	for _, page := range pages {
+		for _, m := range cgitRepoLink.FindAllSubmatch(page, -1) {
+			href, err := url.Parse(html.UnescapeString(string(m[1])))
+			if err != nil {
+				continue
+			}
+			paths = append(paths, base.ResolveReference(href).Path)
+		}
+	}
+	return paths, nil
+}
+
+// listExportListRepos reads the repository paths from the text file at
+// listURL. Only the first space-separated field of each line is used, and it
+// may be URL-encoded (as in gitweb's projects.list).
+func listExportListRepos(ctx context.Context, listURL string) ([]string, error) {
+	if listURL == "" {
+		return nil, errors.New("exportList is not set")
+	}
+	list, err := fetchOtherList(ctx, listURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	scanner := bufio.NewScanner(bytes.NewReader(list))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		p, err := url.QueryUnescape(fields[0])
+		if err != nil {
+			p = fields[0]
+		}
+		paths = append(paths, p)
+	}
+	return paths, scanner.Err()
+}
+
+// listSSHRepos runs command on the SSH host of base (like
+// listGitoliteRepos). Each line of its output is a repository path.
+func listSSHRepos(ctx context.Context, base *url.URL, command string) ([]string, error) {
+	if base.Scheme != "ssh" {
+		return nil, fmt.Errorf("ssh discovery requires an ssh:// URL, not %s", base.Scheme)
+	}
+	if command == "" {
+		command = "ls"
+	}
+
+	host := base.Hostname()
+	if base.User != nil {
+		host = base.User.Username() + "@" + host
+	}
+	args := []string{}
+	if port := base.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	args = append(args, host, command)
+
+	out, err := exec.CommandContext(ctx, "ssh", args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%s (stderr: %q)", err, ee.Stderr)
+		}
+		return nil, err
+	}
+
+	var paths []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths, nil
+}
+
+// fetchOtherList fetches the page or file at urlStr.
+func fetchOtherList(ctx context.Context, urlStr string) ([]byte, error) {
+	resp, err := ctxhttp.Get(ctx, nil, urlStr)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, urlStr)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxOtherListSize))
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestListOtherRepos(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgit/", func(w http.ResponseWriter, r *http.Request) {
+		// An index of 2 pages, both linking to each other (like cgit's pager).
+		pager := `<ul class='pager'><li><a href='/cgit/?ofs=0'>[1]</a></li><li><a href='/cgit/?ofs=2'>[2]</a></li></ul>`
+		switch r.URL.Query().Get("ofs") {
+		case "", "0":
+			fmt.Fprint(w, `<table class='list nowrap'>
+<tr><td class='toplevel-repo'><a title='a.git' href='/cgit/a.git/'>a.git</a></td></tr>
+<tr class='nohover'><td colspan='4' class='reposection'>team</td></tr>
+<tr><td class='sublevel-repo'><a title='team/b.git' href='/cgit/team/b.git/'>b.git</a></td></tr>
+</table>`+pager)
+		case "2":
+			fmt.Fprint(w, `<tr><td class='sublevel-repo'><a title='team/c' href='/cgit/team/c/'>c</a></td></tr>`+pager)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("/projects.list", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# exported repositories\na.git\nteam/my%20repo.git owner\n\nteam/c\n")
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := map[string]struct {
+		conn *schema.OtherExternalServiceConnection
+		want []string
+	}{
+		"cgit": {
+			conn: &schema.OtherExternalServiceConnection{Url: ts.URL + "/cgit/", Discovery: "cgit"},
+			want: []string{"a.git", "team/b.git", "team/c"},
+		},
+		"exportList": {
+			conn: &schema.OtherExternalServiceConnection{Url: "git://git.example.com/", Discovery: "exportList", ExportList: ts.URL + "/projects.list"},
+			want: []string{"a.git", "team/c", "team/my repo.git"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			repos, err := listOtherRepos(context.Background(), test.conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(repos, test.want) {
+				t.Errorf("got %q, want %q", repos, test.want)
+			}
+		})
+	}
+
+	if _, err := listOtherRepos(context.Background(), &schema.OtherExternalServiceConnection{Url: ts.URL + "/missing/", Discovery: "cgit"}); err == nil {
+		t.Error("expected error for missing cgit index")
+	}
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// maintenanceInterval is the minimum time between two maintenances of a
+// repository.
+const maintenanceInterval = 7 * 24 * time.Hour
+
+// maintenanceStep is a git command which maintainRepo runs.
+type maintenanceStep struct {
+	Name string
+	Args []string
+}
+
+// maintenanceSteps returns the steps to maintain a repository with the given
+// version of git. They do the work of git gc incrementally, and add the
+// indexes which speed up reading the repository. Older versions of git lack
+// some of the options, so fall back to what git gc does.
+func maintenanceSteps(partial bool, version gitVersion) []maintenanceStep {
+	steps := []maintenanceStep{
+		// Mirrors have many refs, which are faster to read when packed.
+		{"pack-refs", []string{"pack-refs", "--all", "--prune"}},
+	}
+
+	switch {
+	case version.atLeast(2, 34) && !partial:
+		// Combine the packs (and loose objects) so that there are only a
+		// few, of geometrically increasing size, and index them with a
+		// multi-pack-index and a reachability bitmap. Bitmaps speed up
+		// fetches and counting commits.
+		steps = append(steps, maintenanceStep{"repack", []string{"repack", "-d", "--geometric=2", "--write-midx", "--write-bitmap-index"}})
+	case version.atLeast(2, 34):
+		// A partial clone can't have bitmaps, since it doesn't have all
+		// objects, and git can't repack its promisor packs geometrically.
+		steps = append(steps, maintenanceStep{"repack", []string{"repack", "-d", "--write-midx"}})
+	case version.atLeast(2, 32) && !partial:
+		// git repack can't write the multi-pack-index (or its bitmap)
+		// before 2.34.
+		steps = append(steps,
+			maintenanceStep{"repack", []string{"repack", "-d", "--geometric=2"}},
+			maintenanceStep{"multi-pack-index", []string{"multi-pack-index", "write"}},
+		)
+	default:
+		// Repack everything into a single pack, like git gc. Only a
+		// complete pack can have a bitmap.
+		repack := []string{"repack", "-d", "-A", "--unpack-unreachable=2.weeks.ago"}
+		if !partial {
+			repack = append(repack, "--write-bitmap-index")
+		}
+		steps = append(steps, maintenanceStep{"repack", repack})
+	}
+
+	// Remove unreachable loose objects, with the same grace period as git
+	// gc.
+	steps = append(steps, maintenanceStep{"prune", []string{"prune", "--expire=2.weeks.ago"}})
+
+	// The commit-graph speeds up walking history (git log), and its
+	// changed-path Bloom filters speed up walking the history of a path.
+	switch {
+	case version.atLeast(2, 27):
+		steps = append(steps, maintenanceStep{"commit-graph", []string{"commit-graph", "write", "--reachable", "--split", "--changed-paths"}})
+	case version.atLeast(2, 24):
+		steps = append(steps, maintenanceStep{"commit-graph", []string{"commit-graph", "write", "--reachable", "--split"}})
+	}
+	return steps
+}
+
+// gitVersion is the major and minor version of git.
+type gitVersion struct {
+	major, minor int
+}
+
+// atLeast returns true if v is major.minor or later.
+func (v gitVersion) atLeast(major, minor int) bool {
+	return v.major > major || (v.major == major && v.minor >= minor)
+}
+
+var (
+	installedGitVersionOnce sync.Once
+	installedGitVersionV    gitVersion
+)
+
+// installedGitVersion returns the version of the git which gitserver runs,
+// or the zero version (older than any) if it can't be determined.
+func installedGitVersion() gitVersion {
+	installedGitVersionOnce.Do(func() {
+		out, err := exec.Command("git", "version").Output()
+		if err != nil {
+			log15.Warn("failed to determine git version", "error", err)
+			return
+		}
+		v, ok := parseGitVersion(string(out))
+		if !ok {
+			log15.Warn("failed to parse git version", "output", string(out))
+			return
+		}
+		installedGitVersionV = v
+	})
+	return installedGitVersionV
+}
+
+// parseGitVersion parses the output of git version, such as "git version
+// 2.32.0" or "git version 2.24.3 (Apple Git-128)".
+func parseGitVersion(out string) (gitVersion, bool) {
+	fields := strings.Fields(out)
+	if len(fields) < 3 || fields[0] != "git" || fields[1] != "version" {
+		return gitVersion{}, false
+	}
+	parts := strings.SplitN(fields[2], ".", 3)
+	if len(parts) < 2 {
+		return gitVersion{}, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return gitVersion{}, false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return gitVersion{}, false
+	}
+	return gitVersion{major: major, minor: minor}, true
+}
+
+// maintainRepos maintains the repositories whose last maintenance is longest
+// ago, up to s.MaintenanceLimit of them.
+func (s *Server) maintainRepos() {
+	if s.MaintenanceLimit <= 0 {
+		return
+	}
+
+	bCtx, bCancel := s.serverContext()
+	defer bCancel()
+
+	type dueRepo struct {
+		gitDir         string
+		lastMaintained time.Time
+	}
+	var due []dueRepo
+	filepath.Walk(s.ReposDir, func(gitDir string, fi os.FileInfo, fileErr error) error {
+		if fileErr != nil {
+			return nil
+		}
+
+		if s.ignorePath(gitDir) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Look for $GIT_DIR
+		if !fi.IsDir() || fi.Name() != ".git" {
+			return nil
+		}
+
+		lastMaintained := repoLastMaintained(gitDir)
+		if time.Since(lastMaintained) >= maintenanceInterval {
+			due = append(due, dueRepo{gitDir: gitDir, lastMaintained: lastMaintained})
+		}
+		return filepath.SkipDir
+	})
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].lastMaintained.Before(due[j].lastMaintained)
+	})
+
+	maintained := 0
+	for _, r := range due {
+		if maintained >= s.MaintenanceLimit || bCtx.Err() != nil {
+			break
+		}
+
+		// A clone in progress replaces the repository.
+		if _, cloneInProgress := s.locker.Status(filepath.Dir(r.gitDir)); cloneInProgress {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(bCtx, longGitCommandTimeout)
+		err := s.maintainRepo(ctx, r.gitDir)
+		cancel()
+		if err != nil {
+			log15.Error("failed to maintain repo", "repo", s.repoNameFromDir(r.gitDir), "error", err)
+		}
+		maintained++
+	}
+	maintenanceBacklog.Set(float64(len(due) - maintained))
+}
+
+// maintainRepo runs the maintenance steps in gitDir, and records the time of
+// the maintenance if they all succeed. A repository whose maintenance failed
+// is retried by the next run of maintainRepos.
+func (s *Server) maintainRepo(ctx context.Context, gitDir string) error {
+	start := time.Now()
+	for _, step := range maintenanceSteps(repoIsPartial(ctx, gitDir), installedGitVersion()) {
+		stepStart := time.Now()
+		cmd := exec.CommandContext(ctx, "git", step.Args...)
+		cmd.Dir = gitDir
+		out, err := cmd.CombinedOutput()
+		status := "success"
+		if err != nil {
+			status = "failure"
+		}
+		maintenanceDuration.WithLabelValues(step.Name, status).Observe(time.Since(stepStart).Seconds())
+		if err != nil {
+			maintenanceDuration.WithLabelValues("total", status).Observe(time.Since(start).Seconds())
+			return errors.Wrapf(err, "git %s failed with output %q", strings.Join(step.Args, " "), out)
+		}
+	}
+	maintenanceDuration.WithLabelValues("total", "success").Observe(time.Since(start).Seconds())
+
+	if err := setLastMaintained(gitDir); err != nil {
+		log15.Warn("failed to update last maintenance time", "repo", s.repoNameFromDir(gitDir), "error", err)
+	}
+	return nil
+}
+
+// setLastMaintained records the time of the last maintenance of the
+// repository in gitDir, as the mtime of the file sg_maintenance (like
+// sg_refhash of setLastChanged).
+func setLastMaintained(gitDir string) error {
+	return ioutil.WriteFile(filepath.Join(gitDir, "sg_maintenance"), []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0666)
+}
+
+// repoLastMaintained returns the time of the last maintenance of the
+// repository in gitDir, or the zero time if it was never maintained.
+func repoLastMaintained(gitDir string) time.Time {
+	fi, err := os.Stat(filepath.Join(gitDir, "sg_maintenance"))
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintainRepos(t *testing.T) {
+	reposDir, cleanup := tmpDir(t)
+	defer cleanup()
+
+	for _, repo := range []string{"example.com/a", "example.com/b", "example.com/c"} {
+		initRepoWithCommit(t, filepath.Join(reposDir, repo, ".git"), "https://"+repo)
+	}
+	// a was maintained recently, c longer ago than b.
+	gitDir := func(repo string) string { return filepath.Join(reposDir, repo, ".git") }
+	for repo, age := range map[string]time.Duration{
+		"example.com/a": time.Hour,
+		"example.com/b": 2 * maintenanceInterval,
+		"example.com/c": 3 * maintenanceInterval,
+	} {
+		if err := setLastMaintained(gitDir(repo)); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(gitDir(repo), "sg_maintenance"), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &Server{
+		ReposDir:         reposDir,
+		MaintenanceLimit: 1,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+	}
+	s.maintainRepos()
+
+	maintained := func(repo string) bool {
+		return time.Since(repoLastMaintained(gitDir(repo))) < time.Hour
+	}
+	if maintained("example.com/b") {
+		t.Error("expected b not to be maintained, since the limit is 1")
+	}
+	if !maintained("example.com/c") {
+		t.Fatal("expected c to be maintained")
+	}
+
+	// The loose objects are packed, and indexed.
+	size, err := repoSize(context.Background(), gitDir("example.com/c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size.LooseObjects != 0 || size.Packs != 1 {
+		t.Errorf("got %d loose objects and %d packs, want 0 and 1", size.LooseObjects, size.Packs)
+	}
+	for _, pattern := range []string{
+		"objects/pack/multi-pack-index",
+		"objects/pack/multi-pack-index-*.bitmap",
+		"objects/info/commit-graphs/commit-graph-chain",
+	} {
+		if matches, _ := filepath.Glob(filepath.Join(gitDir("example.com/c"), pattern)); len(matches) == 0 {
+			t.Errorf("expected %s to exist", pattern)
+		}
+	}
+}
+
+func TestMaintainRepo_failed(t *testing.T) {
+	dir, cleanup := tmpDir(t)
+	defer cleanup()
+
+	// dir is not a git repository, so the maintenance fails.
+	s := &Server{ReposDir: dir}
+	if err := s.maintainRepo(context.Background(), dir); err == nil {
+		t.Fatal("expected maintenance to fail")
+	}
+	if lastMaintained := repoLastMaintained(dir); !lastMaintained.IsZero() {
+		t.Errorf("got last maintenance time %s after a failed maintenance, want none", lastMaintained)
+	}
+}
+
+func TestMaintenanceSteps(t *testing.T) {
+	tests := []struct {
+		version gitVersion
+		partial bool
+		want    []string
+	}{
+		{
+			version: gitVersion{2, 39},
+			want:    []string{"pack-refs --all --prune", "repack -d --geometric=2 --write-midx --write-bitmap-index", "prune --expire=2.weeks.ago", "commit-graph write --reachable --split --changed-paths"},
+		},
+		{
+			version: gitVersion{2, 39},
+			partial: true,
+			want:    []string{"pack-refs --all --prune", "repack -d --write-midx", "prune --expire=2.weeks.ago", "commit-graph write --reachable --split --changed-paths"},
+		},
+		{
+			version: gitVersion{2, 32},
+			want:    []string{"pack-refs --all --prune", "repack -d --geometric=2", "multi-pack-index write", "prune --expire=2.weeks.ago", "commit-graph write --reachable --split --changed-paths"},
+		},
+		{
+			version: gitVersion{2, 32},
+			partial: true,
+			want:    []string{"pack-refs --all --prune", "repack -d -A --unpack-unreachable=2.weeks.ago", "prune --expire=2.weeks.ago", "commit-graph write --reachable --split --changed-paths"},
+		},
+		{
+			version: gitVersion{2, 25},
+			want:    []string{"pack-refs --all --prune", "repack -d -A --unpack-unreachable=2.weeks.ago --write-bitmap-index", "prune --expire=2.weeks.ago", "commit-graph write --reachable --split"},
+		},
+		{
+			// Unknown version.
+			want: []string{"pack-refs --all --prune", "repack -d -A --unpack-unreachable=2.weeks.ago --write-bitmap-index", "prune --expire=2.weeks.ago"},
+		},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, step := range maintenanceSteps(test.partial, test.version) {
+			got = append(got, strings.Join(step.Args, " "))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("git %d.%d, partial=%v: got %q, want %q", test.version.major, test.version.minor, test.partial, got, test.want)
+		}
+	}
+}
+
+func TestParseGitVersion(t *testing.T) {
+	tests := map[string]gitVersion{
+		"git version 2.32.0\n":                 {2, 32},
+		"git version 2.24.3 (Apple Git-128)\n": {2, 24},
+		"git version 2.33.0.windows.2\n":       {2, 33},
+		"git version 3.0\n":                    {3, 0},
+	}
+	for out, want := range tests {
+		got, ok := parseGitVersion(out)
+		if !ok || got != want {
+			t.Errorf("parseGitVersion(%q) = %v, %v, want %v", out, got, ok, want)
+		}
+	}
+	for _, out := range []string{"", "git version\n", "git version abc\n", "hub version 2.14.2\n"} {
+		if _, ok := parseGitVersion(out); ok {
+			t.Errorf("parseGitVersion(%q) succeeded, want failure", out)
+		}
+	}
+}
@@ -0,0 +1,538 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"golang.org/x/net/context/ctxhttp"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// rebalanceConcurrency is the number of repos transferred concurrently while
+// rebalancing.
+const rebalanceConcurrency = 4
+
+// remoteURLHeader is the header of a receive-repo request which contains the
+// remote URL of the repo. It is not sent in the query string, since remote
+// URLs may contain credentials.
+const remoteURLHeader = "X-Sourcegraph-Remote-URL"
+
+var reposRebalanced = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "gitserver",
+	Name:      "repos_rebalanced",
+	Help:      "number of repos transferred to another gitserver and removed here",
+})
+
+func init() {
+	prometheus.MustRegister(reposRebalanced)
+}
+
+// rebalanceStatus is the progress of the current (or last) rebalance, for
+// debugging. It is shown by /list?rebalancing.
+type rebalanceStatus struct {
+	mu          sync.Mutex
+	running     bool
+	started     time.Time
+	remaining   int
+	transferred int
+	failed      int
+	repos       map[api.RepoName]string // state of the repos being rebalanced, or why they failed
+}
+
+func (st *rebalanceStatus) start(n int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.running, st.started, st.remaining, st.transferred, st.failed = true, time.Now(), n, 0, 0
+	st.repos = map[api.RepoName]string{}
+}
+
+func (st *rebalanceStatus) setRepo(repo api.RepoName, state string) {
+	st.mu.Lock()
+	st.repos[repo] = state
+	st.mu.Unlock()
+}
+
+func (st *rebalanceStatus) doneRepo(repo api.RepoName, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.remaining--
+	if err != nil {
+		// Keep failed repos in the status until the next rebalance, which
+		// retries them.
+		st.repos[repo] = "failed: " + err.Error()
+		st.failed++
+	} else {
+		delete(st.repos, repo)
+		st.transferred++
+	}
+}
+
+// finish marks the rebalance as finished and returns the number of repos
+// which failed to transfer.
+func (st *rebalanceStatus) finish() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.running = false
+	return st.failed
+}
+
+// lines returns the status as human readable lines.
+func (st *rebalanceStatus) lines() []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.started.IsZero() {
+		return []string{"no rebalance has run"}
+	}
+	state := "finished"
+	if st.running {
+		state = "running"
+	}
+	lines := []string{fmt.Sprintf("rebalance %s (started %s): %d remaining, %d transferred, %d failed", state, st.started.Format(time.RFC3339), st.remaining, st.transferred, st.failed)}
+	var repos []string
+	for repo, state := range st.repos {
+		repos = append(repos, fmt.Sprintf("%s: %s", repo, state))
+	}
+	sort.Strings(repos)
+	return append(lines, repos...)
+}
+
+// Rebalance transfers the repos which no longer belong on this gitserver
+// (according to BelongsOnShard) to the gitservers they now belong on
+// (according to RepoAddrs). A repo is only removed here once all of those
+// gitservers have confirmed they have a copy. It should be called when the
+// list of gitservers changes, so that new owners of a repo don't need to
+// clone it from the code host.
+//
+// Rebalance returns the number of repos which failed to transfer (e.g. because
+// a new owner was unreachable or busy cloning the repo). They are still here,
+// so calling Rebalance again retries them, even if the list of gitservers has
+// not changed since.
+//
+// Rebalance does nothing if BelongsOnShard or RepoAddrs is not set.
+func (s *Server) Rebalance() (failed int) {
+	if s.BelongsOnShard == nil || s.RepoAddrs == nil {
+		return 0
+	}
+	ctx, cancel := s.serverContext()
+	defer cancel()
+
+	type foreignRepo struct {
+		name   api.RepoName
+		gitDir string
+	}
+	var foreign []foreignRepo
+	filepath.Walk(s.ReposDir, func(gitDir string, fi os.FileInfo, fileErr error) error {
+		if fileErr != nil || ctx.Err() != nil {
+			return nil
+		}
+		if s.ignorePath(gitDir) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.IsDir() || fi.Name() != ".git" {
+			return nil
+		}
+		repo := s.repoNameFromDir(gitDir)
+		if belongs, ok := s.BelongsOnShard(ctx, repo); ok && !belongs {
+			foreign = append(foreign, foreignRepo{name: repo, gitDir: gitDir})
+		}
+		return filepath.SkipDir
+	})
+	if len(foreign) == 0 {
+		return 0
+	}
+
+	log15.Info("rebalancing repos which no longer belong on this gitserver", "count", len(foreign))
+	s.rebalance.start(len(foreign))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, rebalanceConcurrency)
+	for _, r := range foreign {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(repo api.RepoName, gitDir string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err := s.rebalanceRepo(ctx, repo, gitDir)
+			if err != nil {
+				log15.Error("failed to rebalance repo", "repo", repo, "error", err)
+			}
+			s.rebalance.doneRepo(repo, err)
+		}(r.name, r.gitDir)
+	}
+	wg.Wait()
+	return s.rebalance.finish()
+}
+
+// rebalanceRepo transfers repo to each gitserver it now belongs on which
+// doesn't have it yet, and then removes it here.
+func (s *Server) rebalanceRepo(ctx context.Context, repo api.RepoName, gitDir string) error {
+	remoteURL, err := repoRemoteURL(ctx, gitDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to get remote URL")
+	}
+	empty, err := repoEmpty(ctx, gitDir)
+	if err != nil {
+		return err
+	}
+
+	addrs := s.RepoAddrs(ctx, repo)
+	if len(addrs) == 0 {
+		return errors.New("no gitserver to transfer to")
+	}
+	for _, addr := range addrs {
+		if empty {
+			// git can't bundle a repo without refs, and there is nothing
+			// worth transferring. The new owner clones it on demand.
+			break
+		}
+		s.rebalance.setRepo(repo, "transferring to "+addr)
+		if err := s.transferRepo(ctx, addr, repo, gitDir, remoteURL); err != nil {
+			return errors.Wrapf(err, "failed to transfer to %s", addr)
+		}
+	}
+
+	// All new owners confirmed they have the repo, so it is safe to remove.
+	s.rebalance.setRepo(repo, "removing")
+	if err := s.removeRepoDirectory(gitDir); err != nil {
+		return err
+	}
+	reposRebalanced.Inc()
+	return nil
+}
+
+// transferRepo sends a bundle of repo to the gitserver at addr, unless it
+// already has a clone of repo. It returns nil once addr has confirmed it has a
+// clone.
+//
+// Partial and shallow clones are not bundled: git would fetch every object
+// which a partial clone omitted to create its bundle, and the bundle of a
+// shallow clone can't be cloned. Instead, addr clones them from their remote
+// (with the repo's git.cloneOptions, like any other clone).
+func (s *Server) transferRepo(ctx context.Context, addr string, repo api.RepoName, gitDir, remoteURL string) error {
+	cloned, err := peerRepoCloned(ctx, addr, repo)
+	if err != nil {
+		return err
+	}
+	if cloned {
+		return nil
+	}
+
+	if repoIsShallow(gitDir) || repoIsPartial(ctx, gitDir) {
+		return peerCloneRepo(ctx, addr, repo, remoteURL)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "bundle", "create", "-", "--all")
+	cmd.Dir = gitDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	bundle, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer cmd.Wait()
+
+	req, err := http.NewRequest("POST", "http://"+addr+"/receive-repo?repo="+url.QueryEscape(string(repo)), bundle)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-git-bundle")
+	req.Header.Set(remoteURLHeader, remoteURL)
+	resp, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("receive-repo: http status %d: %s", resp.StatusCode, body)
+	}
+	if err := cmd.Wait(); err != nil {
+		return errors.Wrapf(err, "git bundle failed: %s", stderr.String())
+	}
+	return nil
+}
+
+// peerCloneRepo asks the gitserver at addr to clone repo from remoteURL. It
+// returns nil once addr has confirmed it has a clone.
+func peerCloneRepo(ctx context.Context, addr string, repo api.RepoName, remoteURL string) error {
+	if remoteURL == "" {
+		return errors.New("can't transfer a partial or shallow clone without a remote URL")
+	}
+	req, err := http.NewRequest("POST", "http://"+addr+"/receive-repo?clone=true&repo="+url.QueryEscape(string(repo)), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(remoteURLHeader, remoteURL)
+	resp, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("receive-repo: http status %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// peerRepoCloned asks the gitserver at addr whether it has a clone of repo.
+func peerRepoCloned(ctx context.Context, addr string, repo api.RepoName) (bool, error) {
+	body, err := json.Marshal(&protocol.IsRepoClonedRequest{Repo: repo})
+	if err != nil {
+		return false, err
+	}
+	resp, err := ctxhttp.Post(ctx, nil, "http://"+addr+"/is-repo-cloned", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("is-repo-cloned: http status %d", resp.StatusCode)
+}
+
+// repoEmpty reports whether the repo in gitDir has no refs.
+func repoEmpty(ctx context.Context, gitDir string) (bool, error) {
+	cmd := exec.CommandContext(ctx, "git", "for-each-ref", "--count=1")
+	cmd.Dir = gitDir
+	out, err := cmd.Output()
+	if err != nil {
+		return false, wrapCmdError(cmd, err)
+	}
+	return len(bytes.TrimSpace(out)) == 0, nil
+}
+
+// handleReceiveRepo clones a repo from the git bundle in the request body,
+// or (with the "clone" query parameter) from its remote URL. It is used by
+// other gitservers to transfer repos which now belong on this gitserver. A
+// 200 response confirms that this gitserver has a clone of the repo (possibly
+// one it already had), so the sender may remove its copy.
+func (s *Server) handleReceiveRepo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	repo := protocol.NormalizeRepo(api.RepoName(r.URL.Query().Get("repo")))
+	if repo == "" {
+		http.Error(w, "repo missing", http.StatusBadRequest)
+		return
+	}
+	dir := filepath.Join(s.ReposDir, string(repo))
+	if repoCloned(dir) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.URL.Query().Get("clone") == "true" {
+		// The sender's copy can't be bundled (see transferRepo).
+		remoteURL := r.Header.Get(remoteURLHeader)
+		if remoteURL == "" {
+			http.Error(w, "remote URL missing", http.StatusBadRequest)
+			return
+		}
+		if _, err := s.cloneRepo(r.Context(), repo, remoteURL, &cloneOptions{Block: true}); err != nil {
+			log15.Error("failed to clone repo for another gitserver", "repo", repo, "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !repoCloned(dir) {
+			// Someone else is cloning the repo. The sender retries it the
+			// next time it rebalances.
+			http.Error(w, "clone in progress", http.StatusConflict)
+			return
+		}
+		log15.Info("repo cloned for another gitserver", "repo", repo)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	lock, ok := s.locker.TryAcquire(dir, "receiving from another gitserver")
+	if !ok {
+		// Someone else is cloning the repo. The sender retries it the next
+		// time it rebalances.
+		http.Error(w, "clone in progress", http.StatusConflict)
+		return
+	}
+	defer lock.Release()
+
+	if err := s.receiveRepo(r.Context(), repo, dir, r.Header.Get(remoteURLHeader), r.Body); err != nil {
+		log15.Error("failed to receive repo", "repo", repo, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log15.Info("repo received from another gitserver", "repo", repo)
+	w.WriteHeader(http.StatusOK)
+}
+
+// receiveRepo clones repo into dir from the git bundle read from bundle.
+func (s *Server) receiveRepo(ctx context.Context, repo api.RepoName, dir, remoteURL string, bundle io.Reader) error {
+	tmp, err := s.tempDir("receive-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	tmpPath := filepath.Join(tmp, ".git")
+	if err := s.cloneBundle(ctx, bundle, remoteURL, tmpPath); err != nil {
+		return err
+	}
+	if err := setLastChanged(tmpPath); err != nil {
+		return errors.Wrapf(err, "failed to update last changed time")
+	}
+	if err := setGitAttributes(tmpPath); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(dir, ".git"))
+}
+
+// cloneBundle clones the git bundle read from bundle into the bare repo
+// gitDir, whose origin is then set to remoteURL.
+func (s *Server) cloneBundle(ctx context.Context, bundle io.Reader, remoteURL, gitDir string) error {
+	tmp, err := s.tempDir("bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	// git clone needs to seek in the bundle, so we can't stream it.
+	bundlePath := filepath.Join(tmp, "repo.bundle")
+	f, err := os.Create(bundlePath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, bundle)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read bundle")
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "clone", "--mirror", bundlePath, gitDir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "clone from bundle failed. Output: %s", out)
+	}
+	if remoteURL != "" {
+		cmd = exec.CommandContext(ctx, "git", "remote", "set-url", "origin", remoteURL)
+		cmd.Dir = gitDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "failed to set remote URL. Output: %s", out)
+		}
+	}
+	return nil
+}
+
+// cloneFromPreviousOwner clones repo into the bare repo gitDir from a bundle
+// fetched from a gitserver repo belonged on before the list of gitservers
+// changed (see PreviousRepoAddrs). It returns false if none of them has a
+// copy which can be bundled.
+func (s *Server) cloneFromPreviousOwner(ctx context.Context, repo api.RepoName, remoteURL, gitDir string) (bool, error) {
+	if s.PreviousRepoAddrs == nil {
+		return false, nil
+	}
+	for _, addr := range s.PreviousRepoAddrs(ctx, repo) {
+		req, err := http.NewRequest("GET", "http://"+addr+"/bundle-repo?repo="+url.QueryEscape(string(repo)), nil)
+		if err != nil {
+			return false, err
+		}
+		resp, err := ctxhttp.Do(ctx, nil, req)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to fetch bundle from %s", addr)
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			// This includes the gitserver asking itself.
+			resp.Body.Close()
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 200))
+			resp.Body.Close()
+			return false, fmt.Errorf("bundle-repo on %s: http status %d: %s", addr, resp.StatusCode, body)
+		}
+		err = s.cloneBundle(ctx, resp.Body, remoteURL, gitDir)
+		resp.Body.Close()
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to clone bundle from %s", addr)
+		}
+		log15.Info("repo fetched from the previous gitserver", "repo", repo, "addr", addr)
+		return true, nil
+	}
+	return false, nil
+}
+
+// handleBundleRepo responds with a git bundle of a repo, or 404 if this
+// gitserver has no copy which can be bundled (see transferRepo). It is used by
+// the gitservers a repo now belongs on to fetch it from this gitserver before
+// it has transferred the repo to them.
+func (s *Server) handleBundleRepo(w http.ResponseWriter, r *http.Request) {
+	repo := protocol.NormalizeRepo(api.RepoName(r.URL.Query().Get("repo")))
+	if repo == "" {
+		http.Error(w, "repo missing", http.StatusBadRequest)
+		return
+	}
+	dir := filepath.Join(s.ReposDir, string(repo))
+	if !repoCloned(dir) {
+		http.Error(w, "repository not cloned", http.StatusNotFound)
+		return
+	}
+	gitDir := filepath.Join(dir, ".git")
+	if repoIsShallow(gitDir) || repoIsPartial(r.Context(), gitDir) {
+		http.Error(w, "partial and shallow clones can't be bundled", http.StatusNotFound)
+		return
+	}
+	if empty, err := repoEmpty(r.Context(), gitDir); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if empty {
+		http.Error(w, "repository is empty", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-git-bundle")
+	cmd := exec.CommandContext(r.Context(), "git", "bundle", "create", "-", "--all")
+	cmd.Dir = gitDir
+	cmd.Stdout = w
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// The response has started, so the receiver only sees a truncated
+		// bundle, which git refuses to clone.
+		log15.Error("failed to bundle repo", "repo", repo, "error", err, "stderr", stderr.String())
+	}
+}
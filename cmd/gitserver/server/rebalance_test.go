@@ -0,0 +1,278 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+)
+
+func TestRebalance(t *testing.T) {
+	srcRoot, cleanup := tmpDir(t)
+	defer cleanup()
+	dstRoot, cleanup := tmpDir(t)
+	defer cleanup()
+
+	// repoA stays, repoB moves to dst and repoC belongs nowhere we know of.
+	for _, repo := range []string{testRepoA, testRepoB, testRepoC} {
+		initRepoWithCommit(t, filepath.Join(srcRoot, repo, ".git"), "https://example.com/"+repo)
+	}
+
+	dst := &Server{ReposDir: dstRoot}
+	ts := httptest.NewServer(dst.Handler())
+	defer ts.Close()
+	dstAddr := strings.TrimPrefix(ts.URL, "http://")
+
+	src := &Server{
+		ReposDir: srcRoot,
+		BelongsOnShard: func(ctx context.Context, repo api.RepoName) (belongs, ok bool) {
+			switch repo {
+			case protocol.NormalizeRepo(testRepoA):
+				return true, true
+			case protocol.NormalizeRepo(testRepoB):
+				return false, true
+			}
+			return false, false // unknown
+		},
+		RepoAddrs: func(ctx context.Context, repo api.RepoName) []string {
+			return []string{dstAddr}
+		},
+	}
+	h := src.Handler()
+	src.Rebalance()
+
+	if _, err := os.Stat(filepath.Join(srcRoot, testRepoA, ".git")); err != nil {
+		t.Error("expected repoA not to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(srcRoot, testRepoB, ".git")); !os.IsNotExist(err) {
+		t.Error("expected repoB to be removed after rebalancing")
+	}
+	if _, err := os.Stat(filepath.Join(srcRoot, testRepoC, ".git")); err != nil {
+		t.Error("expected repoC not to be removed, since we don't know where it belongs")
+	}
+
+	gitDir := filepath.Join(dstRoot, string(protocol.NormalizeRepo(testRepoB)), ".git")
+	cmd := exec.Command("git", "rev-parse", "--verify", "refs/heads/master")
+	cmd.Dir = gitDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("expected repoB to be transferred: %s (output: %s)", err, out)
+	}
+	if got, err := repoRemoteURL(context.Background(), gitDir); err != nil {
+		t.Fatal(err)
+	} else if want := "https://example.com/" + testRepoB; got != want {
+		t.Errorf("got remote URL %q, want %q", got, want)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/list?rebalancing", nil))
+	var status []string
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 1 || !strings.Contains(status[0], "0 remaining, 1 transferred, 0 failed") {
+		t.Errorf("unexpected rebalance status %q", status)
+	}
+}
+
+func TestRebalance_unconfirmed(t *testing.T) {
+	root, cleanup := tmpDir(t)
+	defer cleanup()
+	dstRoot, cleanup := tmpDir(t)
+	defer cleanup()
+
+	gitDir := filepath.Join(root, testRepoA, ".git")
+	initRepoWithCommit(t, gitDir, "https://example.com/"+testRepoA)
+
+	// The new owner is unreachable at first, so the repo must be kept.
+	var available int32
+	dst := (&Server{ReposDir: dstRoot}).Handler()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&available) == 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		dst.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	s := &Server{
+		ReposDir: root,
+		BelongsOnShard: func(ctx context.Context, repo api.RepoName) (belongs, ok bool) {
+			return false, true
+		},
+		RepoAddrs: func(ctx context.Context, repo api.RepoName) []string {
+			return []string{strings.TrimPrefix(ts.URL, "http://")}
+		},
+	}
+	h := s.Handler()
+	if failed := s.Rebalance(); failed != 1 {
+		t.Errorf("got %d failed transfers, want 1", failed)
+	}
+	if _, err := os.Stat(gitDir); err != nil {
+		t.Error("expected repoA not to be removed, since the new owner did not confirm")
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/list?rebalancing", nil))
+	var status []string
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 2 || !strings.HasPrefix(status[1], string(protocol.NormalizeRepo(testRepoA))+": failed: ") {
+		t.Errorf("expected the failed repo in the rebalance status, got %q", status)
+	}
+
+	// The next rebalance retries the repo, although the list of gitservers
+	// has not changed.
+	atomic.StoreInt32(&available, 1)
+	if failed := s.Rebalance(); failed != 0 {
+		t.Errorf("got %d failed transfers on retry, want 0", failed)
+	}
+	if _, err := os.Stat(gitDir); !os.IsNotExist(err) {
+		t.Error("expected repoA to be removed after the retry")
+	}
+}
+
+func TestRebalance_shallow(t *testing.T) {
+	root, cleanup := tmpDir(t)
+	defer cleanup()
+	dstRoot, cleanup := tmpDir(t)
+	defer cleanup()
+
+	// A shallow clone of a repo with two commits can't be bundled, so the
+	// new owner clones the repo from its remote instead.
+	upstream := filepath.Join(root, "upstream")
+	initRepoWithCommit(t, upstream, "https://example.com/upstream")
+	gitDir := filepath.Join(root, testRepoA, ".git")
+	for _, script := range []string{
+		"git update-ref refs/heads/master $(git commit-tree -p master -m second $(git mktree </dev/null))",
+		"git clone --mirror --depth=1 file://" + upstream + " " + gitDir,
+	} {
+		cmd := exec.Command("sh", "-c", script)
+		cmd.Dir = upstream
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s failed: %s (output: %s)", script, err, out)
+		}
+	}
+	if !repoIsShallow(gitDir) {
+		t.Fatal("expected the repo to be shallow")
+	}
+
+	ts := httptest.NewServer((&Server{ReposDir: dstRoot}).Handler())
+	defer ts.Close()
+	s := &Server{
+		ReposDir: root,
+		BelongsOnShard: func(ctx context.Context, repo api.RepoName) (belongs, ok bool) {
+			return false, true
+		},
+		RepoAddrs: func(ctx context.Context, repo api.RepoName) []string {
+			return []string{strings.TrimPrefix(ts.URL, "http://")}
+		},
+	}
+	s.Handler()
+	if failed := s.Rebalance(); failed != 0 {
+		t.Fatalf("got %d failed transfers, want 0", failed)
+	}
+	if _, err := os.Stat(gitDir); !os.IsNotExist(err) {
+		t.Error("expected repoA to be removed after rebalancing")
+	}
+
+	dstGitDir := filepath.Join(dstRoot, string(protocol.NormalizeRepo(testRepoA)), ".git")
+	cmd := exec.Command("git", "rev-list", "--count", "refs/heads/master")
+	cmd.Dir = dstGitDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("expected repoA to be cloned by the new owner: %s (output: %s)", err, out)
+	} else if got := strings.TrimSpace(string(out)); got != "2" {
+		// No git.cloneOptions apply, so the new owner has a full clone.
+		t.Errorf("got %s commits, want 2", got)
+	}
+}
+
+func TestCloneRepo_fromPreviousOwner(t *testing.T) {
+	root, cleanup := tmpDir(t)
+	defer cleanup()
+	prevRoot, cleanup := tmpDir(t)
+	defer cleanup()
+	dstRoot, cleanup := tmpDir(t)
+	defer cleanup()
+
+	// The previous owner's copy has a commit which the remote doesn't, so we
+	// can tell where the repo was cloned from.
+	upstream := filepath.Join(root, "upstream")
+	initRepoWithCommit(t, upstream, "https://example.com/upstream")
+	remoteURL := "file://" + upstream
+	prevGitDir := filepath.Join(prevRoot, string(protocol.NormalizeRepo(testRepoA)), ".git")
+	initRepoWithCommit(t, prevGitDir, remoteURL)
+	cmd := exec.Command("sh", "-c", "git update-ref refs/heads/master $(git commit-tree -p master -m second $(git mktree </dev/null))")
+	cmd.Dir = prevGitDir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to commit: %s (output: %s)", err, out)
+	}
+
+	ts := httptest.NewServer((&Server{ReposDir: prevRoot}).Handler())
+	defer ts.Close()
+	s := &Server{
+		ReposDir: dstRoot,
+		PreviousRepoAddrs: func(ctx context.Context, repo api.RepoName) []string {
+			return []string{strings.TrimPrefix(ts.URL, "http://")}
+		},
+	}
+	s.Handler()
+	if _, err := s.cloneRepo(context.Background(), testRepoA, remoteURL, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	gitDir := filepath.Join(dstRoot, string(protocol.NormalizeRepo(testRepoA)), ".git")
+	cmd = exec.Command("git", "rev-list", "--count", "refs/heads/master")
+	cmd.Dir = gitDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("expected repoA to be cloned: %s (output: %s)", err, out)
+	} else if got := strings.TrimSpace(string(out)); got != "2" {
+		t.Errorf("got %s commits, want 2 (the previous owner's copy)", got)
+	}
+	if got, err := repoRemoteURL(context.Background(), gitDir); err != nil {
+		t.Fatal(err)
+	} else if got != remoteURL {
+		t.Errorf("got remote URL %q, want %q", got, remoteURL)
+	}
+
+	// A repo which the previous owner doesn't have is cloned from its remote.
+	if _, err := s.cloneRepo(context.Background(), testRepoB, remoteURL, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !repoCloned(filepath.Join(dstRoot, string(protocol.NormalizeRepo(testRepoB)))) {
+		t.Error("expected repoB to be cloned from its remote")
+	}
+}
+
+// initRepoWithCommit creates a bare repo in gitDir with an empty commit on
+// master and the given origin.
+func initRepoWithCommit(t *testing.T, gitDir, remoteURL string) {
+	t.Helper()
+	if err := os.MkdirAll(gitDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range []string{
+		"git init --bare .",
+		"git remote add origin " + remoteURL,
+		"git update-ref refs/heads/master $(git commit-tree -m init $(git mktree </dev/null))",
+	} {
+		cmd := exec.Command("sh", "-c", script)
+		cmd.Dir = gitDir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s failed: %s (output: %s)", script, err, out)
+		}
+	}
+}
@@ -58,6 +58,15 @@ func (s *Server) handleRepoInfo(w http.ResponseWriter, r *http.Request) {
 		} else {
 			resp.LastChanged = &lastChanged
 		}
+
+		if size := s.repoSizes.get(repo); size != nil {
+			resp.Size = size
+			if err := checkRepoQuota(repo, size); err != nil {
+				resp.QuotaExceeded = err.Error()
+			}
+		}
+	} else if err := s.repoSizes.refusedClone(repo); err != nil {
+		resp.QuotaExceeded = err.Error()
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
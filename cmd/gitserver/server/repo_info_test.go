@@ -78,7 +78,10 @@ func TestServer_handleRepoInfo(t *testing.T) {
 		repoRemoteURL = func(context.Context, string) (string, error) { return "u", nil }
 		defer func() { repoRemoteURL = origRepoRemoteURL }()
 
-		if got, want := getRepoInfo(t, "x"), (protocol.RepoInfoResponse{Cloned: true, LastFetched: &lastFetched, LastChanged: &lastChanged, URL: "u"}); !reflect.DeepEqual(got, want) {
+		// The size computed by the janitor is served.
+		s.repoSizes.update(&protocol.RepoSize{Repo: "x", TotalBytes: 1})
+
+		if got, want := getRepoInfo(t, "x"), (protocol.RepoInfoResponse{Cloned: true, LastFetched: &lastFetched, LastChanged: &lastChanged, URL: "u", Size: &protocol.RepoSize{Repo: "x", TotalBytes: 1}}); !reflect.DeepEqual(got, want) {
 			t.Errorf("got %+v, want %+v", got, want)
 		}
 	})
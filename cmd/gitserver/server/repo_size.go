@@ -0,0 +1,270 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+)
+
+// refusedCloneTTL is how long we remember that the clone of a repository
+// was refused because of the gitMaxRepoSizeMB quota. Until then, the
+// repository is not cloned again unless the quota is raised.
+const refusedCloneTTL = 24 * time.Hour
+
+// diskUsageInterval is how often the disk usage of a clone in progress is
+// checked against the gitMaxRepoSizeMB quota.
+var diskUsageInterval = 5 * time.Second
+
+// repoSizes tracks the disk usage of repositories.
+type repoSizes struct {
+	mu sync.Mutex
+
+	// sizes of the cloned repositories, computed by the janitor (and
+	// after each clone).
+	sizes      map[api.RepoName]*protocol.RepoSize
+	computedAt time.Time
+
+	// refused are the clones refused because of the quota.
+	refused map[api.RepoName]refusedClone
+}
+
+type refusedClone struct {
+	size int64
+	at   time.Time
+}
+
+// repoQuotaExceededError is the error when a repository is not cloned or
+// updated because it is larger than the gitMaxRepoSizeMB quota.
+type repoQuotaExceededError struct {
+	repo        api.RepoName
+	size, limit int64
+}
+
+func (e *repoQuotaExceededError) Error() string {
+	return fmt.Sprintf("repository %s uses %d MB of disk space, which exceeds the limit of %d MB (gitMaxRepoSizeMB in site configuration)", e.repo, e.size>>20, e.limit>>20)
+}
+
+func isRepoQuotaExceeded(err error) bool {
+	_, ok := errors.Cause(err).(*repoQuotaExceededError)
+	return ok
+}
+
+// repoMaxSize returns the gitMaxRepoSizeMB quota in bytes, or 0 if there is
+// no quota.
+func repoMaxSize() int64 {
+	return int64(conf.Get().GitMaxRepoSizeMB) << 20
+}
+
+// checkRepoQuota returns a *repoQuotaExceededError if size exceeds the
+// gitMaxRepoSizeMB quota.
+func checkRepoQuota(repo api.RepoName, size *protocol.RepoSize) error {
+	if limit := repoMaxSize(); limit > 0 && size.TotalBytes > limit {
+		return &repoQuotaExceededError{repo: repo, size: size.TotalBytes, limit: limit}
+	}
+	return nil
+}
+
+// refuseClone records that the clone of repo was refused because of the
+// quota.
+func (r *repoSizes) refuseClone(repo api.RepoName, size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.refused == nil {
+		r.refused = make(map[api.RepoName]refusedClone)
+	}
+	r.refused[repo] = refusedClone{size: size, at: time.Now()}
+}
+
+// refusedClone returns a *repoQuotaExceededError if the clone of repo was
+// refused recently and the quota has not been raised since.
+func (r *repoSizes) refusedClone(repo api.RepoName) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	refused, ok := r.refused[repo]
+	if !ok {
+		return nil
+	}
+	if limit := repoMaxSize(); limit > 0 && refused.size > limit && time.Since(refused.at) < refusedCloneTTL {
+		return &repoQuotaExceededError{repo: repo, size: refused.size, limit: limit}
+	}
+	delete(r.refused, repo)
+	return nil
+}
+
+func (r *repoSizes) set(sizes map[api.RepoName]*protocol.RepoSize) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sizes = sizes
+	r.computedAt = time.Now()
+}
+
+// update records the size of a single repository, e.g. once it is cloned.
+func (r *repoSizes) update(size *protocol.RepoSize) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sizes == nil {
+		r.sizes = make(map[api.RepoName]*protocol.RepoSize)
+	}
+	r.sizes[size.Repo] = size
+}
+
+// get returns the size of repo as of the janitor's last run (or its clone,
+// if more recent), or nil if it is not known. Computing the size walks the
+// whole repository, so it is too slow to do on every request.
+func (r *repoSizes) get(repo api.RepoName) *protocol.RepoSize {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sizes[repo]
+}
+
+// handleRepoSizes lists the disk usage of the repositories on this
+// gitserver, largest first. The sizes are computed by the janitor, so they
+// are only as recent as its last run.
+func (s *Server) handleRepoSizes(w http.ResponseWriter, r *http.Request) {
+	var resp protocol.RepoSizesResponse
+	s.repoSizes.mu.Lock()
+	resp.ComputedAt = s.repoSizes.computedAt
+	for _, size := range s.repoSizes.sizes {
+		resp.Repos = append(resp.Repos, size)
+	}
+	s.repoSizes.mu.Unlock()
+
+	sort.Slice(resp.Repos, func(i, j int) bool {
+		if resp.Repos[i].TotalBytes != resp.Repos[j].TotalBytes {
+			return resp.Repos[i].TotalBytes > resp.Repos[j].TotalBytes
+		}
+		return resp.Repos[i].Repo < resp.Repos[j].Repo
+	})
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// repoSize returns the disk usage of the repository in dir (or
+// `${dir}/.git`), using `git count-objects`.
+var repoSize = func(ctx context.Context, dir string) (*protocol.RepoSize, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git", "HEAD")); err == nil {
+		dir = filepath.Join(dir, ".git")
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "count-objects", "--verbose")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(wrapCmdError(cmd, err), "failed to count objects")
+	}
+	size, err := parseCountObjects(out)
+	if err != nil {
+		return nil, err
+	}
+
+	size.TotalBytes, err = dirSize(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compute disk usage")
+	}
+
+	// git repack (which git gc runs) writes objects/info/packs.
+	if fi, err := os.Stat(filepath.Join(dir, "objects", "info", "packs")); err == nil {
+		lastGC := fi.ModTime()
+		size.LastGC = &lastGC
+	}
+
+	return size, nil
+}
+
+// dirSize returns the total size of the regular files in dir.
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// Files such as locks and temporary packs come and go.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			total += fi.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// watchDiskUsage checks the disk usage of dir every diskUsageInterval, and
+// calls exceeded with it (at most once) when it exceeds limit. It is used to
+// stop clones as soon as they are too large, instead of once they are
+// complete. The watch stops when ctx is done or stop is called.
+func watchDiskUsage(ctx context.Context, dir string, limit int64, exceeded func(size int64)) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(diskUsageInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if size, err := dirSize(dir); err == nil && size > limit {
+				exceeded(size)
+				return
+			}
+		}
+	}()
+	return cancel
+}
+
+// parseCountObjects parses the output of `git count-objects --verbose`,
+// which reports sizes in KiB.
+func parseCountObjects(out []byte) (*protocol.RepoSize, error) {
+	var size protocol.RepoSize
+	fields := map[string]*int64{
+		"count":        &size.LooseObjects,
+		"size":         &size.LooseBytes,
+		"in-pack":      &size.PackedObjects,
+		"packs":        &size.Packs,
+		"size-pack":    &size.PackedBytes,
+		"size-garbage": &size.GarbageBytes,
+	}
+	kib := map[string]bool{"size": true, "size-pack": true, "size-garbage": true}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		field, ok := fields[parts[0]]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s in output of git count-objects", parts[0])
+		}
+		if kib[parts[0]] {
+			n <<= 10
+		}
+		*field = n
+	}
+	return &size, scanner.Err()
+}
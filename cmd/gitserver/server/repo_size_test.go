@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestParseCountObjects(t *testing.T) {
+	out := `count: 3
+size: 12
+in-pack: 100
+packs: 2
+size-pack: 2048
+prune-packable: 0
+garbage: 1
+size-garbage: 1
+`
+	got, err := parseCountObjects([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &protocol.RepoSize{
+		LooseObjects:  3,
+		LooseBytes:    12 << 10,
+		PackedObjects: 100,
+		Packs:         2,
+		PackedBytes:   2048 << 10,
+		GarbageBytes:  1 << 10,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCloneRepo_quota(t *testing.T) {
+	remote, cleanup1 := tmpDir(t)
+	defer cleanup1()
+
+	// Setup a repo with a 2 MB file that does not compress.
+	for _, c := range []string{
+		"git init .",
+		"head -c 2097152 /dev/urandom > large",
+		"git add large",
+		"git -c user.name=a -c user.email=a@a.com commit -m large",
+	} {
+		cmd := exec.Command("sh", "-c", c)
+		cmd.Dir = remote
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s failed: %s (output: %s)", c, err, out)
+		}
+	}
+
+	reposDir, cleanup2 := tmpDir(t)
+	defer cleanup2()
+
+	conf.Mock(&schema.SiteConfiguration{GitMaxRepoSizeMB: 1})
+	defer conf.Mock(nil)
+
+	s := &Server{
+		ReposDir:         reposDir,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+		cloneLimiter:     mutablelimiter.New(1),
+		cloneableLimiter: mutablelimiter.New(1),
+	}
+	// A local clone hardlinks the loose objects, so use a file:// URL.
+	url := "file://" + remote
+	_, err := s.cloneRepo(context.Background(), "example.com/foo/bar", url, &cloneOptions{Block: true})
+	if !isRepoQuotaExceeded(err) {
+		t.Fatalf("got error %v, want quota exceeded", err)
+	}
+	dir := filepath.Join(reposDir, "example.com/foo/bar")
+	if repoCloned(dir) {
+		t.Error("expected the clone to be discarded")
+	}
+
+	// The repository is not cloned again, until the quota is raised.
+	if _, err := s.cloneRepo(context.Background(), "example.com/foo/bar", url, &cloneOptions{Block: true}); !isRepoQuotaExceeded(err) {
+		t.Fatalf("got error %v on the second clone, want quota exceeded", err)
+	}
+	conf.Mock(&schema.SiteConfiguration{GitMaxRepoSizeMB: 10})
+	if _, err := s.cloneRepo(context.Background(), "example.com/foo/bar", url, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := repoSize(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size.TotalBytes < 2<<20 || size.PackedBytes < 2<<20 || size.Packs != 1 {
+		t.Errorf("unexpected size %+v", size)
+	}
+
+	// Repositories which grow too large are not updated anymore.
+	conf.Mock(&schema.SiteConfiguration{GitMaxRepoSizeMB: 1})
+	if err := s.doRepoUpdate2("example.com/foo/bar", url); !isRepoQuotaExceeded(err) {
+		t.Fatalf("got error %v on update, want quota exceeded", err)
+	} else if !strings.Contains(err.Error(), "exceeds the limit of 1 MB") {
+		t.Errorf("unexpected error message %q", err)
+	}
+}
+
+func TestWatchDiskUsage(t *testing.T) {
+	dir, cleanup := tmpDir(t)
+	defer cleanup()
+
+	orig := diskUsageInterval
+	diskUsageInterval = time.Millisecond
+	defer func() { diskUsageInterval = orig }()
+
+	exceeded := make(chan int64, 1)
+	stop := watchDiskUsage(context.Background(), dir, 100, func(size int64) { exceeded <- size })
+	defer stop()
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "small"), make([]byte, 50), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case size := <-exceeded:
+		t.Fatalf("quota exceeded with %d bytes, want not exceeded", size)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "large"), make([]byte, 100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case size := <-exceeded:
+		if size != 150 {
+			t.Errorf("got size %d, want 150", size)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the quota to be exceeded")
+	}
+}
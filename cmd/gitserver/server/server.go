@@ -112,6 +112,29 @@ type Server struct {
 	// Janitor job runs.
 	DeleteStaleRepositories bool
 
+	// MaintenanceLimit is the maximum number of repositories which a Janitor
+	// run maintains (see maintainRepos). 0 disables maintenance.
+	MaintenanceLimit int
+
+	// BelongsOnShard, if set, reports whether repo belongs on this gitserver,
+	// i.e. whether this gitserver is its primary gitserver or one of its
+	// replicas. Rebalance transfers the repositories which do not belong here
+	// to the gitservers returned by RepoAddrs, and Janitor removes the ones
+	// those gitservers already have. ok is false if this can't be determined
+	// (e.g. because the gitserver addresses are unknown), in which case the
+	// repository is kept.
+	BelongsOnShard func(ctx context.Context, repo api.RepoName) (belongs, ok bool)
+
+	// RepoAddrs, if set, returns the addresses of the gitservers repo belongs
+	// on.
+	RepoAddrs func(ctx context.Context, repo api.RepoName) []string
+
+	// PreviousRepoAddrs, if set, returns the addresses of the gitservers repo
+	// belonged on before the list of gitservers last changed, until the repos
+	// have been rebalanced. Those gitservers still have repo, so a repo which
+	// is not cloned here is fetched from them instead of from its remote.
+	PreviousRepoAddrs func(ctx context.Context, repo api.RepoName) []string
+
 	// skipCloneForTests is set by tests to avoid clones.
 	skipCloneForTests bool
 
@@ -126,6 +149,13 @@ type Server struct {
 
 	locker *RepositoryLocker
 
+	// rebalance is the progress of Rebalance.
+	rebalance rebalanceStatus
+
+	// repoSizes is the disk usage of the repositories, and the clones
+	// refused because of the gitMaxRepoSizeMB quota.
+	repoSizes repoSizes
+
 	// cloneLimiter and cloneableLimiter limits the number of concurrent
 	// clones and ls-remotes respectively. Use s.acquireCloneLimiter() and
 	// s.acquireClonableLimiter() instead of using these directly.
@@ -224,9 +254,13 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/is-repo-cloneable", s.handleIsRepoCloneable)
 	mux.HandleFunc("/is-repo-cloned", s.handleIsRepoCloned)
 	mux.HandleFunc("/repo", s.handleRepoInfo)
+	mux.HandleFunc("/repo-sizes", s.handleRepoSizes)
+	mux.HandleFunc("/fetch-history", s.handleFetchHistory)
 	mux.HandleFunc("/delete", s.handleRepoDelete)
 	mux.HandleFunc("/repo-update", s.handleRepoUpdate)
 	mux.HandleFunc("/upload-pack", s.handleUploadPack)
+	mux.HandleFunc("/receive-repo", s.handleReceiveRepo)
+	mux.HandleFunc("/bundle-repo", s.handleBundleRepo)
 	mux.HandleFunc("/getGitolitePhabricatorMetadata", s.handleGetGitolitePhabricatorMetadata)
 	mux.HandleFunc("/create-commit-from-patch", s.handleCreateCommitFromPatch)
 	return mux
@@ -240,6 +274,10 @@ func (s *Server) Janitor() {
 
 	// Other janitorial tasks
 	s.cleanupRepos()
+
+	// Run git gc incrementally and write the indexes which speed up reading
+	// repositories.
+	s.maintainRepos()
 }
 
 // Stop cancels the running background jobs and returns when done.
@@ -402,6 +440,7 @@ func (s *Server) handleRepoUpdate(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			log15.Warn("error cloning repo", "repo", req.Repo, "err", err)
 			resp.Error = err.Error()
+			resp.QuotaExceeded = isRepoQuotaExceeded(err)
 		}
 	} else {
 		resp.Cloned = true
@@ -436,6 +475,7 @@ func (s *Server) handleRepoUpdate(w http.ResponseWriter, r *http.Request) {
 		// An update error "wins" over a status error.
 		if updateErr != nil {
 			resp.Error = updateErr.Error()
+			resp.QuotaExceeded = isRepoQuotaExceeded(updateErr)
 		}
 	}
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -561,6 +601,12 @@ func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
 	}
 	if !repoCloned(dir) {
 		cloneProgress, err := s.cloneRepo(ctx, req.Repo, req.URL, nil)
+		if isRepoQuotaExceeded(err) {
+			status = "quota-exceeded"
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(&protocol.NotFoundPayload{QuotaExceeded: err.Error()})
+			return
+		}
 		if err != nil {
 			log15.Debug("error cloning repo", "repo", req.Repo, "err", err)
 			status = "repo-not-found"
@@ -609,6 +655,10 @@ func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
 	cmdStart = time.Now()
 	cmd := exec.CommandContext(ctx, "git", req.Args...)
 	cmd.Dir = dir
+	// A partial clone fetches the objects it omitted from its remote when a
+	// command first reads them, so the command must not prompt.
+	cmd.Env = os.Environ()
+	setRemoteOpts(cmd)
 	cmd.Stdout = stdoutW
 	cmd.Stderr = stderrW
 
@@ -678,6 +728,11 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoName, url string, o
 		return progress, nil
 	}
 
+	// Don't clone repositories again which were too large the last time.
+	if err := s.repoSizes.refusedClone(repo); err != nil {
+		return "", err
+	}
+
 	// isCloneable causes a network request, so we limit the number that can
 	// run at one time. We use a separate semaphore to cloning since these
 	// checks being blocked by a few slow clones will lead to poor feedback to
@@ -742,15 +797,68 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoName, url string, o
 		defer os.RemoveAll(tmpPath)
 		tmpPath = filepath.Join(tmpPath, ".git")
 
-		cmd := exec.CommandContext(ctx, "git", "clone", "--mirror", "--progress", url, tmpPath)
-		log15.Info("cloning repo", "repo", repo, "tmp", tmpPath, "dst", dstPath)
+		// Stop the clone as soon as it uses more disk space than the quota
+		// allows.
+		cloneCtx, cancelClone := context.WithCancel(ctx)
+		defer cancelClone()
+		var exceededSize int64 // accessed atomically
+		if limit := repoMaxSize(); limit > 0 {
+			stop := watchDiskUsage(cloneCtx, tmpPath, limit, func(size int64) {
+				atomic.StoreInt64(&exceededSize, size)
+				cancelClone()
+			})
+			defer stop()
+		}
+
+		// While the repos are being rebalanced, the gitserver repo
+		// belonged on before has a copy, which is cheaper to fetch than
+		// cloning it from the code host.
+		fromPeer, err := s.cloneFromPreviousOwner(cloneCtx, repo, url, tmpPath)
+		if err != nil && cloneCtx.Err() == nil {
+			log15.Warn("failed to fetch repo from the previous gitserver, cloning it from its remote", "repo", repo, "error", err)
+			fromPeer, err = false, os.RemoveAll(tmpPath)
+		}
+
+		cloneOpts := repoCloneOptions(repo)
+		if err == nil && !fromPeer {
+			cmd := exec.CommandContext(cloneCtx, "git", cloneArgs(cloneOpts, url, tmpPath)...)
+			log15.Info("cloning repo", "repo", repo, "tmp", tmpPath, "dst", dstPath)
+
+			pr, pw := io.Pipe()
+			defer pw.Close()
+			go readCloneProgress(repo, url, lock, pr)
+
+			if output, err1 := s.runWithRemoteOpts(cloneCtx, cmd, pw); err1 != nil {
+				err = errors.Wrapf(err1, "clone failed. Output: %s", string(output))
+			}
+		}
+		if err != nil {
+			if size := atomic.LoadInt64(&exceededSize); size > 0 {
+				s.repoSizes.refuseClone(repo, size)
+				return &repoQuotaExceededError{repo: repo, size: size, limit: repoMaxSize()}
+			}
+			return err
+		}
 
-		pr, pw := io.Pipe()
-		defer pw.Close()
-		go readCloneProgress(repo, url, lock, pr)
+		if !fromPeer && cloneOpts != nil && cloneOpts.Filter != "" {
+			if err := s.prefetchHEAD(ctx, tmpPath); err != nil {
+				log15.Warn("Failed to prefetch HEAD of partial clone", "repo", repo, "error", err)
+			}
+		}
 
-		if output, err := s.runWithRemoteOpts(ctx, cmd, pw); err != nil {
-			return errors.Wrapf(err, "clone failed. Output: %s", string(output))
+		// The clone may have finished before its disk usage was checked. If
+		// it is too large, discard it.
+		if limit := repoMaxSize(); limit > 0 {
+			size, err := repoSize(ctx, tmpPath)
+			if err != nil {
+				return err
+			}
+			if err := checkRepoQuota(repo, size); err != nil {
+				s.repoSizes.refuseClone(repo, size.TotalBytes)
+				return err
+			}
+			size.Repo = repo
+			s.repoSizes.update(size)
 		}
 
 		// Update the last-changed stamp.
@@ -1188,7 +1296,18 @@ func (s *Server) doRepoUpdate2(repo api.RepoName, url string) error {
 		}
 	}
 
-	cmd := exec.CommandContext(ctx, "git", "fetch", "--prune", url, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*", "+refs/pull/*:refs/pull/*")
+	// Stop updating repositories which have grown too large. The current
+	// clone remains usable. The size is the one computed by the janitor (or
+	// after the clone), since walking the repository before every fetch is
+	// too slow.
+	if size := s.repoSizes.get(repo); size != nil {
+		if err := checkRepoQuota(repo, size); err != nil {
+			return err
+		}
+	}
+
+	partial := repoIsPartial(ctx, dir)
+	cmd := exec.CommandContext(ctx, "git", fetchArgs(repoCloneOptions(repo), dir, url, partial)...)
 	cmd.Dir = dir
 
 	// drop temporary pack files after a fetch. this function won't
@@ -1197,9 +1316,26 @@ func (s *Server) doRepoUpdate2(repo api.RepoName, url string) error {
 	// when the cleanup happens, just that it does.
 	defer s.cleanTmpFiles(dir)
 
-	if output, err := s.runWithRemoteOpts(ctx, cmd, nil); err != nil {
-		log15.Error("Failed to update", "repo", repo, "error", err, "output", string(output))
-		return errors.Wrap(err, "failed to update")
+	// Record the fetch, including the refs and HEAD it changed, in the fetch
+	// history of the repository once we are done.
+	refsBefore, _ := repoRefs(ctx, dir)
+	headBefore, _ := quickRevParseHead(dir)
+	start := time.Now()
+	fetchOutput, fetchErr := s.runWithRemoteOpts(ctx, cmd, nil)
+	fetch := newRepoFetch(start, cmd, fetchOutput, fetchErr, newURLRedactor(url))
+	defer func() {
+		if refsAfter, err := repoRefs(ctx, dir); err == nil && refsBefore != nil {
+			fetch.RefsChanged = countChangedRefs(refsBefore, refsAfter)
+		}
+		headAfter, _ := quickRevParseHead(dir)
+		fetch.HeadMoved = headBefore != headAfter
+		if err := appendFetchHistory(dir, fetch); err != nil {
+			log15.Warn("Failed to record fetch history", "repo", repo, "error", err)
+		}
+	}()
+	if fetchErr != nil {
+		log15.Error("Failed to update", "repo", repo, "error", fetchErr, "output", string(fetchOutput))
+		return errors.Wrap(fetchErr, "failed to update")
 	}
 
 	// Update the last-changed stamp.
@@ -1251,6 +1387,12 @@ func (s *Server) doRepoUpdate2(repo api.RepoName, url string) error {
 		log15.Error("Failed to set HEAD", "repo", repo, "error", err, "output", string(output))
 		return errors.Wrap(err, "Failed to set HEAD")
 	}
+
+	if partial {
+		if err := s.prefetchHEAD(ctx, dir); err != nil {
+			log15.Warn("Failed to prefetch HEAD of partial clone", "repo", repo, "error", err)
+		}
+	}
 	return nil
 }
 
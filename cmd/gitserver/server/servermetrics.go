@@ -9,6 +9,27 @@ import (
 	"gopkg.in/inconshreveable/log15.v2"
 )
 
+var (
+	maintenanceDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "src",
+		Subsystem: "gitserver",
+		Name:      "maintenance_duration_seconds",
+		Help:      "Time spent on each step (and in total) of the maintenance of a repository.",
+		Buckets:   []float64{0.1, 1, 10, 60, 300, 900, 1800, 3600},
+	}, []string{"step", "status"})
+	maintenanceBacklog = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "src",
+		Subsystem: "gitserver",
+		Name:      "maintenance_backlog",
+		Help:      "Number of repositories due for maintenance which the last janitor run left for later.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(maintenanceDuration)
+	prometheus.MustRegister(maintenanceBacklog)
+}
+
 func (s *Server) RegisterMetrics() {
 	// test the latency of exec, which may increase under certain memory
 	// conditions
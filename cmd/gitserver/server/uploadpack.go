@@ -1,15 +1,26 @@
 package server
 
 import (
+	"bytes"
 	"compress/gzip"
+	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"os/exec"
 	"path"
+	"strings"
 
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
 )
 
+// handleUploadPack serves git's smart HTTP protocol for fetching from a
+// repository, like git-http-backend: a GET request is answered with the ref
+// (or, in protocol version 2, capability) advertisement of
+// /info/refs?service=git-upload-pack, and a POST request with the result of
+// the client's git-upload-pack request. The Git-Protocol header is passed to
+// git, so clients can use protocol version 2.
 func (s *Server) handleUploadPack(w http.ResponseWriter, r *http.Request) {
 	repo := protocol.NormalizeRepo(api.RepoName(r.URL.Query().Get("repo")))
 	if repo == "" {
@@ -17,28 +28,93 @@ func (s *Server) handleUploadPack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if r.Header.Get("Content-Type") != "application/x-git-upload-pack-request" {
-		http.Error(w, "Unexpected Content-Type", http.StatusBadRequest)
+	dir := path.Join(s.ReposDir, string(repo))
+	if !repoCloned(dir) {
+		http.Error(w, "repository not cloned", http.StatusNotFound)
 		return
 	}
-	w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
 
-	body := r.Body
-	if r.Header.Get("Content-Encoding") == "gzip" {
-		var err error
-		body, err = gzip.NewReader(body)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+	gitProtocol := r.Header.Get("Git-Protocol")
+	args := []string{"-c", "uploadpack.allowFilter=true", "upload-pack", "--stateless-rpc"}
+
+	var stdin io.Reader
+	switch r.Method {
+	case "GET":
+		args = append(args, "--advertise-refs")
+
+	case "POST":
+		if r.Header.Get("Content-Type") != "application/x-git-upload-pack-request" {
+			http.Error(w, "Unexpected Content-Type", http.StatusBadRequest)
 			return
 		}
+
+		body := r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			var err error
+			body, err = gzip.NewReader(body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		defer body.Close()
+		stdin = body
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cmd := exec.CommandContext(r.Context(), "git", append(args, ".")...)
+	cmd.Dir = dir
+	cmd.Stdin = stdin
+	cmd.Env = os.Environ()
+	if gitProtocol != "" {
+		cmd.Env = append(cmd.Env, "GIT_PROTOCOL="+gitProtocol)
 	}
-	defer body.Close()
+	// A partial clone fetches the objects it omitted from its remote when
+	// they are packed, so the command must not prompt.
+	setRemoteOpts(cmd)
 
-	cmd := exec.CommandContext(r.Context(), "git", "upload-pack", "--stateless-rpc", ".")
-	cmd.Dir = path.Join(s.ReposDir, string(repo))
-	cmd.Stdout = w
-	cmd.Stdin = body
+	// Buffer the advertisement, so that we can still report an error.
+	var stdout bytes.Buffer
+	if r.Method == "GET" {
+		cmd.Stdout = &stdout
+	} else {
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+		w.Header().Set("Cache-Control", "no-cache")
+		cmd.Stdout = w
+	}
 	if err := cmd.Run(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	if r.Method != "GET" {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Protocol version 2 starts with the capability advertisement instead.
+	if !isGitProtocolV2(gitProtocol) {
+		w.Write(pktLine("# service=git-upload-pack\n"))
+		w.Write([]byte("0000"))
+	}
+	w.Write(stdout.Bytes())
+}
+
+// isGitProtocolV2 reports whether the Git-Protocol header (a colon-separated
+// list of key=value parameters) requests protocol version 2.
+func isGitProtocolV2(header string) bool {
+	for _, param := range strings.Split(header, ":") {
+		if param == "version=2" {
+			return true
+		}
+	}
+	return false
+}
+
+// pktLine returns s in git's pkt-line format.
+func pktLine(s string) []byte {
+	return []byte(fmt.Sprintf("%04x%s", len(s)+4, s))
 }
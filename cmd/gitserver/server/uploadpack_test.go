@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServer_handleUploadPack(t *testing.T) {
+	reposDir, cleanup1 := tmpDir(t)
+	defer cleanup1()
+	initRepoWithCommit(t, filepath.Join(reposDir, "example.com/foo/bar/.git"), "https://example.com/foo/bar")
+
+	// Serve the repository like the frontend, which proxies requests to
+	// /.api/git/<repo>/info/refs and /.api/git/<repo>/git-upload-pack.
+	s := &Server{ReposDir: reposDir}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repo := strings.TrimSuffix(strings.TrimSuffix(r.URL.Path, "/info/refs"), "/git-upload-pack")
+		r.URL.RawQuery = "repo=" + strings.TrimPrefix(repo, "/")
+		s.handleUploadPack(w, r)
+	}))
+	defer ts.Close()
+
+	for _, version := range []string{"0", "2"} {
+		dir, cleanup2 := tmpDir(t)
+		defer cleanup2()
+
+		cmd := exec.Command("git", "-c", "protocol.version="+version, "clone", "--bare", ts.URL+"/example.com/foo/bar", dir)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("protocol version %s: clone failed: %s (output: %s)", version, err, out)
+		}
+		cmd = exec.Command("git", "rev-parse", "--verify", "master")
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("protocol version %s: clone has no master: %s (output: %s)", version, err, out)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/example.com/foo/missing/info/refs?service=git-upload-pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("got status %d for a missing repository, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
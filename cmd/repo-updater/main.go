@@ -51,6 +51,24 @@ func main() {
 			w.Header().Set("Content-Type", "application/json")
 			w.Write(d)
 		}),
+	}, debugserver.Endpoint{
+		Name: "Repo Sync Diff (dry run)",
+		Path: "/repo-updater-sync-diff",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			diffs, err := repos.Syncer.DryRun(r.Context())
+			if err != nil {
+				http.Error(w, "failed to compute sync diff: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			d, err := json.MarshalIndent(diffs, "", "  ")
+			if err != nil {
+				http.Error(w, "failed to marshal sync diff: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(d)
+		}),
 	})
 
 	// Start up handler that frontend relies on
@@ -80,6 +98,9 @@ func main() {
 	// GitLab Repository syncing thread
 	go repos.RunGitLabRepositorySyncWorker(ctx)
 
+	// Gitea and Gogs repository syncing thread
+	go repos.RunGiteaRepositorySyncWorker(ctx)
+
 	// AWS CodeCommit repository syncing thread
 	go repos.RunAWSCodeCommitRepositorySyncWorker(ctx)
 
@@ -89,6 +110,9 @@ func main() {
 	// Gitolite syncing thread
 	go repos.RunGitoliteRepositorySyncWorker(ctx)
 
+	// Other Git hosts syncing thread
+	go repos.RunOtherRepositorySyncWorker(ctx)
+
 	// Bitbucket Server syncing thread
 	go repos.RunBitbucketServerRepositorySyncWorker(ctx)
 
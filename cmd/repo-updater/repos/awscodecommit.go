@@ -103,6 +103,11 @@ func GetAWSCodeCommitRepository(ctx context.Context, args protocol.RepoLookupArg
 var awsCodeCommitRepositorySyncWorker = &worker{
 	work: func(ctx context.Context, shutdown chan struct{}) {
 		awsCodeCommitConnections := awsCodeCommitConnections.Get().([]*awsCodeCommitConnection)
+		sources := make([]string, len(awsCodeCommitConnections))
+		for i, c := range awsCodeCommitConnections {
+			sources[i] = c.source()
+		}
+		Syncer.setSources(awscodecommit.ServiceType, sources)
 		if len(awsCodeCommitConnections) == 0 {
 			return
 		}
@@ -151,16 +156,18 @@ func awsCodeCommitRepositoryToRepoPath(conn *awsCodeCommitConnection, repo *awsc
 
 // updateAWSCodeCommitRepositories ensures that all provided repositories have been added and updated on Sourcegraph.
 func updateAWSCodeCommitRepositories(ctx context.Context, conn *awsCodeCommitConnection) {
-	repos := conn.listAllRepositories(ctx)
+	listing := newRepoListing(awscodecommit.ServiceType)
+	repos := conn.listAllRepositories(ctx, listing)
 
 	repoChan := make(chan repoCreateOrUpdateRequest)
 	defer close(repoChan)
-	go createEnableUpdateRepos(ctx, fmt.Sprintf("aws:%s", conn.config.AccessKeyID), repoChan)
+	go createEnableUpdateRepos(ctx, conn.source(), conn.filter, listing, repoChan)
 	for repo := range repos {
 		// log15.Debug("awscodecommit sync: create/enable/update repo", "repo", repo.Name)
 		remoteURL, err := conn.authenticatedRemoteURL(repo)
 		if err != nil {
 			log15.Error("Error generating remote URL for AWS CodeCommit repository. Skipping.", "repo", repo.ARN, "error", err)
+			listing.failed()
 			continue
 		}
 		repoChan <- repoCreateOrUpdateRequest{
@@ -185,9 +192,14 @@ func newAWSCodeCommitConnection(config *schema.AWSCodeCommitConnection) (*awsCod
 			Source:          "sourcegraph-site-configuration",
 		},
 	}
+	filter, err := newRepoFilter(config.Include, config.Exclude)
+	if err != nil {
+		return nil, err
+	}
 	conn := &awsCodeCommitConnection{
 		config:    config,
 		awsConfig: awsConfig,
+		filter:    filter,
 	}
 	conn.client = awscodecommit.NewClient(conn.awsConfig)
 
@@ -209,6 +221,7 @@ type awsCodeCommitConnection struct {
 	awsPartition endpoints.Partition // "aws", "aws-cn", "aws-us-gov"
 	awsRegion    endpoints.Region
 	client       *awscodecommit.Client
+	filter       *repoFilter // the exclude and include rules
 
 	mu           sync.Mutex
 	awsAccountID string
@@ -307,7 +320,13 @@ func makeSHA256(data []byte) []byte {
 	return hash.Sum(nil)
 }
 
-func (c *awsCodeCommitConnection) listAllRepositories(ctx context.Context) <-chan *awscodecommit.Repository {
+// source identifies the connection to createEnableUpdateRepos and the Syncer.
+func (c *awsCodeCommitConnection) source() string {
+	return fmt.Sprintf("aws:%s", c.config.AccessKeyID)
+}
+
+// listAllRepositories returns the repositories of the connection. Errors are recorded in listing.
+func (c *awsCodeCommitConnection) listAllRepositories(ctx context.Context, listing *repoListing) <-chan *awscodecommit.Repository {
 	ch := make(chan *awscodecommit.Repository, awscodecommit.MaxMetadataBatch)
 	go func() {
 		defer close(ch)
@@ -316,6 +335,7 @@ func (c *awsCodeCommitConnection) listAllRepositories(ctx context.Context) <-cha
 			repos, token, err := c.client.ListRepositories(ctx, nextToken)
 			if err != nil {
 				log15.Error("Error listing AWS CodeCommit repositories", "error", err)
+				listing.failed()
 				return
 			}
 			for _, r := range repos {
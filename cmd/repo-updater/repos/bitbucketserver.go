@@ -194,7 +194,13 @@ func GetBitbucketServerRepository(ctx context.Context, args protocol.RepoLookupA
 
 var bitbucketServerWorker = &worker{
 	work: func(ctx context.Context, shutdown chan struct{}) {
-		for _, c := range bitbucketServerConnections.Get().([]*bitbucketServerConnection) {
+		conns := bitbucketServerConnections.Get().([]*bitbucketServerConnection)
+		sources := make([]string, len(conns))
+		for i, c := range conns {
+			sources[i] = c.source()
+		}
+		Syncer.setSources(bitbucketserver.ServiceType, sources)
+		for _, c := range conns {
 			go func(c *bitbucketServerConnection) {
 				for {
 					reservationTime := time.Now()
@@ -232,14 +238,11 @@ func RunBitbucketServerRepositorySyncWorker(ctx context.Context) {
 
 // updateBitbucketServerRepos ensures that all provided repositories exist in the repository table.
 func updateBitbucketServerRepos(ctx context.Context, conn *bitbucketServerConnection) {
+	listing := newRepoListing(bitbucketserver.ServiceType)
 	repoChan := make(chan repoCreateOrUpdateRequest)
 	defer close(repoChan)
-	sourceID := conn.config.Token
-	if sourceID == "" {
-		sourceID = conn.config.Username
-	}
-	go createEnableUpdateRepos(ctx, fmt.Sprintf("bitbucket:%s", sourceID), repoChan)
-	for r := range conn.listAllRepos(ctx) {
+	go createEnableUpdateRepos(ctx, conn.source(), conn.filter, listing, repoChan)
+	for r := range conn.listAllRepos(ctx, listing) {
 		if r.State != "AVAILABLE" {
 			continue
 		}
@@ -300,8 +303,14 @@ func newBitbucketServerConnection(config *schema.BitbucketServerConnection) (*bi
 		return nil, err
 	}
 
+	filter, err := newRepoFilter(config.Include, config.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
 	return &bitbucketServerConnection{
 		config: config,
+		filter: filter,
 		client: &bitbucketserver.Client{
 			URL:      baseURL,
 			Token:    config.Token,
@@ -318,9 +327,20 @@ func newBitbucketServerConnection(config *schema.BitbucketServerConnection) (*bi
 type bitbucketServerConnection struct {
 	config *schema.BitbucketServerConnection
 	client *bitbucketserver.Client
+	filter *repoFilter // the exclude and include rules
+}
+
+// source identifies the connection to createEnableUpdateRepos and the Syncer.
+func (c *bitbucketServerConnection) source() string {
+	sourceID := c.config.Token
+	if sourceID == "" {
+		sourceID = c.config.Username
+	}
+	return fmt.Sprintf("bitbucket:%s", sourceID)
 }
 
-func (c *bitbucketServerConnection) listAllRepos(ctx context.Context) <-chan *bitbucketserver.Repo {
+// listAllRepos returns the repositories of the connection. Errors are recorded in listing.
+func (c *bitbucketServerConnection) listAllRepos(ctx context.Context, listing *repoListing) <-chan *bitbucketserver.Repo {
 	perPage := 100
 	ch := make(chan *bitbucketserver.Repo, perPage)
 	go func() {
@@ -347,6 +367,7 @@ func (c *bitbucketServerConnection) listAllRepos(ctx context.Context) <-chan *bi
 			repos, page, err = c.client.Repos(ctx, page)
 			if err != nil {
 				log15.Error("failed when listing Bitbucket Server repos", "url", c.client.URL, "error", err)
+				listing.failed()
 				return
 			}
 			for _, r := range repos {
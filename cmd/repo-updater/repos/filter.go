@@ -0,0 +1,145 @@
+package repos
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+// repoFilter decides which of the repositories listed by a code host connection are mirrored,
+// according to the connection's exclude and include rules. A nil *repoFilter mirrors all
+// repositories.
+type repoFilter struct {
+	include []*repoRule // if non-empty, only repositories matching any of these are mirrored
+	exclude []*repoRule // repositories matching any of these are not mirrored
+}
+
+// repoRule is a compiled schema.RepositoryFilter. A repository matches it if it matches all of its
+// set properties.
+type repoRule struct {
+	name      *regexp.Regexp
+	fork      bool
+	archived  bool
+	sizeAbove int64 // in bytes
+}
+
+// newRepoFilter compiles the include and exclude rules of a code host connection. It returns nil
+// if there are no rules.
+func newRepoFilter(include, exclude []*schema.RepositoryFilter) (*repoFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	compile := func(filters []*schema.RepositoryFilter) ([]*repoRule, error) {
+		rules := make([]*repoRule, 0, len(filters))
+		for _, f := range filters {
+			rule := &repoRule{
+				fork:      f.Fork,
+				archived:  f.Archived,
+				sizeAbove: int64(f.SizeAboveMB) * 1024 * 1024,
+			}
+			if f.Name != "" {
+				var err error
+				if rule.name, err = regexp.Compile(f.Name); err != nil {
+					return nil, errors.Wrapf(err, "invalid repository name pattern %q", f.Name)
+				}
+			}
+			rules = append(rules, rule)
+		}
+		return rules, nil
+	}
+
+	var f repoFilter
+	var err error
+	if f.include, err = compile(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compile(exclude); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// excluded reports whether the repository must not be mirrored.
+func (f *repoFilter) excluded(op *repoCreateOrUpdateRequest) bool {
+	if f == nil {
+		return false
+	}
+	matchesAny := func(rules []*repoRule) bool {
+		for _, rule := range rules {
+			if rule.matches(op) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(f.include) > 0 && !matchesAny(f.include) {
+		return true
+	}
+	return matchesAny(f.exclude)
+}
+
+func (r *repoRule) matches(op *repoCreateOrUpdateRequest) bool {
+	if r.name != nil && !r.name.MatchString(string(op.RepoName)) {
+		return false
+	}
+	if r.fork && !op.Fork {
+		return false
+	}
+	if r.archived && !op.Archived {
+		return false
+	}
+	if r.sizeAbove > 0 && op.Size <= r.sizeAbove { // an unknown size (0) never matches
+		return false
+	}
+	return true
+}
+
+// syncedRepos records the repositories that the last sync of each source (see
+// createEnableUpdateRepos) mirrored and excluded, so that RunRepositoryPurgeWorker can remove the
+// excluded repositories.
+var syncedRepos = struct {
+	mu       sync.Mutex
+	mirrored map[string]map[api.RepoName]bool // source -> repos
+	excluded map[string]map[api.RepoName]bool // source -> repos
+}{
+	mirrored: make(map[string]map[api.RepoName]bool),
+	excluded: make(map[string]map[api.RepoName]bool),
+}
+
+// setSyncedRepos records the result of a sync of source.
+func setSyncedRepos(source string, mirrored, excluded map[api.RepoName]bool) {
+	syncedRepos.mu.Lock()
+	defer syncedRepos.mu.Unlock()
+	syncedRepos.mirrored[source] = mirrored
+	syncedRepos.excluded[source] = excluded
+}
+
+// excludedRepos returns the repositories which the last sync of a source excluded, and which no
+// other source mirrors.
+func excludedRepos() []api.RepoName {
+	syncedRepos.mu.Lock()
+	defer syncedRepos.mu.Unlock()
+
+	var repos []api.RepoName
+	seen := make(map[api.RepoName]bool)
+	for _, excluded := range syncedRepos.excluded {
+	nextRepo:
+		for name := range excluded {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			for _, mirrored := range syncedRepos.mirrored {
+				if mirrored[name] {
+					continue nextRepo
+				}
+			}
+			repos = append(repos, name)
+		}
+	}
+	return repos
+}
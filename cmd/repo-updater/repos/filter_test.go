@@ -0,0 +1,111 @@
+package repos
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestRepoFilter(t *testing.T) {
+	repo := func(name string, fork, archived bool, sizeMB int64) *repoCreateOrUpdateRequest {
+		return &repoCreateOrUpdateRequest{
+			RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{RepoName: api.RepoName(name), Fork: fork, Archived: archived},
+			Size:                      sizeMB * 1024 * 1024,
+		}
+	}
+	var (
+		plain    = repo("github.com/o/plain", false, false, 1)
+		fork     = repo("github.com/o/fork", true, false, 1)
+		archived = repo("github.com/o/archived", false, true, 1)
+		huge     = repo("github.com/o/huge", false, false, 2000)
+		unsized  = repo("github.com/o/unsized", false, false, 0)
+		other    = repo("github.com/other/plain", false, false, 1)
+	)
+	all := []*repoCreateOrUpdateRequest{plain, fork, archived, huge, unsized, other}
+
+	tests := []struct {
+		name             string
+		include, exclude []*schema.RepositoryFilter
+		wantExcluded     []*repoCreateOrUpdateRequest
+	}{
+		{
+			name: "no rules",
+		},
+		{
+			name:         "exclude forks and archived",
+			exclude:      []*schema.RepositoryFilter{{Fork: true}, {Archived: true}},
+			wantExcluded: []*repoCreateOrUpdateRequest{fork, archived},
+		},
+		{
+			name:         "exclude by size",
+			exclude:      []*schema.RepositoryFilter{{SizeAboveMB: 1000}},
+			wantExcluded: []*repoCreateOrUpdateRequest{huge},
+		},
+		{
+			name:         "exclude by name and fork",
+			exclude:      []*schema.RepositoryFilter{{Name: "^github\\.com/o/", Fork: true}},
+			wantExcluded: []*repoCreateOrUpdateRequest{fork},
+		},
+		{
+			name:         "include",
+			include:      []*schema.RepositoryFilter{{Name: "^github\\.com/other/"}},
+			wantExcluded: []*repoCreateOrUpdateRequest{plain, fork, archived, huge, unsized},
+		},
+		{
+			name:         "include and exclude",
+			include:      []*schema.RepositoryFilter{{Name: "^github\\.com/o/"}},
+			exclude:      []*schema.RepositoryFilter{{Name: "huge"}},
+			wantExcluded: []*repoCreateOrUpdateRequest{huge, other},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := newRepoFilter(test.include, test.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var excluded []*repoCreateOrUpdateRequest
+			for _, r := range all {
+				if f.excluded(r) {
+					excluded = append(excluded, r)
+				}
+			}
+			if !reflect.DeepEqual(excluded, test.wantExcluded) {
+				t.Errorf("got excluded %v, want %v", names(excluded), names(test.wantExcluded))
+			}
+		})
+	}
+
+	if _, err := newRepoFilter(nil, []*schema.RepositoryFilter{{Name: "("}}); err == nil {
+		t.Error("expected error for invalid name pattern")
+	}
+}
+
+func names(ops []*repoCreateOrUpdateRequest) []api.RepoName {
+	var names []api.RepoName
+	for _, op := range ops {
+		names = append(names, op.RepoName)
+	}
+	return names
+}
+
+func TestExcludedRepos(t *testing.T) {
+	defer func() {
+		syncedRepos.mirrored = make(map[string]map[api.RepoName]bool)
+		syncedRepos.excluded = make(map[string]map[api.RepoName]bool)
+	}()
+
+	setSyncedRepos("a", map[api.RepoName]bool{"x": true}, map[api.RepoName]bool{"y": true, "z": true})
+	setSyncedRepos("b", map[api.RepoName]bool{"z": true}, nil)
+	if got, want := excludedRepos(), []api.RepoName{"y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// A later sync that no longer excludes y.
+	setSyncedRepos("a", map[api.RepoName]bool{"x": true, "y": true}, nil)
+	if got := excludedRepos(); len(got) != 0 {
+		t.Errorf("got %v, want none", got)
+	}
+}
@@ -0,0 +1,341 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/atomicvalue"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitea"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
+	"github.com/sourcegraph/sourcegraph/schema"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+var giteaConnections = atomicvalue.New()
+
+func init() {
+	conf.Watch(func() {
+		giteaConnections.Set(func() interface{} {
+			var conns []*giteaConnection
+			for _, c := range conf.Get().Gitea {
+				conn, err := newGiteaConnection(c)
+				if err != nil {
+					log15.Error("Error processing configured Gitea connection. Skipping it.", "url", c.Url, "error", err)
+					continue
+				}
+				conns = append(conns, conn)
+			}
+			return conns
+		})
+		giteaRepositorySyncWorker.restart()
+	})
+}
+
+// getGiteaConnection returns the Gitea connection (config + API client) that is responsible for
+// the repository specified by the args.
+func getGiteaConnection(args protocol.RepoLookupArgs) (*giteaConnection, error) {
+	giteaConnections := giteaConnections.Get().([]*giteaConnection)
+	if args.ExternalRepo != nil && args.ExternalRepo.ServiceType == gitea.ServiceType {
+		// Look up by external repository spec.
+		for _, conn := range giteaConnections {
+			if args.ExternalRepo.ServiceID == conn.baseURL.String() {
+				return conn, nil
+			}
+		}
+		return nil, errors.Wrap(gitea.ErrNotFound, fmt.Sprintf("no configured Gitea connection with URL: %q", args.ExternalRepo.ServiceID))
+	}
+
+	if args.Repo != "" {
+		// Look up by repository name.
+		repo := strings.ToLower(string(args.Repo))
+		for _, conn := range giteaConnections {
+			if strings.HasPrefix(repo, conn.baseURL.Hostname()+"/") {
+				return conn, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// GetGiteaRepositoryMock is set by tests that need to mock GetGiteaRepository.
+var GetGiteaRepositoryMock func(args protocol.RepoLookupArgs) (repo *protocol.RepoInfo, authoritative bool, err error)
+
+// GetGiteaRepository queries a configured Gitea (or Gogs) connection endpoint for information about
+// the specified repository.
+//
+// If args.Repo refers to a repository that is not known to be on a configured Gitea connection's
+// host, it returns authoritative == false.
+func GetGiteaRepository(ctx context.Context, args protocol.RepoLookupArgs) (repo *protocol.RepoInfo, authoritative bool, err error) {
+	if GetGiteaRepositoryMock != nil {
+		return GetGiteaRepositoryMock(args)
+	}
+
+	conn, err := getGiteaConnection(args)
+	if err != nil {
+		return nil, true, err // refers to a Gitea repo but the host is not configured
+	}
+	if conn == nil {
+		return nil, false, nil // refers to a non-Gitea repo
+	}
+
+	if args.ExternalRepo != nil && args.ExternalRepo.ServiceType == gitea.ServiceType {
+		// Look up by external repository spec.
+		id, err := strconv.ParseInt(args.ExternalRepo.ID, 10, 64)
+		if err != nil {
+			return nil, true, err
+		}
+		r, err := conn.client.GetRepositoryByID(ctx, id)
+		if r != nil {
+			repo = giteaRepositoryToRepoInfo(conn, r)
+		}
+		return repo, true, err
+	}
+
+	if args.Repo != "" {
+		// Look up by repository name.
+		nameWithOwner := strings.TrimPrefix(strings.ToLower(string(args.Repo)), conn.baseURL.Hostname()+"/")
+		parts := strings.SplitN(nameWithOwner, "/", 2)
+		if len(parts) != 2 {
+			return nil, true, gitea.ErrNotFound
+		}
+		r, err := conn.client.GetRepository(ctx, parts[0], parts[1])
+		if r != nil {
+			repo = giteaRepositoryToRepoInfo(conn, r)
+		}
+		return repo, true, err
+	}
+
+	return nil, true, fmt.Errorf("unable to look up Gitea repository (%+v)", args)
+}
+
+func giteaRepositoryToRepoInfo(conn *giteaConnection, repo *gitea.Repository) *protocol.RepoInfo {
+	return &protocol.RepoInfo{
+		Name:         giteaRepositoryToRepoPath(conn, repo),
+		ExternalRepo: gitea.ExternalRepoSpec(repo, *conn.baseURL),
+		Description:  repo.Description,
+		Fork:         repo.Fork,
+		Archived:     repo.Archived,
+		VCS: protocol.VCSInfo{
+			URL: conn.authenticatedRemoteURL(repo),
+		},
+		Links: &protocol.RepoLinks{
+			Root:   repo.HTMLURL,
+			Tree:   repo.HTMLURL + "/src/{rev}/{path}",
+			Blob:   repo.HTMLURL + "/src/{rev}/{path}",
+			Commit: repo.HTMLURL + "/commit/{commit}",
+		},
+	}
+}
+
+var giteaRepositorySyncWorker = &worker{
+	work: func(ctx context.Context, shutdown chan struct{}) {
+		giteaConnections := giteaConnections.Get().([]*giteaConnection)
+		sources := make([]string, len(giteaConnections))
+		for i, c := range giteaConnections {
+			sources[i] = c.source()
+		}
+		Syncer.setSources(gitea.ServiceType, sources)
+		if len(giteaConnections) == 0 {
+			return
+		}
+		for _, c := range giteaConnections {
+			go func(c *giteaConnection) {
+				for {
+					updateGiteaRepositories(ctx, c)
+					giteaUpdateTime.WithLabelValues(c.baseURL.String()).Set(float64(time.Now().Unix()))
+					select {
+					case <-shutdown:
+						return
+					case <-time.After(getUpdateInterval()):
+					}
+				}
+			}(c)
+		}
+	},
+}
+
+// RunGiteaRepositorySyncWorker runs the worker that syncs repositories from configured Gitea and
+// Gogs instances to Sourcegraph.
+func RunGiteaRepositorySyncWorker(ctx context.Context) {
+	giteaRepositorySyncWorker.start(ctx)
+}
+
+func giteaRepositoryToRepoPath(conn *giteaConnection, repo *gitea.Repository) api.RepoName {
+	return reposource.GiteaRepoName(conn.config.RepositoryPathPattern, conn.baseURL.Hostname(), repo.FullName)
+}
+
+// updateGiteaRepositories ensures that all provided repositories exist in the repository table.
+func updateGiteaRepositories(ctx context.Context, conn *giteaConnection) {
+	listing := newRepoListing(gitea.ServiceType)
+	repos := conn.listAllRepositories(ctx, listing)
+
+	repoChan := make(chan repoCreateOrUpdateRequest)
+	defer close(repoChan)
+	go createEnableUpdateRepos(ctx, conn.source(), conn.filter, listing, repoChan)
+	for repo := range repos {
+		repoChan <- repoCreateOrUpdateRequest{
+			RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
+				RepoName:     giteaRepositoryToRepoPath(conn, repo),
+				ExternalRepo: gitea.ExternalRepoSpec(repo, *conn.baseURL),
+				Description:  repo.Description,
+				Fork:         repo.Fork,
+				Archived:     repo.Archived,
+				Stars:        repo.Stars,
+				Enabled:      conn.config.InitialRepositoryEnablement,
+			},
+			URL:  conn.authenticatedRemoteURL(repo),
+			Size: repo.Size * 1024,
+		}
+	}
+}
+
+func newGiteaConnection(config *schema.GiteaConnection) (*giteaConnection, error) {
+	baseURL, err := url.Parse(config.Url)
+	if err != nil {
+		return nil, err
+	}
+	baseURL = NormalizeBaseURL(baseURL)
+
+	transport, err := cachedTransportWithCertTrusted(config.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := newRepoFilter(config.Include, config.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	return &giteaConnection{
+		config:  config,
+		baseURL: baseURL,
+		client:  gitea.NewClient(baseURL, config.Token, transport),
+		filter:  filter,
+	}, nil
+}
+
+type giteaConnection struct {
+	config  *schema.GiteaConnection
+	baseURL *url.URL
+	client  *gitea.Client
+	filter  *repoFilter // the exclude and include rules
+}
+
+// authenticatedRemoteURL returns the Gitea repository's Git remote URL with the configured access
+// token inserted in the URL userinfo, for repositories needing authentication.
+func (c *giteaConnection) authenticatedRemoteURL(repo *gitea.Repository) string {
+	if c.config.GitURLType == "ssh" {
+		return repo.SSHURL // SSH authentication must be provided out-of-band
+	}
+	if c.config.Token == "" || !repo.Private {
+		return repo.CloneURL
+	}
+	u, err := url.Parse(repo.CloneURL)
+	if err != nil {
+		log15.Warn("Error adding authentication to Gitea repository Git remote URL.", "url", repo.CloneURL, "error", err)
+		return repo.CloneURL
+	}
+	// Gitea and Gogs accept an access token as the username (with any or no password).
+	u.User = url.User(c.config.Token)
+	return u.String()
+}
+
+// source identifies the connection to createEnableUpdateRepos and the Syncer.
+func (c *giteaConnection) source() string {
+	return fmt.Sprintf("gitea:%s", c.config.Token)
+}
+
+// listAllRepositories returns the repositories specified by the connection's repos and
+// repositoryQuery configuration, without duplicates. Errors are recorded in listing.
+func (c *giteaConnection) listAllRepositories(ctx context.Context, listing *repoListing) <-chan *gitea.Repository {
+	repositoryQuery := c.config.RepositoryQuery
+	if len(repositoryQuery) == 0 {
+		// Users need to specify ["none"] to disable mirroring.
+		repositoryQuery = []string{"affiliated"}
+	}
+
+	const perPage = 50 // max Gitea API limit parameter (by default)
+	ch := make(chan *gitea.Repository, perPage)
+	go func() {
+		defer close(ch)
+
+		seen := make(map[int64]bool)
+		send := func(repo *gitea.Repository) {
+			if !seen[repo.ID] {
+				seen[repo.ID] = true
+				ch <- repo
+			}
+		}
+
+		for _, nameWithOwner := range c.config.Repos {
+			parts := strings.SplitN(nameWithOwner, "/", 2)
+			if len(parts) != 2 {
+				log15.Error("Skipping invalid Gitea repository name", "name", nameWithOwner)
+				continue
+			}
+			repo, err := c.client.GetRepository(ctx, parts[0], parts[1])
+			if err != nil {
+				log15.Error("Error getting Gitea repository", "name", nameWithOwner, "error", err)
+				if !gitea.IsNotFound(err) {
+					listing.failed()
+				}
+				continue
+			}
+			send(repo)
+		}
+
+	repositoryQueries:
+		for _, query := range repositoryQuery {
+			var path string
+			switch {
+			case query == "none":
+				continue
+			case query == "affiliated":
+				path = "user/repos"
+			case query == "all":
+				path = "repos/search"
+			case strings.HasPrefix(query, "org:"):
+				path = "orgs/" + url.PathEscape(strings.TrimPrefix(query, "org:")) + "/repos"
+			default:
+				log15.Error("Skipping invalid Gitea repositoryQuery", "repositoryQuery", query)
+				continue
+			}
+
+			url := path + "?limit=" + strconv.Itoa(perPage) // first page URL
+			listed := make(map[int64]bool)
+			for {
+				repos, nextPageURL, err := c.client.ListRepositories(ctx, url)
+				if err != nil {
+					log15.Error("Error listing Gitea repositories", "url", url, "error", err)
+					listing.failed()
+					continue repositoryQueries
+				}
+				var added int
+				for _, repo := range repos {
+					if !listed[repo.ID] {
+						listed[repo.ID] = true
+						added++
+					}
+					send(repo)
+				}
+				// Stop if the page had no new repositories, because some Gogs endpoints ignore
+				// pagination and would return the same page forever.
+				if nextPageURL == nil || added == 0 {
+					break
+				}
+				url = *nextPageURL
+			}
+		}
+	}()
+
+	return ch
+}
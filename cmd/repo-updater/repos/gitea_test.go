@@ -0,0 +1,92 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitea"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestGiteaConnection_listAllRepositories(t *testing.T) {
+	// The fixture server ignores pagination for the user's repositories (like some Gogs endpoints),
+	// but always reports a next page.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/user/repos", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/api/v1/user/repos?page=2>; rel="next"`, r.Host))
+		fmt.Fprint(w, `[{"id":1,"full_name":"me/a"},{"id":2,"full_name":"me/b"}]`)
+	})
+	mux.HandleFunc("/api/v1/orgs/o/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id":2,"full_name":"me/b"},{"id":3,"full_name":"o/c"}]`)
+	})
+	mux.HandleFunc("/api/v1/repos/x/y", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":4,"full_name":"x/y"}`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	conn, err := newGiteaConnection(&schema.GiteaConnection{
+		Url:             ts.URL,
+		Repos:           []string{"x/y"},
+		RepositoryQuery: []string{"affiliated", "org:o"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listing := newRepoListing(gitea.ServiceType)
+	var names []string
+	for repo := range conn.listAllRepositories(context.Background(), listing) {
+		names = append(names, repo.FullName)
+	}
+	sort.Strings(names)
+	if want := []string{"me/a", "me/b", "o/c", "x/y"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+	if !listing.complete() {
+		t.Error("got incomplete listing, want complete")
+	}
+
+	// Listing an unknown org fails, so the listing is incomplete.
+	conn.config.RepositoryQuery = []string{"org:unknown"}
+	listing = newRepoListing(gitea.ServiceType)
+	for range conn.listAllRepositories(context.Background(), listing) {
+	}
+	if listing.complete() {
+		t.Error("got complete listing, want incomplete")
+	}
+}
+
+func TestGiteaConnection_authenticatedRemoteURL(t *testing.T) {
+	repo := func(private bool) *gitea.Repository {
+		return &gitea.Repository{
+			FullName: "o/r",
+			Private:  private,
+			CloneURL: "https://gitea.example.com/o/r.git",
+			SSHURL:   "git@gitea.example.com:o/r.git",
+		}
+	}
+	tests := []struct {
+		name string
+		conf schema.GiteaConnection
+		repo *gitea.Repository
+		want string
+	}{
+		{"public", schema.GiteaConnection{Token: "t"}, repo(false), "https://gitea.example.com/o/r.git"},
+		{"private", schema.GiteaConnection{Token: "t"}, repo(true), "https://t@gitea.example.com/o/r.git"},
+		{"private without token", schema.GiteaConnection{}, repo(true), "https://gitea.example.com/o/r.git"},
+		{"ssh", schema.GiteaConnection{Token: "t", GitURLType: "ssh"}, repo(true), "git@gitea.example.com:o/r.git"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := &giteaConnection{config: &test.conf}
+			if got := conn.authenticatedRemoteURL(test.repo); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -237,6 +237,11 @@ func GetGitHubRepository(ctx context.Context, args protocol.RepoLookupArgs) (rep
 var gitHubRepositorySyncWorker = &worker{
 	work: func(ctx context.Context, shutdown chan struct{}) {
 		githubConnections := githubConnections.Get().([]*githubConnection)
+		sources := make([]string, len(githubConnections))
+		for i, c := range githubConnections {
+			sources[i] = c.source()
+		}
+		Syncer.setSources(github.ServiceType, sources)
 		if len(githubConnections) == 0 {
 			return
 		}
@@ -273,11 +278,12 @@ func githubRepositoryToRepoPath(conn *githubConnection, repo *github.Repository)
 
 // updateGitHubRepositories ensures that all provided repositories have been added and updated on Sourcegraph.
 func updateGitHubRepositories(ctx context.Context, conn *githubConnection) {
-	repos := conn.listAllRepositories(ctx)
+	listing := newRepoListing(github.ServiceType)
+	repos := conn.listAllRepositories(ctx, listing)
 
 	repoChan := make(chan repoCreateOrUpdateRequest)
 	defer close(repoChan)
-	go createEnableUpdateRepos(ctx, fmt.Sprintf("github:%s", conn.config.Token), repoChan)
+	go createEnableUpdateRepos(ctx, conn.source(), conn.filter, listing, repoChan)
 	for repo := range repos {
 		// log15.Debug("github sync: create/enable/update repo", "repo", repo.NameWithOwner)
 		repoChan <- repoCreateOrUpdateRequest{
@@ -287,9 +293,11 @@ func updateGitHubRepositories(ctx context.Context, conn *githubConnection) {
 				Description:  repo.Description,
 				Fork:         repo.IsFork,
 				Archived:     repo.IsArchived,
+				Stars:        repo.StargazerCount,
 				Enabled:      conn.config.InitialRepositoryEnablement,
 			},
-			URL: conn.authenticatedRemoteURL(repo),
+			URL:  conn.authenticatedRemoteURL(repo),
+			Size: int64(repo.DiskUsage) * 1024,
 		}
 	}
 }
@@ -309,6 +317,11 @@ func newGitHubConnection(config *schema.GitHubConnection) (*githubConnection, er
 		return nil, err
 	}
 
+	filter, err := newRepoFilter(config.Include, config.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
 	return &githubConnection{
 		config:           config,
 		baseURL:          baseURL,
@@ -316,6 +329,7 @@ func newGitHubConnection(config *schema.GitHubConnection) (*githubConnection, er
 		client:           github.NewClient(apiURL, config.Token, transport),
 		searchClient:     github.NewClient(apiURL, config.Token, transport),
 		originalHostname: originalHostname,
+		filter:           filter,
 	}, nil
 }
 
@@ -331,6 +345,8 @@ type githubConnection struct {
 	// originalHostname is the hostname of config.Url (differs from client APIURL, whose host is api.github.com
 	// for an originalHostname of github.com).
 	originalHostname string
+
+	filter *repoFilter // the exclude and include rules
 }
 
 // authenticatedRemoteURL returns the repository's Git remote URL with the configured
@@ -353,7 +369,14 @@ func (c *githubConnection) authenticatedRemoteURL(repo *github.Repository) strin
 	return u.String()
 }
 
-func (c *githubConnection) listAllRepositories(ctx context.Context) <-chan *github.Repository {
+// source identifies the connection to createEnableUpdateRepos and the Syncer.
+func (c *githubConnection) source() string {
+	return fmt.Sprintf("github:%s", c.config.Token)
+}
+
+// listAllRepositories returns the repositories specified by the connection's repos and
+// repositoryQuery configuration. Errors are recorded in listing.
+func (c *githubConnection) listAllRepositories(ctx context.Context, listing *repoListing) <-chan *github.Repository {
 	const first = 100 // max GitHub API "first" parameter
 	ch := make(chan *github.Repository, first)
 
@@ -383,6 +406,7 @@ func (c *githubConnection) listAllRepositories(ctx context.Context) <-chan *gith
 					repos, err := c.client.ListPublicRepositories(ctx, sinceRepoID)
 					if err != nil {
 						log15.Error("Error listing public repositories", "sinceRepoID", sinceRepoID, "error", err)
+						listing.failed()
 						return
 					}
 					if len(repos) == 0 {
@@ -405,6 +429,7 @@ func (c *githubConnection) listAllRepositories(ctx context.Context) <-chan *gith
 					repos, hasNextPage, rateLimitCost, err = c.client.ListViewerRepositories(ctx, "", page)
 					if err != nil {
 						log15.Error("Error listing viewer's affiliated GitHub repositories", "page", page, "error", err)
+						listing.failed()
 						break
 					}
 					rateLimitRemaining, rateLimitReset, _ := c.client.RateLimit.Get()
@@ -436,6 +461,7 @@ func (c *githubConnection) listAllRepositories(ctx context.Context) <-chan *gith
 					repos, hasNextPage, rateLimitCost, err = c.searchClient.ListRepositoriesForSearch(ctx, repositoryQuery, page)
 					if err != nil {
 						log15.Error("Error listing GitHub repositories for search", "searchString", repositoryQuery, "page", page, "error", err)
+						listing.failed()
 						break
 					}
 					rateLimitRemaining, rateLimitReset, _ := c.searchClient.RateLimit.Get()
@@ -463,6 +489,9 @@ func (c *githubConnection) listAllRepositories(ctx context.Context) <-chan *gith
 			repo, err := c.client.GetRepository(ctx, owner, name)
 			if err != nil {
 				log15.Error("Error getting GitHub repository", "nameWithOwner", nameWithOwner, "error", err)
+				if !github.IsNotFound(err) {
+					listing.failed()
+				}
 				continue
 			}
 			log15.Debug("github sync: GetRepository", "repo", repo.NameWithOwner)
@@ -155,6 +155,11 @@ func GetGitLabRepository(ctx context.Context, args protocol.RepoLookupArgs) (rep
 var gitLabRepositorySyncWorker = &worker{
 	work: func(ctx context.Context, shutdown chan struct{}) {
 		gitlabConnections := gitlabConnections.Get().([]*gitlabConnection)
+		sources := make([]string, len(gitlabConnections))
+		for i, c := range gitlabConnections {
+			sources[i] = c.source()
+		}
+		Syncer.setSources(gitlab.ServiceType, sources)
 		if len(gitlabConnections) == 0 {
 			return
 		}
@@ -191,11 +196,12 @@ func gitlabProjectToRepoPath(conn *gitlabConnection, proj *gitlab.Project) api.R
 
 // updateGitLabProjects ensures that all provided repositories exist in the repository table.
 func updateGitLabProjects(ctx context.Context, conn *gitlabConnection) {
-	projs := conn.listAllProjects(ctx)
+	listing := newRepoListing(gitlab.ServiceType)
+	projs := conn.listAllProjects(ctx, listing)
 
 	repoChan := make(chan repoCreateOrUpdateRequest)
 	defer close(repoChan)
-	go createEnableUpdateRepos(ctx, fmt.Sprintf("gitlab:%s", conn.config.Token), repoChan)
+	go createEnableUpdateRepos(ctx, conn.source(), conn.filter, listing, repoChan)
 	for proj := range projs {
 		repoChan <- repoCreateOrUpdateRequest{
 			RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
@@ -204,6 +210,7 @@ func updateGitLabProjects(ctx context.Context, conn *gitlabConnection) {
 				Description:  proj.Description,
 				Fork:         proj.ForkedFromProject != nil,
 				Archived:     proj.Archived,
+				Stars:        proj.StarCount,
 				Enabled:      conn.config.InitialRepositoryEnablement,
 			},
 			URL: conn.authenticatedRemoteURL(proj),
@@ -223,10 +230,16 @@ func newGitLabConnection(config *schema.GitLabConnection) (*gitlabConnection, er
 		return nil, err
 	}
 
+	filter, err := newRepoFilter(config.Include, config.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
 	return &gitlabConnection{
 		config:  config,
 		baseURL: baseURL,
 		client:  gitlab.NewClient(baseURL, config.Token, transport),
+		filter:  filter,
 	}, nil
 }
 
@@ -234,6 +247,7 @@ type gitlabConnection struct {
 	config  *schema.GitLabConnection
 	baseURL *url.URL // URL with path /api/v4 (no trailing slash)
 	client  *gitlab.Client
+	filter  *repoFilter // the exclude and include rules
 }
 
 // authenticatedRemoteURL returns the GitLab projects's Git remote URL with the configured GitLab personal access
@@ -255,7 +269,14 @@ func (c *gitlabConnection) authenticatedRemoteURL(proj *gitlab.Project) string {
 	return u.String()
 }
 
-func (c *gitlabConnection) listAllProjects(ctx context.Context) <-chan *gitlab.Project {
+// source identifies the connection to createEnableUpdateRepos and the Syncer.
+func (c *gitlabConnection) source() string {
+	return fmt.Sprintf("gitlab:%s", c.config.Token)
+}
+
+// listAllProjects returns the projects specified by the connection's projectQuery
+// configuration. Errors are recorded in listing.
+func (c *gitlabConnection) listAllProjects(ctx context.Context, listing *repoListing) <-chan *gitlab.Project {
 	if len(c.config.ProjectQuery) == 0 {
 		c.config.ProjectQuery = []string{"?membership=true"}
 	}
@@ -292,6 +313,7 @@ func (c *gitlabConnection) listAllProjects(ctx context.Context) <-chan *gitlab.P
 				projects, nextPageURL, err := c.client.ListProjects(ctx, url)
 				if err != nil {
 					log15.Error("Error listing GitLab projects", "url", url, "error", err)
+					listing.failed()
 					continue projectsQueries
 				}
 				for _, p := range projects {
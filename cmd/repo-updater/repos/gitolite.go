@@ -96,6 +96,11 @@ func tryUpdateGitolitePhabricatorMetadata(ctx context.Context, gconf *schema.Git
 // gitoliteUpdateRepos updates the repos associated with a specific
 // Gitolite connection.
 func gitoliteUpdateRepos(ctx context.Context, gconf *schema.GitoliteConnection, doPhabricator bool) error {
+	filter, err := newRepoFilter(gconf.Include, gconf.Exclude)
+	if err != nil {
+		return err
+	}
+
 	// Get list of Gitolite repositories for this connection.
 	rlist, err := gitserver.DefaultClient.ListGitolite(ctx, gconf.Host)
 	if err != nil {
@@ -104,7 +109,7 @@ func gitoliteUpdateRepos(ctx context.Context, gconf *schema.GitoliteConnection,
 
 	repoChan := make(chan repoCreateOrUpdateRequest)
 	defer close(repoChan)
-	go createEnableUpdateRepos(ctx, fmt.Sprintf("gitolite:%s", gconf.Prefix), repoChan)
+	go createEnableUpdateRepos(ctx, fmt.Sprintf("gitolite:%s", gconf.Prefix), filter, nil, repoChan)
 	if doPhabricator && gconf.PhabricatorMetadataCommand != "" {
 		go tryUpdateGitolitePhabricatorMetadata(ctx, gconf, rlist)
 	}
@@ -15,6 +15,12 @@ var (
 		Name:      "time_last_gitlab_sync",
 		Help:      "The last time a comprehensive GitLab sync finished",
 	}, []string{"id"})
+	giteaUpdateTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "src",
+		Subsystem: "repoupdater",
+		Name:      "time_last_gitea_sync",
+		Help:      "The last time a comprehensive Gitea sync finished",
+	}, []string{"id"})
 	awsCodeCommitUpdateTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "src",
 		Subsystem: "repoupdater",
@@ -46,6 +52,13 @@ var (
 		Help:      "The last time a comprehensive Gitolite sync finished",
 	})
 
+	otherUpdateTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "src",
+		Subsystem: "repoupdater",
+		Name:      "time_last_other_sync",
+		Help:      "The last time a comprehensive sync of another Git host finished",
+	}, []string{"id"})
+
 	repoListUpdateTime = prometheus.NewGauge(prometheus.GaugeOpts{
 		Namespace: "src",
 		Subsystem: "repoupdater",
@@ -113,10 +126,12 @@ var (
 func init() {
 	prometheus.MustRegister(githubUpdateTime)
 	prometheus.MustRegister(gitlabUpdateTime)
+	prometheus.MustRegister(giteaUpdateTime)
 	prometheus.MustRegister(awsCodeCommitUpdateTime)
 	prometheus.MustRegister(phabricatorUpdateTime)
 	prometheus.MustRegister(bitbucketServerUpdateTime)
 	prometheus.MustRegister(gitoliteUpdateTime)
+	prometheus.MustRegister(otherUpdateTime)
 	prometheus.MustRegister(repoListUpdateTime)
 	prometheus.MustRegister(purgeSuccess)
 	prometheus.MustRegister(purgeFailed)
@@ -0,0 +1,98 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
+	"github.com/sourcegraph/sourcegraph/schema"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// RunOtherRepositorySyncWorker runs the worker that syncs the repositories discovered on other Git
+// hosts (such as cgit or git daemon) to Sourcegraph.
+func RunOtherRepositorySyncWorker(ctx context.Context) {
+	for {
+		for _, c := range conf.Get().Other {
+			if err := otherUpdateRepos(ctx, c); err != nil {
+				log15.Error("error updating repositories of Git host", "url", c.Url, "err", err)
+				continue
+			}
+			otherUpdateTime.WithLabelValues(c.Url).Set(float64(time.Now().Unix()))
+		}
+		time.Sleep(getUpdateInterval())
+	}
+}
+
+// GetOtherRepository returns repo info about a repository on another Git host. As with Gitolite,
+// the host has no API to fetch metadata from, so the info is derived from the configuration alone.
+func GetOtherRepository(ctx context.Context, args protocol.RepoLookupArgs) (repo *protocol.RepoInfo, authoritative bool, err error) {
+	for _, c := range conf.Get().Other {
+		baseURL, err := otherBaseURL(c)
+		if err != nil {
+			continue
+		}
+		// Everything in the pattern before {repo} is the same for all of the host's repositories.
+		prefix := string(reposource.OtherRepoName(c.RepositoryPathPattern, baseURL.Hostname(), "{repo}"))
+		if i := strings.Index(prefix, "{repo}"); i >= 0 {
+			prefix = prefix[:i]
+		}
+		if prefix == "" || !strings.HasPrefix(string(args.Repo), prefix) {
+			continue
+		}
+		return &protocol.RepoInfo{
+			Name:         args.Repo,
+			ExternalRepo: args.ExternalRepo,
+		}, true, nil
+	}
+	return nil, false, nil // not found
+}
+
+// otherUpdateRepos updates the repos discovered on the Git host c.
+func otherUpdateRepos(ctx context.Context, c *schema.OtherExternalServiceConnection) error {
+	baseURL, err := otherBaseURL(c)
+	if err != nil {
+		return err
+	}
+	filter, err := newRepoFilter(c.Include, c.Exclude)
+	if err != nil {
+		return err
+	}
+
+	// Discovery runs on gitserver, which has the SSH keys for ssh discovery.
+	paths, err := gitserver.DefaultClient.ListOther(ctx, c.Url)
+	if err != nil {
+		return err
+	}
+
+	repoChan := make(chan repoCreateOrUpdateRequest)
+	defer close(repoChan)
+	go createEnableUpdateRepos(ctx, fmt.Sprintf("other:%s", c.Url), filter, nil, repoChan)
+	for _, path := range paths {
+		repoChan <- repoCreateOrUpdateRequest{
+			RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
+				RepoName: reposource.OtherRepoName(c.RepositoryPathPattern, baseURL.Hostname(), path),
+				Enabled:  c.InitialRepositoryEnablement,
+			},
+			URL: baseURL.ResolveReference(&url.URL{Path: path}).String(),
+		}
+	}
+	return nil
+}
+
+// otherBaseURL returns the normalized URL of the Git host c, which the paths of its repositories
+// are relative to.
+func otherBaseURL(c *schema.OtherExternalServiceConnection) (*url.URL, error) {
+	baseURL, err := url.Parse(c.Url)
+	if err != nil {
+		return nil, err
+	}
+	return NormalizeBaseURL(baseURL), nil
+}
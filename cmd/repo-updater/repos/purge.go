@@ -15,7 +15,9 @@ import (
 )
 
 // RunRepositoryPurgeWorker is a worker which deletes repos which are present
-// on gitserver, but not enabled/present in our repos table.
+// on gitserver, but not enabled/present in our repos table. It also deletes
+// the repos which the exclude and include rules of their code host
+// connections exclude from our repos table.
 func RunRepositoryPurgeWorker(ctx context.Context) {
 	log := log15.Root().New("worker", "repo-purge")
 
@@ -26,6 +28,7 @@ func RunRepositoryPurgeWorker(ctx context.Context) {
 	}
 
 	for {
+		purgeExcluded(ctx, log)
 		err := purge(ctx, log)
 		if err != nil {
 			log.Error("failed to run repository clone purge", "error", err)
@@ -113,6 +116,36 @@ func purge(ctx context.Context, log log15.Logger) error {
 	return nil
 }
 
+// purgeExcluded deletes the repos which were excluded by the last sync of
+// their code host connections (and not mirrored by any other connection) from
+// our repos table, and removes their clones.
+func purgeExcluded(ctx context.Context, log log15.Logger) {
+	removed := 0
+	for _, repo := range excludedRepos() {
+		deleted, err := api.InternalClient.ReposDelete(ctx, repo)
+		if err != nil {
+			log.Error("failed to delete excluded repository", "repo", repo, "error", err)
+			purgeFailed.Inc()
+			continue
+		}
+		// The clone is removed even if the row was already gone, since a
+		// previous removal may have failed.
+		if err := gitserver.DefaultClient.Remove(ctx, protocol.NormalizeRepo(repo)); err != nil {
+			// The clone is removed by purge once it is old enough.
+			log.Error("failed to remove excluded repository clone", "repo", repo, "error", err)
+		}
+		if !deleted {
+			continue // already deleted
+		}
+		log.Info("deleted excluded repository", "repo", repo)
+		removed++
+		purgeSuccess.Inc()
+	}
+	if removed > 0 {
+		log.Info("excluded repository purge finished", "removed", removed)
+	}
+}
+
 // randSleep will sleep for an expected d duration with a jitter in [-jitter /
 // 2, jitter / 2].
 func randSleep(d, jitter time.Duration) {
@@ -22,6 +22,16 @@ const (
 
 	// maxDelay is the maximum amount of time between scheduled updates for a single repository.
 	maxDelay = 8 * time.Hour
+
+	// webhookDelay is the minimum amount of time between scheduled updates for a repository
+	// whose code host notifies us of new commits with webhooks. Scheduled updates of these
+	// repositories are only a safety net for missed webhooks.
+	webhookDelay = 4 * time.Hour
+
+	// webhookTTL is how long after the last webhook for a repository we rely on webhooks for
+	// it. Repositories whose webhooks stop arriving (e.g. because the webhook was deleted on
+	// the code host) return to the normal schedule after this.
+	webhookTTL = 7 * 24 * time.Hour
 )
 
 // updateScheduler schedules repo update (or clone) requests to gitserver.
@@ -33,6 +43,8 @@ const (
 // then the next update will be scheduled 4 hours from now. If there are still no new commits,
 // then the next update will be scheduled 6 hours from then.
 // This heuristic is simple to compute and has nice backoff properties.
+// Repositories for which we receive webhooks are updated when the webhook arrives, and
+// scheduled at most every webhookDelay.
 //
 // When it is time for a repo to update, the scheduler inserts the repo into a queue.
 //
@@ -45,6 +57,9 @@ type updateScheduler struct {
 	// so we can compute which repos have been added/removed/enabled/disabled.
 	sourceRepos map[string]sourceRepoMap
 
+	// webhooks stores the time of the last webhook received for each repo.
+	webhooks map[api.RepoName]time.Time
+
 	updateQueue *updateQueue
 	schedule    *schedule
 }
@@ -70,6 +85,7 @@ const notifyChanBuffer = 1
 func newUpdateScheduler() *updateScheduler {
 	return &updateScheduler{
 		sourceRepos: make(map[string]sourceRepoMap),
+		webhooks:    make(map[api.RepoName]time.Time),
 		updateQueue: &updateQueue{
 			index:         make(map[api.RepoName]*repoUpdate),
 			notifyEnqueue: make(chan struct{}, notifyChanBuffer),
@@ -158,6 +174,9 @@ func (s *updateScheduler) runUpdateLoop(ctx context.Context) {
 					// This is the heuristic that is described in the updateScheduler documentation.
 					// Update that documentation if you update this logic.
 					interval := resp.LastFetched.Sub(*resp.LastChanged) / 2
+					if interval < webhookDelay && s.receivesWebhooks(repo.Name) {
+						interval = webhookDelay
+					}
 					s.schedule.updateInterval(repo, interval)
 				}
 			}(ctx, repo, cancel)
@@ -237,6 +256,29 @@ func (s *updateScheduler) UpdateOnce(name api.RepoName, url string) {
 	s.updateQueue.enqueue(repo, priorityHigh)
 }
 
+// UpdateFromWebhook causes a single update of the given repository, for which a
+// webhook reported new commits. Until no webhook is received for the repository for
+// webhookTTL, scheduled updates of it only happen every webhookDelay.
+func (s *updateScheduler) UpdateFromWebhook(name api.RepoName, url string) {
+	s.mu.Lock()
+	s.webhooks[name] = timeNow()
+	s.mu.Unlock()
+	s.UpdateOnce(name, url)
+}
+
+// receivesWebhooks reports whether we received a webhook for the given repository
+// recently.
+func (s *updateScheduler) receivesWebhooks(name api.RepoName) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.webhooks[name]
+	if ok && timeNow().Sub(last) > webhookTTL {
+		delete(s.webhooks, name)
+		return false
+	}
+	return ok
+}
+
 // DebugDump returns the state of the update scheduler for debugging.
 func (s *updateScheduler) DebugDump() interface{} {
 	data := struct {
@@ -1111,6 +1111,7 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 		gitMaxConcurrentClones int
 		initialSchedule        []*scheduledRepoUpdate
 		initialQueue           []*repoUpdate
+		webhooks               map[api.RepoName]time.Time
 		mockRequestRepoUpdates []*mockRequestRepoUpdate
 		finalSchedule          []*scheduledRepoUpdate
 		finalQueue             []*repoUpdate
@@ -1177,6 +1178,64 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 				return []chan struct{}{s.schedule.wakeup}
 			},
 		},
+		{
+			name:                   "schedule updated for repo receiving webhooks",
+			gitMaxConcurrentClones: 1,
+			initialSchedule: []*scheduledRepoUpdate{
+				{Repo: a, Interval: time.Hour, Due: defaultTime.Add(time.Hour)},
+			},
+			initialQueue: []*repoUpdate{
+				{Repo: a, Seq: 1},
+			},
+			webhooks: map[api.RepoName]time.Time{
+				"a": defaultTime.Add(-time.Hour),
+			},
+			mockRequestRepoUpdates: []*mockRequestRepoUpdate{
+				{
+					repo: a,
+					resp: &gitserverprotocol.RepoUpdateResponse{
+						LastFetched: timePtr(defaultTime.Add(2 * time.Minute)),
+						LastChanged: timePtr(defaultTime),
+					},
+				},
+			},
+			finalSchedule: []*scheduledRepoUpdate{
+				{Repo: a, Interval: webhookDelay, Due: defaultTime.Add(webhookDelay)},
+			},
+			timeAfterFuncDelays: []time.Duration{webhookDelay},
+			expectedNotifications: func(s *updateScheduler) []chan struct{} {
+				return []chan struct{}{s.schedule.wakeup}
+			},
+		},
+		{
+			name:                   "schedule updated for repo whose webhooks stopped",
+			gitMaxConcurrentClones: 1,
+			initialSchedule: []*scheduledRepoUpdate{
+				{Repo: a, Interval: time.Hour, Due: defaultTime.Add(time.Hour)},
+			},
+			initialQueue: []*repoUpdate{
+				{Repo: a, Seq: 1},
+			},
+			webhooks: map[api.RepoName]time.Time{
+				"a": defaultTime.Add(-webhookTTL - time.Hour),
+			},
+			mockRequestRepoUpdates: []*mockRequestRepoUpdate{
+				{
+					repo: a,
+					resp: &gitserverprotocol.RepoUpdateResponse{
+						LastFetched: timePtr(defaultTime.Add(2 * time.Minute)),
+						LastChanged: timePtr(defaultTime),
+					},
+				},
+			},
+			finalSchedule: []*scheduledRepoUpdate{
+				{Repo: a, Interval: time.Minute, Due: defaultTime.Add(time.Minute)},
+			},
+			timeAfterFuncDelays: []time.Duration{time.Minute},
+			expectedNotifications: func(s *updateScheduler) []chan struct{} {
+				return []chan struct{}{s.schedule.wakeup}
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -1220,6 +1279,9 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 
 			setupInitialSchedule(s, test.initialSchedule)
 			setupInitialQueue(s, test.initialQueue)
+			for name, t := range test.webhooks {
+				s.webhooks[name] = t
+			}
 
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
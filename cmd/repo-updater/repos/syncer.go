@@ -0,0 +1,215 @@
+package repos
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// Syncer reconciles the repos table with the listings of the code host
+// connections. The sync workers hand it the full listing of a connection
+// (a source) after each sync, and it diffs the listings of all the sources
+// of each external service against the repos table by external ID (see
+// api.ReposSyncRequest).
+var Syncer = &syncer{
+	sources:  make(map[string]map[string]bool),
+	listings: make(map[string]*sourceListing),
+}
+
+type syncer struct {
+	// mu serializes syncs, so that the listings of an external service are
+	// always diffed against the repos table as a whole.
+	mu sync.Mutex
+
+	// sources are the configured sources of each external service type.
+	sources map[string]map[string]bool
+
+	// listings are the last listings of the sources.
+	listings map[string]*sourceListing
+}
+
+// sourceListing is the last listing of a source.
+type sourceListing struct {
+	serviceType string
+	repos       []api.RepoCreateOrUpdateRequest
+
+	// complete is whether repos includes every repository of the source. A
+	// listing which failed part-way is merged with the previous listing of
+	// the source, so it is complete if that was.
+	complete bool
+}
+
+// A repoListing records whether listing the repositories of a source
+// succeeded. Listers call failed when they skip part of the listing because
+// of an error, so that the syncer doesn't delete the repositories which are
+// missing from it.
+type repoListing struct {
+	serviceType string
+	incomplete  int32 // atomic
+}
+
+func newRepoListing(serviceType string) *repoListing {
+	return &repoListing{serviceType: serviceType}
+}
+
+func (l *repoListing) failed() {
+	atomic.StoreInt32(&l.incomplete, 1)
+}
+
+func (l *repoListing) complete() bool {
+	return atomic.LoadInt32(&l.incomplete) == 0
+}
+
+// setSources sets the configured sources of the external service type. The
+// listings of the sources which are no longer configured are dropped.
+// Repositories are only deleted once every configured source of their
+// external service type has listed all of its repositories.
+func (s *syncer) setSources(serviceType string, sources []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	configured := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		configured[source] = true
+	}
+	s.sources[serviceType] = configured
+	for source, l := range s.listings {
+		if l.serviceType == serviceType && !configured[source] {
+			delete(s.listings, source)
+		}
+	}
+}
+
+// sync records the listing of the source and syncs the external services of
+// its repositories (and of its previous listing). It returns the stored
+// repositories of the listings, keyed by external ID.
+func (s *syncer) sync(ctx context.Context, serviceType, source string, repos []api.RepoCreateOrUpdateRequest, complete bool) (map[string]*api.Repo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	services := make(map[string]bool)
+	for _, r := range repos {
+		services[r.ExternalRepo.ServiceID] = true
+	}
+	if prev := s.listings[source]; prev != nil {
+		for _, r := range prev.repos {
+			services[r.ExternalRepo.ServiceID] = true
+		}
+		if !complete {
+			repos = mergeListings(repos, prev.repos)
+			complete = prev.complete
+		}
+	}
+	s.listings[source] = &sourceListing{serviceType: serviceType, repos: repos, complete: complete}
+
+	stored := make(map[string]*api.Repo, len(repos))
+	for _, serviceID := range sortedKeys(services) {
+		resp, err := s.syncService(ctx, serviceType, serviceID, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range resp.Repos {
+			stored[r.ExternalRepo.ID] = r
+		}
+		if d := resp.Diff; !d.Empty() || len(d.Conflicts) > 0 {
+			log15.Info("synced repositories", "type", serviceType, "url", serviceID, "created", len(d.Created), "renamed", len(d.Renamed), "updated", len(d.Updated), "deleted", len(d.Deleted), "conflicts", d.Conflicts)
+		}
+	}
+	return stored, nil
+}
+
+// syncService diffs the listings of the external service against the repos
+// table, applying the diff unless dryRun is true.
+func (s *syncer) syncService(ctx context.Context, serviceType, serviceID string, dryRun bool) (*api.ReposSyncResponse, error) {
+	// Every configured source of the service type must have listed its
+	// repositories before we know which repositories are missing.
+	complete := true
+	for source := range s.sources[serviceType] {
+		if l := s.listings[source]; l == nil || !l.complete {
+			complete = false
+		}
+	}
+
+	var sources []string
+	for source, l := range s.listings {
+		if l.serviceType == serviceType {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+
+	var repos []api.RepoCreateOrUpdateRequest
+	for _, source := range sources {
+		l := s.listings[source]
+		if !l.complete {
+			complete = false
+		}
+		for _, r := range l.repos {
+			if r.ExternalRepo.ServiceID == serviceID {
+				repos = append(repos, r)
+			}
+		}
+	}
+
+	return api.InternalClient.ReposSync(ctx, api.ReposSyncRequest{
+		ServiceType: serviceType,
+		ServiceID:   serviceID,
+		Repos:       mergeListings(repos, nil),
+		Complete:    complete,
+		DryRun:      dryRun,
+	})
+}
+
+// DryRun returns the diffs which the next sync of each external service
+// would apply to the repos table, keyed by service type and URL.
+func (s *syncer) DryRun(ctx context.Context) (map[string]*api.ReposSyncDiff, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	services := make(map[[2]string]bool)
+	for _, l := range s.listings {
+		for _, r := range l.repos {
+			services[[2]string{l.serviceType, r.ExternalRepo.ServiceID}] = true
+		}
+	}
+
+	diffs := make(map[string]*api.ReposSyncDiff, len(services))
+	for service := range services {
+		resp, err := s.syncService(ctx, service[0], service[1], true)
+		if err != nil {
+			return nil, err
+		}
+		diffs[service[0]+" "+service[1]] = &resp.Diff
+	}
+	return diffs, nil
+}
+
+// mergeListings returns the repositories of a, followed by the repositories
+// of b which are not in a (by external ID). Duplicates within a are dropped.
+func mergeListings(a, b []api.RepoCreateOrUpdateRequest) []api.RepoCreateOrUpdateRequest {
+	seen := make(map[string]bool, len(a))
+	merged := make([]api.RepoCreateOrUpdateRequest, 0, len(a)+len(b))
+	for _, repos := range [][]api.RepoCreateOrUpdateRequest{a, b} {
+		for _, r := range repos {
+			if seen[r.ExternalRepo.ID] {
+				continue
+			}
+			seen[r.ExternalRepo.ID] = true
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
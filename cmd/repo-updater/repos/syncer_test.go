@@ -0,0 +1,87 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+func TestSyncer(t *testing.T) {
+	var got *api.ReposSyncRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.internal/repos/sync" {
+			t.Errorf("got path %q, want /.internal/repos/sync", r.URL.Path)
+		}
+		got = new(api.ReposSyncRequest)
+		json.NewDecoder(r.Body).Decode(got)
+		var resp api.ReposSyncResponse
+		for _, repo := range got.Repos {
+			resp.Repos = append(resp.Repos, &api.Repo{Name: repo.RepoName, ExternalRepo: repo.ExternalRepo})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer ts.Close()
+	origURL := api.InternalClient.URL
+	api.InternalClient.URL = ts.URL
+	defer func() { api.InternalClient.URL = origURL }()
+
+	listed := func(ids ...string) []api.RepoCreateOrUpdateRequest {
+		var repos []api.RepoCreateOrUpdateRequest
+		for _, id := range ids {
+			repos = append(repos, api.RepoCreateOrUpdateRequest{
+				RepoName:     api.RepoName("github.com/o/" + id),
+				ExternalRepo: &api.ExternalRepoSpec{ID: id, ServiceType: "github", ServiceID: "https://github.com/"},
+			})
+		}
+		return repos
+	}
+	ids := func(repos []api.RepoCreateOrUpdateRequest) []string {
+		var ids []string
+		for _, r := range repos {
+			ids = append(ids, r.ExternalRepo.ID)
+		}
+		return ids
+	}
+
+	s := &syncer{sources: make(map[string]map[string]bool), listings: make(map[string]*sourceListing)}
+	s.setSources("github", []string{"a", "b"})
+
+	check := func(source string, repos []api.RepoCreateOrUpdateRequest, complete, wantComplete bool, wantIDs ...string) {
+		t.Helper()
+		got = nil
+		stored, err := s.sync(context.Background(), "github", source, repos, complete)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil {
+			t.Fatal("no sync request")
+		}
+		if got.Complete != wantComplete {
+			t.Errorf("got complete %v, want %v", got.Complete, wantComplete)
+		}
+		if gotIDs := ids(got.Repos); !reflect.DeepEqual(gotIDs, wantIDs) {
+			t.Errorf("got repos %v, want %v", gotIDs, wantIDs)
+		}
+		for _, r := range repos {
+			if stored[r.ExternalRepo.ID] == nil {
+				t.Errorf("repo %s is not stored", r.ExternalRepo.ID)
+			}
+		}
+	}
+
+	// Repositories are not deleted until every source listed its repositories.
+	check("a", listed("1", "2"), true, false, "1", "2")
+	check("b", listed("2", "3"), true, true, "1", "2", "3")
+
+	// An incomplete listing is merged with the previous listing of the source.
+	check("a", listed("4"), false, true, "4", "1", "2", "3")
+
+	// The listings of sources which are no longer configured are dropped.
+	s.setSources("github", []string{"a"})
+	check("a", listed("1"), true, true, "1")
+}
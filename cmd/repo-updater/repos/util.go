@@ -57,23 +57,55 @@ func cachedTransportWithCertTrusted(cert string) (http.RoundTripper, error) {
 // plus a specific URL we'd like to use for it.
 type repoCreateOrUpdateRequest struct {
 	api.RepoCreateOrUpdateRequest
-	URL string // the repository's Git remote URL
+	URL  string // the repository's Git remote URL
+	Size int64  // the repository's size in bytes, or 0 if the code host doesn't report it
 }
 
 // createEnableUpdateRepos receives requests on the provided channel. The
 // source argument should be a distinctive string identifying the configuration
 // being updated, so repo-updater can detect when repositories are dropped from
-// a given source.
-func createEnableUpdateRepos(ctx context.Context, source string, repoChan <-chan repoCreateOrUpdateRequest) {
+// a given source. Repositories excluded by filter (which may be nil) are
+// skipped, and removed by RunRepositoryPurgeWorker.
+//
+// If listing is non-nil, the requests with an ExternalRepo are the listing of
+// the source, which is synced to the repos table by the Syncer once the
+// channel is closed. The other requests create or update their repository
+// one by one.
+func createEnableUpdateRepos(ctx context.Context, source string, filter *repoFilter, listing *repoListing, repoChan <-chan repoCreateOrUpdateRequest) {
 	newList := make(sourceRepoList)
 	newScheduler := conf.UpdateScheduler2Enabled()
 	newMap := make(sourceRepoMap)
+	mirrored := make(map[api.RepoName]bool)
+	excluded := make(map[api.RepoName]bool)
+	var synced []repoCreateOrUpdateRequest
+
+	add := func(repo *api.Repo, url string) {
+		if newScheduler {
+			newMap[repo.Name] = &configuredRepo2{
+				Name:    repo.Name,
+				URL:     url,
+				Enabled: repo.Enabled,
+			}
+			return
+		}
+
+		newList[string(repo.Name)] = configuredRepo{url: url, enabled: repo.Enabled}
+	}
 
 	do := func(op repoCreateOrUpdateRequest) {
 		if op.RepoCreateOrUpdateRequest.RepoName == "" {
 			log15.Warn("ignoring invalid request to create or enable repo with empty name", "source", source, "repo", op.RepoCreateOrUpdateRequest.ExternalRepo)
 			return
 		}
+		if filter.excluded(&op) {
+			excluded[op.RepoName] = true
+			return
+		}
+		mirrored[op.RepoName] = true
+		if listing != nil && op.ExternalRepo != nil {
+			synced = append(synced, op)
+			return
+		}
 		createdRepo, err := api.InternalClient.ReposCreateIfNotExists(ctx, op.RepoCreateOrUpdateRequest)
 		if err != nil {
 			log15.Warn("Error creating or updating repository", "repo", op.RepoName, "error", err)
@@ -86,20 +118,33 @@ func createEnableUpdateRepos(ctx context.Context, source string, repoChan <-chan
 			return
 		}
 
-		if newScheduler {
-			newMap[createdRepo.Name] = &configuredRepo2{
-				Name:    createdRepo.Name,
-				URL:     op.URL,
-				Enabled: createdRepo.Enabled,
-			}
-			return
-		}
-
-		newList[string(createdRepo.Name)] = configuredRepo{url: op.URL, enabled: createdRepo.Enabled}
+		add(createdRepo, op.URL)
 	}
 	for repo := range repoChan {
 		do(repo)
 	}
+	setSyncedRepos(source, mirrored, excluded)
+
+	if listing != nil {
+		listed := make([]api.RepoCreateOrUpdateRequest, 0, len(synced))
+		for _, op := range synced {
+			listed = append(listed, op.RepoCreateOrUpdateRequest)
+		}
+		stored, err := Syncer.sync(ctx, listing.serviceType, source, listed, listing.complete())
+		if err != nil {
+			// Keep updating the repositories of the previous sync.
+			log15.Warn("Error syncing repositories", "source", listing.serviceType, "error", err)
+			return
+		}
+		for _, op := range synced {
+			// A listed repository is not stored if its name conflicts with
+			// another repository.
+			if repo := stored[op.ExternalRepo.ID]; repo != nil {
+				add(repo, op.URL)
+			}
+		}
+	}
+
 	if newScheduler {
 		Scheduler.updateSource(source, newMap)
 		return
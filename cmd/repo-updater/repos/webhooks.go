@@ -0,0 +1,234 @@
+package repos
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketserver"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/github"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitlab"
+)
+
+// ErrWebhookUnauthorized is returned by the webhook parsers if the webhook was not
+// sent with the webhook secret of a configured connection for the repository's host.
+var ErrWebhookUnauthorized = errors.New("webhook not authenticated with the webhook secret of a configured connection")
+
+// WebhookPush is a repository which a webhook reported new commits for.
+type WebhookPush struct {
+	Name api.RepoName // the Sourcegraph repository name
+	URL  string       // the Git remote URL (with credentials, if configured)
+}
+
+// ParseGitHubWebhook authenticates and parses a webhook from GitHub or GitHub
+// Enterprise. It returns nil if the webhook is not a push event (e.g. the "ping"
+// event GitHub sends when a webhook is created).
+func ParseGitHubWebhook(header http.Header, body []byte) (*WebhookPush, error) {
+	var payload struct {
+		Repository *struct {
+			FullName string `json:"full_name"`
+			HTMLURL  string `json:"html_url"`
+			Private  bool   `json:"private"`
+			Fork     bool   `json:"fork"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Repository == nil {
+		if header.Get("X-GitHub-Event") == "ping" {
+			// The ping of an organization webhook has no repository, so we
+			// can't look up the connection. Authenticate it with any
+			// connection's secret.
+			for _, c := range githubConnections.Get().([]*githubConnection) {
+				if validGitHubSignature(header, body, c.config.WebhookSecret) {
+					return nil, nil
+				}
+			}
+			return nil, ErrWebhookUnauthorized
+		}
+		return nil, errors.New("webhook has no repository")
+	}
+
+	var conn *githubConnection
+	for _, c := range githubConnections.Get().([]*githubConnection) {
+		if hostMatches(payload.Repository.HTMLURL, c.originalHostname) && validGitHubSignature(header, body, c.config.WebhookSecret) {
+			conn = c
+			break
+		}
+	}
+	if conn == nil {
+		return nil, ErrWebhookUnauthorized
+	}
+	if header.Get("X-GitHub-Event") != "push" {
+		return nil, nil
+	}
+
+	repo := &github.Repository{
+		NameWithOwner: payload.Repository.FullName,
+		URL:           payload.Repository.HTMLURL,
+		IsPrivate:     payload.Repository.Private,
+		IsFork:        payload.Repository.Fork,
+	}
+	return &WebhookPush{
+		Name: githubRepositoryToRepoPath(conn, repo),
+		URL:  conn.authenticatedRemoteURL(repo),
+	}, nil
+}
+
+// validGitHubSignature reports whether the body of a GitHub webhook is signed
+// with secret. GitHub sends the SHA-256 HMAC in the X-Hub-Signature-256 header,
+// and (also, or only in older versions of GitHub Enterprise) the SHA-1 HMAC in
+// the X-Hub-Signature header.
+func validGitHubSignature(header http.Header, body []byte, secret string) bool {
+	if sig := header.Get("X-Hub-Signature-256"); sig != "" {
+		return validHMAC(sha256.New, "sha256=", sig, body, secret)
+	}
+	return validHMAC(sha1.New, "sha1=", header.Get("X-Hub-Signature"), body, secret)
+}
+
+// ParseGitLabWebhook authenticates and parses a webhook from GitLab. It returns
+// nil if the webhook is not a push event.
+func ParseGitLabWebhook(header http.Header, body []byte) (*WebhookPush, error) {
+	var payload struct {
+		Project *struct {
+			PathWithNamespace string `json:"path_with_namespace"`
+			WebURL            string `json:"web_url"`
+			GitHTTPURL        string `json:"git_http_url"`
+			GitSSHURL         string `json:"git_ssh_url"`
+			VisibilityLevel   int    `json:"visibility_level"`
+		} `json:"project"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Project == nil {
+		return nil, errors.New("webhook has no project")
+	}
+
+	var conn *gitlabConnection
+	for _, c := range gitlabConnections.Get().([]*gitlabConnection) {
+		if hostMatches(payload.Project.WebURL, c.baseURL.Hostname()) && validToken(header.Get("X-Gitlab-Token"), c.config.WebhookSecret) {
+			conn = c
+			break
+		}
+	}
+	if conn == nil {
+		return nil, ErrWebhookUnauthorized
+	}
+	if event := header.Get("X-Gitlab-Event"); event != "Push Hook" && event != "Tag Push Hook" {
+		return nil, nil
+	}
+
+	proj := &gitlab.Project{
+		ProjectCommon: gitlab.ProjectCommon{
+			PathWithNamespace: payload.Project.PathWithNamespace,
+			WebURL:            payload.Project.WebURL,
+			HTTPURLToRepo:     payload.Project.GitHTTPURL,
+			SSHURLToRepo:      payload.Project.GitSSHURL,
+		},
+		Visibility: gitlabVisibility(payload.Project.VisibilityLevel),
+	}
+	return &WebhookPush{
+		Name: gitlabProjectToRepoPath(conn, proj),
+		URL:  conn.authenticatedRemoteURL(proj),
+	}, nil
+}
+
+// gitlabVisibility returns the visibility ("private", "internal" or "public")
+// of a GitLab project with the given visibility level, which webhooks report
+// instead of the visibility.
+func gitlabVisibility(level int) string {
+	switch {
+	case level >= 20:
+		return "public"
+	case level >= 10:
+		return "internal"
+	}
+	return "private"
+}
+
+// ParseBitbucketServerWebhook authenticates and parses a webhook from Bitbucket
+// Server. It returns nil if the webhook is not a push event (e.g. the ping sent
+// by the "Test connection" button).
+func ParseBitbucketServerWebhook(header http.Header, body []byte) (*WebhookPush, error) {
+	var payload struct {
+		Repository *bitbucketserver.Repo `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if header.Get("X-Event-Key") == "diagnostics:ping" {
+		// The ping has no repository, so we can't look up the connection.
+		// Authenticate it with any connection's secret.
+		for _, c := range bitbucketServerConnections.Get().([]*bitbucketServerConnection) {
+			if validHMAC(sha256.New, "sha256=", header.Get("X-Hub-Signature"), body, c.config.WebhookSecret) {
+				return nil, nil
+			}
+		}
+		return nil, ErrWebhookUnauthorized
+	}
+	if payload.Repository == nil {
+		return nil, errors.New("webhook has no repository")
+	}
+
+	var conn *bitbucketServerConnection
+	for _, c := range bitbucketServerConnections.Get().([]*bitbucketServerConnection) {
+		var self string
+		if len(payload.Repository.Links.Self) > 0 {
+			self = payload.Repository.Links.Self[0].Href
+		}
+		if hostMatches(self, c.client.URL.Hostname()) && validHMAC(sha256.New, "sha256=", header.Get("X-Hub-Signature"), body, c.config.WebhookSecret) {
+			conn = c
+			break
+		}
+	}
+	if conn == nil {
+		return nil, ErrWebhookUnauthorized
+	}
+	if header.Get("X-Event-Key") != "repo:refs_changed" {
+		return nil, nil
+	}
+
+	info := bitbucketServerRepoInfo(conn.config, payload.Repository)
+	if info == nil {
+		return nil, errors.New("invalid Bitbucket Server connection")
+	}
+	return &WebhookPush{Name: info.Name, URL: info.VCS.URL}, nil
+}
+
+// validHMAC reports whether sig is prefix followed by the hex-encoded HMAC of
+// body with the given secret. It returns false if secret is empty.
+func validHMAC(h func() hash.Hash, prefix, sig string, body []byte, secret string) bool {
+	if secret == "" || !strings.HasPrefix(sig, prefix) {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, prefix))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// validToken reports whether token is secret. It returns false if secret is
+// empty.
+func validToken(token, secret string) bool {
+	return secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// hostMatches reports whether rawURL is a URL on host.
+func hostMatches(rawURL, host string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && strings.EqualFold(u.Hostname(), host)
+}
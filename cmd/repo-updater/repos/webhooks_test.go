@@ -0,0 +1,202 @@
+package repos
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketserver"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func sign(h func() hash.Hash, prefix, secret, body string) string {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write([]byte(body))
+	return prefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+type webhookTest struct {
+	name    string
+	header  http.Header
+	body    string
+	want    *WebhookPush
+	wantErr error
+}
+
+func testWebhooks(t *testing.T, parse func(http.Header, []byte) (*WebhookPush, error), tests []webhookTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parse(test.header, []byte(test.body))
+			if err != test.wantErr {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseGitHubWebhook(t *testing.T) {
+	orig := githubConnections.Get()
+	githubConnections.Set(func() interface{} {
+		return []*githubConnection{
+			{originalHostname: "github.com", config: &schema.GitHubConnection{Token: "t"}},
+			{originalHostname: "github.example.com", config: &schema.GitHubConnection{Token: "t", WebhookSecret: "s"}},
+		}
+	})
+	defer func() { githubConnections.Set(func() interface{} { return orig }) }()
+
+	push := `{"ref":"refs/heads/master","repository":{"full_name":"foo/bar","html_url":"https://github.example.com/foo/bar"}}`
+	pushDotCom := `{"ref":"refs/heads/master","repository":{"full_name":"foo/bar","html_url":"https://github.com/foo/bar"}}`
+	header := func(event, sig1, sig256 string) http.Header {
+		h := http.Header{"X-Github-Event": []string{event}}
+		if sig1 != "" {
+			h.Set("X-Hub-Signature", sig1)
+		}
+		if sig256 != "" {
+			h.Set("X-Hub-Signature-256", sig256)
+		}
+		return h
+	}
+	want := &WebhookPush{Name: "github.example.com/foo/bar", URL: "https://t@github.example.com/foo/bar"}
+
+	testWebhooks(t, ParseGitHubWebhook, []webhookTest{
+		{
+			name:   "push sha1",
+			header: header("push", sign(sha1.New, "sha1=", "s", push), ""),
+			body:   push,
+			want:   want,
+		},
+		{
+			name:   "push sha256",
+			header: header("push", "", sign(sha256.New, "sha256=", "s", push)),
+			body:   push,
+			want:   want,
+		},
+		{
+			name:   "ping",
+			header: header("ping", sign(sha1.New, "sha1=", "s", push), ""),
+			body:   push,
+		},
+		{
+			name:    "wrong secret",
+			header:  header("push", sign(sha1.New, "sha1=", "x", push), ""),
+			body:    push,
+			wantErr: ErrWebhookUnauthorized,
+		},
+		{
+			name:    "unsigned",
+			header:  header("push", "", ""),
+			body:    push,
+			wantErr: ErrWebhookUnauthorized,
+		},
+		{
+			name:    "connection without secret",
+			header:  header("push", sign(sha1.New, "sha1=", "", pushDotCom), ""),
+			body:    pushDotCom,
+			wantErr: ErrWebhookUnauthorized,
+		},
+		{
+			name:    "other host",
+			header:  header("push", sign(sha1.New, "sha1=", "s", pushDotCom), ""),
+			body:    pushDotCom,
+			wantErr: ErrWebhookUnauthorized,
+		},
+	})
+}
+
+func TestParseGitLabWebhook(t *testing.T) {
+	orig := gitlabConnections.Get()
+	gitlabConnections.Set(func() interface{} {
+		return []*gitlabConnection{
+			{baseURL: &url.URL{Scheme: "https", Host: "gitlab.example.com"}, config: &schema.GitLabConnection{Token: "t", WebhookSecret: "s"}},
+		}
+	})
+	defer func() { gitlabConnections.Set(func() interface{} { return orig }) }()
+
+	push := func(visibility string) string {
+		return `{"object_kind":"push","project":{"path_with_namespace":"foo/bar","web_url":"https://gitlab.example.com/foo/bar","git_http_url":"https://gitlab.example.com/foo/bar.git","git_ssh_url":"git@gitlab.example.com:foo/bar.git","visibility_level":` + visibility + `}}`
+	}
+	header := func(event, token string) http.Header {
+		return http.Header{"X-Gitlab-Event": []string{event}, "X-Gitlab-Token": []string{token}}
+	}
+
+	testWebhooks(t, ParseGitLabWebhook, []webhookTest{
+		{
+			name:   "push private",
+			header: header("Push Hook", "s"),
+			body:   push("0"),
+			want:   &WebhookPush{Name: "gitlab.example.com/foo/bar", URL: "https://git:t@gitlab.example.com/foo/bar.git"},
+		},
+		{
+			name:   "tag push public",
+			header: header("Tag Push Hook", "s"),
+			body:   push("20"),
+			want:   &WebhookPush{Name: "gitlab.example.com/foo/bar", URL: "https://gitlab.example.com/foo/bar.git"},
+		},
+		{
+			name:   "merge request",
+			header: header("Merge Request Hook", "s"),
+			body:   push("0"),
+		},
+		{
+			name:    "wrong token",
+			header:  header("Push Hook", "x"),
+			body:    push("0"),
+			wantErr: ErrWebhookUnauthorized,
+		},
+	})
+}
+
+func TestParseBitbucketServerWebhook(t *testing.T) {
+	orig := bitbucketServerConnections.Get()
+	bitbucketServerConnections.Set(func() interface{} {
+		return []*bitbucketServerConnection{
+			{
+				config: &schema.BitbucketServerConnection{Url: "https://bitbucket.example.com", WebhookSecret: "s"},
+				client: &bitbucketserver.Client{URL: &url.URL{Scheme: "https", Host: "bitbucket.example.com"}},
+			},
+		}
+	})
+	defer func() { bitbucketServerConnections.Set(func() interface{} { return orig }) }()
+
+	push := `{"eventKey":"repo:refs_changed","repository":{"slug":"bar","project":{"key":"FOO"},"links":{"clone":[{"href":"https://bitbucket.example.com/scm/foo/bar.git","name":"http"}],"self":[{"href":"https://bitbucket.example.com/projects/FOO/repos/bar/browse"}]}}}`
+	ping := `{"test":true}`
+	header := func(event, sig string) http.Header {
+		return http.Header{"X-Event-Key": []string{event}, "X-Hub-Signature": []string{sig}}
+	}
+
+	testWebhooks(t, ParseBitbucketServerWebhook, []webhookTest{
+		{
+			name:   "push",
+			header: header("repo:refs_changed", sign(sha256.New, "sha256=", "s", push)),
+			body:   push,
+			want:   &WebhookPush{Name: "bitbucket.example.com/FOO/bar", URL: "https://bitbucket.example.com/scm/foo/bar.git"},
+		},
+		{
+			name:   "ping",
+			header: header("diagnostics:ping", sign(sha256.New, "sha256=", "s", ping)),
+			body:   ping,
+		},
+		{
+			name:    "unauthenticated ping",
+			header:  header("diagnostics:ping", sign(sha256.New, "sha256=", "x", ping)),
+			body:    ping,
+			wantErr: ErrWebhookUnauthorized,
+		},
+		{
+			name:    "wrong secret",
+			header:  header("repo:refs_changed", sign(sha256.New, "sha256=", "x", push)),
+			body:    push,
+			wantErr: ErrWebhookUnauthorized,
+		},
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/errcode"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/awscodecommit"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitea"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/github"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitlab"
 	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
@@ -29,6 +32,9 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/repo-update-scheduler-info", s.handleRepoUpdateSchedulerInfo)
 	mux.HandleFunc("/repo-lookup", s.handleRepoLookup)
 	mux.HandleFunc("/enqueue-repo-update", s.handleEnqueueRepoUpdate)
+	mux.HandleFunc("/webhooks/github", s.handleWebhook(repos.ParseGitHubWebhook))
+	mux.HandleFunc("/webhooks/gitlab", s.handleWebhook(repos.ParseGitLabWebhook))
+	mux.HandleFunc("/webhooks/bitbucket-server", s.handleWebhook(repos.ParseBitbucketServerWebhook))
 	return mux
 }
 
@@ -91,6 +97,48 @@ func (s *Server) handleEnqueueRepoUpdate(w http.ResponseWriter, r *http.Request)
 	repos.UpdateOnce(r.Context(), req.Repo, req.URL)
 }
 
+// maxWebhookSize is the maximum size of a webhook payload. GitHub caps payloads
+// at 25MB, the other code hosts send smaller ones.
+const maxWebhookSize = 25 << 20
+
+// handleWebhook returns a handler for the push webhooks of a code host, which
+// are authenticated and parsed by parse. Repositories which webhooks report new
+// commits for are updated immediately.
+func (s *Server) handleWebhook(parse func(http.Header, []byte) (*repos.WebhookPush, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxWebhookSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		push, err := parse(r.Header, body)
+		if err == repos.ErrWebhookUnauthorized {
+			log15.Warn("Rejected unauthenticated webhook", "path", r.URL.Path, "remoteAddr", r.RemoteAddr)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if push == nil {
+			// Not a push event, nothing to update.
+			return
+		}
+
+		log15.Debug("Webhook reported new commits", "repo", push.Name)
+		if conf.UpdateScheduler2Enabled() {
+			repos.Scheduler.UpdateFromWebhook(push.Name, push.URL)
+			return
+		}
+		repos.UpdateOnce(r.Context(), push.Name, push.URL)
+	}
+}
+
 var mockRepoLookup func(protocol.RepoLookupArgs) (*protocol.RepoLookupResult, error)
 
 func repoLookup(ctx context.Context, args protocol.RepoLookupArgs) (*protocol.RepoLookupResult, error) {
@@ -109,6 +157,9 @@ func repoLookup(ctx context.Context, args protocol.RepoLookupArgs) (*protocol.Re
 	if !authoritative {
 		repo, authoritative, err = repos.GetGitLabRepository(ctx, args)
 	}
+	if !authoritative {
+		repo, authoritative, err = repos.GetGiteaRepository(ctx, args)
+	}
 	if !authoritative {
 		repo, authoritative, err = repos.GetBitbucketServerRepository(ctx, args)
 	}
@@ -121,6 +172,9 @@ func repoLookup(ctx context.Context, args protocol.RepoLookupArgs) (*protocol.Re
 	if !authoritative {
 		repo, authoritative, err = repos.GetGitoliteRepository(ctx, args)
 	}
+	if !authoritative {
+		repo, authoritative, err = repos.GetOtherRepository(ctx, args)
+	}
 	if authoritative {
 		if isNotFound(err) {
 			result.ErrorNotFound = true
@@ -157,7 +211,7 @@ func repoLookup(ctx context.Context, args protocol.RepoLookupArgs) (*protocol.Re
 
 func isNotFound(err error) bool {
 	// TODO(sqs): reduce duplication
-	return github.IsNotFound(err) || gitlab.IsNotFound(err) || awscodecommit.IsNotFound(err) || errcode.IsNotFound(err)
+	return github.IsNotFound(err) || gitlab.IsNotFound(err) || gitea.IsNotFound(err) || awscodecommit.IsNotFound(err) || errcode.IsNotFound(err)
 }
 
 func isUnauthorized(err error) bool {
@@ -169,6 +223,9 @@ func isUnauthorized(err error) bool {
 	if code == 0 {
 		code = gitlab.HTTPErrorCode(err)
 	}
+	if code == 0 {
+		code = gitea.HTTPErrorCode(err)
+	}
 	return code == http.StatusUnauthorized || code == http.StatusForbidden
 }
 
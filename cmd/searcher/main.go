@@ -34,6 +34,7 @@ import (
 
 var cacheDir = env.Get("CACHE_DIR", "/tmp", "directory to store cached archives.")
 var cacheSizeMB = env.Get("SEARCHER_CACHE_SIZE_MB", "100000", "maximum size of the on disk cache in megabytes")
+var trigramIndexMinFiles = env.Get("SEARCHER_TRIGRAM_INDEX_MIN_FILES", "1000", "minimum number of files in an archive before a trigram index is built for it (0 disables trigram indexes)")
 
 const port = "3181"
 
@@ -52,13 +53,24 @@ func main() {
 		cacheSizeBytes = i * 1000 * 1000
 	}
 
+	var minFiles int
+	if i, err := strconv.Atoi(trigramIndexMinFiles); err != nil {
+		log.Fatalf("invalid int %q for SEARCHER_TRIGRAM_INDEX_MIN_FILES: %s", trigramIndexMinFiles, err)
+	} else {
+		minFiles = i
+	}
+
 	service := &search.Service{
 		Store: &search.Store{
 			FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
 				return git.Archive(ctx, repo, git.ArchiveOptions{Treeish: string(commit), Format: "tar"})
 			},
-			Path:              filepath.Join(cacheDir, "searcher-archives"),
-			MaxCacheSizeBytes: cacheSizeBytes,
+			FetchTarPaths: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+				return git.Archive(ctx, repo, git.ArchiveOptions{Treeish: string(commit), Format: "tar", Paths: paths})
+			},
+			Path:                 filepath.Join(cacheDir, "searcher-archives"),
+			MaxCacheSizeBytes:    cacheSizeBytes,
+			TrigramIndexMinFiles: minFiles,
 		},
 	}
 	service.Store.SetMaxConcurrentFetchTar(10)
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"github.com/sourcegraph/sourcegraph/pkg/searcher/protocol"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// diffFind searches zf and baseZf with rg and returns only the matches which
+// differ between them: matches in zf which are not in baseZf (added), and
+// matches in baseZf which are not in zf (Removed is set).
+//
+// Files are compared by path, so a renamed file's matches are reported as
+// removed from the old path and added to the new one. Within a file, line
+// matches are compared by the content of the line, so moving a matching line
+// within a file is not reported.
+func diffFind(ctx context.Context, rg *readerGrep, zf, baseZf *zipFile, fileMatchLimit int, patternMatchesContent, patternMatchesPaths bool) (fm []protocol.FileMatch, limitHit bool, err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DiffFind")
+	ext.Component.Set(span, "matcher")
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.SetTag("err", err.Error())
+		}
+		span.Finish()
+	}()
+
+	if fileMatchLimit > maxFileMatches || fileMatchLimit <= 0 {
+		fileMatchLimit = maxFileMatches
+	}
+
+	// Files with the same contents at both commits can't have added or
+	// removed matches, so we only search the others.
+	changed, baseChanged := changedFiles(zf, baseZf)
+	span.LogFields(
+		otlog.Int("changed", len(changed.Files)),
+		otlog.Int("baseChanged", len(baseChanged.Files)))
+
+	// We need all matches on both sides to compute the difference, so we
+	// search with the maximum limit and apply fileMatchLimit afterwards.
+	matches, limitHit, err := concurrentFind(ctx, rg, changed, maxFileMatches, patternMatchesContent, patternMatchesPaths, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	baseMatches, baseLimitHit, err := concurrentFind(ctx, rg, baseChanged, maxFileMatches, patternMatchesContent, patternMatchesPaths, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	limitHit = limitHit || baseLimitHit
+
+	fm = append(diffFileMatches(matches, baseMatches, false), diffFileMatches(baseMatches, matches, true)...)
+	sort.SliceStable(fm, func(i, j int) bool { return fm[i].Path < fm[j].Path })
+	if len(fm) > fileMatchLimit {
+		fm = fm[:fileMatchLimit]
+		limitHit = true
+	}
+	return fm, limitHit, nil
+}
+
+// changedFiles returns views of zf and baseZf which only contain the files
+// whose contents differ between them (including files which only exist in
+// one of them). The views share the underlying data with zf and baseZf, so
+// they must not be used after those are closed.
+func changedFiles(zf, baseZf *zipFile) (changed, baseChanged *zipFile) {
+	base := make(map[string]*srcFile, len(baseZf.Files))
+	for i := range baseZf.Files {
+		base[baseZf.Files[i].Name] = &baseZf.Files[i]
+	}
+
+	unchanged := map[string]bool{}
+	changed = &zipFile{MaxLen: zf.MaxLen, Data: zf.Data}
+	for i := range zf.Files {
+		f := &zf.Files[i]
+		if bf, ok := base[f.Name]; ok && bytes.Equal(zf.DataFor(f), baseZf.DataFor(bf)) {
+			unchanged[f.Name] = true
+			continue
+		}
+		changed.Files = append(changed.Files, *f)
+	}
+
+	baseChanged = &zipFile{MaxLen: baseZf.MaxLen, Data: baseZf.Data}
+	for _, f := range baseZf.Files {
+		if !unchanged[f.Name] {
+			baseChanged.Files = append(baseChanged.Files, f)
+		}
+	}
+	return changed, baseChanged
+}
+
+// diffFileMatches returns the file matches in matches which are not in other.
+// For files in both, only the line matches which are not in other are kept.
+// removed is set on the returned file matches.
+func diffFileMatches(matches, other []protocol.FileMatch, removed bool) []protocol.FileMatch {
+	otherByPath := make(map[string]*protocol.FileMatch, len(other))
+	for i := range other {
+		otherByPath[other[i].Path] = &other[i]
+	}
+
+	var diff []protocol.FileMatch
+	for _, fm := range matches {
+		o, ok := otherByPath[fm.Path]
+		if !ok {
+			fm.Removed = removed
+			diff = append(diff, fm)
+			continue
+		}
+		// If the file only matched by path, it matches on both sides.
+		if lms := diffLineMatches(fm.LineMatches, o.LineMatches); len(lms) > 0 {
+			diff = append(diff, protocol.FileMatch{
+				Path:        fm.Path,
+				LineMatches: lms,
+				LimitHit:    fm.LimitHit || o.LimitHit,
+				Removed:     removed,
+			})
+		}
+	}
+	return diff
+}
+
+// diffLineMatches returns the line matches in lms whose line does not appear
+// in other. Lines which appear more often in lms than in other are returned
+// for each extra occurrence.
+func diffLineMatches(lms, other []protocol.LineMatch) []protocol.LineMatch {
+	count := make(map[string]int, len(other))
+	for _, lm := range other {
+		count[lm.Preview]++
+	}
+	var diff []protocol.LineMatch
+	for _, lm := range lms {
+		if count[lm.Preview] > 0 {
+			count[lm.Preview]--
+			continue
+		}
+		diff = append(diff, lm)
+	}
+	return diff
+}
@@ -0,0 +1,61 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/searcher/protocol"
+)
+
+func TestDiffFind(t *testing.T) {
+	head, err := createZip(map[string]string{
+		"unchanged.go": "// TODO: unchanged\n",
+		"changed.go":   "// TODO: kept\nfoo()\n// TODO: new\n",
+		"added.go":     "// TODO: added file\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := createZip(map[string]string{
+		"unchanged.go": "// TODO: unchanged\n",
+		"changed.go":   "// TODO: old\n// TODO: kept\nfoo()\n",
+		"deleted.go":   "// TODO: deleted file\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := mockZipFile(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseZf, err := mockZipFile(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg, err := compile(&protocol.PatternInfo{Pattern: "TODO"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, limitHit, err := diffFind(context.Background(), rg, zf, baseZf, 0, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limitHit {
+		t.Error("unexpected limitHit")
+	}
+
+	lm := func(preview string, line int) []protocol.LineMatch {
+		return []protocol.LineMatch{{Preview: preview, LineNumber: line, OffsetAndLengths: [][2]int{{3, 4}}}}
+	}
+	want := []protocol.FileMatch{
+		{Path: "added.go", LineMatches: lm("// TODO: added file", 0)},
+		{Path: "changed.go", LineMatches: lm("// TODO: new", 2)},
+		{Path: "changed.go", LineMatches: lm("// TODO: old", 0), Removed: true},
+		{Path: "deleted.go", LineMatches: lm("// TODO: deleted file", 0), Removed: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -68,6 +68,9 @@ type readerGrep struct {
 	// re is the regexp to match, or nil if empty ("match all files' content").
 	re *regexp.Regexp
 
+	// structural is the structural pattern to match. If set, re is nil.
+	structural *structuralPattern
+
 	// ignoreCase if true means we need to do case insensitive matching.
 	ignoreCase bool
 
@@ -92,9 +95,17 @@ type readerGrep struct {
 func compile(p *protocol.PatternInfo) (*readerGrep, error) {
 	var (
 		re               *regexp.Regexp
+		structural       *structuralPattern
 		literalSubstring []byte
 	)
-	if p.Pattern != "" {
+	if p.Pattern != "" && p.IsStructuralPat {
+		var err error
+		structural, err = compileStructural(p.Pattern, !p.IsCaseSensitive)
+		if err != nil {
+			return nil, err
+		}
+		literalSubstring = structural.literal
+	} else if p.Pattern != "" {
 		expr := p.Pattern
 		if !p.IsRegExp {
 			expr = regexp.QuoteMeta(expr)
@@ -148,6 +159,7 @@ func compile(p *protocol.PatternInfo) (*readerGrep, error) {
 
 	return &readerGrep{
 		re:               re,
+		structural:       structural,
 		ignoreCase:       !p.IsCaseSensitive,
 		matchPath:        matchPath,
 		literalSubstring: literalSubstring,
@@ -163,6 +175,7 @@ func (rg *readerGrep) Copy() *readerGrep {
 	}
 	return &readerGrep{
 		re:               reCopy,
+		structural:       rg.structural,
 		ignoreCase:       rg.ignoreCase,
 		matchPath:        rg.matchPath.Copy(),
 		literalSubstring: rg.literalSubstring,
@@ -172,15 +185,35 @@ func (rg *readerGrep) Copy() *readerGrep {
 // matchString returns whether rg's regexp pattern matches s. It is intended to be
 // used to match file paths.
 func (rg *readerGrep) matchString(s string) bool {
-	if rg.re == nil {
+	if rg.matchesAll() {
 		return true
 	}
 	if rg.ignoreCase {
 		s = strings.ToLower(s)
 	}
+	if rg.structural != nil {
+		return len(rg.structural.FindAll([]byte(s), 1)) > 0
+	}
 	return rg.re.MatchString(s)
 }
 
+// requiredLiteral returns a string which appears in the content of every file
+// matched by rg, or nil if there is none.
+func (rg *readerGrep) requiredLiteral() []byte {
+	if rg.re != nil {
+		if prefix, _ := rg.re.LiteralPrefix(); len(prefix) > len(rg.literalSubstring) {
+			return []byte(prefix)
+		}
+	}
+	return rg.literalSubstring
+}
+
+// matchesAll returns true if rg has no pattern, so it matches all files'
+// content.
+func (rg *readerGrep) matchesAll() bool {
+	return rg.re == nil && rg.structural == nil
+}
+
 // Find returns a LineMatch for each line that matches rg in reader.
 // LimitHit is true if some matches may not have been included in the result.
 // NOTE: This is not safe to use concurrently.
@@ -214,6 +247,10 @@ func (rg *readerGrep) Find(zf *zipFile, f *srcFile) (matches []protocol.LineMatc
 	if !bytes.Contains(fileMatchBuf, rg.literalSubstring) {
 		return nil, false, nil
 	}
+	if rg.structural != nil {
+		matches, limitHit = rg.findStructural(fileBuf, fileMatchBuf)
+		return matches, limitHit, nil
+	}
 	first := rg.re.FindIndex(fileMatchBuf)
 	if first == nil {
 		return nil, false, nil
@@ -288,13 +325,17 @@ func (rg *readerGrep) FindZip(zf *zipFile, f *srcFile) (protocol.FileMatch, erro
 	}, err
 }
 
-// concurrentFind searches files in zr looking for matches using rg.
-func concurrentFind(ctx context.Context, rg *readerGrep, zf *zipFile, fileMatchLimit int, patternMatchesContent, patternMatchesPaths bool) (fm []protocol.FileMatch, limitHit bool, err error) {
+// concurrentFind searches files in zr looking for matches using rg. If
+// sender is non-nil, it is called with each match as soon as it is found.
+func concurrentFind(ctx context.Context, rg *readerGrep, zf *zipFile, fileMatchLimit int, patternMatchesContent, patternMatchesPaths bool, sender matchSender) (fm []protocol.FileMatch, limitHit bool, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "ConcurrentFind")
 	ext.Component.Set(span, "matcher")
 	if rg.re != nil {
 		span.SetTag("re", rg.re.String())
 	}
+	if rg.structural != nil {
+		span.SetTag("structural", true)
+	}
 	span.SetTag("path", rg.matchPath.String())
 	defer func() {
 		if err != nil {
@@ -331,13 +372,17 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *zipFile, fileMatchL
 		matches   = []protocol.FileMatch{}
 	)
 
-	if patternMatchesPaths && (!patternMatchesContent || rg.re == nil) {
+	if patternMatchesPaths && (!patternMatchesContent || rg.matchesAll()) {
 		// Fast path for only matching file paths (or with a nil pattern, which matches all files,
 		// so is effectively matching only on file paths).
 		for _, f := range files {
 			if rg.matchPath.MatchPath(f.Name) && rg.matchString(f.Name) {
 				if len(matches) < fileMatchLimit {
-					matches = append(matches, protocol.FileMatch{Path: f.Name})
+					fm := protocol.FileMatch{Path: f.Name}
+					matches = append(matches, fm)
+					if sender != nil {
+						sender(fm)
+					}
 				} else {
 					limitHit = true
 					break
@@ -347,6 +392,18 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *zipFile, fileMatchL
 		return matches, limitHit, nil
 	}
 
+	// Use the trigram index (if built) to skip files which can't contain a
+	// match. If the pattern can also match paths, every file is a candidate.
+	if idx := zf.Index(); idx != nil && !patternMatchesPaths {
+		if candidates, ok := idx.Candidates(rg.requiredLiteral()); ok {
+			span.LogFields(otlog.Int("indexCandidates", len(candidates)), otlog.Int("files", len(files)))
+			files = make([]srcFile, len(candidates))
+			for i, c := range candidates {
+				files[i] = zf.Files[c]
+			}
+		}
+	}
+
 	var (
 		done          = ctx.Done()
 		wg            sync.WaitGroup
@@ -407,13 +464,19 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *zipFile, fileMatchL
 				}
 				if match {
 					matchesmu.Lock()
-					if len(matches) < fileMatchLimit {
+					added := len(matches) < fileMatchLimit
+					if added {
 						matches = append(matches, fm)
 					} else {
 						limitHit = true
 						cancel()
 					}
 					matchesmu.Unlock()
+					// Send outside of the lock, so that a slow client does
+					// not block the other workers.
+					if added && sender != nil {
+						sender(fm)
+					}
 				}
 			}
 		}(rg.Copy())
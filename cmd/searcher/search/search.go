@@ -83,7 +83,20 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	matches, limitHit, deadlineHit, err := s.search(ctx, &p)
+	var sw *streamWriter
+	var sender matchSender
+	if p.Stream {
+		sw = newStreamWriter(w)
+		sender = sw.SendMatch
+	}
+
+	matches, limitHit, deadlineHit, err := s.search(ctx, &p, sender)
+	if sw != nil && sw.Started() {
+		// We have already sent a response, so errors can only be reported
+		// in the final event.
+		sw.SendDone(limitHit, deadlineHit, err)
+		return
+	}
 	if err != nil {
 		code := http.StatusInternalServerError
 		if isBadRequest(err) || ctx.Err() == context.Canceled {
@@ -96,6 +109,10 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), code)
 		return
 	}
+	if sw != nil {
+		sw.SendDone(limitHit, deadlineHit, nil)
+		return
+	}
 	if matches == nil {
 		// Return an empty list
 		matches = make([]protocol.FileMatch, 0)
@@ -114,7 +131,7 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(&resp)
 }
 
-func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []protocol.FileMatch, limitHit, deadlineHit bool, err error) {
+func (s *Service) search(ctx context.Context, p *protocol.Request, sender matchSender) (matches []protocol.FileMatch, limitHit, deadlineHit bool, err error) {
 	tr := trace.New("search", fmt.Sprintf("%s@%s", p.Repo, p.Commit))
 	tr.LazyPrintf("%s", p.Pattern)
 
@@ -123,9 +140,12 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	span.SetTag("repo", p.Repo)
 	span.SetTag("url", p.URL)
 	span.SetTag("commit", p.Commit)
+	span.SetTag("baseCommit", p.BaseCommit)
+	span.SetTag("paths", len(p.Paths))
 	span.SetTag("pattern", p.Pattern)
 	span.SetTag("isRegExp", strconv.FormatBool(p.IsRegExp))
 	span.SetTag("isWordMatch", strconv.FormatBool(p.IsWordMatch))
+	span.SetTag("isStructuralPat", strconv.FormatBool(p.IsStructuralPat))
 	span.SetTag("isCaseSensitive", strconv.FormatBool(p.IsCaseSensitive))
 	span.SetTag("pathPatternsAreRegExps", strconv.FormatBool(p.PathPatternsAreRegExps))
 	span.SetTag("pathPatternsAreCaseSensitive", strconv.FormatBool(p.PathPatternsAreCaseSensitive))
@@ -133,6 +153,7 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	span.SetTag("patternMatchesContent", p.PatternMatchesContent)
 	span.SetTag("patternMatchesPath", p.PatternMatchesPath)
 	span.SetTag("deadline", p.Deadline)
+	span.SetTag("stream", p.Stream)
 	defer func(start time.Time) {
 		code := "200"
 		// We often have canceled and timed out requests. We do not want to
@@ -165,7 +186,7 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 		span.SetTag("limitHit", limitHit)
 		span.SetTag("deadlineHit", deadlineHit)
 		span.Finish()
-		log15.Debug("search request", "repo", p.Repo, "commit", p.Commit, "pattern", p.Pattern, "isRegExp", p.IsRegExp, "isWordMatch", p.IsWordMatch, "isCaseSensitive", p.IsCaseSensitive, "patternMatchesContent", p.PatternMatchesContent, "patternMatchesPath", p.PatternMatchesPath, "matches", len(matches), "code", code, "duration", time.Since(start), "err", err)
+		log15.Debug("search request", "repo", p.Repo, "commit", p.Commit, "baseCommit", p.BaseCommit, "pattern", p.Pattern, "isRegExp", p.IsRegExp, "isWordMatch", p.IsWordMatch, "isStructuralPat", p.IsStructuralPat, "isCaseSensitive", p.IsCaseSensitive, "patternMatchesContent", p.PatternMatchesContent, "patternMatchesPath", p.PatternMatchesPath, "matches", len(matches), "code", code, "duration", time.Since(start), "err", err)
 	}(time.Now())
 
 	rg, err := compile(&p.PatternInfo)
@@ -182,7 +203,7 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	}
 	prepareCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
 	defer cancel()
-	path, err := s.Store.prepareZip(prepareCtx, p.GitserverRepo(), p.Commit)
+	path, err := s.Store.prepareZip(prepareCtx, p.GitserverRepo(), p.Commit, p.Paths)
 	if err != nil {
 		return nil, false, false, err
 	}
@@ -201,7 +222,29 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	archiveFiles.Observe(float64(nFiles))
 	archiveSize.Observe(float64(bytes))
 
-	matches, limitHit, err = concurrentFind(ctx, rg, zf, p.FileMatchLimit, p.PatternMatchesContent, p.PatternMatchesPath)
+	if p.BaseCommit != "" {
+		basePath, err := s.Store.prepareZip(prepareCtx, p.GitserverRepo(), p.BaseCommit, nil)
+		if err != nil {
+			return nil, false, false, err
+		}
+		baseZf, err := s.Store.zipCache.get(basePath)
+		if err != nil {
+			return nil, false, false, err
+		}
+		defer baseZf.Close()
+
+		// The difference is only known once both commits have been
+		// searched, so there is nothing to stream before then.
+		matches, limitHit, err = diffFind(ctx, rg, zf, baseZf, p.FileMatchLimit, p.PatternMatchesContent, p.PatternMatchesPath)
+		if err == nil && sender != nil {
+			for _, fm := range matches {
+				sender(fm)
+			}
+		}
+		return matches, limitHit, false, err
+	}
+
+	matches, limitHit, err = concurrentFind(ctx, rg, zf, p.FileMatchLimit, p.PatternMatchesContent, p.PatternMatchesPath, sender)
 	return matches, limitHit, false, err
 }
 
@@ -213,6 +256,12 @@ func validateParams(p *protocol.Request) error {
 	if len(p.Commit) != 40 {
 		return errors.Errorf("Commit must be resolved (Commit=%q)", p.Commit)
 	}
+	if p.BaseCommit != "" && len(p.BaseCommit) != 40 {
+		return errors.Errorf("BaseCommit must be resolved (BaseCommit=%q)", p.BaseCommit)
+	}
+	if p.BaseCommit != "" && len(p.Paths) > 0 {
+		return errors.New("Paths may not be used with BaseCommit")
+	}
 	if p.Pattern == "" && p.ExcludePattern == "" && len(p.IncludePatterns) == 0 && p.IncludePattern == "" {
 		return errors.New("At least one of pattern and include/exclude pattners must be non-empty")
 	}
@@ -292,6 +292,80 @@ func TestSearch_badrequest(t *testing.T) {
 	}
 }
 
+func TestSearch_stream(t *testing.T) {
+	files := map[string]string{
+		"README.md": "Hello world example in go",
+		"main.go":   "fmt.Println(\"Hello world\")",
+		"abc.txt":   "w",
+	}
+
+	store, cleanup, err := newStore(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	ts := httptest.NewServer(&search.Service{Store: store})
+	defer ts.Close()
+
+	form := url.Values{
+		"Repo":                  []string{"foo"},
+		"URL":                   []string{"u"},
+		"Commit":                []string{"deadbeefdeadbeefdeadbeefdeadbeefdeadbeef"},
+		"Pattern":               []string{"world"},
+		"PatternMatchesContent": []string{"true"},
+		"Stream":                []string{"true"},
+	}
+	resp, err := http.PostForm(ts.URL, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("non-200 response: code=%d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("unexpected Content-Type %q", ct)
+	}
+
+	var (
+		m    []protocol.FileMatch
+		done *protocol.StreamEvent
+	)
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev protocol.StreamEvent
+		if err := dec.Decode(&ev); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if done != nil {
+			t.Fatalf("unexpected event after done event: %+v", ev)
+		}
+		if ev.Done {
+			done = &ev
+			continue
+		}
+		if ev.Match == nil {
+			t.Fatalf("event has neither Match nor Done set: %+v", ev)
+		}
+		m = append(m, *ev.Match)
+	}
+	if done == nil {
+		t.Fatal("stream did not end with a done event")
+	}
+	if done.Error != "" || done.LimitHit || done.DeadlineHit {
+		t.Fatalf("unexpected done event: %+v", done)
+	}
+
+	sort.Sort(sortByPath(m))
+	got := toString(m)
+	want := "README.md:1:Hello world example in go\nmain.go:1:fmt.Println(\"Hello world\")\n"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
 func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 	form := url.Values{
 		"Repo":            []string{string(p.Repo)},
@@ -9,6 +9,9 @@ import (
 	"encoding/hex"
 	"io"
 	"log"
+	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -34,6 +37,9 @@ import (
 // * What to evict uses the LRU algorithm.
 // * We touch files when opening them, so can do LRU based on file
 //   modification times.
+// * Each zip may have a trigram index stored next to it (see
+//   trigramIndexPath). It is not counted towards the cache size, and is
+//   evicted together with its zip.
 //
 // Note: The store fetches tarballs but stores zips. We want to be able to
 // filter which files we cache, so we need a format that supports streaming
@@ -45,6 +51,11 @@ type Store struct {
 	// determine if the error is a bad request (eg invalid repo).
 	FetchTar func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error)
 
+	// FetchTarPaths is like FetchTar, but the archive only contains the
+	// specified paths. It is used to search only some files of a commit. If
+	// it is nil, the whole archive is always fetched.
+	FetchTarPaths func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error)
+
 	// Path is the directory to store the cache
 	Path string
 
@@ -54,6 +65,11 @@ type Store struct {
 	// MaxCacheSizeBytes.
 	MaxCacheSizeBytes int64
 
+	// TrigramIndexMinFiles is the minimum number of files an archive must
+	// contain before we build a trigram index for it. Searching smaller
+	// archives is fast enough without one. If zero, no indexes are built.
+	TrigramIndexMinFiles int
+
 	// once protects Start
 	once sync.Once
 
@@ -65,6 +81,13 @@ type Store struct {
 
 	// zipCache provides efficient access to repo zip files.
 	zipCache zipCache
+
+	// indexingMu protects indexing.
+	indexingMu sync.Mutex
+
+	// indexing is the set of zip paths we are currently building a trigram
+	// index for.
+	indexing map[string]bool
 }
 
 // SetMaxConcurrentFetchTar sets the maximum number of concurrent calls allowed
@@ -92,15 +115,17 @@ func (s *Store) Start() {
 			Dir:               s.Path,
 			Component:         "store",
 			BackgroundTimeout: 2 * time.Minute,
-			BeforeEvict:       s.zipCache.delete,
+			BeforeEvict:       s.beforeEvict,
 		}
 		go s.watchAndEvict()
 	})
 }
 
-// prepareZip returns the path to a local zip archive of repo at commit.
-// It will first consult the local cache, otherwise will fetch from the network.
-func (s *Store) prepareZip(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (path string, err error) {
+// prepareZip returns the path to a local zip archive of repo at commit. If
+// paths is non-empty (and FetchTarPaths is set), the archive only contains
+// those paths. It will first consult the local cache, otherwise will fetch
+// from the network.
+func (s *Store) prepareZip(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (path string, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Store.prepareZip")
 	ext.Component.Set(span, "store")
 	defer func() {
@@ -120,8 +145,18 @@ func (s *Store) prepareZip(ctx context.Context, repo gitserver.Repo, commit api.
 		return "", errors.Errorf("commit must be resolved (repo=%q, commit=%q)", repo.Name, commit)
 	}
 
+	if s.FetchTarPaths == nil {
+		paths = nil
+	}
+
 	// key is a sha256 hash since we want to use it for the disk name
-	h := sha256.Sum256([]byte(string(repo.Name) + " " + string(commit)))
+	keyData := string(repo.Name) + " " + string(commit)
+	if len(paths) > 0 {
+		paths = append([]string(nil), paths...)
+		sort.Strings(paths)
+		keyData += " " + strings.Join(paths, "\x00")
+	}
+	h := sha256.Sum256([]byte(keyData))
 	key := hex.EncodeToString(h[:])
 	span.LogKV("key", key)
 
@@ -137,7 +172,7 @@ func (s *Store) prepareZip(ctx context.Context, repo gitserver.Repo, commit api.
 		// since we're just going to close it again immediately.
 		bgctx := opentracing.ContextWithSpan(context.Background(), opentracing.SpanFromContext(ctx))
 		f, err := s.cache.Open(bgctx, key, func(ctx context.Context) (io.ReadCloser, error) {
-			return s.fetch(ctx, repo, commit)
+			return s.fetch(ctx, repo, commit, paths)
 		})
 		var path string
 		if f != nil {
@@ -157,14 +192,80 @@ func (s *Store) prepareZip(ctx context.Context, repo gitserver.Repo, commit api.
 		if res.err != nil {
 			return "", res.err
 		}
+		s.buildTrigramIndex(res.path)
 		return res.path, nil
 	}
 }
 
-// fetch fetches an archive from the network and stores it on disk. It does
-// not populate the in-memory cache. You should probably be calling
-// prepareZip.
-func (s *Store) fetch(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (rc io.ReadCloser, err error) {
+// buildTrigramIndex builds the trigram index for the zip at path in the
+// background, unless it already exists or the zip is too small to need one.
+// The index is stored next to the zip, see trigramIndexPath.
+func (s *Store) buildTrigramIndex(path string) {
+	if s.TrigramIndexMinFiles <= 0 {
+		return
+	}
+
+	s.indexingMu.Lock()
+	if s.indexing[path] {
+		s.indexingMu.Unlock()
+		return
+	}
+	if s.indexing == nil {
+		s.indexing = map[string]bool{}
+	}
+	s.indexing[path] = true
+	s.indexingMu.Unlock()
+
+	go func() {
+		defer func() {
+			s.indexingMu.Lock()
+			delete(s.indexing, path)
+			s.indexingMu.Unlock()
+		}()
+
+		// Holding zf prevents the zip from being evicted while we build
+		// its index.
+		zf, err := s.zipCache.get(path)
+		if err != nil {
+			return
+		}
+		defer zf.Close()
+		if zf.Index() != nil || len(zf.Files) < s.TrigramIndexMinFiles {
+			return
+		}
+
+		indexPath := trigramIndexPath(path)
+		start := time.Now()
+		err = writeTrigramIndex(indexPath, zf)
+		if err == nil {
+			var idx *trigramIndex
+			idx, err = openTrigramIndex(indexPath, len(zf.Files))
+			if err == nil {
+				zf.setIndex(idx)
+			}
+		}
+		if err != nil {
+			log.Printf("failed to build trigram index for %q: %s", path, err)
+			trigramIndexFailed.Inc()
+			return
+		}
+		trigramIndexDuration.Observe(time.Since(start).Seconds())
+	}()
+}
+
+// beforeEvict is called by the disk cache before it removes the zip at path.
+// It removes the zip's in-memory state and its trigram index.
+func (s *Store) beforeEvict(path string) {
+	s.zipCache.delete(path)
+	if err := os.Remove(trigramIndexPath(path)); err != nil && !os.IsNotExist(err) {
+		log.Printf("failed to remove trigram index for %q: %s", path, err)
+	}
+}
+
+// fetch fetches an archive (of only paths, if non-empty) from the network and
+// stores it on disk. It does not populate the in-memory cache. You should
+// probably be calling prepareZip.
+func (s *Store) fetch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (rc io.ReadCloser, err error) {
 	fetchQueueSize.Inc()
 	ctx, releaseFetchLimiter, err := s.fetchLimiter.Acquire(ctx) // Acquire concurrent fetches semaphore
 	if err != nil {
@@ -182,6 +283,7 @@ func (s *Store) fetch(ctx context.Context, repo gitserver.Repo, commit api.Commi
 	span.SetTag("repo", repo.Name)
 	span.SetTag("repoURL", repo.URL)
 	span.SetTag("commit", commit)
+	span.SetTag("paths", len(paths))
 
 	// Done is called when the returned reader is closed, or if this function
 	// returns an error. It should always be called once.
@@ -208,7 +310,12 @@ func (s *Store) fetch(ctx context.Context, repo gitserver.Repo, commit api.Commi
 		}
 	}()
 
-	r, err := s.FetchTar(ctx, repo, commit)
+	var r io.ReadCloser
+	if len(paths) > 0 {
+		r, err = s.FetchTarPaths(ctx, repo, commit, paths)
+	} else {
+		r, err = s.FetchTar(ctx, repo, commit)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -364,6 +471,19 @@ var (
 		Name:      "fetch_failed",
 		Help:      "The total number of archive fetches that failed.",
 	})
+	trigramIndexDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "searcher",
+		Subsystem: "store",
+		Name:      "trigram_index_duration_seconds",
+		Help:      "Time taken to build the trigram index for an archive.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120},
+	})
+	trigramIndexFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "searcher",
+		Subsystem: "store",
+		Name:      "trigram_index_failed",
+		Help:      "The total number of trigram index builds that failed.",
+	})
 )
 
 func init() {
@@ -372,4 +492,6 @@ func init() {
 	prometheus.MustRegister(fetching)
 	prometheus.MustRegister(fetchQueueSize)
 	prometheus.MustRegister(fetchFailed)
+	prometheus.MustRegister(trigramIndexDuration)
+	prometheus.MustRegister(trigramIndexFailed)
 }
@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -41,7 +42,7 @@ func TestPrepareZip(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		go func() {
 			<-startPrepareZip
-			_, err := s.prepareZip(context.Background(), wantRepo, wantCommit)
+			_, err := s.prepareZip(context.Background(), wantRepo, wantCommit, nil)
 			prepareZipErr <- err
 		}()
 	}
@@ -75,7 +76,7 @@ func TestPrepareZip(t *testing.T) {
 	if !onDisk {
 		t.Fatal("timed out waiting for items to appear in cache at", s.Path)
 	}
-	_, err := s.prepareZip(context.Background(), wantRepo, wantCommit)
+	_, err := s.prepareZip(context.Background(), wantRepo, wantCommit, nil)
 	if err != nil {
 		t.Fatal("expected prepareZip to succeed:", err)
 		return
@@ -89,12 +90,49 @@ func TestPrepareZip_fetchTarFail(t *testing.T) {
 	s.FetchTar = func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
 		return nil, fetchErr
 	}
-	_, err := s.prepareZip(context.Background(), gitserver.Repo{Name: "foo"}, "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef")
+	_, err := s.prepareZip(context.Background(), gitserver.Repo{Name: "foo"}, "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef", nil)
 	if errors.Cause(err) != fetchErr {
 		t.Fatalf("expected prepareZip to fail with %v, failed with %v", fetchErr, err)
 	}
 }
 
+func TestPrepareZip_paths(t *testing.T) {
+	s, cleanup := tmpStore(t)
+	defer cleanup()
+
+	var gotPaths [][]string
+	s.FetchTar = func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
+		t.Error("expected only the paths to be fetched")
+		return emptyTar(t), nil
+	}
+	s.FetchTarPaths = func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+		gotPaths = append(gotPaths, paths)
+		return emptyTar(t), nil
+	}
+
+	repo := gitserver.Repo{Name: "foo"}
+	commit := api.CommitID("deadbeefdeadbeefdeadbeefdeadbeefdeadbeef")
+	pathA, err := s.prepareZip(context.Background(), repo, commit, []string{"b.go", "a.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same paths in another order are the same archive.
+	if path, err := s.prepareZip(context.Background(), repo, commit, []string{"a.go", "b.go"}); err != nil {
+		t.Fatal(err)
+	} else if path != pathA {
+		t.Errorf("got archive %q for the same paths, want %q", path, pathA)
+	}
+	if path, err := s.prepareZip(context.Background(), repo, commit, []string{"a.go"}); err != nil {
+		t.Fatal(err)
+	} else if path == pathA {
+		t.Error("got the same archive for other paths")
+	}
+
+	if want := [][]string{{"a.go", "b.go"}, {"a.go"}}; !reflect.DeepEqual(gotPaths, want) {
+		t.Errorf("got fetched paths %q, want %q", gotPaths, want)
+	}
+}
+
 func tmpStore(t *testing.T) (*Store, func()) {
 	d, err := ioutil.TempDir("", "search_test")
 	if err != nil {
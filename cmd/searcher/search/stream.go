@@ -0,0 +1,74 @@
+package search
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/sourcegraph/sourcegraph/pkg/searcher/protocol"
+)
+
+// matchSender is called by concurrentFind with each file match as soon as it
+// is found. It may be called concurrently.
+type matchSender func(protocol.FileMatch)
+
+// streamWriter writes a streaming search response as newline-delimited JSON
+// protocol.StreamEvents. The response headers are only written once the first
+// event is sent, so an error found before any match can still be reported
+// with a normal HTTP error status.
+type streamWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	enc     *json.Encoder
+	started bool
+}
+
+func newStreamWriter(w http.ResponseWriter) *streamWriter {
+	flusher, _ := w.(http.Flusher)
+	return &streamWriter{
+		w:       w,
+		flusher: flusher,
+		enc:     json.NewEncoder(w),
+	}
+}
+
+// Started returns true if at least one event has been written.
+func (sw *streamWriter) Started() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.started
+}
+
+// SendMatch writes fm to the stream and flushes it to the client.
+func (sw *streamWriter) SendMatch(fm protocol.FileMatch) {
+	sw.send(&protocol.StreamEvent{Match: &fm})
+}
+
+// SendDone writes the final event of the stream.
+func (sw *streamWriter) SendDone(limitHit, deadlineHit bool, err error) {
+	ev := &protocol.StreamEvent{
+		Done:        true,
+		LimitHit:    limitHit,
+		DeadlineHit: deadlineHit,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	sw.send(ev)
+}
+
+func (sw *streamWriter) send(ev *protocol.StreamEvent) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if !sw.started {
+		sw.w.Header().Set("Content-Type", "application/x-ndjson")
+		sw.started = true
+	}
+	// Like the non-streaming response, the only reasonable error here is
+	// the client going away. We can't report it, so we just ignore it.
+	_ = sw.enc.Encode(ev)
+	if sw.flusher != nil {
+		sw.flusher.Flush()
+	}
+}
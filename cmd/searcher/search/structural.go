@@ -0,0 +1,421 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/sourcegraph/pkg/searcher/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/trigram"
+)
+
+// structuralPattern is a compiled structural search pattern. A structural
+// pattern is literal text containing holes:
+//
+//   :[name]   matches any text in which (), [] and {} are balanced. Strings
+//             and comments are skipped as a whole, so delimiters inside them
+//             are ignored. The match is lazy and may span multiple lines.
+//   :[[name]] matches all identifier characters ([a-zA-Z0-9_]) at its
+//             position. It must match at least one.
+//   :[_]      is like :[name], but is never bound (see below).
+//
+// A hole name used more than once must match the same text each time. A run
+// of whitespace in the pattern matches any run of whitespace (including none)
+// in the input.
+//
+// For example the pattern "foo(:[args])" matches "foo(bar(1, 2))" but not
+// just "foo(bar(1, 2)".
+type structuralPattern struct {
+	tokens []structuralToken
+
+	// literal is the longest literal in the pattern. It is guaranteed to
+	// appear in every match.
+	literal []byte
+}
+
+type structuralTokenKind int
+
+const (
+	structuralLiteral structuralTokenKind = iota
+	structuralWhitespace
+	structuralHole
+)
+
+type structuralToken struct {
+	kind structuralTokenKind
+
+	// text is set for structuralLiteral.
+	text []byte
+
+	// name and word are set for structuralHole. name is empty for
+	// anonymous holes. word is true for :[[name]] holes.
+	name string
+	word bool
+}
+
+// maxStructuralSteps bounds the amount of backtracking done when matching a
+// structural pattern against a single file.
+const maxStructuralSteps = 1 << 20
+
+// compileStructural parses pattern. If ignoreCase is true, the literal parts
+// of the pattern are lowercased, and the input is expected to be lowercased
+// as well.
+func compileStructural(pattern string, ignoreCase bool) (*structuralPattern, error) {
+	var (
+		tokens []structuralToken
+		lit    []byte
+	)
+	flush := func() {
+		if len(lit) > 0 {
+			tokens = append(tokens, structuralToken{kind: structuralLiteral, text: lit})
+			lit = nil
+		}
+	}
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		switch {
+		case isSpace(c):
+			flush()
+			for i < len(pattern) && isSpace(pattern[i]) {
+				i++
+			}
+			tokens = append(tokens, structuralToken{kind: structuralWhitespace})
+
+		case c == ':' && i+1 < len(pattern) && pattern[i+1] == '[':
+			word := i+2 < len(pattern) && pattern[i+2] == '['
+			open, close := ":[", "]"
+			if word {
+				open, close = ":[[", "]]"
+			}
+			end := bytes.Index([]byte(pattern[i+len(open):]), []byte(close))
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated hole %q in structural pattern", pattern[i:])
+			}
+			name := pattern[i+len(open) : i+len(open)+end]
+			if name == "" || !isWord(name) {
+				return nil, fmt.Errorf("invalid hole name %q in structural pattern (must be non-empty and only contain [a-zA-Z0-9_])", name)
+			}
+			if name == "_" {
+				name = ""
+			}
+			flush()
+			tokens = append(tokens, structuralToken{kind: structuralHole, name: name, word: word})
+			i += len(open) + end + len(close)
+
+		default:
+			if ignoreCase {
+				c = trigram.ToLowerASCII(c)
+			}
+			lit = append(lit, c)
+			i++
+		}
+	}
+	flush()
+
+	// Leading holes would make every match extend back to the previous
+	// match (or the start of the file), so we ignore them. Leading and
+	// trailing whitespace matches nothing useful either.
+	for len(tokens) > 0 && (tokens[0].kind == structuralWhitespace || (tokens[0].kind == structuralHole && !tokens[0].word)) {
+		tokens = tokens[1:]
+	}
+	for len(tokens) > 0 && tokens[len(tokens)-1].kind == structuralWhitespace {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("structural pattern must contain text other than holes")
+	}
+
+	sp := &structuralPattern{tokens: tokens}
+	for _, t := range tokens {
+		if t.kind == structuralLiteral && len(t.text) > len(sp.literal) {
+			sp.literal = t.text
+		}
+	}
+	return sp, nil
+}
+
+// FindAll returns the [start, end) byte offsets of the leftmost
+// non-overlapping matches of sp in buf. If n >= 0, at most n matches are
+// returned.
+func (sp *structuralPattern) FindAll(buf []byte, n int) [][2]int {
+	m := structuralMatcher{tokens: sp.tokens, buf: buf, env: map[string][]byte{}}
+	var matches [][2]int
+	first := sp.tokens[0]
+	for pos := 0; pos < len(buf) && (n < 0 || len(matches) < n); {
+		// Skip ahead to the next position where the first token can match.
+		if first.kind == structuralLiteral {
+			i := bytes.Index(buf[pos:], first.text)
+			if i < 0 {
+				break
+			}
+			pos += i
+		} else if first.word && (!isWordChar(buf[pos]) || (pos > 0 && isWordChar(buf[pos-1]))) {
+			pos++
+			continue
+		}
+
+		for name := range m.env {
+			delete(m.env, name)
+		}
+		end, ok := m.match(0, pos)
+		if m.steps > maxStructuralSteps {
+			break
+		}
+		if !ok {
+			pos++
+			continue
+		}
+		matches = append(matches, [2]int{pos, end})
+		if end > pos {
+			pos = end
+		} else {
+			pos++
+		}
+	}
+	return matches
+}
+
+// structuralMatcher holds the state for matching a structural pattern at a
+// position in buf.
+type structuralMatcher struct {
+	tokens []structuralToken
+	buf    []byte
+
+	// env maps hole names to the text they are currently bound to.
+	env map[string][]byte
+
+	// steps counts the match attempts so far. Once it exceeds
+	// maxStructuralSteps all match attempts fail.
+	steps int
+}
+
+// match reports whether tokens[ti:] match buf starting at pos, and returns
+// the end of the match.
+func (m *structuralMatcher) match(ti, pos int) (int, bool) {
+	m.steps++
+	if m.steps > maxStructuralSteps {
+		return 0, false
+	}
+	if ti == len(m.tokens) {
+		return pos, true
+	}
+
+	t := m.tokens[ti]
+	switch t.kind {
+	case structuralLiteral:
+		if !bytes.HasPrefix(m.buf[pos:], t.text) {
+			return 0, false
+		}
+		return m.match(ti+1, pos+len(t.text))
+
+	case structuralWhitespace:
+		for pos < len(m.buf) && isSpace(m.buf[pos]) {
+			pos++
+		}
+		return m.match(ti+1, pos)
+
+	case structuralHole:
+		if bound, ok := m.env[t.name]; ok && t.name != "" {
+			if !bytes.HasPrefix(m.buf[pos:], bound) {
+				return 0, false
+			}
+			return m.match(ti+1, pos+len(bound))
+		}
+
+		if t.word {
+			// Word holes match the whole identifier at pos, so there is
+			// nothing to backtrack.
+			end := pos
+			for end < len(m.buf) && isWordChar(m.buf[end]) {
+				end++
+			}
+			if end == pos {
+				return 0, false
+			}
+			if t.name != "" {
+				m.env[t.name] = m.buf[pos:end]
+			}
+			matchEnd, ok := m.match(ti+1, end)
+			if !ok {
+				delete(m.env, t.name)
+			}
+			return matchEnd, ok
+		}
+
+		for end := pos; ; {
+			if t.name != "" {
+				m.env[t.name] = m.buf[pos:end]
+			}
+			if matchEnd, ok := m.match(ti+1, end); ok {
+				return matchEnd, true
+			}
+			if m.steps > maxStructuralSteps {
+				break
+			}
+
+			// Extend the hole by one balanced unit.
+			var ok bool
+			end, ok = m.skipUnit(end)
+			if !ok {
+				break
+			}
+		}
+		delete(m.env, t.name)
+		return 0, false
+	}
+	panic("unreachable")
+}
+
+// skipUnit returns the position after the balanced unit (a character, a
+// delimited group, a string or a comment) starting at pos. It returns false
+// if there is no such unit, e.g. because buf[pos] is a closing delimiter.
+func (m *structuralMatcher) skipUnit(pos int) (int, bool) {
+	if pos >= len(m.buf) {
+		return 0, false
+	}
+	switch c := m.buf[pos]; c {
+	case '(', '[', '{':
+		return m.skipGroup(pos)
+
+	case ')', ']', '}':
+		return 0, false
+
+	case '"', '\'', '`':
+		// Strings end at the matching unescaped quote. Only raw strings
+		// (`) may span lines. An unterminated string is treated as an
+		// ordinary character, since quotes also appear in prose and
+		// comments.
+		for i := pos + 1; i < len(m.buf); i++ {
+			switch m.buf[i] {
+			case c:
+				return i + 1, true
+			case '\\':
+				if c != '`' {
+					i++
+				}
+			case '\n':
+				if c != '`' {
+					return pos + 1, true
+				}
+			}
+		}
+		return pos + 1, true
+
+	case '/':
+		if bytes.HasPrefix(m.buf[pos:], []byte("//")) {
+			if i := bytes.IndexByte(m.buf[pos:], '\n'); i >= 0 {
+				return pos + i, true
+			}
+			return len(m.buf), true
+		}
+		if bytes.HasPrefix(m.buf[pos:], []byte("/*")) {
+			if i := bytes.Index(m.buf[pos+2:], []byte("*/")); i >= 0 {
+				return pos + 2 + i + 2, true
+			}
+		}
+	}
+	return pos + 1, true
+}
+
+// skipGroup returns the position after the delimiter that closes the one at
+// pos. It returns false if the group is not closed, or closed by a
+// mismatched delimiter.
+func (m *structuralMatcher) skipGroup(pos int) (int, bool) {
+	var close byte
+	switch m.buf[pos] {
+	case '(':
+		close = ')'
+	case '[':
+		close = ']'
+	case '{':
+		close = '}'
+	}
+	for pos++; pos < len(m.buf); {
+		switch c := m.buf[pos]; {
+		case c == close:
+			return pos + 1, true
+		case c == ')' || c == ']' || c == '}':
+			return 0, false
+		}
+		next, ok := m.skipUnit(pos)
+		if !ok {
+			return 0, false
+		}
+		pos = next
+	}
+	return 0, false
+}
+
+// findStructural returns a LineMatch for each line covered by a match of
+// rg.structural in fileMatchBuf. Matches spanning multiple lines are reported
+// on every line they cover. fileBuf is the original data (for Preview).
+func (rg *readerGrep) findStructural(fileBuf, fileMatchBuf []byte) (matches []protocol.LineMatch, limitHit bool) {
+	ranges := rg.structural.FindAll(fileMatchBuf, -1)
+
+	lineStart := 0
+	for i := 0; len(ranges) > 0 && len(matches) < maxLineMatches; i++ {
+		advance, lineBuf, err := bufio.ScanLines(fileBuf[lineStart:], true)
+		if err != nil || advance == 0 {
+			break
+		}
+		lineEnd := lineStart + len(lineBuf)
+
+		var offsetAndLengths [][2]int
+		for _, r := range ranges {
+			if r[0] >= lineEnd {
+				break
+			}
+			start, end := r[0], r[1]
+			if start < lineStart {
+				start = lineStart
+			}
+			if end > lineEnd {
+				end = lineEnd
+			}
+			if start >= end || len(offsetAndLengths) == maxOffsets {
+				continue
+			}
+			offset := utf8.RuneCount(lineBuf[:start-lineStart])
+			length := utf8.RuneCount(lineBuf[start-lineStart : end-lineStart])
+			offsetAndLengths = append(offsetAndLengths, [2]int{offset, length})
+		}
+
+		// Drop the ranges which end on this line. Ranges do not overlap, so
+		// they are always at the front.
+		for len(ranges) > 0 && ranges[0][1] <= lineStart+advance {
+			ranges = ranges[1:]
+		}
+		lineStart += advance
+
+		// Skip lines that are too long.
+		if len(offsetAndLengths) == 0 || len(lineBuf) > maxLineSize {
+			continue
+		}
+		matches = append(matches, protocol.LineMatch{
+			Preview:          string(lineBuf),
+			LineNumber:       i,
+			OffsetAndLengths: offsetAndLengths,
+			LimitHit:         len(offsetAndLengths) == maxOffsets,
+		})
+	}
+	return matches, len(matches) == maxLineMatches
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func isWord(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isWordChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/searcher/protocol"
+)
+
+func TestStructuralPattern_FindAll(t *testing.T) {
+	cases := []struct {
+		pattern string
+		input   string
+		want    []string
+	}{
+		{"foo(:[x])", "foo(bar(1, 2))", []string{"foo(bar(1, 2))"}},
+		{"foo(:[x])", "foo(bar(1, 2)", nil},
+		{"foo(:[x])", "a := foo(1); b := foo(2)", []string{"foo(1)", "foo(2)"}},
+
+		// Delimiters inside strings and comments are ignored.
+		{"foo(:[x])", `foo(")", 1)`, []string{`foo(")", 1)`}},
+		{"foo(:[x])", "foo(a /* ) */, b)", []string{"foo(a /* ) */, b)"}},
+		{"foo(:[x])", "foo(a, // )\nb)", []string{"foo(a, // )\nb)"}},
+
+		// Holes do not match mismatched delimiters.
+		{"{:[x]}", "{ a ] }", nil},
+
+		// Whitespace matches any whitespace.
+		{"foo(:[a], :[b])", "foo(1,2)", []string{"foo(1,2)"}},
+		{"if :[cond] {", "if x  &&\n\ty {", []string{"if x  &&\n\ty {"}},
+
+		// Repeated holes must match the same text.
+		{":[[a]] == :[[a]]", "x == y; z == z", []string{"z == z"}},
+
+		// Word holes match whole identifiers.
+		{"errors.:[[fn]](", "errors.New(", []string{"errors.New("}},
+		{":[[fn]](nil)", "a.foo(nil)", []string{"foo(nil)"}},
+
+		// Leading holes are ignored.
+		{":[x] = nil", "err = nil", []string{"= nil"}},
+	}
+	for _, tt := range cases {
+		sp, err := compileStructural(tt.pattern, false)
+		if err != nil {
+			t.Errorf("%q: %s", tt.pattern, err)
+			continue
+		}
+		var got []string
+		for _, r := range sp.FindAll([]byte(tt.input), -1) {
+			got = append(got, tt.input[r[0]:r[1]])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q in %q: got %q, want %q", tt.pattern, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCompileStructural_errors(t *testing.T) {
+	for _, pattern := range []string{"foo(:[x)", ":[a-b]", ":[]", ":[x] :[y]"} {
+		if _, err := compileStructural(pattern, false); err == nil {
+			t.Errorf("%q: expected error", pattern)
+		}
+	}
+}
+
+func TestReaderGrep_structural(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"a.go": "package a\n\nfunc f() {\n\tFoo(bar(1),\n\t\tbaz)\n\tfoo(x)\n}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := mockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg, err := compile(&protocol.PatternInfo{Pattern: "foo(:[args])", IsStructuralPat: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, limitHit, err := rg.Find(zf, &zf.Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limitHit {
+		t.Error("unexpected limitHit")
+	}
+	want := []protocol.LineMatch{
+		{Preview: "\tFoo(bar(1),", LineNumber: 3, OffsetAndLengths: [][2]int{{1, 11}}},
+		{Preview: "\t\tbaz)", LineNumber: 4, OffsetAndLengths: [][2]int{{0, 6}}},
+		{Preview: "\tfoo(x)", LineNumber: 5, OffsetAndLengths: [][2]int{{1, 6}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
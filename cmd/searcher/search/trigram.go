@@ -0,0 +1,253 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/trigram"
+	"golang.org/x/sys/unix"
+)
+
+// A trigramIndex maps each trigram (3 consecutive bytes, ASCII lowercased) to
+// the files of a zipFile whose content contains it. It is used to narrow down
+// which files need to be searched before running readerGrep.Find.
+//
+// The index is stored on disk next to the zip it belongs to, and accessed
+// via mmap. The file format (all integers little endian) is:
+//
+//   magic       [4]byte "sgt1"
+//   numFiles    uint32  len(zipFile.Files) at the time the index was built
+//   numTrigrams uint32
+//   table       [numTrigrams]{trigram uint32; offset uint32} sorted by trigram
+//   postings    for each trigram, the delta-encoded uvarint indexes into
+//               zipFile.Files of the files containing it. offset is
+//               relative to the start of postings.
+type trigramIndex struct {
+	data        []byte // the mmap'd file
+	numFiles    int
+	numTrigrams int
+	table       []byte
+	postings    []byte
+}
+
+const (
+	trigramIndexMagic      = "sgt1"
+	trigramIndexHeaderSize = 12
+	trigramTableEntrySize  = 8
+)
+
+// trigramIndexPath returns the path of the trigram index for the zip at
+// zipPath.
+func trigramIndexPath(zipPath string) string {
+	return strings.TrimSuffix(zipPath, ".zip") + ".trigrams"
+}
+
+// writeTrigramIndex builds the trigram index for zf and writes it to path.
+func writeTrigramIndex(path string, zf *zipFile) error {
+	type posting struct {
+		last uint32
+		buf  []byte
+	}
+	postings := map[uint32]*posting{}
+	var scratch [binary.MaxVarintLen32]byte
+	for i := range zf.Files {
+		data := zf.DataFor(&zf.Files[i])
+		fileIdx := uint32(i)
+		for j := 0; j+3 <= len(data); j++ {
+			tri := trigram.Of(data[j:])
+			p, ok := postings[tri]
+			if !ok {
+				p = &posting{}
+				postings[tri] = p
+			} else if p.last == fileIdx && len(p.buf) > 0 {
+				// Already recorded for this file.
+				continue
+			}
+			n := binary.PutUvarint(scratch[:], uint64(fileIdx-p.last))
+			p.buf = append(p.buf, scratch[:n]...)
+			p.last = fileIdx
+		}
+	}
+
+	trigrams := make([]uint32, 0, len(postings))
+	for tri := range postings {
+		trigrams = append(trigrams, tri)
+	}
+	sort.Slice(trigrams, func(i, j int) bool { return trigrams[i] < trigrams[j] })
+
+	tmpPath := path + ".part"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create trigram index")
+	}
+	defer os.Remove(tmpPath)
+	w := bufio.NewWriter(f)
+
+	var header [trigramIndexHeaderSize]byte
+	copy(header[:4], trigramIndexMagic)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(zf.Files)))
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(trigrams)))
+	w.Write(header[:])
+
+	var (
+		entry  [trigramTableEntrySize]byte
+		offset uint64
+	)
+	for _, tri := range trigrams {
+		if offset > 1<<32-1 {
+			f.Close()
+			return errors.New("trigram index too large")
+		}
+		binary.LittleEndian.PutUint32(entry[:4], tri)
+		binary.LittleEndian.PutUint32(entry[4:], uint32(offset))
+		w.Write(entry[:])
+		offset += uint64(len(postings[tri].buf))
+	}
+	for _, tri := range trigrams {
+		w.Write(postings[tri].buf)
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to write trigram index")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "failed to write trigram index")
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// openTrigramIndex mmaps the trigram index at path. numFiles is the number of
+// files in the zipFile it belongs to, and is used to detect stale indexes.
+// The returned index must be released with Close.
+func openTrigramIndex(path string, numFiles int) (*trigramIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < trigramIndexHeaderSize {
+		return nil, errors.Errorf("trigram index %s is truncated", path)
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &trigramIndex{
+		data:        data,
+		numFiles:    int(binary.LittleEndian.Uint32(data[4:])),
+		numTrigrams: int(binary.LittleEndian.Uint32(data[8:])),
+	}
+	tableEnd := trigramIndexHeaderSize + idx.numTrigrams*trigramTableEntrySize
+	switch {
+	case string(data[:4]) != trigramIndexMagic:
+		err = errors.Errorf("trigram index %s has unknown format", path)
+	case idx.numFiles != numFiles:
+		err = errors.Errorf("trigram index %s is for %d files, want %d", path, idx.numFiles, numFiles)
+	case tableEnd > len(data):
+		err = errors.Errorf("trigram index %s is truncated", path)
+	}
+	if err != nil {
+		unix.Munmap(data)
+		return nil, err
+	}
+	idx.table = data[trigramIndexHeaderSize:tableEnd]
+	idx.postings = data[tableEnd:]
+	return idx, nil
+}
+
+// Close releases the resources associated with idx. It must not be used
+// afterwards.
+func (idx *trigramIndex) Close() error {
+	return unix.Munmap(idx.data)
+}
+
+// lookup returns the postings for tri, or nil if no file contains it.
+func (idx *trigramIndex) lookup(tri uint32) []byte {
+	entry := func(i int) []byte { return idx.table[i*trigramTableEntrySize:] }
+	i := sort.Search(idx.numTrigrams, func(i int) bool {
+		return binary.LittleEndian.Uint32(entry(i)) >= tri
+	})
+	if i == idx.numTrigrams || binary.LittleEndian.Uint32(entry(i)) != tri {
+		return nil
+	}
+	start := binary.LittleEndian.Uint32(entry(i)[4:])
+	end := uint32(len(idx.postings))
+	if i+1 < idx.numTrigrams {
+		end = binary.LittleEndian.Uint32(entry(i + 1)[4:])
+	}
+	if start > end || end > uint32(len(idx.postings)) {
+		// Corrupt index. Treat the trigram as missing rather than
+		// panicking; the caller falls back to searching all files.
+		return nil
+	}
+	return idx.postings[start:end]
+}
+
+// Candidates returns the indexes into zipFile.Files of the files which may
+// contain lit (compared ASCII case insensitively). ok is false if the index
+// can't narrow down the files, in which case all files must be searched.
+func (idx *trigramIndex) Candidates(lit []byte) (candidates []int, ok bool) {
+	if len(lit) < 3 {
+		return nil, false
+	}
+
+	seen := map[uint32]bool{}
+	first := true
+	for i := 0; i+3 <= len(lit); i++ {
+		tri := trigram.Of(lit[i:])
+		if seen[tri] {
+			continue
+		}
+		seen[tri] = true
+
+		p := idx.lookup(tri)
+		if p == nil {
+			return nil, true
+		}
+		files, err := decodePostings(p, idx.numFiles)
+		if err != nil {
+			return nil, false
+		}
+		if first {
+			candidates = files
+			first = false
+		} else {
+			candidates = trigram.IntersectSorted(candidates, files)
+		}
+		if len(candidates) == 0 {
+			return nil, true
+		}
+	}
+	return candidates, true
+}
+
+func decodePostings(p []byte, numFiles int) ([]int, error) {
+	var (
+		files []int
+		last  uint64
+	)
+	for r := bytes.NewReader(p); r.Len() > 0; {
+		delta, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		last += delta
+		if last >= uint64(numFiles) {
+			return nil, errors.New("trigram index posting out of range")
+		}
+		files = append(files, int(last))
+	}
+	return files, nil
+}
@@ -0,0 +1,130 @@
+package search
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/searcher/protocol"
+)
+
+func TestTrigramIndex(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"a.go":     "package a\n\nfunc Hello() {}\n",
+		"b.go":     "package b\n\nfunc hello() {}\n",
+		"c.go":     "package c\n\nfunc World() {}\n",
+		"empty.go": "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := mockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "trigram_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := trigramIndexPath(filepath.Join(dir, "repo.zip"))
+	if err := writeTrigramIndex(path, zf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openTrigramIndex(path, len(zf.Files)+1); err == nil {
+		t.Error("expected error opening index with the wrong number of files")
+	}
+	idx, err := openTrigramIndex(path, len(zf.Files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	names := func(candidates []int) []string {
+		var names []string
+		for _, c := range candidates {
+			names = append(names, zf.Files[c].Name)
+		}
+		return names
+	}
+
+	cases := []struct {
+		lit    string
+		want   []string
+		wantOK bool
+	}{
+		{lit: "hello", want: []string{"a.go", "b.go"}, wantOK: true},
+		{lit: "func World", want: []string{"c.go"}, wantOK: true},
+		{lit: "package", want: []string{"a.go", "b.go", "c.go"}, wantOK: true},
+		{lit: "missing", want: nil, wantOK: true},
+		{lit: "he", want: nil, wantOK: false},
+	}
+	for _, tt := range cases {
+		candidates, ok := idx.Candidates([]byte(tt.lit))
+		if ok != tt.wantOK {
+			t.Errorf("%q: got ok=%v, want %v", tt.lit, ok, tt.wantOK)
+			continue
+		}
+		// Candidates are in the same order as zf.Files, which is not
+		// necessarily the order files were added to the zip.
+		got := map[string]bool{}
+		for _, name := range names(candidates) {
+			got[name] = true
+		}
+		want := map[string]bool{}
+		for _, name := range tt.want {
+			want[name] = true
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got candidates %v, want %v", tt.lit, names(candidates), tt.want)
+		}
+	}
+}
+
+func TestConcurrentFind_trigramIndex(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"a.go": "package a\n\nfunc Hello() {}\n",
+		"b.go": "package b\n\nfunc World() {}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := mockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "trigram_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := trigramIndexPath(filepath.Join(dir, "repo.zip"))
+	if err := writeTrigramIndex(path, zf); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := openTrigramIndex(path, len(zf.Files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	zf.setIndex(idx)
+
+	rg, err := compile(&protocol.PatternInfo{Pattern: "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 0, true, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fileMatches) != 1 || fileMatches[0].Path != "b.go" {
+		t.Errorf("got %+v, want a single match in b.go", fileMatches)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/pkg/errors"
@@ -22,17 +23,16 @@ type zipCache struct {
 	// Split the cache into many parts, to minimize lock contention.
 	// This matters because, for simplicity,
 	// we sometimes hold the lock for long-running operations,
-	// such as reading a zip file from disk
-	// or waiting for all users of a zip file to finish their work.
-	// (The latter case should basically never block, since it only
-	// occurs when a file is being deleted, and files are deleted
-	// when no one has used them for a long time. Nevertheless, take care.)
+	// such as reading a zip file from disk.
+	// (Deleting a file waits for all users of it to finish their work
+	// without holding the lock.)
 	shards [64]zipCacheShard
 }
 
 type zipCacheShard struct {
-	mu sync.Mutex
-	m  map[string]*zipFile // path -> zipFile
+	mu       sync.Mutex
+	m        map[string]*zipFile      // path -> zipFile
+	deleting map[string]chan struct{} // path -> closed once the deleted zipFile is released
 }
 
 func (c *zipCache) shardFor(path string) *zipCacheShard {
@@ -47,6 +47,16 @@ func (c *zipCache) get(path string) (*zipFile, error) {
 	shard := c.shardFor(path)
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
+	// Don't reopen a file which is being deleted before it is released.
+	for {
+		done, ok := shard.deleting[path]
+		if !ok {
+			break
+		}
+		shard.mu.Unlock()
+		<-done
+		shard.mu.Lock()
+	}
 	if shard.m == nil {
 		shard.m = make(map[string]*zipFile)
 	}
@@ -70,12 +80,29 @@ func (c *zipCache) get(path string) (*zipFile, error) {
 func (c *zipCache) delete(path string) {
 	shard := c.shardFor(path)
 	shard.mu.Lock()
-	defer shard.mu.Unlock()
 	zf, ok := shard.m[path]
 	if !ok {
 		// already deleted?!
+		shard.mu.Unlock()
 		return
 	}
+	// Remove zf before waiting for its clients, so that a slow search does
+	// not block getting the other files of the shard. Until zf is released,
+	// get waits instead of reopening it.
+	delete(shard.m, path)
+	if shard.deleting == nil {
+		shard.deleting = make(map[string]chan struct{})
+	}
+	done := make(chan struct{})
+	shard.deleting[path] = done
+	shard.mu.Unlock()
+	defer func() {
+		shard.mu.Lock()
+		delete(shard.deleting, path)
+		shard.mu.Unlock()
+		close(done)
+	}()
+
 	// Wait for all clients using this zipFile to complete their work.
 	zf.wg.Wait()
 	// Mock zipFiles have nil f. Only try to munmap and close f if it is non-nil.
@@ -90,7 +117,11 @@ func (c *zipCache) delete(path string) {
 			log.Printf("failed to close %q: %v", zf.f.Name(), err)
 		}
 	}
-	delete(shard.m, path)
+	if idx := zf.Index(); idx != nil {
+		if err := idx.Close(); err != nil {
+			log.Printf("failed to munmap trigram index for %q: %v", path, err)
+		}
+	}
 }
 
 // zipFile provides efficient access to a single zip file.
@@ -102,6 +133,7 @@ type zipFile struct {
 	Data   []byte
 	f      *os.File
 	wg     sync.WaitGroup // ensures underlying file is not munmap'd or closed while in use
+	index  atomic.Value   // *trigramIndex, set once the index is available
 }
 
 func readZipFile(path string) (*zipFile, error) {
@@ -135,6 +167,15 @@ func readZipFile(path string) (*zipFile, error) {
 		log.Printf("failed to madvise for %q: %v", path, err)
 	}
 
+	// Use the trigram index if one was built before we were (re)started.
+	indexPath := trigramIndexPath(path)
+	if idx, err := openTrigramIndex(indexPath, len(zf.Files)); err == nil {
+		zf.setIndex(idx)
+	} else if !os.IsNotExist(err) {
+		log.Printf("ignoring trigram index for %q: %v", path, err)
+		os.Remove(indexPath)
+	}
+
 	return zf, nil
 }
 
@@ -174,6 +215,16 @@ func (f *zipFile) Close() {
 	f.wg.Done()
 }
 
+// Index returns the trigram index for f, or nil if it has not been built.
+func (f *zipFile) Index() *trigramIndex {
+	idx, _ := f.index.Load().(*trigramIndex)
+	return idx
+}
+
+func (f *zipFile) setIndex(idx *trigramIndex) {
+	f.index.Store(idx)
+}
+
 func mockZipFile(data []byte) (*zipFile, error) {
 	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
 	if err != nil {
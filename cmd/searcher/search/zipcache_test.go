@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
@@ -21,7 +22,7 @@ func TestZipCacheDelete(t *testing.T) {
 	}
 
 	// Grab a zip.
-	path, err := s.prepareZip(context.Background(), gitserver.Repo{Name: "somerepo"}, "0123456789012345678901234567890123456789")
+	path, err := s.prepareZip(context.Background(), gitserver.Repo{Name: "somerepo"}, "0123456789012345678901234567890123456789", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -61,3 +62,52 @@ func TestZipCacheDelete(t *testing.T) {
 		t.Errorf("expected non-existence error, got %v", err)
 	}
 }
+
+// TestZipCacheDelete_inUse ensures that deleting a zipFile which is in use
+// waits for it to be closed, without blocking the rest of its shard, and that
+// getting it meanwhile waits for the deletion.
+func TestZipCacheDelete_inUse(t *testing.T) {
+	var c zipCache
+	const path = "a.zip"
+	zf := &zipFile{} // mock zipFile, with nil f
+	zf.wg.Add(1)
+	c.shardFor(path).m = map[string]*zipFile{path: zf}
+
+	deleted := make(chan struct{})
+	go func() {
+		c.delete(path)
+		close(deleted)
+	}()
+
+	// The zipFile is removed from the cache while it is still in use.
+	deadline := time.Now().Add(5 * time.Second)
+	for c.count() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("zipFile was not removed from the cache")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-deleted:
+		t.Fatal("delete returned before the zipFile was closed")
+	default:
+	}
+
+	got := make(chan error)
+	go func() {
+		_, err := c.get(path)
+		got <- err
+	}()
+	select {
+	case <-got:
+		t.Fatal("get returned while the zipFile was being deleted")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	zf.Close()
+	<-deleted
+	// get reads the (nonexistent) file again instead of reusing zf.
+	if err := <-got; !os.IsNotExist(err) {
+		t.Errorf("expected non-existence error, got %v", err)
+	}
+}
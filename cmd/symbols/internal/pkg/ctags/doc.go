@@ -1,2 +1,3 @@
-// Package ctags provides a Go wrapper for universal-ctags.
+// Package ctags provides a Go wrapper for universal-ctags, and a registry of
+// native Parsers which are used instead of it for some languages.
 package ctags
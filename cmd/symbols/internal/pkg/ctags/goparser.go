@@ -0,0 +1,178 @@
+package ctags
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+func init() {
+	Register("Go", []string{".go"}, func() (Parser, error) { return goParser{}, nil })
+}
+
+// goParser extracts the symbols of Go files with go/parser. Unlike ctags, it
+// reports the full signature of functions (including results) and the
+// receiver type of methods as their parent.
+//
+// The kinds of the entries are the same as universal-ctags uses for Go, so
+// results do not depend on which parser was used.
+type goParser struct{}
+
+func (goParser) Close() {}
+
+func (goParser) Parse(name string, content []byte) ([]Entry, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, content, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := bytes.Split(content, []byte("\n"))
+	var entries []Entry
+	add := func(ident *ast.Ident, kind, parent, parentKind, signature string) {
+		if ident == nil || ident.Name == "_" {
+			return
+		}
+		line := fset.Position(ident.Pos()).Line
+		entries = append(entries, Entry{
+			Name:       ident.Name,
+			Path:       name,
+			Line:       line,
+			Kind:       kind,
+			Language:   "Go",
+			Parent:     parent,
+			ParentKind: parentKind,
+			Pattern:    goPattern(lines, line),
+			Signature:  signature,
+		})
+	}
+
+	// Methods may be declared before their receiver type, so collect the kinds
+	// of the file's types first.
+	typeKinds := map[string]string{}
+	for _, decl := range f.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				typeKinds[ts.Name.Name] = goTypeKind(ts.Type)
+			}
+		}
+	}
+
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			var parent, parentKind string
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				parent = goReceiverName(d.Recv.List[0].Type)
+				parentKind = typeKinds[parent]
+				if parentKind == "" {
+					parentKind = "type"
+				}
+			}
+			add(d.Name, "func", parent, parentKind, goSignature(fset, d.Type))
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					kind := goTypeKind(s.Type)
+					add(s.Name, kind, "", "", "")
+					switch t := s.Type.(type) {
+					case *ast.StructType:
+						for _, field := range t.Fields.List {
+							if len(field.Names) == 0 {
+								add(goEmbeddedIdent(field.Type), "anonMember", s.Name.Name, kind, "")
+							}
+							for _, n := range field.Names {
+								add(n, "member", s.Name.Name, kind, "")
+							}
+						}
+					case *ast.InterfaceType:
+						for _, method := range t.Methods.List {
+							ft, ok := method.Type.(*ast.FuncType)
+							if !ok {
+								continue // embedded interface
+							}
+							for _, n := range method.Names {
+								add(n, "methodSpec", s.Name.Name, kind, goSignature(fset, ft))
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					kind := "var"
+					if d.Tok == token.CONST {
+						kind = "const"
+					}
+					for _, n := range s.Names {
+						add(n, kind, "", "", "")
+					}
+				}
+			}
+		}
+	}
+	return entries, nil
+}
+
+// goTypeKind returns the ctags kind of a type declaration with type expression
+// typ.
+func goTypeKind(typ ast.Expr) string {
+	switch typ.(type) {
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	}
+	return "type"
+}
+
+// goReceiverName returns the name of the type of a method receiver, e.g. "T"
+// for "*T".
+func goReceiverName(typ ast.Expr) string {
+	if ident := goEmbeddedIdent(typ); ident != nil {
+		return ident.Name
+	}
+	return ""
+}
+
+// goEmbeddedIdent returns the identifier of the type name in typ, which is an
+// embedded field or receiver type such as "T", "*T" or "pkg.T".
+func goEmbeddedIdent(typ ast.Expr) *ast.Ident {
+	for {
+		switch t := typ.(type) {
+		case *ast.Ident:
+			return t
+		case *ast.StarExpr:
+			typ = t.X
+		case *ast.SelectorExpr:
+			return t.Sel
+		case *ast.ParenExpr:
+			typ = t.X
+		default:
+			return nil
+		}
+	}
+}
+
+// goSignature returns the parameters and results of ft as written in the
+// source, e.g. "(ctx context.Context) error".
+func goSignature(fset *token.FileSet, ft *ast.FuncType) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, &ast.FuncType{Params: ft.Params, Results: ft.Results}); err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(buf.String(), "func")
+}
+
+// goPattern returns the ctags search pattern of the given line (1-based).
+func goPattern(lines [][]byte, line int) string {
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	s := strings.TrimSuffix(string(lines[line-1]), "\r")
+	s = strings.NewReplacer(`\`, `\\`, `/`, `\/`).Replace(s)
+	return "/^" + s + "$/"
+}
@@ -0,0 +1,94 @@
+package ctags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGoParser(t *testing.T) {
+	src := `package p
+
+import "context"
+
+const A, _ = 1, 2
+
+var b = "/"
+
+func (s *S) Get(ctx context.Context, key string) ([]byte, error) { return nil, nil }
+
+type S struct {
+	*Base
+	Name, Path string
+}
+
+type I interface {
+	context.Context
+	Run(n int) error
+}
+
+type T int
+
+func New() *S { return nil }
+`
+	got, err := goParser{}.Parse("p/p.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := func(name string, line int, kind, parent, parentKind, signature, pattern string) Entry {
+		return Entry{Name: name, Path: "p/p.go", Line: line, Kind: kind, Language: "Go", Parent: parent, ParentKind: parentKind, Signature: signature, Pattern: "/^" + pattern + "$/"}
+	}
+	want := []Entry{
+		entry("A", 5, "const", "", "", "", "const A, _ = 1, 2"),
+		entry("b", 7, "var", "", "", "", `var b = "\/"`),
+		entry("Get", 9, "func", "S", "struct", "(ctx context.Context, key string) ([]byte, error)", "func (s *S) Get(ctx context.Context, key string) ([]byte, error) { return nil, nil }"),
+		entry("S", 11, "struct", "", "", "", "type S struct {"),
+		entry("Base", 12, "anonMember", "S", "struct", "", "\t*Base"),
+		entry("Name", 13, "member", "S", "struct", "", "\tName, Path string"),
+		entry("Path", 13, "member", "S", "struct", "", "\tName, Path string"),
+		entry("I", 16, "interface", "", "", "", "type I interface {"),
+		entry("Run", 18, "methodSpec", "I", "interface", "(n int) error", "\tRun(n int) error"),
+		entry("T", 21, "type", "", "", "", "type T int"),
+		entry("New", 23, "func", "", "", "() *S", "func New() *S { return nil }"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestLanguageParser(t *testing.T) {
+	fallback := &recordingParser{}
+	p := NewLanguageParser(fallback)
+	defer p.Close()
+
+	// Go files are parsed with the native Go parser.
+	entries, err := p.Parse("a.go", []byte("package a\nfunc F() {}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "F" {
+		t.Errorf("got %+v, want entry for F", entries)
+	}
+
+	// Other languages, and Go files the native parser fails on, use the
+	// fallback.
+	for _, name := range []string{"a.js", "b.go"} {
+		if _, err := p.Parse(name, []byte("func {")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"a.js", "b.go"}; !reflect.DeepEqual(fallback.parsed, want) {
+		t.Errorf("got fallback calls %q, want %q", fallback.parsed, want)
+	}
+}
+
+type recordingParser struct {
+	parsed []string
+}
+
+func (p *recordingParser) Parse(name string, content []byte) ([]Entry, error) {
+	p.parsed = append(p.parsed, name)
+	return nil, nil
+}
+
+func (*recordingParser) Close() {}
@@ -0,0 +1,80 @@
+package ctags
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// extractor is a Parser implementation registered for a language.
+type extractor struct {
+	language   string
+	extensions []string
+	newParser  func() (Parser, error)
+}
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = map[string]*extractor{} // keyed by lowercase file extension
+)
+
+// Register registers newParser as the constructor of the Parser for files of
+// language, which are recognized by their extensions (e.g. ".go"). Parsers
+// returned by NewLanguageParser use it instead of their fallback for those
+// files. Registering an extension twice replaces the earlier registration.
+func Register(language string, extensions []string, newParser func() (Parser, error)) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	e := &extractor{language: language, extensions: extensions, newParser: newParser}
+	for _, ext := range extensions {
+		extractors[strings.ToLower(ext)] = e
+	}
+}
+
+func lookupExtractor(name string) *extractor {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	return extractors[strings.ToLower(path.Ext(name))]
+}
+
+// NewLanguageParser returns a Parser which parses each file with the Parser
+// registered for its language, and with fallback (usually the universal-ctags
+// parser returned by NewParser) for all other files. If a registered Parser
+// fails to parse a file, fallback is used for that file too, since the file
+// may just be invalid.
+//
+// Like other Parsers, the returned Parser must not be used concurrently.
+// Closing it closes fallback and the Parsers it created.
+func NewLanguageParser(fallback Parser) Parser {
+	return &languageParser{fallback: fallback, parsers: map[*extractor]Parser{}}
+}
+
+type languageParser struct {
+	fallback Parser
+	parsers  map[*extractor]Parser // created lazily
+}
+
+func (p *languageParser) Parse(name string, content []byte) ([]Entry, error) {
+	if e := lookupExtractor(name); e != nil {
+		parser, ok := p.parsers[e]
+		if !ok {
+			var err error
+			parser, err = e.newParser()
+			if err != nil {
+				return nil, err
+			}
+			p.parsers[e] = parser
+		}
+		if entries, err := parser.Parse(name, content); err == nil {
+			return entries, nil
+		}
+	}
+	return p.fallback.Parse(name, content)
+}
+
+func (p *languageParser) Close() {
+	for _, parser := range p.parsers {
+		parser.Close()
+	}
+	p.fallback.Close()
+}
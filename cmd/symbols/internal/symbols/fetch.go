@@ -19,7 +19,9 @@ type parseRequest struct {
 	data []byte
 }
 
-func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoName, commitID api.CommitID) (<-chan parseRequest, <-chan error, error) {
+// fetchRepositoryArchive streams the files of repo at commitID to be parsed.
+// If paths is non-nil, only those paths are fetched.
+func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoName, commitID api.CommitID, paths []string) (<-chan parseRequest, <-chan error, error) {
 	fetchQueueSize.Inc()
 	s.fetchSem <- 1 // acquire concurrent fetches semaphore
 	fetchQueueSize.Dec()
@@ -59,8 +61,15 @@ func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoName,
 		span.Finish()
 	}
 
-	r, err := s.FetchTar(ctx, gitserver.Repo{Name: repo}, commitID)
+	var r io.ReadCloser
+	var err error
+	if paths != nil {
+		r, err = s.FetchTarPaths(ctx, gitserver.Repo{Name: repo}, commitID, paths)
+	} else {
+		r, err = s.FetchTar(ctx, gitserver.Repo{Name: repo}, commitID)
+	}
 	if err != nil {
+		done(err)
 		return nil, nil, err
 	}
 
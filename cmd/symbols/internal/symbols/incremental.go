@@ -0,0 +1,154 @@
+package symbols
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+// maxAncestorDistance is the number of ancestors of a commit which are
+// checked for an index to parse incrementally from.
+const maxAncestorDistance = 100
+
+// maxIncrementalChangedPaths is the number of changed paths above which we
+// do a full parse instead. Fetching an archive of many individual paths is not
+// cheaper than fetching the whole repository.
+const maxIncrementalChangedPaths = 1000
+
+// parseIncremental returns the symbols of repo at commitID, computed from the
+// index of the closest ancestor which is already in the cache. Symbols in
+// files which are unchanged since the ancestor are copied forward, and only
+// the added and modified files are parsed.
+//
+// ok is false if incremental parsing is not configured, no ancestor within
+// maxAncestorDistance is indexed, or too many files changed. The caller should
+// then do a full parse.
+func (s *Service) parseIncremental(ctx context.Context, repo api.RepoName, commitID api.CommitID) (symbols []protocol.Symbol, ok bool, err error) {
+	if s.FetchTarPaths == nil || s.ListAncestors == nil || s.DiffNameStatus == nil {
+		return nil, false, nil
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "parseIncremental")
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+	}()
+	span.SetTag("repo", string(repo))
+	span.SetTag("commit", string(commitID))
+
+	base, baseIdx, err := s.closestIndexedAncestor(ctx, repo, commitID)
+	if err != nil || baseIdx == nil {
+		return nil, false, err
+	}
+	defer baseIdx.Close()
+	span.SetTag("base", string(base))
+
+	out, err := s.DiffNameStatus(ctx, gitserver.Repo{Name: repo}, base, commitID)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "diff")
+	}
+	changed, err := parseNameStatus(out)
+	if err != nil {
+		return nil, false, err
+	}
+	span.LogFields(otlog.Int("changed", len(changed)))
+	if len(changed) > maxIncrementalChangedPaths {
+		return nil, false, nil
+	}
+
+	for id := 0; id < baseIdx.Len(); id++ {
+		if _, ok := changed[baseIdx.path(baseIdx.pathID(id))]; ok {
+			continue
+		}
+		symbols = append(symbols, baseIdx.symbol(id))
+	}
+
+	var paths []string
+	for path, deleted := range changed {
+		if !deleted {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) > 0 {
+		sort.Strings(paths)
+		parsed, err := s.parseUncached(ctx, repo, commitID, paths)
+		if err != nil {
+			return nil, false, err
+		}
+		symbols = append(symbols, parsed...)
+	}
+	// Order the symbols like a full parse does.
+	sortSymbols(symbols)
+
+	incrementalParses.Inc()
+	return symbols, true, nil
+}
+
+// closestIndexedAncestor returns the closest ancestor of commitID whose symbol
+// index is in the cache, and the index. The index is nil if there is none
+// within maxAncestorDistance.
+func (s *Service) closestIndexedAncestor(ctx context.Context, repo api.RepoName, commitID api.CommitID) (api.CommitID, *symbolIndex, error) {
+	ancestors, err := s.ListAncestors(ctx, gitserver.Repo{Name: repo}, commitID, maxAncestorDistance)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "list ancestors")
+	}
+	for _, ancestor := range ancestors {
+		f, err := s.cache.OpenIfExists(symbolsKey(repo, ancestor))
+		if err != nil {
+			continue
+		}
+		idx, err := openSymbolIndex(f.File)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		return ancestor, idx, nil
+	}
+	return "", nil, nil
+}
+
+// parseNameStatus parses the output of `git diff -z --name-status
+// --no-renames`. It returns the changed paths, mapped to whether the path was
+// deleted.
+func parseNameStatus(out []byte) (map[string]bool, error) {
+	changed := map[string]bool{}
+	fields := bytes.Split(bytes.TrimSuffix(out, []byte{0}), []byte{0})
+	if len(out) == 0 {
+		return changed, nil
+	}
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("invalid git diff --name-status output: %q", out)
+	}
+	for i := 0; i < len(fields); i += 2 {
+		status, path := fields[i], string(fields[i+1])
+		if len(status) == 0 {
+			return nil, fmt.Errorf("invalid git diff --name-status output: %q", out)
+		}
+		changed[path] = status[0] == 'D'
+	}
+	return changed, nil
+}
+
+var incrementalParses = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "symbols",
+	Subsystem: "parse",
+	Name:      "incremental",
+	Help:      "The total number of commits whose symbols were parsed incrementally from an ancestor.",
+})
+
+func init() {
+	prometheus.MustRegister(incrementalParses)
+}
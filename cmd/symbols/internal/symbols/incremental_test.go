@@ -0,0 +1,122 @@
+package symbols
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+)
+
+func TestIndexedSymbols_incremental(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { os.RemoveAll(tmpDir) }()
+
+	commits := map[api.CommitID]map[string]string{
+		"a": {"unchanged.go": "u1 u2", "changed.go": "c1", "deleted.go": "d1"},
+		"b": {"unchanged.go": "u1 u2", "changed.go": "c2 c3", "added.go": "a1"},
+	}
+	var fetchTar, fetchTarPaths [][]string
+	service := Service{
+		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
+			fetchTar = append(fetchTar, []string{string(commit)})
+			return createTar(commits[commit])
+		},
+		FetchTarPaths: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+			fetchTarPaths = append(fetchTarPaths, paths)
+			files := map[string]string{}
+			for _, p := range paths {
+				files[p] = commits[commit][p]
+			}
+			return createTar(files)
+		},
+		ListAncestors: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, n int) ([]api.CommitID, error) {
+			if commit == "b" {
+				return []api.CommitID{"a"}, nil
+			}
+			return nil, nil
+		},
+		DiffNameStatus: func(ctx context.Context, repo gitserver.Repo, base, head api.CommitID) ([]byte, error) {
+			if base != "a" || head != "b" {
+				return nil, fmt.Errorf("unexpected diff %s..%s", base, head)
+			}
+			return []byte("M\x00changed.go\x00D\x00deleted.go\x00A\x00added.go\x00"), nil
+		},
+		NewParser: func() (ctags.Parser, error) {
+			return wordParser{}, nil
+		},
+		Path: tmpDir,
+	}
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(commit api.CommitID) []string {
+		idx, err := service.indexedSymbols(context.Background(), "r", commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		var names []string
+		for id := 0; id < idx.Len(); id++ {
+			s := idx.symbol(id)
+			names = append(names, s.Path+":"+s.Name)
+		}
+		return names
+	}
+
+	if got, want := names("a"), []string{"changed.go:c1", "deleted.go:d1", "unchanged.go:u1", "unchanged.go:u2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := names("b"), []string{"added.go:a1", "changed.go:c2", "changed.go:c3", "unchanged.go:u1", "unchanged.go:u2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if want := [][]string{{"a"}}; !reflect.DeepEqual(fetchTar, want) {
+		t.Errorf("got FetchTar calls %q, want %q", fetchTar, want)
+	}
+	if want := [][]string{{"added.go", "changed.go"}}; !reflect.DeepEqual(fetchTarPaths, want) {
+		t.Errorf("got FetchTarPaths calls %q, want %q", fetchTarPaths, want)
+	}
+}
+
+func TestParseNameStatus(t *testing.T) {
+	got, err := parseNameStatus([]byte("M\x00a b.go\x00D\x00c.go\x00T\x00d\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"a b.go": false, "c.go": true, "d": false}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got, err := parseNameStatus(nil); err != nil || len(got) != 0 {
+		t.Errorf("got %v, %v for empty output", got, err)
+	}
+	if _, err := parseNameStatus([]byte("M\x00")); err == nil {
+		t.Error("expected error for truncated output")
+	}
+}
+
+// wordParser returns a symbol for each space separated word of a file.
+type wordParser struct{}
+
+func (wordParser) Parse(name string, content []byte) ([]ctags.Entry, error) {
+	var entries []ctags.Entry
+	for _, w := range strings.Fields(string(content)) {
+		entries = append(entries, ctags.Entry{Name: w, Path: name})
+	}
+	return entries, nil
+}
+
+func (wordParser) Close() {}
@@ -1,22 +1,21 @@
 package symbols
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/gob"
 	"io"
-	"io/ioutil"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
-	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
 	"golang.org/x/net/trace"
+	log15 "gopkg.in/inconshreveable/log15.v2"
 )
 
-func (s *Service) indexedSymbols(ctx context.Context, repo api.RepoName, commitID api.CommitID) (symbols []protocol.Symbol, err error) {
+// indexedSymbols returns the index of the symbols of repo at commitID,
+// building it if it is not in the cache yet. The index must be closed when
+// it is no longer needed.
+func (s *Service) indexedSymbols(ctx context.Context, repo api.RepoName, commitID api.CommitID) (idx *symbolIndex, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "indexedSymbols")
 	defer func() {
 		if err != nil {
@@ -26,14 +25,16 @@ func (s *Service) indexedSymbols(ctx context.Context, repo api.RepoName, commitI
 		span.Finish()
 	}()
 
-	key := string(repo) + ":" + string(commitID) + ":v1" // suffix is index format version (vN)
+	key := symbolsKey(repo, commitID)
 
 	tr := trace.New("indexedSymbols", string(repo))
 	tr.LazyPrintf("commitID: %s", commitID)
 
-	var fetched bool
+	var fetched, incremental bool
 	defer func() {
-		tr.LazyPrintf("fetched=%v symbols=%d", fetched, len(symbols))
+		if idx != nil {
+			tr.LazyPrintf("fetched=%v incremental=%v symbols=%d", fetched, incremental, idx.Len())
+		}
 		if err != nil {
 			tr.LazyPrintf("error: %s", err)
 			tr.SetError()
@@ -43,67 +44,38 @@ func (s *Service) indexedSymbols(ctx context.Context, repo api.RepoName, commitI
 
 	f, err := s.cache.Open(ctx, key, func(ctx context.Context) (io.ReadCloser, error) {
 		fetched = true
-
-		var err error
-		symbols, err = s.parseUncached(ctx, repo, commitID)
+		symbols, ok, err := s.parseIncremental(ctx, repo, commitID)
 		if err != nil {
-			return nil, err
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// A full parse is always correct, so fall back to it.
+			log15.Warn("Incremental symbols parse failed, parsing all files.", "repo", repo, "commitID", commitID, "error", err)
+			ok = false
+		}
+		incremental = ok
+		if !ok {
+			symbols, err = s.parseUncached(ctx, repo, commitID, nil)
+			if err != nil {
+				return nil, err
+			}
 		}
-		return encodeSymbols(symbols)
+		return encodeSymbolIndex(symbols)
 	})
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	// Skip deserializing symbols if we just serialized them in the s.cache.Open call above and still have the
-	// slice.
-	if symbols == nil {
-		var size int64
-		if fi, err := f.Stat(); err == nil {
-			size = fi.Size()
-		}
-		tr.LazyPrintf("decode bytes=%d", size)
-		symbols, err = decodeSymbols(ctx, f)
-		tr.LazyPrintf("decode (done) symbols=%d", len(symbols))
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	span.LogFields(otlog.String("event", "result"), otlog.Int("count", len(symbols)))
-	return symbols, nil
-}
-
-func encodeSymbols(symbols []protocol.Symbol) (io.ReadCloser, error) {
-	var buf bytes.Buffer
-	zw := gzip.NewWriter(&buf)
-	enc := gob.NewEncoder(zw)
-	if err := enc.Encode(symbols); err != nil {
-		return nil, err
-	}
-	if err := zw.Close(); err != nil {
+	idx, err = openSymbolIndex(f.File)
+	if err != nil {
 		return nil, err
 	}
-	return ioutil.NopCloser(&buf), nil
+	span.LogFields(otlog.String("event", "result"), otlog.Int("count", idx.Len()))
+	return idx, nil
 }
 
-func decodeSymbols(ctx context.Context, r io.Reader) (symbols []protocol.Symbol, err error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "decodeSymbols")
-	defer func() {
-		if err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
-		}
-		span.Finish()
-	}()
-
-	zr, err := gzip.NewReader(r)
-	if err != nil {
-		return nil, err
-	}
-	defer zr.Close()
-	dec := gob.NewDecoder(zr)
-	err = dec.Decode(&symbols)
-	return symbols, err
+// symbolsKey returns the cache key of the symbol index of repo at commitID.
+func symbolsKey(repo api.RepoName, commitID api.CommitID) string {
+	return string(repo) + ":" + string(commitID) + ":v3" // suffix is index format version (vN)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 
@@ -37,7 +38,10 @@ func (s *Service) startParsers() error {
 	return nil
 }
 
-func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID api.CommitID) (symbols []protocol.Symbol, err error) {
+// parseUncached parses the symbols of repo at commitID. If paths is non-nil,
+// only the files at those paths are parsed. The symbols are sorted with
+// sortSymbols.
+func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID api.CommitID, paths []string) (symbols []protocol.Symbol, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "parseUncached")
 	defer func() {
 		if err != nil {
@@ -51,6 +55,9 @@ func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID
 
 	tr := trace.New("parseUncached", string(repo))
 	tr.LazyPrintf("commitID: %s", commitID)
+	if paths != nil {
+		tr.LazyPrintf("paths: %d", len(paths))
+	}
 
 	defer func() {
 		tr.LazyPrintf("symbols=%d", len(symbols))
@@ -62,7 +69,7 @@ func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID
 	}()
 
 	tr.LazyPrintf("fetch")
-	parseRequests, errChan, err := s.fetchRepositoryArchive(ctx, repo, commitID)
+	parseRequests, errChan, err := s.fetchRepositoryArchive(ctx, repo, commitID, paths)
 	tr.LazyPrintf("fetch (returned chans)")
 	if err != nil {
 		return nil, err
@@ -123,9 +130,23 @@ func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID
 	if err := <-errChan; err != nil {
 		return nil, err
 	}
+	sortSymbols(symbols)
 	return symbols, nil
 }
 
+// sortSymbols sorts symbols by path and line. The symbols of a line keep the
+// order in which the parser returned them. Files are parsed concurrently, so
+// this makes the order of the index independent of how they were scheduled
+// (and of whether it was parsed incrementally).
+func sortSymbols(symbols []protocol.Symbol) {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if symbols[i].Path != symbols[j].Path {
+			return symbols[i].Path < symbols[j].Path
+		}
+		return symbols[i].Line < symbols[j].Line
+	})
+}
+
 // parse gets a parser from the pool and uses it to satisfy the parse request.
 func (s *Service) parse(ctx context.Context, req parseRequest) (entries []ctags.Entry, err error) {
 	parseQueueSize.Inc()
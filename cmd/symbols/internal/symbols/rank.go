@@ -0,0 +1,262 @@
+package symbols
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/trigram"
+)
+
+// Weights of the components of a symbol's score. The match quality is the
+// most important, the others break ties between similarly good matches.
+const (
+	matchWeight   = 100 // multiplied by the match quality, from 0 to 1
+	kindWeight    = 20  // multiplied by kindRank, from 0 to 1
+	depthWeight   = 2   // subtracted per directory the file is nested in
+	maxDepth      = 10  // directories deeper than this are not penalized further
+	testPenalty   = 15  // subtracted for symbols in test files
+	vendorPenalty = 30  // subtracted for symbols in vendored files
+)
+
+// symbolScore returns the score of symbol s, whose name matched the query
+// with the given quality (from 0 to 1).
+func symbolScore(s *protocol.Symbol, quality float64) float64 {
+	score := matchWeight*quality + kindWeight*kindRank(s.Kind)
+	depth := strings.Count(s.Path, "/")
+	if depth > maxDepth {
+		depth = maxDepth
+	}
+	score -= float64(depthWeight * depth)
+	if isTestPath(s.Path) {
+		score -= testPenalty
+	}
+	if isVendoredPath(s.Path) {
+		score -= vendorPenalty
+	}
+	return score
+}
+
+// kindRank ranks a ctags kind: types are usually what is searched for,
+// followed by functions, followed by variables.
+func kindRank(kind string) float64 {
+	switch kind {
+	case "class", "struct", "interface", "type", "typedef", "enum", "union", "trait", "protocol":
+		return 1
+	case "func", "function", "method", "constructor", "singletonMethod", "subroutine", "procedure":
+		return 0.7
+	case "var", "variable", "const", "constant", "field", "member", "property":
+		return 0.4
+	}
+	return 0.2
+}
+
+// rankedSymbols keeps the best limit symbols added to it (all of them if
+// limit is 0), so that every match is ranked but only the best ones are held
+// in memory. Symbols with equal scores stay in the order they were added.
+type rankedSymbols struct {
+	limit int
+	heap  symbolHeap
+	added int
+}
+
+func (r *rankedSymbols) add(s protocol.Symbol) {
+	rs := rankedSymbol{Symbol: s, order: r.added}
+	r.added++
+	if r.limit == 0 || len(r.heap) < r.limit {
+		heap.Push(&r.heap, rs)
+	} else if r.heap.worse(r.heap[0], rs) {
+		r.heap[0] = rs
+		heap.Fix(&r.heap, 0)
+	}
+}
+
+// symbols returns the kept symbols, best first.
+func (r *rankedSymbols) symbols() []protocol.Symbol {
+	sort.Slice(r.heap, func(i, j int) bool { return r.heap.worse(r.heap[j], r.heap[i]) })
+	var res []protocol.Symbol
+	for _, rs := range r.heap {
+		res = append(res, rs.Symbol)
+	}
+	return res
+}
+
+type rankedSymbol struct {
+	protocol.Symbol
+	order int // the order in which the symbol was added
+}
+
+// symbolHeap is a heap whose root is the worst symbol.
+type symbolHeap []rankedSymbol
+
+// worse reports whether a ranks below b.
+func (symbolHeap) worse(a, b rankedSymbol) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.order > b.order
+}
+
+func (h symbolHeap) Len() int            { return len(h) }
+func (h symbolHeap) Less(i, j int) bool  { return h.worse(h[i], h[j]) }
+func (h symbolHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *symbolHeap) Push(x interface{}) { *h = append(*h, x.(rankedSymbol)) }
+func (h *symbolHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+func isTestPath(path string) bool {
+	path = "/" + strings.ToLower(path)
+	for _, s := range []string{"_test.", ".test.", ".spec.", "/test/", "/tests/", "/__tests__/", "/testdata/"} {
+		if strings.Contains(path, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isVendoredPath(path string) bool {
+	path = "/" + path
+	for _, s := range []string{"/vendor/", "/node_modules/", "/third_party/", "/bower_components/"} {
+		if strings.Contains(path, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// substringQuality returns the quality of a match of a query against name,
+// where the match is at name[start:end].
+func substringQuality(name []byte, start, end int) float64 {
+	switch {
+	case start == 0 && end == len(name):
+		return 1
+	case start == 0:
+		return 0.8
+	case isWordStart(name, start):
+		return 0.6
+	}
+	return 0.4
+}
+
+// Scores of the characters of a fuzzy match. A match of the whole query in one
+// run from the start of the name gets the highest score.
+const (
+	fuzzyCharScore        = 1
+	fuzzyWordStartBonus   = 2 // character starts a word, e.g. "R" in "NewRepoCache"
+	fuzzyNameStartBonus   = 2 // character is the first of the name (in addition to fuzzyWordStartBonus)
+	fuzzyConsecutiveBonus = 3 // character follows the previously matched one
+	fuzzyGapPenalty       = 1 // characters were skipped since the previously matched one
+)
+
+// fuzzyQuality reports whether name contains the characters of query in
+// order, and if so how well it matches from 0 to 1. Matches whose characters
+// are consecutive or start words of the name are better. If caseSensitive is
+// false, ASCII letters are compared case-insensitively.
+func fuzzyQuality(query, name []byte, caseSensitive bool) (float64, bool) {
+	if len(query) == 0 {
+		return 0, true
+	}
+	if !isSubsequence(query, name, caseSensitive) {
+		return 0, false
+	}
+	best := fuzzyRawScore(query, name, caseSensitive)
+	ideal := fuzzyRawScore(query, query, true)
+	quality := 0.8*float64(best)/float64(ideal) + 0.2*float64(len(query))/float64(len(name))
+	if quality > 1 {
+		quality = 1
+	} else if quality < 0 {
+		quality = 0
+	}
+	return quality, true
+}
+
+func isSubsequence(query, name []byte, caseSensitive bool) bool {
+	i := 0
+	for j := 0; j < len(name) && i < len(query); j++ {
+		if equalFold(query[i], name[j], caseSensitive) {
+			i++
+		}
+	}
+	return i == len(query)
+}
+
+// fuzzyRawScore returns the best score of an alignment of query as a
+// subsequence of name. query must be a subsequence of name.
+func fuzzyRawScore(query, name []byte, caseSensitive bool) int {
+	const none = -1 << 30
+	// prev[j] is the best score of query[:i] with query[i-1] at name[j], and
+	// prevMax[j] the best one with query[i-1] at or before name[j].
+	prev := make([]int, len(name))
+	prevMax := make([]int, len(name))
+	cur := make([]int, len(name))
+	for i := range query {
+		for j := range name {
+			cur[j] = none
+			if !equalFold(query[i], name[j], caseSensitive) {
+				continue
+			}
+			s := fuzzyCharScore
+			if isWordStart(name, j) {
+				s += fuzzyWordStartBonus
+			}
+			if i == 0 {
+				if j == 0 {
+					s += fuzzyNameStartBonus
+				}
+				cur[j] = s
+				continue
+			}
+			if j > 0 && prev[j-1] != none {
+				cur[j] = prev[j-1] + s + fuzzyConsecutiveBonus
+			}
+			if j > 1 && prevMax[j-2] != none && prevMax[j-2]+s-fuzzyGapPenalty > cur[j] {
+				cur[j] = prevMax[j-2] + s - fuzzyGapPenalty
+			}
+		}
+		prev, cur = cur, prev
+		for j := range prev {
+			prevMax[j] = prev[j]
+			if j > 0 && prevMax[j-1] > prevMax[j] {
+				prevMax[j] = prevMax[j-1]
+			}
+		}
+	}
+	return prevMax[len(name)-1]
+}
+
+// isWordStart reports whether name[i] starts a word, as in "foo_bar",
+// "fooBar" or "HTTPServer".
+func isWordStart(name []byte, i int) bool {
+	if i == 0 {
+		return true
+	}
+	c, p := name[i], name[i-1]
+	switch {
+	case !isAlnum(p):
+		return isAlnum(c)
+	case isUpper(c) && !isUpper(p):
+		return true
+	case isUpper(c) && i+1 < len(name) && isLower(name[i+1]):
+		return true
+	case isDigit(c) && !isDigit(p):
+		return true
+	}
+	return false
+}
+
+func equalFold(a, b byte, caseSensitive bool) bool {
+	if caseSensitive {
+		return a == b
+	}
+	return trigram.ToLowerASCII(a) == trigram.ToLowerASCII(b)
+}
+
+func isUpper(c byte) bool { return 'A' <= c && c <= 'Z' }
+func isLower(c byte) bool { return 'a' <= c && c <= 'z' }
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+func isAlnum(c byte) bool { return isUpper(c) || isLower(c) || isDigit(c) || c >= 0x80 }
@@ -72,10 +72,11 @@ func (s *Service) search(ctx context.Context, args protocol.SearchArgs) (result
 		tr.Finish()
 	}()
 
-	symbols, err := s.indexedSymbols(ctx, args.Repo, args.CommitID)
+	idx, err := s.indexedSymbols(ctx, args.Repo, args.CommitID)
 	if err != nil {
 		return nil, err
 	}
+	defer idx.Close()
 
 	const maxFirst = 500
 	if args.First < 0 || args.First > maxFirst {
@@ -85,12 +86,15 @@ func (s *Service) search(ctx context.Context, args protocol.SearchArgs) (result
 	result = &protocol.SearchResult{}
 	if args.Query == "" && len(args.IncludePatterns) == 0 && args.ExcludePattern == "" {
 		// No filters were provided, save iterating the symbols and return a slice
-		if args.First != 0 && len(symbols) > args.First {
-			symbols = symbols[:args.First]
+		n := idx.Len()
+		if args.First != 0 && n > args.First {
+			n = args.First
+		}
+		for id := 0; id < n; id++ {
+			result.Symbols = append(result.Symbols, idx.symbol(id))
 		}
-		result.Symbols = symbols
 	} else {
-		res, err := filterSymbols(ctx, symbols, args)
+		res, err := filterSymbols(ctx, idx, args)
 		if err != nil {
 			return nil, err
 		}
@@ -99,7 +103,7 @@ func (s *Service) search(ctx context.Context, args protocol.SearchArgs) (result
 	return result, nil
 }
 
-func filterSymbols(ctx context.Context, symbols []protocol.Symbol, args protocol.SearchArgs) (res []protocol.Symbol, err error) {
+func filterSymbols(ctx context.Context, idx *symbolIndex, args protocol.SearchArgs) (res []protocol.Symbol, err error) {
 	span, _ := opentracing.StartSpanFromContext(ctx, "filterSymbols")
 	defer func() {
 		if err != nil {
@@ -108,12 +112,22 @@ func filterSymbols(ctx context.Context, symbols []protocol.Symbol, args protocol
 		}
 		span.Finish()
 	}()
-	span.SetTag("before", len(symbols))
+	span.SetTag("before", idx.Len())
+
+	var fuzzy bool
+	switch args.MatchMode {
+	case protocol.MatchSubstring:
+	case protocol.MatchFuzzy:
+		fuzzy = true
+	default:
+		return nil, fmt.Errorf("unknown match mode %q", args.MatchMode)
+	}
 
 	query := args.Query
-	if !args.IsRegExp {
+	if !args.IsRegExp || fuzzy {
 		query = regexp.QuoteMeta(query)
 	}
+	literalQuery := query
 	if !args.IsCaseSensitive {
 		query = "(?i:" + query + ")"
 	}
@@ -130,15 +144,74 @@ func filterSymbols(ctx context.Context, symbols []protocol.Symbol, args protocol
 		return nil, err
 	}
 
-	for _, symbol := range symbols {
-		if !fileFilter.MatchPath(symbol.Path) || !queryRegex.MatchString(symbol.Name) {
-			continue
+	// Use the index to find the symbols whose name may match, so we don't
+	// need to look at every symbol. candidates is nil if all symbols may
+	// match. The characters of a fuzzy query need not be adjacent in the
+	// name, so the index can't help with those.
+	var candidates []int
+	all := true
+	if args.Query != "" && !fuzzy {
+		prefix, contained, err := queryLiterals(literalQuery)
+		if err != nil {
+			return nil, err
+		}
+		if prefix != "" && (len(prefix) >= len(contained) || len(contained) < 3) {
+			if ids, ok := idx.withPrefix(prefix); ok {
+				candidates, all = ids, false
+			}
+		} else if ids, ok := idx.containing(contained); ok {
+			candidates, all = ids, false
+		}
+	}
+	if !all {
+		span.SetTag("candidates", len(candidates))
+	}
+
+	// Many symbols share a path, so only match each path once.
+	pathMatches := map[int]bool{}
+	fuzzyQuery := []byte(args.Query)
+	matchQuality := func(id int) (quality float64, ok bool) {
+		pathID := idx.pathID(id)
+		match, ok := pathMatches[pathID]
+		if !ok {
+			match = fileFilter.MatchPath(idx.path(pathID))
+			pathMatches[pathID] = match
+		}
+		if !match {
+			return 0, false
+		}
+		name := idx.name(id)
+		if fuzzy {
+			return fuzzyQuality(fuzzyQuery, name, args.IsCaseSensitive)
 		}
-		res = append(res, symbol)
-		if args.First > 0 && len(res) == args.First {
-			break
+		loc := queryRegex.FindIndex(name)
+		if loc == nil {
+			return 0, false
+		}
+		return substringQuality(name, loc[0], loc[1]), true
+	}
+
+	// Every match is scored, and only the best args.First are kept, so the
+	// result doesn't depend on where in the index the best matches are.
+	ranked := &rankedSymbols{limit: args.First}
+	n := idx.Len()
+	if !all {
+		n = len(candidates)
+	}
+	for i := 0; i < n; i++ {
+		id := i
+		if !all {
+			id = candidates[i]
+		}
+		quality, ok := matchQuality(id)
+		if !ok {
+			continue
 		}
+		symbol := idx.symbol(id)
+		symbol.Score = symbolScore(&symbol, quality)
+		ranked.add(symbol)
 	}
+	res = ranked.symbols()
 
 	span.SetTag("after", len(res))
 	return res, nil
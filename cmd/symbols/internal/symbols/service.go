@@ -22,6 +22,23 @@ type Service struct {
 	// determine if the error is a bad request (eg invalid repo).
 	FetchTar func(context.Context, gitserver.Repo, api.CommitID) (io.ReadCloser, error)
 
+	// FetchTarPaths is like FetchTar, but the archive only contains the
+	// specified paths. It is used to parse only the changed files when
+	// indexing incrementally.
+	FetchTarPaths func(context.Context, gitserver.Repo, api.CommitID, []string) (io.ReadCloser, error)
+
+	// ListAncestors returns up to n ancestors of the commit, closest first,
+	// not including the commit itself.
+	ListAncestors func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, n int) ([]api.CommitID, error)
+
+	// DiffNameStatus returns the output of `git diff -z --name-status
+	// --no-renames base head`.
+	//
+	// If FetchTarPaths, ListAncestors and DiffNameStatus are all set, the
+	// symbols of a commit are computed from those of the closest ancestor
+	// already in the cache, by only parsing the files which changed since.
+	DiffNameStatus func(ctx context.Context, repo gitserver.Repo, base, head api.CommitID) ([]byte, error)
+
 	// MaxConcurrentFetchTar is the maximum number of concurrent calls allowed
 	// to FetchTar. It defaults to 15.
 	MaxConcurrentFetchTar int
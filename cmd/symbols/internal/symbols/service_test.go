@@ -53,7 +53,7 @@ func TestService(t *testing.T) {
 		},
 		"onematch": {
 			args: protocol.SearchArgs{Query: "x"},
-			want: protocol.SearchResult{Symbols: []protocol.Symbol{{Name: "x"}}},
+			want: protocol.SearchResult{Symbols: []protocol.Symbol{{Name: "x", Score: 104}}},
 		},
 		"nomatches": {
 			args: protocol.SearchArgs{Query: "foo"},
@@ -0,0 +1,516 @@
+package symbols
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp/syntax"
+	"sort"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/trigram"
+	"golang.org/x/sys/unix"
+)
+
+// A symbolIndex is the on-disk index of the symbols of a repository at a
+// commit. Unlike decoding all symbols for every query, it lets us answer
+// queries by only reading the symbols which may match:
+//
+// * Symbols whose name has a prefix are found by binary search over the
+//   symbols sorted by name.
+// * Symbols whose name contains a literal are found by intersecting the
+//   posting lists of the literal's trigrams.
+// * Path filters are evaluated once per distinct path.
+//
+// Names are compared ASCII case insensitively by the index. Callers must
+// check that the returned candidates actually match.
+//
+// The file format (all integers little endian) is:
+//
+//	header      magic [8]byte, numSymbols, numPaths, numTrigrams uint32,
+//	            pad uint32, then the uint64 offsets of each following section
+//	symbols     [numSymbols]uint64 offset of each symbol's record in data
+//	pathIDs     [numSymbols]uint32 index into paths of each symbol's path
+//	byName      [numSymbols]uint32 symbol IDs sorted by lowercased name
+//	paths       [numPaths+1]uint64 offset of each path in data (the last
+//	            entry is the end of the last path)
+//	trigrams    [numTrigrams]{trigram uint32, offset uint64} sorted by
+//	            trigram. offset is relative to the start of postings.
+//	postings    for each trigram, the delta-encoded uvarint IDs of the
+//	            symbols whose lowercased name contains it
+//	data        paths and symbol records. A record is the uvarint-prefixed
+//	            Name, Kind, Language, Parent, ParentKind, Signature and
+//	            Pattern, followed by the uvarint Line and a FileLimited byte.
+//
+// A symbol's ID is its position in the slice the index was built from, so
+// iterating over IDs in order yields symbols in their original order.
+type symbolIndex struct {
+	data []byte // the mmap'd file
+
+	numSymbols  int
+	numPaths    int
+	numTrigrams int
+
+	symbols  []byte
+	pathIDs  []byte
+	byName   []byte
+	paths    []byte
+	trigrams []byte
+	postings []byte
+}
+
+const (
+	symbolIndexMagic      = "SGSYMv2\n"
+	symbolIndexHeaderSize = 8 + 4*4 + 7*8
+	symbolTrigramSize     = 4 + 8
+)
+
+// encodeSymbolIndex returns the index for symbols.
+func encodeSymbolIndex(symbols []protocol.Symbol) (io.ReadCloser, error) {
+	var (
+		data       bytes.Buffer
+		scratch    [binary.MaxVarintLen64]byte
+		putUvarint = func(w *bytes.Buffer, v uint64) {
+			n := binary.PutUvarint(scratch[:], v)
+			w.Write(scratch[:n])
+		}
+		putString = func(s string) {
+			putUvarint(&data, uint64(len(s)))
+			data.WriteString(s)
+		}
+	)
+
+	// Paths are written first so they are contiguous in data.
+	pathIDs := map[string]uint32{}
+	var pathOffsets []uint64
+	symbolPathIDs := make([]uint32, len(symbols))
+	for i, sym := range symbols {
+		id, ok := pathIDs[sym.Path]
+		if !ok {
+			id = uint32(len(pathOffsets))
+			pathIDs[sym.Path] = id
+			pathOffsets = append(pathOffsets, uint64(data.Len()))
+			data.WriteString(sym.Path)
+		}
+		symbolPathIDs[i] = id
+	}
+	pathOffsets = append(pathOffsets, uint64(data.Len()))
+
+	type posting struct {
+		last uint32
+		buf  bytes.Buffer
+	}
+	postings := map[uint32]*posting{}
+	recordOffsets := make([]uint64, len(symbols))
+	for i, sym := range symbols {
+		recordOffsets[i] = uint64(data.Len())
+		for _, s := range []string{sym.Name, sym.Kind, sym.Language, sym.Parent, sym.ParentKind, sym.Signature, sym.Pattern} {
+			putString(s)
+		}
+		putUvarint(&data, uint64(sym.Line))
+		if sym.FileLimited {
+			data.WriteByte(1)
+		} else {
+			data.WriteByte(0)
+		}
+
+		id := uint32(i)
+		for j := 0; j+3 <= len(sym.Name); j++ {
+			tri := trigram.OfString(sym.Name[j:])
+			p, ok := postings[tri]
+			if !ok {
+				p = &posting{}
+				postings[tri] = p
+			} else if p.last == id && p.buf.Len() > 0 {
+				continue
+			}
+			putUvarint(&p.buf, uint64(id-p.last))
+			p.last = id
+		}
+	}
+
+	byName := make([]uint32, len(symbols))
+	for i := range byName {
+		byName[i] = uint32(i)
+	}
+	sort.SliceStable(byName, func(i, j int) bool {
+		return lowerASCII(symbols[byName[i]].Name) < lowerASCII(symbols[byName[j]].Name)
+	})
+
+	trigrams := make([]uint32, 0, len(postings))
+	for tri := range postings {
+		trigrams = append(trigrams, tri)
+	}
+	sort.Slice(trigrams, func(i, j int) bool { return trigrams[i] < trigrams[j] })
+
+	// Compute the section offsets, then write everything out.
+	var offsets [7]uint64
+	off := uint64(symbolIndexHeaderSize)
+	for i, size := range []int{
+		8 * len(symbols),                  // symbols
+		4 * len(symbols),                  // pathIDs
+		4 * len(symbols),                  // byName
+		8 * len(pathOffsets),              // paths
+		symbolTrigramSize * len(trigrams), // trigrams
+		0,                                 // postings, see below
+	} {
+		offsets[i] = off
+		off += uint64(size)
+	}
+	for _, tri := range trigrams {
+		off += uint64(postings[tri].buf.Len())
+	}
+	offsets[6] = off // data
+
+	var buf bytes.Buffer
+	buf.Grow(int(off) + data.Len())
+	le := binary.LittleEndian
+	var b [8]byte
+	put32 := func(v uint32) { le.PutUint32(b[:4], v); buf.Write(b[:4]) }
+	put64 := func(v uint64) { le.PutUint64(b[:], v); buf.Write(b[:]) }
+
+	buf.WriteString(symbolIndexMagic)
+	put32(uint32(len(symbols)))
+	put32(uint32(len(pathOffsets) - 1))
+	put32(uint32(len(trigrams)))
+	put32(0)
+	for _, o := range offsets {
+		put64(o)
+	}
+	for _, o := range recordOffsets {
+		put64(offsets[6] + o)
+	}
+	for _, id := range symbolPathIDs {
+		put32(id)
+	}
+	for _, id := range byName {
+		put32(id)
+	}
+	for _, o := range pathOffsets {
+		put64(offsets[6] + o)
+	}
+	var postingsOff uint64
+	for _, tri := range trigrams {
+		put32(tri)
+		put64(postingsOff)
+		postingsOff += uint64(postings[tri].buf.Len())
+	}
+	for _, tri := range trigrams {
+		buf.Write(postings[tri].buf.Bytes())
+	}
+	buf.Write(data.Bytes())
+	return ioutil.NopCloser(&buf), nil
+}
+
+// openSymbolIndex mmaps the index in f. f may be closed once it returns. The
+// index must be released with Close.
+func openSymbolIndex(f *os.File) (*symbolIndex, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < symbolIndexHeaderSize {
+		return nil, errors.Errorf("symbol index %s is truncated", f.Name())
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := newSymbolIndex(data)
+	if err != nil {
+		unix.Munmap(data)
+		return nil, errors.Wrapf(err, "symbol index %s", f.Name())
+	}
+	return idx, nil
+}
+
+func newSymbolIndex(data []byte) (*symbolIndex, error) {
+	if len(data) < symbolIndexHeaderSize || string(data[:8]) != symbolIndexMagic {
+		return nil, errors.New("unknown format")
+	}
+	le := binary.LittleEndian
+	idx := &symbolIndex{
+		data:        data,
+		numSymbols:  int(le.Uint32(data[8:])),
+		numPaths:    int(le.Uint32(data[12:])),
+		numTrigrams: int(le.Uint32(data[16:])),
+	}
+	var offsets [8]uint64
+	for i := 0; i < 7; i++ {
+		offsets[i] = le.Uint64(data[24+8*i:])
+	}
+	offsets[7] = uint64(len(data))
+	for i := 0; i < 7; i++ {
+		if offsets[i] > offsets[i+1] {
+			return nil, errors.New("invalid section offsets")
+		}
+	}
+	section := func(i int) []byte { return data[offsets[i]:offsets[i+1]] }
+	idx.symbols = section(0)
+	idx.pathIDs = section(1)
+	idx.byName = section(2)
+	idx.paths = section(3)
+	idx.trigrams = section(4)
+	idx.postings = section(5)
+	if len(idx.symbols) != 8*idx.numSymbols || len(idx.pathIDs) != 4*idx.numSymbols || len(idx.byName) != 4*idx.numSymbols ||
+		len(idx.paths) != 8*(idx.numPaths+1) || len(idx.trigrams) != symbolTrigramSize*idx.numTrigrams {
+		return nil, errors.New("invalid section sizes")
+	}
+	return idx, nil
+}
+
+// Close releases the resources associated with idx. It must not be used
+// afterwards.
+func (idx *symbolIndex) Close() error {
+	return unix.Munmap(idx.data)
+}
+
+// Len returns the number of symbols in idx.
+func (idx *symbolIndex) Len() int { return idx.numSymbols }
+
+// pathID returns the ID of the path of symbol id.
+func (idx *symbolIndex) pathID(id int) int {
+	return int(binary.LittleEndian.Uint32(idx.pathIDs[4*id:]))
+}
+
+// path returns the path with the given ID.
+func (idx *symbolIndex) path(pathID int) string {
+	if pathID < 0 || pathID >= idx.numPaths {
+		return "" // corrupt index
+	}
+	start := binary.LittleEndian.Uint64(idx.paths[8*pathID:])
+	end := binary.LittleEndian.Uint64(idx.paths[8*(pathID+1):])
+	if start > end || end > uint64(len(idx.data)) {
+		return "" // corrupt index
+	}
+	return string(idx.data[start:end])
+}
+
+// record returns the data of symbol id, starting at its record.
+func (idx *symbolIndex) record(id int) []byte {
+	off := binary.LittleEndian.Uint64(idx.symbols[8*id:])
+	if off > uint64(len(idx.data)) {
+		// Corrupt index. Decoding nil yields empty fields rather than
+		// panicking.
+		return nil
+	}
+	return idx.data[off:]
+}
+
+// name returns the name of symbol id. It does not copy, so the returned
+// bytes must not be used after idx is closed.
+func (idx *symbolIndex) name(id int) []byte {
+	name, _ := readString(idx.record(id))
+	return name
+}
+
+// symbol decodes symbol id.
+func (idx *symbolIndex) symbol(id int) protocol.Symbol {
+	var fields [7][]byte
+	rec := idx.record(id)
+	for i := range fields {
+		fields[i], rec = readString(rec)
+	}
+	line, n := binary.Uvarint(rec)
+	return protocol.Symbol{
+		Name:        string(fields[0]),
+		Path:        idx.path(idx.pathID(id)),
+		Line:        int(line),
+		Kind:        string(fields[1]),
+		Language:    string(fields[2]),
+		Parent:      string(fields[3]),
+		ParentKind:  string(fields[4]),
+		Signature:   string(fields[5]),
+		Pattern:     string(fields[6]),
+		FileLimited: n > 0 && n < len(rec) && rec[n] == 1,
+	}
+}
+
+func readString(b []byte) (s, rest []byte) {
+	l, n := binary.Uvarint(b)
+	if n <= 0 || uint64(len(b)-n) < l {
+		return nil, nil
+	}
+	return b[n : n+int(l)], b[n+int(l):]
+}
+
+// withPrefix returns the IDs (in ascending order) of the symbols whose name
+// starts with prefix, compared ASCII case insensitively. ok is false if the
+// index is corrupt, in which case all symbols must be checked.
+func (idx *symbolIndex) withPrefix(prefix string) (ids []int, ok bool) {
+	prefix = lowerASCII(prefix)
+	corrupt := false
+	idAt := func(i int) int {
+		id := int(binary.LittleEndian.Uint32(idx.byName[4*i:]))
+		if id >= idx.numSymbols {
+			corrupt = true
+			return -1
+		}
+		return id
+	}
+	nameAt := func(i int) string {
+		id := idAt(i)
+		if id < 0 {
+			return ""
+		}
+		return lowerASCII(string(idx.name(id)))
+	}
+	start := sort.Search(idx.numSymbols, func(i int) bool { return nameAt(i) >= prefix })
+	for i := start; i < idx.numSymbols && !corrupt; i++ {
+		if name := nameAt(i); len(name) < len(prefix) || name[:len(prefix)] != prefix {
+			break
+		}
+		ids = append(ids, idAt(i))
+	}
+	if corrupt {
+		return nil, false
+	}
+	sort.Ints(ids)
+	return ids, true
+}
+
+// containing returns the IDs (in ascending order) of the symbols whose name
+// may contain lit, compared ASCII case insensitively. ok is false if the
+// index can't narrow down the symbols (lit is shorter than a trigram, or the
+// index is corrupt).
+func (idx *symbolIndex) containing(lit string) (ids []int, ok bool) {
+	if len(lit) < 3 {
+		return nil, false
+	}
+	seen := map[uint32]bool{}
+	first := true
+	for i := 0; i+3 <= len(lit); i++ {
+		tri := trigram.OfString(lit[i:])
+		if seen[tri] {
+			continue
+		}
+		seen[tri] = true
+
+		p, found := idx.lookupTrigram(tri)
+		if !found {
+			return nil, true
+		}
+		var (
+			list []int
+			last uint64
+		)
+		for r := bytes.NewReader(p); r.Len() > 0; {
+			delta, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, false
+			}
+			last += delta
+			if last >= uint64(idx.numSymbols) {
+				return nil, false // corrupt index
+			}
+			list = append(list, int(last))
+		}
+		if first {
+			ids, first = list, false
+		} else {
+			ids = trigram.IntersectSorted(ids, list)
+		}
+		if len(ids) == 0 {
+			return nil, true
+		}
+	}
+	return ids, true
+}
+
+func (idx *symbolIndex) lookupTrigram(tri uint32) ([]byte, bool) {
+	le := binary.LittleEndian
+	entry := func(i int) []byte { return idx.trigrams[i*symbolTrigramSize:] }
+	i := sort.Search(idx.numTrigrams, func(i int) bool { return le.Uint32(entry(i)) >= tri })
+	if i == idx.numTrigrams || le.Uint32(entry(i)) != tri {
+		return nil, false
+	}
+	start, end := le.Uint64(entry(i)[4:]), uint64(len(idx.postings))
+	if i+1 < idx.numTrigrams {
+		end = le.Uint64(entry(i + 1)[4:])
+	}
+	if start > end || end > uint64(len(idx.postings)) {
+		return nil, false
+	}
+	return idx.postings[start:end], true
+}
+
+// queryLiterals returns the literal prefix of every match of the regexp
+// query (if it is anchored at the start), and a literal contained in every
+// match. Either may be empty. Literals containing non-ASCII characters are
+// not returned, since the index only folds ASCII case.
+func queryLiterals(query string) (prefix, contained string, err error) {
+	re, err := syntax.Parse(query, syntax.Perl)
+	if err != nil {
+		return "", "", err
+	}
+	re = re.Simplify()
+	if re.Op == syntax.OpConcat && len(re.Sub) > 0 && (re.Sub[0].Op == syntax.OpBeginText || re.Sub[0].Op == syntax.OpBeginLine) {
+		for _, sub := range re.Sub[1:] {
+			if sub.Op != syntax.OpLiteral {
+				break
+			}
+			prefix += string(sub.Rune)
+		}
+	}
+	contained = requiredLiteral(re)
+	if !isASCII(prefix) {
+		prefix = ""
+	}
+	if !isASCII(contained) {
+		contained = ""
+	}
+	return prefix, contained, nil
+}
+
+// requiredLiteral returns the longest literal found in every match of re
+// that is easy to determine.
+func requiredLiteral(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return string(re.Rune)
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiteral(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var longest, run string
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run += string(sub.Rune)
+			} else {
+				run = ""
+				if lit := requiredLiteral(sub); len(lit) > len(longest) {
+					longest = lit
+				}
+			}
+			if len(run) > len(longest) {
+				longest = run
+			}
+		}
+		return longest
+	}
+	return ""
+}
+
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		b[i] = trigram.ToLowerASCII(c)
+	}
+	return string(b)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,255 @@
+package symbols
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+var testSymbols = []protocol.Symbol{
+	{Name: "NewRepoCache", Path: "cache/cache.go", Line: 10, Kind: "func", Language: "Go", Signature: "()"},
+	{Name: "repoCache", Path: "cache/cache.go", Line: 20, Kind: "type", Language: "Go"},
+	{Name: "handleExec", Path: "cmd/server/exec.go", Line: 5, Kind: "func", Language: "Go", Parent: "Server", ParentKind: "type"},
+	{Name: "TestHandleExec", Path: "cmd/server/exec_test.go", Line: 7, Kind: "func", Language: "Go", FileLimited: true},
+	{Name: "x", Path: "a.js", Line: 1, Kind: "variable", Language: "JavaScript"},
+}
+
+func newTestSymbolIndex(t *testing.T, symbols []protocol.Symbol) *symbolIndex {
+	rc, err := encodeSymbolIndex(symbols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := newSymbolIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func TestSymbolIndex_roundTrip(t *testing.T) {
+	idx := newTestSymbolIndex(t, testSymbols)
+	if idx.Len() != len(testSymbols) {
+		t.Fatalf("got %d symbols, want %d", idx.Len(), len(testSymbols))
+	}
+	for id, want := range testSymbols {
+		if got := idx.symbol(id); !reflect.DeepEqual(got, want) {
+			t.Errorf("symbol %d: got %+v, want %+v", id, got, want)
+		}
+	}
+
+	empty := newTestSymbolIndex(t, nil)
+	if empty.Len() != 0 {
+		t.Errorf("got %d symbols in empty index", empty.Len())
+	}
+}
+
+// TestSymbolIndex_corrupt ensures that out of range offsets and IDs in an
+// index don't panic, and that queries then check all symbols.
+func TestSymbolIndex_corrupt(t *testing.T) {
+	le := binary.LittleEndian
+	tests := map[string]struct {
+		corrupt func(idx *symbolIndex)
+		want    bool // whether the matches are still found
+	}{
+		"byName": {
+			corrupt: func(idx *symbolIndex) {
+				for i := 0; i < idx.numSymbols; i++ {
+					le.PutUint32(idx.byName[4*i:], 1<<31)
+				}
+			},
+			want: true,
+		},
+		"postings": {
+			corrupt: func(idx *symbolIndex) {
+				for i := range idx.postings {
+					idx.postings[i] = 0x7f
+				}
+			},
+			want: true,
+		},
+		"records": {
+			corrupt: func(idx *symbolIndex) {
+				for i := 0; i < idx.numSymbols; i++ {
+					le.PutUint64(idx.symbols[8*i:], 1<<62)
+				}
+			},
+		},
+		"paths": {
+			corrupt: func(idx *symbolIndex) {
+				for i := 0; i < idx.numSymbols; i++ {
+					le.PutUint32(idx.pathIDs[4*i:], 1<<31)
+				}
+				for i := 0; i <= idx.numPaths; i++ {
+					le.PutUint64(idx.paths[8*i:], 1<<62)
+				}
+			},
+			want: true,
+		},
+	}
+	queries := map[string]protocol.SearchArgs{
+		"prefix":    {Query: "^repo", IsRegExp: true},
+		"substring": {Query: "repoCache", IsCaseSensitive: true},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			idx := newTestSymbolIndex(t, testSymbols)
+			test.corrupt(idx)
+			for id := 0; id < idx.Len(); id++ {
+				idx.symbol(id)
+			}
+			for query, args := range queries {
+				symbols, err := filterSymbols(context.Background(), idx, args)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if found := len(symbols) == 1 && symbols[0].Name == "repoCache"; found != test.want {
+					t.Errorf("%s: got %+v, want found %v", query, symbols, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterSymbols(t *testing.T) {
+	idx := newTestSymbolIndex(t, testSymbols)
+
+	tests := map[string]struct {
+		args protocol.SearchArgs
+		want []string
+	}{
+		"substring": {
+			args: protocol.SearchArgs{Query: "cache"},
+			want: []string{"repoCache", "NewRepoCache"},
+		},
+		"case sensitive": {
+			args: protocol.SearchArgs{Query: "Repo", IsCaseSensitive: true},
+			want: []string{"NewRepoCache"},
+		},
+		"short": {
+			args: protocol.SearchArgs{Query: "x"},
+			want: []string{"x", "handleExec", "TestHandleExec"},
+		},
+		"prefix": {
+			args: protocol.SearchArgs{Query: "^handle", IsRegExp: true},
+			want: []string{"handleExec"},
+		},
+		"regexp": {
+			args: protocol.SearchArgs{Query: "Repo.*e$", IsRegExp: true},
+			want: []string{"repoCache", "NewRepoCache"},
+		},
+		"no match": {
+			args: protocol.SearchArgs{Query: "missing"},
+			want: nil,
+		},
+		"include": {
+			args: protocol.SearchArgs{Query: "exec", IncludePatterns: []string{"_test\\.go$"}, IsRegExp: true},
+			want: []string{"TestHandleExec"},
+		},
+		"exclude": {
+			args: protocol.SearchArgs{ExcludePattern: "^cmd/", IsRegExp: true},
+			want: []string{"repoCache", "NewRepoCache", "x"},
+		},
+		"first": {
+			args: protocol.SearchArgs{Query: "e", First: 2},
+			want: []string{"repoCache", "NewRepoCache"},
+		},
+		"first ranks all matches": {
+			args: protocol.SearchArgs{Query: "x", First: 1},
+			want: []string{"x"},
+		},
+		"first ranks all regexp matches": {
+			args: protocol.SearchArgs{Query: "c.*e$", IsRegExp: true, First: 1},
+			want: []string{"repoCache"},
+		},
+		"fuzzy": {
+			args: protocol.SearchArgs{Query: "hndlExec", MatchMode: protocol.MatchFuzzy},
+			want: []string{"handleExec", "TestHandleExec"},
+		},
+		"fuzzy first": {
+			args: protocol.SearchArgs{Query: "e", First: 2, MatchMode: protocol.MatchFuzzy},
+			want: []string{"handleExec", "TestHandleExec"},
+		},
+		"fuzzy camel case": {
+			args: protocol.SearchArgs{Query: "NRC", MatchMode: protocol.MatchFuzzy},
+			want: []string{"NewRepoCache"},
+		},
+		"fuzzy ignores regexp": {
+			args: protocol.SearchArgs{Query: "r.C", IsRegExp: true, MatchMode: protocol.MatchFuzzy},
+			want: nil,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			symbols, err := filterSymbols(context.Background(), idx, test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range symbols {
+				got = append(got, s.Name)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFuzzyQuality(t *testing.T) {
+	tests := []struct {
+		query, name string
+		match       bool
+	}{
+		{"hndlExec", "handleExec", true},
+		{"NRC", "NewRepoCache", true},
+		{"nrc", "NewRepoCache", true},
+		{"NRCX", "NewRepoCache", false},
+		{"ceh", "cache", false},
+	}
+	for _, test := range tests {
+		_, ok := fuzzyQuality([]byte(test.query), []byte(test.name), false)
+		if ok != test.match {
+			t.Errorf("fuzzyQuality(%q, %q) matched = %v, want %v", test.query, test.name, ok, test.match)
+		}
+	}
+
+	// Better matches must have a higher quality.
+	ordered := []string{"repoCache", "repoCacheEntry", "reportCache"}
+	var last float64 = 2
+	for _, name := range ordered {
+		q, ok := fuzzyQuality([]byte("repoC"), []byte(name), false)
+		if !ok {
+			t.Fatalf("repoC does not match %q", name)
+		}
+		if q >= last {
+			t.Errorf("quality of %q is %v, want less than the previous %v", name, q, last)
+		}
+		last = q
+	}
+}
+
+func TestSymbolScore(t *testing.T) {
+	score := func(s protocol.Symbol) float64 { return symbolScore(&s, 0.5) }
+	// Each symbol must rank below the previous one.
+	ordered := []protocol.Symbol{
+		{Kind: "type", Path: "a.go"},
+		{Kind: "func", Path: "a.go"},
+		{Kind: "var", Path: "a.go"},
+		{Kind: "var", Path: "a/b/c.go"},
+		{Kind: "var", Path: "a_test.go"},
+		{Kind: "var", Path: "vendor/a.go"},
+	}
+	for i := 1; i < len(ordered); i++ {
+		if score(ordered[i]) >= score(ordered[i-1]) {
+			t.Errorf("%+v scored %v, want less than %+v with %v", ordered[i], score(ordered[i]), ordered[i-1], score(ordered[i-1]))
+		}
+	}
+}
@@ -57,12 +57,40 @@ func main() {
 		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
 			return git.Archive(ctx, repo, git.ArchiveOptions{Treeish: string(commit), Format: "tar"})
 		},
+		FetchTarPaths: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+			// The paths are file names, not pathspec patterns (which would
+			// match other files if the names contain glob characters).
+			pathspecs := make([]string, len(paths))
+			for i, path := range paths {
+				pathspecs[i] = ":(literal)" + path
+			}
+			return git.Archive(ctx, repo, git.ArchiveOptions{Treeish: string(commit), Format: "tar", Paths: pathspecs})
+		},
+		ListAncestors: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, n int) ([]api.CommitID, error) {
+			// The first commit listed is commit itself.
+			commits, err := git.Commits(ctx, repo, git.CommitsOptions{Range: string(commit), N: uint(n) + 1})
+			if err != nil || len(commits) == 0 {
+				return nil, err
+			}
+			ancestors := make([]api.CommitID, 0, len(commits)-1)
+			for _, c := range commits[1:] {
+				ancestors = append(ancestors, c.ID)
+			}
+			return ancestors, nil
+		},
+		DiffNameStatus: func(ctx context.Context, repo gitserver.Repo, base, head api.CommitID) ([]byte, error) {
+			cmd := gitserver.DefaultClient.Command("git", "diff", "-z", "--name-status", "--no-renames", string(base), string(head), "--")
+			cmd.Repo = repo
+			return cmd.Output(ctx)
+		},
 		NewParser: func() (ctags.Parser, error) {
 			parser, err := ctags.NewParser(ctagsCommand)
 			if err != nil {
 				return nil, errors.Wrap(err, fmt.Sprintf("command: %s", ctagsCommand))
 			}
-			return parser, nil
+			// Use the native parsers registered in package ctags for the
+			// languages they support, and ctags for all others.
+			return ctags.NewLanguageParser(parser), nil
 		},
 		Path: cacheDir,
 	}
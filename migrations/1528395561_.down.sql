@@ -0,0 +1 @@
+ALTER TABLE repo DROP COLUMN IF EXISTS stars;
@@ -0,0 +1 @@
+ALTER TABLE repo ADD COLUMN stars integer NOT NULL DEFAULT 0;
@@ -44,6 +44,71 @@ type RepoCreateOrUpdateRequest struct {
 
 	// Archived is whether this repository is archived (according to its external origin).
 	Archived bool `json:"archived"`
+
+	// Stars is the number of stars of this repository on its external origin, or 0 if unknown. It is only
+	// stored by ReposSync.
+	Stars int `json:"stars,omitempty"`
+}
+
+// ReposSyncRequest is a request to reconcile the stored repositories of an external service (such as a single
+// GitHub Enterprise instance) with a listing of the repositories on it. Stored repositories are matched to the
+// listing by their ExternalRepo ID.
+type ReposSyncRequest struct {
+	// ServiceType and ServiceID identify the external service (see ExternalRepoSpec).
+	ServiceType string `json:"serviceType"`
+	ServiceID   string `json:"serviceID"`
+
+	// Repos is the listing of the external service's repositories. The ExternalRepo of every repository must be set
+	// and belong to the external service.
+	Repos []RepoCreateOrUpdateRequest `json:"repos"`
+
+	// Complete is whether Repos lists all of the external service's repositories. Stored repositories missing from
+	// the listing are only soft-deleted if it does.
+	Complete bool `json:"complete"`
+
+	// DryRun is whether to only compute the diff, without applying it.
+	DryRun bool `json:"dryRun"`
+}
+
+// ReposSyncResponse is the response to a ReposSyncRequest.
+type ReposSyncResponse struct {
+	// Diff is the set of changes made (or, for a dry run, that would be made) to the stored repositories.
+	Diff ReposSyncDiff `json:"diff"`
+
+	// Repos are the stored repositories of the listing after the sync. It is empty for a dry run.
+	Repos []*Repo `json:"repos"`
+}
+
+// ReposSyncDiff describes the changes that reconcile the stored repositories of an external service with a
+// listing of its repositories.
+type ReposSyncDiff struct {
+	// Created are the repositories in the listing that were not stored.
+	Created []RepoName `json:"created"`
+
+	// Renamed are the stored repositories whose name differs from their name in the listing.
+	Renamed []ReposSyncRename `json:"renamed"`
+
+	// Updated are the stored repositories whose metadata differs from the listing, or which were soft-deleted
+	// and are listed again.
+	Updated []RepoName `json:"updated"`
+
+	// Deleted are the stored repositories missing from a complete listing, which are soft-deleted.
+	Deleted []RepoName `json:"deleted"`
+
+	// Conflicts are the names in the listing which are held by a stored repository of another external service
+	// (or which another repository of the listing is renamed to), so they are skipped.
+	Conflicts []RepoName `json:"conflicts"`
+}
+
+// ReposSyncRename is a repository rename in a ReposSyncDiff.
+type ReposSyncRename struct {
+	From RepoName `json:"from"`
+	To   RepoName `json:"to"`
+}
+
+// Empty reports whether the diff has no changes.
+func (d *ReposSyncDiff) Empty() bool {
+	return len(d.Created) == 0 && len(d.Renamed) == 0 && len(d.Updated) == 0 && len(d.Deleted) == 0
 }
 
 type RepoUpdateIndexRequest struct {
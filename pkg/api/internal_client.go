@@ -280,6 +280,23 @@ func (c *internalClient) ReposListEnabled(ctx context.Context) ([]RepoName, erro
 	return names, err
 }
 
+// ReposDelete deletes the repository from the repo table, including a
+// soft-deleted row. It reports whether the repository existed.
+func (c *internalClient) ReposDelete(ctx context.Context, repo RepoName) (deleted bool, err error) {
+	err = c.postInternal(ctx, "repos/delete", repo, &deleted)
+	return deleted, err
+}
+
+// ReposSync reconciles the stored repositories of an external service with a
+// listing of its repositories (see ReposSyncRequest).
+func (c *internalClient) ReposSync(ctx context.Context, req ReposSyncRequest) (*ReposSyncResponse, error) {
+	var resp ReposSyncResponse
+	if err := c.postInternal(ctx, "repos/sync", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 func (c *internalClient) ConfigurationRawJSON(ctx context.Context) (string, error) {
 	var rawJSON string
 	err := c.postInternal(ctx, "configuration/raw-json", nil, &rawJSON)
@@ -232,3 +232,19 @@ func readSrcGitServers() []string {
 	}
 	return strings.Fields(v)
 }
+
+// SrcGitServerReplicas represents the SRC_GIT_SERVER_REPLICAS environment
+// variable. It is the number of gitservers each repository is cloned onto:
+// the gitserver the repository hashes to, and the gitservers following it in
+// SRC_GIT_SERVERS. Clients fail over to the replicas if a gitserver is
+// unreachable. It must be set to the same value on all services.
+var SrcGitServerReplicas = readSrcGitServerReplicas()
+
+func readSrcGitServerReplicas() int {
+	v := env.Get("SRC_GIT_SERVER_REPLICAS", "1", "number of gitservers each repository is cloned onto")
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		log.Fatalf("Invalid SRC_GIT_SERVER_REPLICAS %q: must be a positive integer", v)
+	}
+	return n
+}
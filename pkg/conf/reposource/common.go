@@ -37,10 +37,12 @@ func CloneURLToRepoName(cloneURL string) (repoName api.RepoName, err error) {
 
 	repoSources := make([]repoSource, 0, len(cfg.Github)+
 		len(cfg.Gitlab)+
+		len(cfg.Gitea)+
 		len(cfg.BitbucketServer)+
 		len(cfg.AwsCodeCommit)+
 		1+ /* for repos.list */
-		len(cfg.Gitolite))
+		len(cfg.Gitolite)+
+		len(cfg.Other))
 
 	for _, c := range cfg.Github {
 		repoSources = append(repoSources, GitHub{c})
@@ -48,6 +50,9 @@ func CloneURLToRepoName(cloneURL string) (repoName api.RepoName, err error) {
 	for _, c := range cfg.Gitlab {
 		repoSources = append(repoSources, GitLab{c})
 	}
+	for _, c := range cfg.Gitea {
+		repoSources = append(repoSources, Gitea{c})
+	}
 	for _, c := range cfg.BitbucketServer {
 		repoSources = append(repoSources, BitbucketServer{c})
 	}
@@ -58,6 +63,9 @@ func CloneURLToRepoName(cloneURL string) (repoName api.RepoName, err error) {
 	for _, c := range cfg.Gitolite {
 		repoSources = append(repoSources, Gitolite{c})
 	}
+	for _, c := range cfg.Other {
+		repoSources = append(repoSources, Other{c})
+	}
 	for _, ch := range repoSources {
 		repoName, err := ch.cloneURLToRepoName(cloneURL)
 		if err != nil {
@@ -0,0 +1,44 @@
+package reposource
+
+import (
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+type Other struct {
+	*schema.OtherExternalServiceConnection
+}
+
+var _ repoSource = Other{}
+
+func (c Other) cloneURLToRepoName(cloneURL string) (repoName api.RepoName, err error) {
+	parsedCloneURL, baseURL, match, err := parseURLs(cloneURL, c.Url)
+	if err != nil {
+		return "", err
+	}
+	if !match {
+		return "", nil
+	}
+
+	// Only repositories below the path of the URL are on this host.
+	path := "/" + strings.TrimPrefix(parsedCloneURL.Path, "/")
+	if !strings.HasPrefix(path, baseURL.Path) {
+		return "", nil
+	}
+	return OtherRepoName(c.RepositoryPathPattern, baseURL.Hostname(), strings.TrimPrefix(path, baseURL.Path)), nil
+}
+
+// OtherRepoName returns the Sourcegraph repository name for the repository at
+// path (relative to the URL of the Git host).
+func OtherRepoName(repositoryPathPattern, host, path string) api.RepoName {
+	if repositoryPathPattern == "" {
+		repositoryPathPattern = "{host}/{repo}"
+	}
+
+	return api.RepoName(strings.NewReplacer(
+		"{host}", host,
+		"{repo}", strings.TrimSuffix(strings.Trim(path, "/"), ".git"),
+	).Replace(repositoryPathPattern))
+}
@@ -0,0 +1,48 @@
+package reposource
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestOther_cloneURLToRepoName(t *testing.T) {
+	var tests = []struct {
+		conn schema.OtherExternalServiceConnection
+		urls []urlToRepoName
+	}{{
+		conn: schema.OtherExternalServiceConnection{
+			Url: "https://git.example.com/cgit/",
+		},
+		urls: []urlToRepoName{
+			{"https://git.example.com/cgit/myrepo.git", "git.example.com/myrepo"},
+			{"https://git.example.com/cgit/myteam/myrepo", "git.example.com/myteam/myrepo"},
+
+			{"https://git.example.com/other/myrepo.git", ""},
+			{"https://asdf.com/cgit/myrepo.git", ""},
+		},
+	}, {
+		conn: schema.OtherExternalServiceConnection{
+			Url:                   "ssh://git@git.example.com/srv/git",
+			RepositoryPathPattern: "git/{repo}",
+		},
+		urls: []urlToRepoName{
+			{"ssh://git@git.example.com/srv/git/myteam/myrepo.git", "git/myteam/myrepo"},
+			{"git@git.example.com:/srv/git/myrepo.git", "git/myrepo"},
+
+			{"ssh://git@git.example.com/home/myrepo.git", ""},
+		},
+	}}
+
+	for _, test := range tests {
+		for _, u := range test.urls {
+			repoName, err := Other{&test.conn}.cloneURLToRepoName(u.cloneURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.repoName != string(repoName) {
+				t.Errorf("expected %q but got %q for clone URL %q (connection: %+v)", u.repoName, repoName, u.cloneURL, test.conn)
+			}
+		}
+	}
+}
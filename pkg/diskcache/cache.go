@@ -73,10 +73,7 @@ func (s *Store) Open(ctx context.Context, key string, fetcher Fetcher) (file *Fi
 		return nil, errors.New("diskcache.Store.Dir must be set")
 	}
 
-	// path uses a sha256 hash of the key since we want to use it for the
-	// disk name.
-	h := sha256.Sum256([]byte(key))
-	path := filepath.Join(s.Dir, hex.EncodeToString(h[:])) + ".zip"
+	path := s.path(key)
 	span.LogKV("key", key, "path", path)
 
 	// First do a fast-path, assume already on disk
@@ -116,6 +113,30 @@ func (s *Store) Open(ctx context.Context, key string, fetcher Fetcher) (file *Fi
 	}
 }
 
+// OpenIfExists opens the file for key if it is already in the local cache.
+// Unlike Open it never fetches. If the key is not in the cache, the returned
+// error satisfies os.IsNotExist.
+func (s *Store) OpenIfExists(key string) (*File, error) {
+	if s.Dir == "" {
+		return nil, errors.New("diskcache.Store.Dir must be set")
+	}
+	path := s.path(key)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// Update modified time, since this counts as a use of the item.
+	touch(path)
+	return &File{File: f, Path: path}, nil
+}
+
+// path returns the path on disk of the item for key. It uses a sha256 hash of
+// the key since we want to use it for the disk name.
+func (s *Store) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(h[:])) + ".zip"
+}
+
 func doFetch(ctx context.Context, path string, fetcher Fetcher) (file *File, err error) {
 	// We have to grab the lock for this key, so we can fetch or wait for
 	// someone else to finish fetching.
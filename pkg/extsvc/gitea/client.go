@@ -0,0 +1,114 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/metrics"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+var requestCounter = metrics.NewRequestCounter("gitea", "Total number of requests sent to the Gitea API.")
+
+// Client is a Gitea (or Gogs) API client.
+type Client struct {
+	baseURL    *url.URL     // base URL of the API; e.g., https://gitea.example.com/api/v1/
+	token      string       // an access token to authenticate requests, if set
+	httpClient *http.Client // the HTTP client to use
+}
+
+// NewClient creates a new Gitea API client with an optional access token to authenticate requests.
+//
+// The URL must point to the base URL of the Gitea or Gogs instance (e.g., https://gitea.example.com).
+func NewClient(baseURL *url.URL, token string, transport http.RoundTripper) *Client {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	transport = requestCounter.Transport(transport, func(u *url.URL) string {
+		// The 3rd component of the Path (/api/v1/XYZ) mostly maps to the type of API
+		// request we are making.
+		var category string
+		if parts := strings.SplitN(u.Path, "/", 5); len(parts) >= 4 {
+			category = parts[3]
+		}
+		return category
+	})
+
+	return &Client{
+		baseURL:    baseURL.ResolveReference(&url.URL{Path: path.Join(baseURL.Path, "api/v1") + "/"}),
+		token:      token,
+		httpClient: &http.Client{Transport: transport},
+	}
+}
+
+func (c *Client) do(ctx context.Context, req *http.Request, result interface{}) (responseHeader http.Header, err error) {
+	req.URL = c.baseURL.ResolveReference(req.URL)
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token) // supported by both Gitea and Gogs
+	}
+
+	var resp *http.Response
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Gitea")
+	span.SetTag("URL", req.URL.String())
+	defer func() {
+		if err != nil {
+			span.SetTag("error", err.Error())
+		}
+		if resp != nil {
+			span.SetTag("status", resp.Status)
+		}
+		span.Finish()
+	}()
+
+	resp, err = ctxhttp.Do(ctx, c.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Wrap(httpError(resp.StatusCode), fmt.Sprintf("unexpected response from Gitea API (%s)", req.URL))
+	}
+
+	return resp.Header, json.NewDecoder(resp.Body).Decode(result)
+}
+
+type httpError int
+
+func (err httpError) Error() string {
+	return fmt.Sprintf("HTTP error status %d", err)
+}
+
+// HTTPErrorCode returns err's HTTP status code, if it is an HTTP error from
+// this package. Otherwise it returns 0.
+func HTTPErrorCode(err error) int {
+	e, ok := err.(httpError)
+	if !ok {
+		// Try one level deeper.
+		err = errors.Cause(err)
+		e, ok = err.(httpError)
+	}
+	if ok {
+		return int(e)
+	}
+	return 0
+}
+
+// ErrNotFound is when the requested Gitea repository is not found.
+var ErrNotFound = errors.New("Gitea repository not found")
+
+// IsNotFound reports whether err is ErrNotFound or an HTTP 404 from the Gitea API.
+func IsNotFound(err error) bool {
+	if err == ErrNotFound || errors.Cause(err) == ErrNotFound {
+		return true
+	}
+	return HTTPErrorCode(err) == http.StatusNotFound
+}
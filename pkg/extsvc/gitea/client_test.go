@@ -0,0 +1,143 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// newFixtureServer returns a server that serves 3 repositories from the user/repos endpoint (using
+// Link headers) and the repos/search endpoint (wrapped, without Link headers), requiring the
+// access token "t".
+func newFixtureServer(t *testing.T) *httptest.Server {
+	all := []string{
+		`{"id":1,"name":"a","full_name":"o/a","fork":true}`,
+		`{"id":2,"name":"b","full_name":"o/b","archived":true}`,
+		`{"id":3,"name":"c","full_name":"o/c","private":true}`,
+	}
+	page := func(r *http.Request) []string {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		p, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if p < 1 {
+			p = 1
+		}
+		start, end := (p-1)*limit, p*limit
+		if start > len(all) {
+			start = len(all)
+		}
+		if end > len(all) {
+			end = len(all)
+		}
+		return all[start:end]
+	}
+	join := func(items []string) string {
+		s := "["
+		for i, item := range items {
+			if i > 0 {
+				s += ","
+			}
+			s += item
+		}
+		return s + "]"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/user/repos", func(w http.ResponseWriter, r *http.Request) {
+		items := page(r)
+		if p, _ := strconv.Atoi(r.URL.Query().Get("page")); p < 2 {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/api/v1/user/repos?limit=2&page=2>; rel="next"`, r.Host))
+		}
+		fmt.Fprint(w, join(items))
+	})
+	mux.HandleFunc("/api/v1/repos/search", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"ok":true,"data":%s}`, join(page(r)))
+	})
+	mux.HandleFunc("/api/v1/repos/o/a", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, all[0])
+	})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "token t" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func TestClient_ListRepositories(t *testing.T) {
+	ts := newFixtureServer(t)
+	defer ts.Close()
+	baseURL, _ := url.Parse(ts.URL)
+	c := NewClient(baseURL, "t", nil)
+
+	for _, urlStr := range []string{"user/repos?limit=2", "repos/search?limit=2"} {
+		t.Run(urlStr, func(t *testing.T) {
+			var names []string
+			next := &urlStr
+			for pages := 0; next != nil; pages++ {
+				if pages > 3 {
+					t.Fatal("too many pages")
+				}
+				repos, nextPageURL, err := c.ListRepositories(context.Background(), *next)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, repo := range repos {
+					names = append(names, repo.FullName)
+				}
+				next = nextPageURL
+			}
+			if want := []string{"o/a", "o/b", "o/c"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("got %v, want %v", names, want)
+			}
+		})
+	}
+
+	if _, _, err := NewClient(baseURL, "x", nil).ListRepositories(context.Background(), "user/repos"); HTTPErrorCode(err) != http.StatusUnauthorized {
+		t.Errorf("got error %v, want HTTP 401", err)
+	}
+}
+
+func TestClient_GetRepository(t *testing.T) {
+	ts := newFixtureServer(t)
+	defer ts.Close()
+	baseURL, _ := url.Parse(ts.URL)
+	c := NewClient(baseURL, "t", nil)
+
+	repo, err := c.GetRepository(context.Background(), "o", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&Repository{ID: 1, Name: "a", FullName: "o/a", Fork: true}); !reflect.DeepEqual(repo, want) {
+		t.Errorf("got %+v, want %+v", repo, want)
+	}
+
+	if _, err := c.GetRepository(context.Background(), "o", "missing"); !IsNotFound(err) {
+		t.Errorf("got error %v, want not found", err)
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	tests := map[string]struct {
+		urlStr string
+		n      int
+		want   string
+	}{
+		"full first page": {"repos/search?limit=2", 2, "repos/search?limit=2&page=2"},
+		"full later page": {"repos/search?limit=2&page=2", 2, "repos/search?limit=2&page=3"},
+		"partial page":    {"repos/search?limit=2&page=2", 1, ""},
+		"no limit":        {"user/repos", 30, ""},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := nextPage(test.urlStr, test.n); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
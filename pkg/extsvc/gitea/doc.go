@@ -0,0 +1,3 @@
+// Package gitea implements a Gitea API client. It also works with Gogs, which Gitea was forked from
+// and whose API is a subset of Gitea's.
+package gitea
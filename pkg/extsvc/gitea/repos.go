@@ -0,0 +1,144 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/peterhellberg/link"
+)
+
+// Repository is a Gitea (or Gogs) repository.
+type Repository struct {
+	ID          int64  `json:"id"`
+	Owner       User   `json:"owner"`
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"` // e.g., "owner/name"
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+	Fork        bool   `json:"fork"`
+	Archived    bool   `json:"archived"` // always false on Gogs, which has no archiving
+	Size        int64  `json:"size"`     // in KB
+	Stars       int    `json:"stars_count"`
+	HTMLURL     string `json:"html_url"`
+	CloneURL    string `json:"clone_url"`
+	SSHURL      string `json:"ssh_url"`
+}
+
+// User is a Gitea user or organization.
+type User struct {
+	Login    string `json:"login"`
+	Username string `json:"username"` // Gogs and older versions of Gitea only set this
+}
+
+// MockGetRepository, if non-nil, will be called instead of every invocation of Client.GetRepository.
+var MockGetRepository func(ctx context.Context, owner, name string) (*Repository, error)
+
+// MockGetRepositoryByID, if non-nil, will be called instead of every invocation of Client.GetRepositoryByID.
+var MockGetRepositoryByID func(ctx context.Context, id int64) (*Repository, error)
+
+// MockListRepositories, if non-nil, will be called instead of every invocation of Client.ListRepositories.
+var MockListRepositories func(ctx context.Context, urlStr string) (repos []*Repository, nextPageURL *string, err error)
+
+// GetRepository gets the repository with the given owner and name.
+func (c *Client) GetRepository(ctx context.Context, owner, name string) (*Repository, error) {
+	if MockGetRepository != nil {
+		return MockGetRepository(ctx, owner, name)
+	}
+
+	req, err := http.NewRequest("GET", "repos/"+url.PathEscape(owner)+"/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.getRepository(ctx, req)
+}
+
+// GetRepositoryByID gets the repository with the given ID. It is not supported by Gogs.
+func (c *Client) GetRepositoryByID(ctx context.Context, id int64) (*Repository, error) {
+	if MockGetRepositoryByID != nil {
+		return MockGetRepositoryByID(ctx, id)
+	}
+
+	req, err := http.NewRequest("GET", "repositories/"+strconv.FormatInt(id, 10), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.getRepository(ctx, req)
+}
+
+func (c *Client) getRepository(ctx context.Context, req *http.Request) (*Repository, error) {
+	var repo Repository
+	if _, err := c.do(ctx, req, &repo); err != nil {
+		if IsNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// ListRepositories lists the repositories returned by the API endpoint urlStr (relative to the API
+// base URL), e.g. "user/repos?limit=50", "orgs/foo/repos?limit=50" or "repos/search?limit=50".
+//
+// Newer versions of Gitea report the next page in the Link header. For versions that don't (and for
+// Gogs), the page query parameter is incremented when urlStr has a limit query parameter and the
+// page is full. Callers must stop if a page yields no new repositories, because some Gogs endpoints
+// ignore the page query parameter.
+func (c *Client) ListRepositories(ctx context.Context, urlStr string) (repos []*Repository, nextPageURL *string, err error) {
+	if MockListRepositories != nil {
+		return MockListRepositories(ctx, urlStr)
+	}
+
+	req, err := http.NewRequest("GET", urlStr, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var raw json.RawMessage
+	respHeader, err := c.do(ctx, req, &raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	if raw = bytes.TrimSpace(raw); len(raw) > 0 && raw[0] == '{' {
+		// The search endpoint wraps the results: {"ok": true, "data": [...]}.
+		var result struct {
+			Data []*Repository `json:"data"`
+		}
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, nil, err
+		}
+		repos = result.Data
+	} else if err := json.Unmarshal(raw, &repos); err != nil {
+		return nil, nil, err
+	}
+
+	if l := link.Parse(respHeader.Get("Link"))["next"]; l != nil {
+		nextPageURL = &l.URI
+	} else if next := nextPage(urlStr, len(repos)); next != "" {
+		nextPageURL = &next
+	}
+	return repos, nextPageURL, nil
+}
+
+// nextPage returns urlStr with its page query parameter incremented if n (the number of
+// results on the page) is its limit query parameter. Otherwise it returns "".
+func nextPage(urlStr string, n int) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 || n < limit {
+		return ""
+	}
+	page, err := strconv.Atoi(q.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	q.Set("page", strconv.Itoa(page+1))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -32,6 +32,8 @@ type Repository struct {
 	IsPrivate        bool   // whether the repository is private
 	IsFork           bool   // whether the repository is a fork of another repository
 	IsArchived       bool   // whether the repository is archived on the code host
+	DiskUsage        int    // the size of the repository in KB, or 0 if unknown
+	StargazerCount   int    // the number of stars of the repository, or 0 if unknown
 	ViewerPermission string // ADMIN, WRITE, READ, or empty if unknown. Only the graphql api populates this.
 }
 
@@ -48,6 +50,8 @@ fragment RepositoryFields on Repository {
 	isPrivate
 	isFork
 	isArchived
+	diskUsage
+	stargazerCount
 	viewerPermission
 }
 	`
@@ -55,6 +59,7 @@ fragment RepositoryFields on Repository {
 	// Some fields are not yet available on GitHub Enterprise yet
 	// or are available but too new to expect our customers to have updated:
 	// - viewerPermission
+	// - stargazerCount
 	return `
 fragment RepositoryFields on Repository {
 	id
@@ -64,6 +69,7 @@ fragment RepositoryFields on Repository {
 	isPrivate
 	isFork
 	isArchived
+	diskUsage
 }
 	`
 }
@@ -228,6 +234,8 @@ type restRepository struct {
 	Private     bool
 	Fork        bool
 	Archived    bool
+	Size        int // in KB
+	Stargazers  int `json:"stargazers_count"`
 }
 
 // getRepositoryFromAPI attempts to fetch a repository from the GitHub API without use of the redis cache.
@@ -247,14 +255,16 @@ func (c *Client) getRepositoryFromAPI(ctx context.Context, owner, name string) (
 // to a standard format.
 func convertRestRepo(restRepo restRepository) *Repository {
 	return &Repository{
-		ID:            restRepo.ID,
-		DatabaseID:    restRepo.DatabaseID,
-		NameWithOwner: restRepo.FullName,
-		Description:   restRepo.Description,
-		URL:           restRepo.HTMLURL,
-		IsPrivate:     restRepo.Private,
-		IsFork:        restRepo.Fork,
-		IsArchived:    restRepo.Archived,
+		ID:             restRepo.ID,
+		DatabaseID:     restRepo.DatabaseID,
+		NameWithOwner:  restRepo.FullName,
+		Description:    restRepo.Description,
+		URL:            restRepo.HTMLURL,
+		IsPrivate:      restRepo.Private,
+		IsFork:         restRepo.Fork,
+		IsArchived:     restRepo.Archived,
+		DiskUsage:      restRepo.Size,
+		StargazerCount: restRepo.Stargazers,
 	}
 }
 
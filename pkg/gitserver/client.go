@@ -10,6 +10,7 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -56,6 +57,7 @@ var DefaultClient = &Client{
 	// which service is making the request (excluding requests proxied via the
 	// frontend internal API)
 	UserAgent: filepath.Base(os.Args[0]),
+	Replicas:  conf.SrcGitServerReplicas,
 }
 
 func init() {
@@ -123,24 +125,53 @@ type Client struct {
 	// UserAgent is a string identifing who the client is. It will be logged in
 	// the telemetry in gitserver.
 	UserAgent string
+
+	// Replicas is the number of gitservers each repository is cloned onto.
+	// The replicas of a repository are the gitservers following its primary
+	// gitserver in Addrs. Requests fail over to the replicas if the primary
+	// is unreachable. 0 is treated as 1 (no replication).
+	Replicas int
 }
 
 // addrForRepo returns the gitserver address to use for the given repo name.
 func (c *Client) addrForRepo(ctx context.Context, repo api.RepoName) string {
+	return c.AddrsForRepo(ctx, repo)[0]
+}
+
+// AddrsForRepo returns the addresses of the gitservers the given repo is
+// cloned onto: its primary gitserver first, followed by its replicas.
+func (c *Client) AddrsForRepo(ctx context.Context, repo api.RepoName) []string {
 	repo = protocol.NormalizeRepo(repo) // in case the caller didn't already normalize it
-	return c.addrForKey(ctx, string(repo))
+	return c.addrsForKey(ctx, string(repo))
 }
 
 // addrForKey returns the gitserver address to use for the given string key,
 // which is hashed for sharding purposes.
 func (c *Client) addrForKey(ctx context.Context, key string) string {
+	return c.addrsForKey(ctx, key)[0]
+}
+
+// addrsForKey returns the gitserver addresses to use for the given string
+// key: the one it hashes to, followed by the next c.Replicas-1 addresses.
+func (c *Client) addrsForKey(ctx context.Context, key string) []string {
 	addrs := c.Addrs(ctx)
 	if len(addrs) == 0 {
 		panic("unexpected state: no gitserver addresses")
 	}
 	sum := md5.Sum([]byte(key))
 	serverIndex := binary.BigEndian.Uint64(sum[:]) % uint64(len(addrs))
-	return addrs[serverIndex]
+
+	n := c.Replicas
+	if n < 1 {
+		n = 1
+	} else if n > len(addrs) {
+		n = len(addrs)
+	}
+	replicas := make([]string, n)
+	for i := range replicas {
+		replicas[i] = addrs[(serverIndex+uint64(i))%uint64(len(addrs))]
+	}
+	return replicas
 }
 
 func (c *Cmd) sendExec(ctx context.Context) (_ io.ReadCloser, _ http.Header, errRes error) {
@@ -187,6 +218,9 @@ func (c *Cmd) sendExec(ctx context.Context) (_ io.ReadCloser, _ http.Header, err
 			return nil, nil, err
 		}
 		resp.Body.Close()
+		if payload.QuotaExceeded != "" {
+			return nil, nil, &RepoQuotaExceededErr{Repo: repoName, Reason: payload.QuotaExceeded}
+		}
 		return nil, nil, &vcs.RepoNotExistError{Repo: repoName, CloneInProgress: payload.CloneInProgress, CloneProgress: payload.CloneProgress}
 
 	default:
@@ -333,6 +367,13 @@ func (c *Client) ListGitolite(ctx context.Context, gitoliteHost string) ([]strin
 	return doListOne(ctx, "?gitolite="+url.QueryEscape(gitoliteHost), c.addrForKey(ctx, gitoliteHost))
 }
 
+// ListOther lists the repository paths discovered on the other Git host (see
+// schema.OtherExternalServiceConnection) with the given URL.
+func (c *Client) ListOther(ctx context.Context, hostURL string) ([]string, error) {
+	// As with Gitolite, only a single gitserver should crawl the host.
+	return doListOne(ctx, "?other="+url.QueryEscape(hostURL), c.addrForKey(ctx, hostURL))
+}
+
 // ListCloned lists all cloned repositories
 func (c *Client) ListCloned(ctx context.Context) ([]string, error) {
 	var (
@@ -397,7 +438,24 @@ func (c *Client) RequestRepoUpdate(ctx context.Context, repo Repo, since time.Du
 		URL:   repo.URL,
 		Since: since,
 	}
-	resp, err := c.httpPost(ctx, repo.Name, "repo-update", req)
+
+	// Keep the replicas up to date too. This is also what clones the repo
+	// onto the replicas in the first place. Their responses are not
+	// interesting to the caller, so we only log their errors.
+	addrs := c.AddrsForRepo(ctx, repo.Name)
+	for _, addr := range addrs[1:] {
+		addr := addr
+		go func() {
+			resp, err := c.httpPostAddr(ctx, addr, "repo-update", req)
+			if err != nil {
+				log15.Warn("failed to update gitserver replica", "repo", repo.Name, "addr", addr, "error", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+
+	resp, err := c.httpPostAddr(ctx, addrs[0], "repo-update", req)
 	if err != nil {
 		return nil, err
 	}
@@ -479,6 +537,25 @@ func (e *RepoNotCloneableErr) Error() string {
 	return fmt.Sprintf("repo not found (name=%s url=%s notfound=%v) because %s", e.repo.Name, e.repo.URL, e.notFound, e.reason)
 }
 
+// RepoQuotaExceededErr is the error that happens when a repository is not
+// cloned because it is larger than the gitMaxRepoSizeMB quota in site
+// configuration.
+type RepoQuotaExceededErr struct {
+	Repo   api.RepoName
+	Reason string
+}
+
+// NotFound returns true, so that a repository which is not cloned because it
+// exceeds the quota is treated like a missing repository (e.g., searches skip
+// it instead of failing).
+func (e *RepoQuotaExceededErr) NotFound() bool {
+	return true
+}
+
+func (e *RepoQuotaExceededErr) Error() string {
+	return fmt.Sprintf("repo not cloned (name=%s) because %s", e.Repo, e.Reason)
+}
+
 func (c *Client) IsRepoCloned(ctx context.Context, repo api.RepoName) (bool, error) {
 	req := &protocol.IsRepoClonedRequest{
 		Repo: repo,
@@ -518,12 +595,47 @@ func (c *Client) RepoInfo(ctx context.Context, repo api.RepoName) (*protocol.Rep
 	return info, err
 }
 
+// FetchHistory returns the most recent fetches of the repository from its
+// remote, newest first. A repository which is not cloned has no fetch
+// history.
+func (c *Client) FetchHistory(ctx context.Context, repo api.RepoName) ([]*protocol.RepoFetch, error) {
+	req := &protocol.FetchHistoryRequest{
+		Repo: repo,
+	}
+	resp, err := c.httpPost(ctx, repo, "fetch-history", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 200))
+		return nil, &url.Error{URL: resp.Request.URL.String(), Op: "FetchHistory", Err: fmt.Errorf("FetchHistory: http status %d: %s", resp.StatusCode, body)}
+	}
+
+	var history protocol.FetchHistoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		return nil, err
+	}
+	return history.Fetches, nil
+}
+
 // Remove removes the repository clone from gitserver.
 func (c *Client) Remove(ctx context.Context, repo api.RepoName) error {
 	req := &protocol.RepoDeleteRequest{
 		Repo: repo,
 	}
-	resp, err := c.httpPost(ctx, repo, "delete", req)
+	// The repo must be removed from the replicas too, otherwise it would be
+	// served again by a failover.
+	for _, addr := range c.AddrsForRepo(ctx, repo) {
+		if err := c.remove(ctx, addr, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) remove(ctx context.Context, addr string, req *protocol.RepoDeleteRequest) error {
+	resp, err := c.httpPostAddr(ctx, addr, "delete", req)
 	if err != nil {
 		return err
 	}
@@ -536,7 +648,46 @@ func (c *Client) Remove(ctx context.Context, repo api.RepoName) error {
 	return nil
 }
 
+// httpPost sends a request for repo to its primary gitserver. If the
+// gitserver can't be connected to, the request is retried on the replicas of
+// repo in order.
 func (c *Client) httpPost(ctx context.Context, repo api.RepoName, method string, payload interface{}) (resp *http.Response, err error) {
+	addrs := c.AddrsForRepo(ctx, repo)
+	for i, addr := range addrs {
+		resp, err = c.httpPostAddr(ctx, addr, method, payload)
+		if err == nil || i == len(addrs)-1 || ctx.Err() != nil || !isConnectionError(err) {
+			break
+		}
+		log15.Warn("gitserver unreachable, failing over to a replica", "repo", repo, "addr", addr, "replica", addrs[i+1], "error", err)
+		replicaFailovers.Inc()
+	}
+	return resp, err
+}
+
+// isConnectionError reports whether err is an error connecting to a
+// gitserver, as opposed to an error of an established request. Only
+// requests which failed to connect are safe to retry on a replica.
+func isConnectionError(err error) bool {
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	operr, ok := err.(*net.OpError)
+	return ok && operr.Op == "dial"
+}
+
+var replicaFailovers = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "gitserver",
+	Name:      "client_replica_failovers",
+	Help:      "Times that a request was retried on a replica because a gitserver was unreachable",
+})
+
+func init() {
+	prometheus.MustRegister(replicaFailovers)
+}
+
+// httpPostAddr sends a request to the gitserver at addr.
+func (c *Client) httpPostAddr(ctx context.Context, addr, method string, payload interface{}) (resp *http.Response, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Client.httpPost")
 	defer func() {
 		if err != nil {
@@ -545,13 +696,13 @@ func (c *Client) httpPost(ctx context.Context, repo api.RepoName, method string,
 		}
 		span.Finish()
 	}()
+	span.SetTag("addr", addr)
 
 	reqBody, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
 
-	addr := c.addrForRepo(ctx, repo)
 	req, err := http.NewRequest("POST", "http://"+addr+"/"+method, bytes.NewReader(reqBody))
 	if err != nil {
 		return nil, err
@@ -576,6 +727,11 @@ func (c *Client) httpPost(ctx context.Context, repo api.RepoName, method string,
 	return ctxhttp.Do(ctx, c.HTTPClient, req)
 }
 
+// UploadPack proxies a request of git's smart HTTP protocol for fetching from
+// the repository to gitserver: a GET request for the ref advertisement
+// (/info/refs?service=git-upload-pack) or a POST request to git-upload-pack.
+// The caller is responsible for checking that the user may read the
+// repository.
 func (c *Client) UploadPack(repoName api.RepoName, w http.ResponseWriter, r *http.Request) {
 	repoName = protocol.NormalizeRepo(repoName)
 	addr := c.addrForRepo(r.Context(), repoName)
@@ -586,9 +742,15 @@ func (c *Client) UploadPack(repoName api.RepoName, w http.ResponseWriter, r *htt
 		return
 	}
 
+	// The response's Content-Type is gitserver's.
+	w.Header().Del("Content-Type")
+
 	(&httputil.ReverseProxy{
 		Director: func(r *http.Request) {
 			r.URL = u
+			// gitserver doesn't need the user's credentials.
+			r.Header.Del("Authorization")
+			r.Header.Del("Cookie")
 		},
 		ErrorLog: uploadPackErrorLog,
 	}).ServeHTTP(w, r)
@@ -56,6 +56,7 @@ type RepoUpdateResponse struct {
 	LastFetched     *time.Time
 	LastChanged     *time.Time
 	Error           string // an error reported by the update, as opposed to a protocol error
+	QuotaExceeded   bool   // whether Error is because the repository exceeds the gitMaxRepoSizeMB quota
 	QueueCap        int    // size of the clone queue
 	QueueLen        int    // current clone operations
 	// Following items likely provided only if the request specified waiting.
@@ -69,6 +70,10 @@ type NotFoundPayload struct {
 
 	// CloneProgress is a progress message from the running clone command.
 	CloneProgress string `json:"cloneProgress,omitempty"`
+
+	// QuotaExceeded is a message explaining that the repository is not
+	// cloned because it is larger than the gitMaxRepoSizeMB quota.
+	QuotaExceeded string `json:"quotaExceeded,omitempty"`
 }
 
 // IsRepoCloneableRequest is a request to determine if a repo is cloneable.
@@ -117,6 +122,64 @@ type RepoInfoResponse struct {
 	// recloned automatically, so this time is likely to move forward
 	// periodically.
 	CloneTime *time.Time
+
+	// Size is the disk usage of the repository as of gitserver's last
+	// janitor run (or its clone, if more recent), or nil if it is not cloned
+	// or its size is not known yet.
+	Size *RepoSize
+
+	// QuotaExceeded is a message explaining that the repository is not
+	// cloned or updated because it is larger than the gitMaxRepoSizeMB
+	// quota, or empty if it is not.
+	QuotaExceeded string
+}
+
+// RepoSize is the disk usage of a repository on gitserver, as reported by
+// `git count-objects`.
+type RepoSize struct {
+	Repo api.RepoName
+
+	TotalBytes    int64      // the size of all files in the repository's Git directory
+	LooseObjects  int64      // the number of loose objects
+	LooseBytes    int64      // the size of the loose objects
+	PackedObjects int64      // the number of objects in packs
+	Packs         int64      // the number of packs
+	PackedBytes   int64      // the size of the packs
+	GarbageBytes  int64      // the size of files in the object directory that are not valid objects or packs
+	LastGC        *time.Time // when the objects were last repacked (by gc or repack), if known
+}
+
+// RepoSizesResponse is the response of the repo-sizes endpoint, which lists
+// the disk usage of the repositories on a gitserver, largest first.
+type RepoSizesResponse struct {
+	Repos []*RepoSize
+
+	// ComputedAt is when the sizes were last computed.
+	ComputedAt time.Time
+}
+
+// FetchHistoryRequest is a request for the fetch history of a repository on
+// gitserver.
+type FetchHistoryRequest struct {
+	// Repo is the repository to get the fetch history of.
+	Repo api.RepoName
+}
+
+// FetchHistoryResponse is the response to a FetchHistoryRequest.
+type FetchHistoryResponse struct {
+	// Fetches are the most recent fetches of the repository, newest first.
+	Fetches []*RepoFetch
+}
+
+// RepoFetch is a record of a fetch of a repository from its remote.
+type RepoFetch struct {
+	Start       time.Time
+	Duration    time.Duration
+	ExitCode    int    // the exit code of git fetch, or -1 if it was killed (e.g. because it timed out)
+	Error       string // the error of the fetch, or empty if it succeeded
+	Output      string // the end of the output of git fetch, with credentials and the remote URL redacted
+	RefsChanged int    // the number of refs which the fetch created, updated or deleted
+	HeadMoved   bool   // whether the commit of HEAD changed
 }
 
 // CreateCommitFromPatchRequest is the request information needed for creating
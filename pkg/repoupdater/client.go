@@ -5,7 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	"github.com/opentracing/opentracing-go"
@@ -158,6 +161,30 @@ func (c *Client) EnqueueRepoUpdate(ctx context.Context, repo gitserver.Repo) err
 	return nil
 }
 
+// Webhook proxies a push webhook of a code host (kind is "github", "gitlab"
+// or "bitbucket-server") to repo-updater, which authenticates it with the
+// webhook secret of the code host connection.
+func (c *Client) Webhook(kind string, w http.ResponseWriter, r *http.Request) {
+	u, err := url.Parse(c.URL + "/webhooks/" + kind)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	(&httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL = u
+			r.Host = u.Host
+			// repo-updater doesn't need the user's credentials.
+			r.Header.Del("Authorization")
+			r.Header.Del("Cookie")
+		},
+		ErrorLog: webhookErrorLog,
+	}).ServeHTTP(w, r)
+}
+
+var webhookErrorLog = log.New(env.DebugOut, "webhook proxy: ", log.LstdFlags)
+
 func (c *Client) httpPost(ctx context.Context, method string, payload interface{}) (resp *http.Response, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Client.httpPost")
 	defer func() {
@@ -20,6 +20,18 @@ type Request struct {
 	// "599cba5e7b6137d46ddf58fb1765f5d928e69604"
 	Commit api.CommitID
 
+	// BaseCommit if non-empty makes this a diff search: both Commit and
+	// BaseCommit are searched, and only the matches which were added in
+	// Commit or removed since BaseCommit are returned. Like Commit, it must be
+	// resolved.
+	BaseCommit api.CommitID
+
+	// Paths if non-empty restricts the search to these files of Commit, so
+	// that searcher only needs to fetch them. It is used to search only the
+	// files of a commit which were not already searched in other revisions.
+	// It may not be used with BaseCommit.
+	Paths []string
+
 	PatternInfo
 
 	// The amount of time to wait for a repo archive to fetch.
@@ -39,6 +51,11 @@ type Request struct {
 	// The deadline for the search request.
 	// It is parsed with time.Time.UnmarshalText.
 	Deadline string
+
+	// Stream if true makes searcher respond with newline-delimited JSON
+	// StreamEvents instead of a single Response. Each FileMatch is written
+	// as soon as it is found, and the last event has Done set.
+	Stream bool
 }
 
 // GitserverRepo returns the repository information necessary to perform gitserver requests.
@@ -57,6 +74,11 @@ type PatternInfo struct {
 	// IsWordMatch if true will only match the pattern at word boundaries.
 	IsWordMatch bool
 
+	// IsStructuralPat if true will treat the Pattern as a structural search
+	// pattern, in which holes like :[x] match text with balanced
+	// delimiters. IsRegExp and IsWordMatch are ignored.
+	IsStructuralPat bool
+
 	// IsCaseSensitive if false will ignore the case of text and pattern
 	// when finding matches.
 	IsCaseSensitive bool
@@ -125,6 +147,29 @@ type Response struct {
 	DeadlineHit bool
 }
 
+// StreamEvent is a single line of a streaming search response (see
+// Request.Stream). Either Match is set, or Done is true and the remaining
+// fields describe how the search finished.
+type StreamEvent struct {
+	// Match is a file match found by the search.
+	Match *FileMatch `json:",omitempty"`
+
+	// Done is true for the last event in the stream.
+	Done bool `json:",omitempty"`
+
+	// LimitHit is true if the stream may not include all FileMatches because
+	// a match limit was hit. Only set when Done is true.
+	LimitHit bool `json:",omitempty"`
+
+	// DeadlineHit is true if the stream may not include all FileMatches
+	// because a deadline was hit. Only set when Done is true.
+	DeadlineHit bool `json:",omitempty"`
+
+	// Error is set if the search failed after the stream had started. Only
+	// set when Done is true.
+	Error string `json:",omitempty"`
+}
+
 // FileMatch is the struct used by vscode to receive search results
 type FileMatch struct {
 	Path        string
@@ -132,6 +177,11 @@ type FileMatch struct {
 
 	// LimitHit is true if LineMatches may not include all LineMatches.
 	LimitHit bool
+
+	// Removed is only set for diff searches (see Request.BaseCommit). If
+	// true, LineMatches are from BaseCommit and were removed in Commit.
+	// Otherwise they are from Commit and were added since BaseCommit.
+	Removed bool `json:",omitempty"`
 }
 
 // LineMatch is the struct used by vscode to receive search results for a line.
@@ -35,10 +35,29 @@ type SearchArgs struct {
 	// need to match to get included in the result
 	ExcludePattern string
 
-	// First indicates that only the first n symbols should be returned.
+	// First indicates that only the first n symbols should be returned. The
+	// symbols matching Query are sorted by Score, so they are the best n.
 	First int
+
+	// MatchMode is how Query is matched against symbol names. The zero
+	// value matches Query as a substring (or regexp, see IsRegExp).
+	MatchMode MatchMode `json:",omitempty"`
 }
 
+// MatchMode is a way of matching a query against symbol names.
+type MatchMode string
+
+const (
+	// MatchSubstring matches symbols whose name contains the query, or
+	// matches it if it is a regexp.
+	MatchSubstring MatchMode = ""
+
+	// MatchFuzzy matches symbols whose name contains the characters of the
+	// query in order, e.g. "hndlExec" matches "handleExec" and "NRC" matches
+	// "NewRepoCache". The query is never treated as a regexp.
+	MatchFuzzy MatchMode = "fuzzy"
+)
+
 // SearchResult is the result of a search on the symbols service.
 type SearchResult struct {
 	Symbols []Symbol // code symbols
@@ -57,4 +76,10 @@ type Symbol struct {
 	Pattern    string
 
 	FileLimited bool
+
+	// Score ranks the symbol as a result of a search, higher is better. It
+	// reflects how well the name matches the query, the kind of the symbol
+	// and where its file is, and is comparable across repositories so that
+	// their results can be merged.
+	Score float64 `json:",omitempty"`
 }
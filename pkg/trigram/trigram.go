@@ -0,0 +1,42 @@
+// Package trigram has the helpers shared by the trigram indexes of searcher
+// and symbols. A trigram is 3 consecutive bytes, ASCII lowercased, packed
+// into a uint32.
+package trigram
+
+// Of returns the trigram of the first 3 bytes of b.
+func Of(b []byte) uint32 {
+	return uint32(ToLowerASCII(b[0]))<<16 | uint32(ToLowerASCII(b[1]))<<8 | uint32(ToLowerASCII(b[2]))
+}
+
+// OfString returns the trigram of the first 3 bytes of s.
+func OfString(s string) uint32 {
+	return uint32(ToLowerASCII(s[0]))<<16 | uint32(ToLowerASCII(s[1]))<<8 | uint32(ToLowerASCII(s[2]))
+}
+
+// ToLowerASCII returns c lowercased if it is an ASCII uppercase letter, and
+// c otherwise.
+func ToLowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// IntersectSorted returns the elements in both a and b, which must be
+// sorted. It reuses a's storage.
+func IntersectSorted(a, b []int) []int {
+	out := a[:0]
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
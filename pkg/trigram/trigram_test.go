@@ -0,0 +1,37 @@
+package trigram
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	want := uint32('f')<<16 | uint32('o')<<8 | uint32('o')
+	if got := Of([]byte("FoOBar")); got != want {
+		t.Errorf("got %x, want %x", got, want)
+	}
+	if got := OfString("fOo"); got != want {
+		t.Errorf("got %x, want %x", got, want)
+	}
+	// Only ASCII letters are lowercased.
+	for _, c := range []byte{'_', '[', 0xc9} {
+		if got := ToLowerASCII(c); got != c {
+			t.Errorf("ToLowerASCII(%q) = %q, want it unchanged", c, got)
+		}
+	}
+}
+
+func TestIntersectSorted(t *testing.T) {
+	tests := []struct {
+		a, b, want []int
+	}{
+		{a: []int{1, 2, 3}, b: []int{2, 3, 4}, want: []int{2, 3}},
+		{a: []int{1, 5, 9}, b: []int{2, 6}, want: []int{}},
+		{a: []int{}, b: []int{1}, want: []int{}},
+	}
+	for _, test := range tests {
+		if got := IntersectSorted(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("IntersectSorted(%v, %v) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
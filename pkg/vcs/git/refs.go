@@ -247,6 +247,55 @@ func ListTags(ctx context.Context, repo gitserver.Repo) ([]*Tag, error) {
 	return tags, nil
 }
 
+// A Ref is a Git reference to a commit.
+type Ref struct {
+	// Name is the full name of the ref (e.g., "refs/heads/master").
+	Name string
+	// CommitID is the commit that the ref points to. For an annotated tag, it
+	// is the tagged commit (not the tag object).
+	CommitID api.CommitID
+}
+
+// ListRefs returns all refs in the repository which point to commits, sorted
+// by name.
+func ListRefs(ctx context.Context, repo gitserver.Repo) ([]*Ref, error) {
+	if Mocks.ListRefs != nil {
+		return Mocks.ListRefs()
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: ListRefs")
+	defer span.Finish()
+
+	cmd := gitserver.DefaultClient.Command("git", "for-each-ref", "--sort=refname", "--format=%(if)%(*objectname)%(then)%(*objectname)%00%(*objecttype)%(else)%(objectname)%00%(objecttype)%(end)%00%(refname)")
+	cmd.Repo = repo
+	out, err := cmd.CombinedOutput(ctx)
+	if err != nil {
+		if vcs.IsRepoNotExist(err) {
+			return nil, err
+		}
+		return nil, errors.WithMessage(err, fmt.Sprintf("git command %v failed (output: %q)", cmd.Args, out))
+	}
+
+	out = bytes.TrimSuffix(out, []byte("\n")) // remove trailing newline
+	if len(out) == 0 {
+		return nil, nil // no refs
+	}
+	lines := bytes.Split(out, []byte("\n"))
+	refs := make([]*Ref, 0, len(lines))
+	for _, line := range lines {
+		parts := bytes.SplitN(line, []byte("\x00"), 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid git for-each-ref output line: %q", line)
+		}
+		if ObjectType(parts[1]) != ObjectTypeCommit {
+			// E.g., a tag of a tree.
+			continue
+		}
+		refs = append(refs, &Ref{Name: string(parts[2]), CommitID: api.CommitID(parts[0])})
+	}
+	return refs, nil
+}
+
 type byteSlices [][]byte
 
 func (p byteSlices) Len() int           { return len(p) }
@@ -3,6 +3,7 @@ package git
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"fmt"
 	"os"
 	stdlibpath "path"
@@ -293,3 +294,92 @@ func lsTreeUncached(ctx context.Context, repo gitserver.Repo, commit api.CommitI
 
 	return fis, nil
 }
+
+// A BlobEntry is a file (blob) in a Git tree.
+type BlobEntry struct {
+	// Path is the full path of the file in the tree.
+	Path string
+	// OID is the OID of the file's blob, which is the same for all files with
+	// the same contents.
+	OID OID
+}
+
+// listBlobsCache caches the result of ListBlobs by repository and commit. The
+// tree of a commit never changes, and searches of multiple revisions list the
+// same commits (e.g., the tips of release branches) again and again.
+var (
+	listBlobsCacheMu sync.Mutex
+	listBlobsCache   = lru.New(20)
+)
+
+// ListBlobs returns all files (not directories or submodules) in the tree at
+// commit, recursively, sorted by path. The result is cached, so callers must
+// not modify it.
+func ListBlobs(ctx context.Context, repo gitserver.Repo, commit api.CommitID) ([]*BlobEntry, error) {
+	if Mocks.ListBlobs != nil {
+		return Mocks.ListBlobs(commit)
+	}
+
+	key := string(repo.Name) + ":" + string(commit)
+	listBlobsCacheMu.Lock()
+	v, ok := listBlobsCache.Get(key)
+	listBlobsCacheMu.Unlock()
+	if ok {
+		return v.([]*BlobEntry), nil
+	}
+	blobs, err := listBlobsUncached(ctx, repo, commit)
+	if err != nil {
+		return nil, err
+	}
+	listBlobsCacheMu.Lock()
+	listBlobsCache.Add(key, blobs)
+	listBlobsCacheMu.Unlock()
+	return blobs, nil
+}
+
+func listBlobsUncached(ctx context.Context, repo gitserver.Repo, commit api.CommitID) ([]*BlobEntry, error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: ListBlobs")
+	span.SetTag("Commit", commit)
+	defer span.Finish()
+
+	if err := checkSpecArgSafety(string(commit)); err != nil {
+		return nil, err
+	}
+	ensureAbsCommit(commit)
+
+	cmd := gitserver.DefaultClient.Command("git", "ls-tree", "-r", "-z", "--full-tree", string(commit))
+	cmd.Repo = repo
+	out, err := cmd.CombinedOutput(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("git command %v failed (output: %q)", cmd.Args, out))
+	}
+
+	var blobs []*BlobEntry
+	for _, line := range strings.Split(string(out), "\x00") {
+		if line == "" {
+			// The output ends with a NUL byte.
+			continue
+		}
+		tabPos := strings.IndexByte(line, '\t')
+		if tabPos == -1 {
+			return nil, fmt.Errorf("invalid `git ls-tree` output: %q", line)
+		}
+		info := strings.SplitN(line[:tabPos], " ", 3)
+		if len(info) != 3 {
+			return nil, fmt.Errorf("invalid `git ls-tree` output: %q", line)
+		}
+		if ObjectType(info[1]) != ObjectTypeBlob {
+			// Submodule.
+			continue
+		}
+		oidBytes, err := hex.DecodeString(info[2])
+		if err != nil || len(oidBytes) != len(OID{}) {
+			return nil, fmt.Errorf("invalid `git ls-tree` oid output: %q", info[2])
+		}
+		blob := &BlobEntry{Path: line[tabPos+1:]}
+		copy(blob.OID[:], oidBytes)
+		blobs = append(blobs, blob)
+	}
+	return blobs, nil
+}
@@ -452,3 +452,48 @@ func TestRepository_FileSystem_gitSubmodules(t *testing.T) {
 		}
 	}
 }
+
+func TestRepository_ListBlobs(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"mkdir dir",
+		"echo x > a",
+		"echo x > dir/b",
+		"echo y > c",
+		"git add a dir/b c",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	repo := makeGitRepository(t, gitCommands...)
+	commitID, err := git.ResolveRevision(ctx, repo, nil, "master", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobs, err := git.ListBlobs(ctx, repo, commitID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, blob := range blobs {
+		got = append(got, blob.Path+" "+blob.OID.String())
+	}
+	// Files with the same contents have the same OID.
+	want := []string{
+		"a 587be6b4c3f93f93c489c0111bba5596147a26cb",
+		"c 975fbec8256d3e8a3797e7a3611380f27c49f4ac",
+		"dir/b 587be6b4c3f93f93c489c0111bba5596147a26cb",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got blobs %v, want %v", got, want)
+	}
+
+	// The blobs of a commit are cached.
+	cached, err := git.ListBlobs(ctx, repo, commitID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != len(blobs) || cached[0] != blobs[0] {
+		t.Error("expected the blobs to be cached")
+	}
+}
@@ -10,11 +10,13 @@ import (
 )
 
 type AWSCodeCommitConnection struct {
-	AccessKeyID                 string `json:"accessKeyID"`
-	InitialRepositoryEnablement bool   `json:"initialRepositoryEnablement,omitempty"`
-	Region                      string `json:"region"`
-	RepositoryPathPattern       string `json:"repositoryPathPattern,omitempty"`
-	SecretAccessKey             string `json:"secretAccessKey"`
+	AccessKeyID                 string              `json:"accessKeyID"`
+	Exclude                     []*RepositoryFilter `json:"exclude,omitempty"`
+	Include                     []*RepositoryFilter `json:"include,omitempty"`
+	InitialRepositoryEnablement bool                `json:"initialRepositoryEnablement,omitempty"`
+	Region                      string              `json:"region"`
+	RepositoryPathPattern       string              `json:"repositoryPathPattern,omitempty"`
+	SecretAccessKey             string              `json:"secretAccessKey"`
 }
 type Action struct {
 	ActionItem       *ActionItem   `json:"actionItem,omitempty"`
@@ -97,15 +99,18 @@ type AuthnProvider struct {
 	Type           string `json:"type"`
 }
 type BitbucketServerConnection struct {
-	Certificate                 string `json:"certificate,omitempty"`
-	ExcludePersonalRepositories bool   `json:"excludePersonalRepositories,omitempty"`
-	GitURLType                  string `json:"gitURLType,omitempty"`
-	InitialRepositoryEnablement bool   `json:"initialRepositoryEnablement,omitempty"`
-	Password                    string `json:"password,omitempty"`
-	RepositoryPathPattern       string `json:"repositoryPathPattern,omitempty"`
-	Token                       string `json:"token,omitempty"`
-	Url                         string `json:"url"`
-	Username                    string `json:"username,omitempty"`
+	Certificate                 string              `json:"certificate,omitempty"`
+	Exclude                     []*RepositoryFilter `json:"exclude,omitempty"`
+	ExcludePersonalRepositories bool                `json:"excludePersonalRepositories,omitempty"`
+	GitURLType                  string              `json:"gitURLType,omitempty"`
+	Include                     []*RepositoryFilter `json:"include,omitempty"`
+	InitialRepositoryEnablement bool                `json:"initialRepositoryEnablement,omitempty"`
+	Password                    string              `json:"password,omitempty"`
+	RepositoryPathPattern       string              `json:"repositoryPathPattern,omitempty"`
+	Token                       string              `json:"token,omitempty"`
+	Url                         string              `json:"url"`
+	Username                    string              `json:"username,omitempty"`
+	WebhookSecret               string              `json:"webhookSecret,omitempty"`
 }
 
 // BuiltinAuthProvider description: Configures the builtin username-password authentication provider.
@@ -155,6 +160,13 @@ type Extensions struct {
 	RemoteRegistry        interface{} `json:"remoteRegistry,omitempty"`
 }
 
+// GitCloneOptions description: Options for cloning and fetching the repositories matching a pattern.
+type GitCloneOptions struct {
+	Depth   int    `json:"depth,omitempty"`
+	Filter  string `json:"filter,omitempty"`
+	Pattern string `json:"pattern"`
+}
+
 // GitHubAuthProvider description: Configures the GitHub (or GitHub Enterprise) OAuth authentication provider for SSO. In addition to specifying this configuration object, you must also create a OAuth App on your GitHub instance: https://developer.github.com/apps/building-oauth-apps/creating-an-oauth-app/. When a user signs into Sourcegraph or links their GitHub account to their existing Sourcegraph account, GitHub will prompt the user for the repo scope.
 type GitHubAuthProvider struct {
 	ClientID     string `json:"clientID"`
@@ -171,13 +183,16 @@ type GitHubAuthorization struct {
 type GitHubConnection struct {
 	Authorization               *GitHubAuthorization `json:"authorization,omitempty"`
 	Certificate                 string               `json:"certificate,omitempty"`
+	Exclude                     []*RepositoryFilter  `json:"exclude,omitempty"`
 	GitURLType                  string               `json:"gitURLType,omitempty"`
+	Include                     []*RepositoryFilter  `json:"include,omitempty"`
 	InitialRepositoryEnablement bool                 `json:"initialRepositoryEnablement,omitempty"`
 	Repos                       []string             `json:"repos,omitempty"`
 	RepositoryPathPattern       string               `json:"repositoryPathPattern,omitempty"`
 	RepositoryQuery             []string             `json:"repositoryQuery,omitempty"`
 	Token                       string               `json:"token"`
 	Url                         string               `json:"url"`
+	WebhookSecret               string               `json:"webhookSecret,omitempty"`
 }
 
 // GitLabAuthorization description: If non-null, enforces GitLab repository permissions. This requires that the value of `token` be an access token with "sudo" and "api" scopes.
@@ -188,18 +203,35 @@ type GitLabAuthorization struct {
 type GitLabConnection struct {
 	Authorization               *GitLabAuthorization `json:"authorization,omitempty"`
 	Certificate                 string               `json:"certificate,omitempty"`
+	Exclude                     []*RepositoryFilter  `json:"exclude,omitempty"`
 	GitURLType                  string               `json:"gitURLType,omitempty"`
+	Include                     []*RepositoryFilter  `json:"include,omitempty"`
 	InitialRepositoryEnablement bool                 `json:"initialRepositoryEnablement,omitempty"`
 	ProjectQuery                []string             `json:"projectQuery,omitempty"`
 	RepositoryPathPattern       string               `json:"repositoryPathPattern,omitempty"`
 	Token                       string               `json:"token"`
 	Url                         string               `json:"url"`
+	WebhookSecret               string               `json:"webhookSecret,omitempty"`
+}
+type GiteaConnection struct {
+	Certificate                 string              `json:"certificate,omitempty"`
+	Exclude                     []*RepositoryFilter `json:"exclude,omitempty"`
+	GitURLType                  string              `json:"gitURLType,omitempty"`
+	Include                     []*RepositoryFilter `json:"include,omitempty"`
+	InitialRepositoryEnablement bool                `json:"initialRepositoryEnablement,omitempty"`
+	Repos                       []string            `json:"repos,omitempty"`
+	RepositoryPathPattern       string              `json:"repositoryPathPattern,omitempty"`
+	RepositoryQuery             []string            `json:"repositoryQuery,omitempty"`
+	Token                       string              `json:"token,omitempty"`
+	Url                         string              `json:"url"`
 }
 type GitoliteConnection struct {
-	Blacklist                  string `json:"blacklist,omitempty"`
-	Host                       string `json:"host"`
-	PhabricatorMetadataCommand string `json:"phabricatorMetadataCommand,omitempty"`
-	Prefix                     string `json:"prefix"`
+	Blacklist                  string              `json:"blacklist,omitempty"`
+	Exclude                    []*RepositoryFilter `json:"exclude,omitempty"`
+	Host                       string              `json:"host"`
+	Include                    []*RepositoryFilter `json:"include,omitempty"`
+	PhabricatorMetadataCommand string              `json:"phabricatorMetadataCommand,omitempty"`
+	Prefix                     string              `json:"prefix"`
 }
 
 // HTTPHeaderAuthProvider description: Configures the HTTP header authentication provider (which authenticates users by consulting an HTTP request header set by an authentication proxy such as https://github.com/bitly/oauth2_proxy).
@@ -265,6 +297,16 @@ type OpenIDConnectAuthProvider struct {
 	RequireEmailDomain string `json:"requireEmailDomain,omitempty"`
 	Type               string `json:"type"`
 }
+type OtherExternalServiceConnection struct {
+	Discovery                   string              `json:"discovery"`
+	Exclude                     []*RepositoryFilter `json:"exclude,omitempty"`
+	ExportList                  string              `json:"exportList,omitempty"`
+	Include                     []*RepositoryFilter `json:"include,omitempty"`
+	InitialRepositoryEnablement bool                `json:"initialRepositoryEnablement,omitempty"`
+	RepositoryPathPattern       string              `json:"repositoryPathPattern,omitempty"`
+	SshCommand                  string              `json:"sshCommand,omitempty"`
+	Url                         string              `json:"url"`
+}
 
 // ParentSourcegraph description: URL to fetch unreachable repository details from. Defaults to "https://sourcegraph.com"
 type ParentSourcegraph struct {
@@ -285,6 +327,14 @@ type Repository struct {
 	Type  string `json:"type,omitempty"`
 	Url   string `json:"url"`
 }
+
+// RepositoryFilter description: A rule matching repositories of a code host connection. A repository matches the rule if it matches all of the rule's properties.
+type RepositoryFilter struct {
+	Archived    bool   `json:"archived,omitempty"`
+	Fork        bool   `json:"fork,omitempty"`
+	Name        string `json:"name,omitempty"`
+	SizeAboveMB int    `json:"sizeAboveMB,omitempty"`
+}
 type ReviewBoard struct {
 	Url string `json:"url,omitempty"`
 }
@@ -315,6 +365,18 @@ type SMTPServerConfig struct {
 	Port           int    `json:"port"`
 	Username       string `json:"username,omitempty"`
 }
+
+// SearchRanking description: Configures how text search results are ranked. Each file match is scored by combining the following signals, and the results are ordered by decreasing score. Set a weight to 0 to ignore a signal. A search collects more file matches than the query's `count:` (up to 5 times as many, and at most 1000 unless more are requested), and returns the highest-scoring ones.
+type SearchRanking struct {
+	Enabled          *bool              `json:"enabled,omitempty"`
+	ForkPenalty      *float64           `json:"forkPenalty,omitempty"`
+	IndexScore       *float64           `json:"indexScore,omitempty"`
+	LineMatches      *float64           `json:"lineMatches,omitempty"`
+	PathPenalties    map[string]float64 `json:"pathPenalties,omitempty"`
+	Popularity       *float64           `json:"popularity,omitempty"`
+	RepositoryBoosts map[string]float64 `json:"repositoryBoosts,omitempty"`
+	Symbols          *float64           `json:"symbols,omitempty"`
+}
 type SearchSavedQueries struct {
 	Description    string `json:"description"`
 	Key            string `json:"key"`
@@ -340,6 +402,7 @@ type Settings struct {
 	Extensions             map[string]bool           `json:"extensions,omitempty"`
 	Motd                   []string                  `json:"motd,omitempty"`
 	NotificationsSlack     *SlackNotificationsConfig `json:"notifications.slack,omitempty"`
+	SearchRanking          *SearchRanking            `json:"search.ranking,omitempty"`
 	SearchRepositoryGroups map[string][]string       `json:"search.repositoryGroups,omitempty"`
 	SearchSavedQueries     []*SearchSavedQueries     `json:"search.savedQueries,omitempty"`
 	SearchScopes           []*SearchScope            `json:"search.scopes,omitempty"`
@@ -347,64 +410,68 @@ type Settings struct {
 
 // SiteConfiguration description: Configuration for a Sourcegraph site.
 type SiteConfiguration struct {
-	AuthAccessTokens                  *AuthAccessTokens            `json:"auth.accessTokens,omitempty"`
-	AuthDisableAccessTokens           bool                         `json:"auth.disableAccessTokens,omitempty"`
-	AuthProviders                     []AuthProviders              `json:"auth.providers,omitempty"`
-	AuthPublic                        bool                         `json:"auth.public,omitempty"`
-	AuthSessionExpiry                 string                       `json:"auth.sessionExpiry,omitempty"`
-	AuthUserOrgMap                    map[string][]string          `json:"auth.userOrgMap,omitempty"`
-	AwsCodeCommit                     []*AWSCodeCommitConnection   `json:"awsCodeCommit,omitempty"`
-	BitbucketServer                   []*BitbucketServerConnection `json:"bitbucketServer,omitempty"`
-	BlacklistGoGet                    []string                     `json:"blacklistGoGet,omitempty"`
-	CorsOrigin                        string                       `json:"corsOrigin,omitempty"`
-	DisableAutoGitUpdates             bool                         `json:"disableAutoGitUpdates,omitempty"`
-	DisableBrowserExtension           bool                         `json:"disableBrowserExtension,omitempty"`
-	DisableBuiltInSearches            bool                         `json:"disableBuiltInSearches,omitempty"`
-	DisablePublicRepoRedirects        bool                         `json:"disablePublicRepoRedirects,omitempty"`
-	Discussions                       *Discussions                 `json:"discussions,omitempty"`
-	DontIncludeSymbolResultsByDefault bool                         `json:"dontIncludeSymbolResultsByDefault,omitempty"`
-	EmailAddress                      string                       `json:"email.address,omitempty"`
-	EmailImap                         *IMAPServerConfig            `json:"email.imap,omitempty"`
-	EmailSmtp                         *SMTPServerConfig            `json:"email.smtp,omitempty"`
-	ExecuteGradleOriginalRootPaths    string                       `json:"executeGradleOriginalRootPaths,omitempty"`
-	ExperimentalFeatures              *ExperimentalFeatures        `json:"experimentalFeatures,omitempty"`
-	Extensions                        *Extensions                  `json:"extensions,omitempty"`
-	ExternalURL                       string                       `json:"externalURL,omitempty"`
-	GitCloneURLToRepositoryName       []*CloneURLToRepositoryName  `json:"git.cloneURLToRepositoryName,omitempty"`
-	GitMaxConcurrentClones            int                          `json:"gitMaxConcurrentClones,omitempty"`
-	Github                            []*GitHubConnection          `json:"github,omitempty"`
-	GithubClientID                    string                       `json:"githubClientID,omitempty"`
-	GithubClientSecret                string                       `json:"githubClientSecret,omitempty"`
-	Gitlab                            []*GitLabConnection          `json:"gitlab,omitempty"`
-	Gitolite                          []*GitoliteConnection        `json:"gitolite,omitempty"`
-	HtmlBodyBottom                    string                       `json:"htmlBodyBottom,omitempty"`
-	HtmlBodyTop                       string                       `json:"htmlBodyTop,omitempty"`
-	HtmlHeadBottom                    string                       `json:"htmlHeadBottom,omitempty"`
-	HtmlHeadTop                       string                       `json:"htmlHeadTop,omitempty"`
-	HttpStrictTransportSecurity       interface{}                  `json:"httpStrictTransportSecurity,omitempty"`
-	HttpToHttpsRedirect               interface{}                  `json:"httpToHttpsRedirect,omitempty"`
-	Langservers                       []*Langservers               `json:"langservers,omitempty"`
-	LicenseKey                        string                       `json:"licenseKey,omitempty"`
-	LightstepAccessToken              string                       `json:"lightstepAccessToken,omitempty"`
-	LightstepProject                  string                       `json:"lightstepProject,omitempty"`
-	Log                               *Log                         `json:"log,omitempty"`
-	MaxReposToSearch                  int                          `json:"maxReposToSearch,omitempty"`
-	NoGoGetDomains                    string                       `json:"noGoGetDomains,omitempty"`
-	ParentSourcegraph                 *ParentSourcegraph           `json:"parentSourcegraph,omitempty"`
-	Phabricator                       []*Phabricator               `json:"phabricator,omitempty"`
-	PrivateArtifactRepoID             string                       `json:"privateArtifactRepoID,omitempty"`
-	PrivateArtifactRepoPassword       string                       `json:"privateArtifactRepoPassword,omitempty"`
-	PrivateArtifactRepoURL            string                       `json:"privateArtifactRepoURL,omitempty"`
-	PrivateArtifactRepoUsername       string                       `json:"privateArtifactRepoUsername,omitempty"`
-	RepoListUpdateInterval            int                          `json:"repoListUpdateInterval,omitempty"`
-	ReposList                         []*Repository                `json:"repos.list,omitempty"`
-	ReviewBoard                       []*ReviewBoard               `json:"reviewBoard,omitempty"`
-	SearchIndexEnabled                *bool                        `json:"search.index.enabled,omitempty"`
-	TlsLetsencrypt                    string                       `json:"tls.letsencrypt,omitempty"`
-	TlsCert                           string                       `json:"tlsCert,omitempty"`
-	TlsKey                            string                       `json:"tlsKey,omitempty"`
-	UpdateChannel                     string                       `json:"update.channel,omitempty"`
-	UseJaeger                         bool                         `json:"useJaeger,omitempty"`
+	AuthAccessTokens                  *AuthAccessTokens                 `json:"auth.accessTokens,omitempty"`
+	AuthDisableAccessTokens           bool                              `json:"auth.disableAccessTokens,omitempty"`
+	AuthProviders                     []AuthProviders                   `json:"auth.providers,omitempty"`
+	AuthPublic                        bool                              `json:"auth.public,omitempty"`
+	AuthSessionExpiry                 string                            `json:"auth.sessionExpiry,omitempty"`
+	AuthUserOrgMap                    map[string][]string               `json:"auth.userOrgMap,omitempty"`
+	AwsCodeCommit                     []*AWSCodeCommitConnection        `json:"awsCodeCommit,omitempty"`
+	BitbucketServer                   []*BitbucketServerConnection      `json:"bitbucketServer,omitempty"`
+	BlacklistGoGet                    []string                          `json:"blacklistGoGet,omitempty"`
+	CorsOrigin                        string                            `json:"corsOrigin,omitempty"`
+	DisableAutoGitUpdates             bool                              `json:"disableAutoGitUpdates,omitempty"`
+	DisableBrowserExtension           bool                              `json:"disableBrowserExtension,omitempty"`
+	DisableBuiltInSearches            bool                              `json:"disableBuiltInSearches,omitempty"`
+	DisablePublicRepoRedirects        bool                              `json:"disablePublicRepoRedirects,omitempty"`
+	Discussions                       *Discussions                      `json:"discussions,omitempty"`
+	DontIncludeSymbolResultsByDefault bool                              `json:"dontIncludeSymbolResultsByDefault,omitempty"`
+	EmailAddress                      string                            `json:"email.address,omitempty"`
+	EmailImap                         *IMAPServerConfig                 `json:"email.imap,omitempty"`
+	EmailSmtp                         *SMTPServerConfig                 `json:"email.smtp,omitempty"`
+	ExecuteGradleOriginalRootPaths    string                            `json:"executeGradleOriginalRootPaths,omitempty"`
+	ExperimentalFeatures              *ExperimentalFeatures             `json:"experimentalFeatures,omitempty"`
+	Extensions                        *Extensions                       `json:"extensions,omitempty"`
+	ExternalURL                       string                            `json:"externalURL,omitempty"`
+	GitCloneOptions                   []*GitCloneOptions                `json:"git.cloneOptions,omitempty"`
+	GitCloneURLToRepositoryName       []*CloneURLToRepositoryName       `json:"git.cloneURLToRepositoryName,omitempty"`
+	GitMaxConcurrentClones            int                               `json:"gitMaxConcurrentClones,omitempty"`
+	GitMaxRepoSizeMB                  int                               `json:"gitMaxRepoSizeMB,omitempty"`
+	Gitea                             []*GiteaConnection                `json:"gitea,omitempty"`
+	Github                            []*GitHubConnection               `json:"github,omitempty"`
+	GithubClientID                    string                            `json:"githubClientID,omitempty"`
+	GithubClientSecret                string                            `json:"githubClientSecret,omitempty"`
+	Gitlab                            []*GitLabConnection               `json:"gitlab,omitempty"`
+	Gitolite                          []*GitoliteConnection             `json:"gitolite,omitempty"`
+	HtmlBodyBottom                    string                            `json:"htmlBodyBottom,omitempty"`
+	HtmlBodyTop                       string                            `json:"htmlBodyTop,omitempty"`
+	HtmlHeadBottom                    string                            `json:"htmlHeadBottom,omitempty"`
+	HtmlHeadTop                       string                            `json:"htmlHeadTop,omitempty"`
+	HttpStrictTransportSecurity       interface{}                       `json:"httpStrictTransportSecurity,omitempty"`
+	HttpToHttpsRedirect               interface{}                       `json:"httpToHttpsRedirect,omitempty"`
+	Langservers                       []*Langservers                    `json:"langservers,omitempty"`
+	LicenseKey                        string                            `json:"licenseKey,omitempty"`
+	LightstepAccessToken              string                            `json:"lightstepAccessToken,omitempty"`
+	LightstepProject                  string                            `json:"lightstepProject,omitempty"`
+	Log                               *Log                              `json:"log,omitempty"`
+	MaxReposToSearch                  int                               `json:"maxReposToSearch,omitempty"`
+	NoGoGetDomains                    string                            `json:"noGoGetDomains,omitempty"`
+	Other                             []*OtherExternalServiceConnection `json:"other,omitempty"`
+	ParentSourcegraph                 *ParentSourcegraph                `json:"parentSourcegraph,omitempty"`
+	Phabricator                       []*Phabricator                    `json:"phabricator,omitempty"`
+	PrivateArtifactRepoID             string                            `json:"privateArtifactRepoID,omitempty"`
+	PrivateArtifactRepoPassword       string                            `json:"privateArtifactRepoPassword,omitempty"`
+	PrivateArtifactRepoURL            string                            `json:"privateArtifactRepoURL,omitempty"`
+	PrivateArtifactRepoUsername       string                            `json:"privateArtifactRepoUsername,omitempty"`
+	RepoListUpdateInterval            int                               `json:"repoListUpdateInterval,omitempty"`
+	ReposList                         []*Repository                     `json:"repos.list,omitempty"`
+	ReviewBoard                       []*ReviewBoard                    `json:"reviewBoard,omitempty"`
+	SearchIndexEnabled                *bool                             `json:"search.index.enabled,omitempty"`
+	TlsLetsencrypt                    string                            `json:"tls.letsencrypt,omitempty"`
+	TlsCert                           string                            `json:"tlsCert,omitempty"`
+	TlsKey                            string                            `json:"tlsKey,omitempty"`
+	UpdateChannel                     string                            `json:"update.channel,omitempty"`
+	UseJaeger                         bool                              `json:"useJaeger,omitempty"`
 }
 
 // SlackNotificationsConfig description: Configuration for sending notifications to Slack.
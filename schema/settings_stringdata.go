@@ -61,6 +61,9 @@ const SettingsSchemaJSON = `{
         "items": { "type": "string" }
       }
     },
+    "search.ranking": {
+      "$ref": "#/definitions/SearchRanking"
+    },
     "notifications.slack": {
       "$ref": "#/definitions/SlackNotificationsConfig"
     },
@@ -110,6 +113,73 @@ const SettingsSchemaJSON = `{
         }
       }
     },
+    "SearchRanking": {
+      "description":
+        "Configures how text search results are ranked. Each file match is scored by combining the following signals, and the results are ordered by decreasing score. Set a weight to 0 to ignore a signal. A search collects more file matches than the query's ` + "`" + `count:` + "`" + ` (up to 5 times as many, and at most 1000 unless more are requested), and returns the highest-scoring ones.",
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "enabled": {
+          "description":
+            "Whether to rank search results by score. If false, results are ordered by repository name and file path.",
+          "type": "boolean",
+          "default": true,
+          "!go": { "pointer": true }
+        },
+        "indexScore": {
+          "description":
+            "The weight of the score which the search index (Zoekt) assigns to a file match, which favors matches on whole words and in symbol definitions.",
+          "type": "number",
+          "default": 1,
+          "minimum": 0,
+          "!go": { "pointer": true }
+        },
+        "lineMatches": {
+          "description": "The weight of the number of matching lines in a file.",
+          "type": "number",
+          "default": 2,
+          "minimum": 0,
+          "!go": { "pointer": true }
+        },
+        "symbols": {
+          "description":
+            "The weight of the number of symbols (e.g., function or type definitions) in a file which match the query, according to the symbols service.",
+          "type": "number",
+          "default": 4,
+          "minimum": 0,
+          "!go": { "pointer": true }
+        },
+        "popularity": {
+          "description":
+            "The weight of the number of stars of a repository on its code host (GitHub, GitLab or Gitea), which is added to the score of the matches in the repository.",
+          "type": "number",
+          "default": 0.5,
+          "minimum": 0,
+          "!go": { "pointer": true }
+        },
+        "forkPenalty": {
+          "description": "The amount subtracted from the score of matches in repositories which are forks.",
+          "type": "number",
+          "default": 3,
+          "minimum": 0,
+          "!go": { "pointer": true }
+        },
+        "pathPenalties": {
+          "description":
+            "Amounts subtracted from the score of matches in files whose path matches a regular expression. These are added to the default penalties for vendored, test and generated files (e.g., ` + "`" + `vendor/` + "`" + `, ` + "`" + `_test.go` + "`" + `, ` + "`" + `.pb.go` + "`" + `). Set the penalty for a default pattern to 0 to disable it.",
+          "type": "object",
+          "additionalProperties": { "type": "number" },
+          "examples": [{ "(^|/)fixtures/": 5, "(^|/)vendor/": 0 }]
+        },
+        "repositoryBoosts": {
+          "description":
+            "Amounts added to the score of matches in repositories whose name matches a regular expression. Use this to favor the repositories which matter most to your organization (and negative amounts to demote repositories).",
+          "type": "object",
+          "additionalProperties": { "type": "number" },
+          "examples": [{ "^github\\.com/myorg/": 5, "-archive$": -5 }]
+        }
+      }
+    },
     "SlackNotificationsConfig": {
       "type": "object",
       "description": "Configuration for sending notifications to Slack.",
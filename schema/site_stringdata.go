@@ -195,6 +195,14 @@ const SiteSchemaJSON = `{
         "$ref": "#/definitions/CloneURLToRepositoryName"
       }
     },
+    "git.cloneOptions": {
+      "description":
+        "JSON array of options for cloning and fetching repositories, such as partial or shallow clones of very large repositories. A repository uses the options of the first entry whose ` + "`" + `pattern` + "`" + ` matches its name. Changes apply to repositories cloned (or re-cloned) afterwards, except that ` + "`" + `depth` + "`" + ` also applies to every fetch.",
+      "type": "array",
+      "items": {
+        "$ref": "#/definitions/GitCloneOptions"
+      }
+    },
     "github": {
       "description":
         "JSON array of configuration for GitHub hosts. See GitHub Configuration section for more information.",
@@ -218,6 +226,13 @@ const SiteSchemaJSON = `{
         "$ref": "#/definitions/GitLabConnection"
       }
     },
+    "gitea": {
+      "description": "JSON array of configuration for Gitea and Gogs hosts.",
+      "type": "array",
+      "items": {
+        "$ref": "#/definitions/GiteaConnection"
+      }
+    },
     "awsCodeCommit": {
       "description": "JSON array of configuration for AWS CodeCommit endpoints.",
       "type": "array",
@@ -239,11 +254,26 @@ const SiteSchemaJSON = `{
         "$ref": "#/definitions/GitoliteConnection"
       }
     },
+    "other": {
+      "description":
+        "JSON array of configuration for other Git hosts (such as cgit, gitweb or git daemon), whose repositories are discovered by crawling the host.",
+      "type": "array",
+      "items": {
+        "$ref": "#/definitions/OtherExternalServiceConnection"
+      }
+    },
     "gitMaxConcurrentClones": {
       "description": "Maximum number of git clone processes that will be run concurrently to update repositories.",
       "type": "integer",
       "default": 5
     },
+    "gitMaxRepoSizeMB": {
+      "description":
+        "Maximum disk space in megabytes that a single repository may use on gitserver. Repositories larger than this are not cloned (a clone is discarded once it completes), and repositories that grow larger than this are no longer updated. The default (0) is no limit.",
+      "type": "integer",
+      "minimum": 0,
+      "default": 0
+    },
     "repos.list": {
       "description": "JSON array of configuration for external repositories.",
       "type": "array",
@@ -612,6 +642,12 @@ const SiteSchemaJSON = `{
           "type": "string",
           "pattern": "^-----BEGIN CERTIFICATE-----\n"
         },
+        "webhookSecret": {
+          "description":
+            "The shared secret of the push webhooks configured on GitHub or GitHub Enterprise to notify Sourcegraph of new commits. GitHub signs the webhook payload with it (the \"Secret\" of the webhook). Repositories are updated immediately when a push webhook with this secret is received at Sourcegraph's /.api/webhooks/github endpoint, instead of waiting for the next periodic update.",
+          "type": "string",
+          "minLength": 1
+        },
         "repos": {
           "description":
             "An array of repository \"owner/name\" strings specifying which GitHub or GitHub Enterprise repositories to mirror on Sourcegraph.",
@@ -631,6 +667,18 @@ const SiteSchemaJSON = `{
           "type": "string",
           "default": "{host}/{nameWithOwner}"
         },
+        "exclude": {
+          "description":
+            "Rules for repositories that should not be mirrored on Sourcegraph, even if they are returned by the code host. A repository is excluded if it matches any of the rules. Excluded repositories that were already mirrored are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
+        "include": {
+          "description":
+            "Rules for the only repositories that should be mirrored on Sourcegraph. If set, a repository returned by the code host is mirrored only if it matches any of the rules (and no rule in ` + "`" + `exclude` + "`" + `). Repositories that no longer match are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
         "initialRepositoryEnablement": {
           "description":
             "Defines whether repositories from this GitHub instance should be enabled and cloned when they are first seen by Sourcegraph. If false, the site admin must explicitly enable GitHub repositories (in the site admin area) to clone them and make them searchable on Sourcegraph. If true, they will be enabled and cloned immediately (subject to rate limiting by GitHub); site admins can still disable them explicitly, and they'll remain disabled.",
@@ -687,6 +735,12 @@ const SiteSchemaJSON = `{
           "type": "string",
           "pattern": "^-----BEGIN CERTIFICATE-----\n"
         },
+        "webhookSecret": {
+          "description":
+            "The shared secret of the push webhooks configured on GitLab to notify Sourcegraph of new commits. GitLab sends it in the X-Gitlab-Token header (the \"Secret Token\" of the webhook). Repositories are updated immediately when a push webhook with this secret is received at Sourcegraph's /.api/webhooks/gitlab endpoint, instead of waiting for the next periodic update.",
+          "type": "string",
+          "minLength": 1
+        },
         "projectQuery": {
           "description":
             "An array of strings specifying which GitLab projects to mirror on Sourcegraph. Each string is a URL query string for the GitLab projects API, such as \"?membership=true&search=foo\".\n\nThe query string is passed directly to GitLab to retrieve the list of projects. The special string \"none\" can be used as the only element to disable this feature. Projects matched by multiple query strings are only imported once. See https://docs.gitlab.com/ee/api/projects.html#list-all-projects for available query string options.",
@@ -702,6 +756,18 @@ const SiteSchemaJSON = `{
           "type": "string",
           "default": "{host}/{pathWithNamespace}"
         },
+        "exclude": {
+          "description":
+            "Rules for repositories that should not be mirrored on Sourcegraph, even if they are returned by the code host. A repository is excluded if it matches any of the rules. Excluded repositories that were already mirrored are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
+        "include": {
+          "description":
+            "Rules for the only repositories that should be mirrored on Sourcegraph. If set, a repository returned by the code host is mirrored only if it matches any of the rules (and no rule in ` + "`" + `exclude` + "`" + `). Repositories that no longer match are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
         "initialRepositoryEnablement": {
           "description":
             "Defines whether repositories from this GitLab instance should be enabled and cloned when they are first seen by Sourcegraph. If false, the site admin must explicitly enable GitLab repositories (in the site admin area) to clone them and make them searchable on Sourcegraph. If true, they will be enabled and cloned immediately (subject to rate limiting by GitLab); site admins can still disable them explicitly, and they'll remain disabled.",
@@ -746,6 +812,81 @@ const SiteSchemaJSON = `{
         }
       }
     },
+    "GiteaConnection": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["url"],
+      "properties": {
+        "url": {
+          "description": "URL of a Gitea or Gogs instance, such as https://gitea.example.com.",
+          "type": "string",
+          "pattern": "^https?://",
+          "not": {
+            "type": "string",
+            "pattern": "example\\.com"
+          },
+          "format": "uri",
+          "examples": ["https://gitea.example.com", "https://try.gitea.io"]
+        },
+        "token": {
+          "description":
+            "A Gitea or Gogs access token (created in the user settings under \"Applications\"). It is used to list and clone private repositories. Without a token, only public repositories are available.",
+          "type": "string",
+          "pattern": "^[^<>]+$"
+        },
+        "gitURLType": {
+          "description":
+            "The type of Git URLs to use for cloning and fetching Git repositories on this Gitea instance.\n\nIf \"http\", Sourcegraph will access Gitea repositories using Git URLs of the form http(s)://gitea.example.com/myteam/myrepo.git (using https: if the Gitea instance uses HTTPS).\n\nIf \"ssh\", Sourcegraph will access Gitea repositories using Git URLs of the form git@gitea.example.com:myteam/myrepo.git. See the documentation for how to provide SSH private keys and known_hosts: https://docs.sourcegraph.com/admin/repo/add_from_git_repository#repositories-that-need-http-s-or-ssh-authentication.",
+          "type": "string",
+          "enum": ["http", "ssh"],
+          "default": "http"
+        },
+        "certificate": {
+          "description": "TLS certificate of a Gitea instance.",
+          "type": "string",
+          "pattern": "^-----BEGIN CERTIFICATE-----\n"
+        },
+        "repos": {
+          "description":
+            "An array of repository \"owner/name\" strings specifying which Gitea repositories to mirror on Sourcegraph.",
+          "type": "array",
+          "items": { "type": "string", "pattern": "^[\\w-]+/[\\w.-]+$" }
+        },
+        "repositoryQuery": {
+          "description":
+            "An array of strings specifying which Gitea repositories to mirror on Sourcegraph. The valid values are:\n\n- ` + "`" + `affiliated` + "`" + ` mirrors all repositories the token's user owns or is a collaborator or organization member of\n\n- ` + "`" + `all` + "`" + ` mirrors all repositories visible to the token's user (or all public repositories, if no token is set). This uses the repository search API, which Gogs supports only in recent versions.\n\n- ` + "`" + `org:name` + "`" + ` mirrors all repositories of the organization \"name\"\n\n- ` + "`" + `none` + "`" + ` mirrors no repositories (except those specified in the ` + "`" + `repos` + "`" + ` configuration property or added manually)\n\nIf multiple values are provided, their results are unioned.",
+          "type": "array",
+          "default": ["affiliated"],
+          "items": {
+            "type": "string",
+            "pattern": "^(affiliated|all|none|org:.+)$"
+          }
+        },
+        "repositoryPathPattern": {
+          "description":
+            "The pattern used to generate the corresponding Sourcegraph repository name for a Gitea repository. In the pattern, the variable \"{host}\" is replaced with the Gitea URL's host (such as gitea.example.com), and \"{nameWithOwner}\" is replaced with the Gitea repository's \"owner/name\" (such as \"myteam/myrepo\").\n\nFor example, if your Gitea is https://gitea.example.com and your Sourcegraph is https://src.example.com, then a repositoryPathPattern of \"{host}/{nameWithOwner}\" would mean that a Gitea repository at https://gitea.example.com/myteam/myrepo is available on Sourcegraph at https://src.example.com/gitea.example.com/myteam/myrepo.\n\nIt is important that the Sourcegraph repository name generated with this pattern be unique to this code host. If different code hosts generate repository names that collide, Sourcegraph's behavior is undefined.",
+          "type": "string",
+          "default": "{host}/{nameWithOwner}"
+        },
+        "exclude": {
+          "description":
+            "Rules for repositories that should not be mirrored on Sourcegraph, even if they are returned by the code host. A repository is excluded if it matches any of the rules. Excluded repositories that were already mirrored are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
+        "include": {
+          "description":
+            "Rules for the only repositories that should be mirrored on Sourcegraph. If set, a repository returned by the code host is mirrored only if it matches any of the rules (and no rule in ` + "`" + `exclude` + "`" + `). Repositories that no longer match are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
+        "initialRepositoryEnablement": {
+          "description":
+            "Defines whether repositories from this Gitea instance should be enabled and cloned when they are first seen by Sourcegraph. If false, the site admin must explicitly enable Gitea repositories (in the site admin area) to clone them and make them searchable on Sourcegraph. If true, they will be enabled and cloned immediately; site admins can still disable them explicitly, and they'll remain disabled.",
+          "type": "boolean"
+        }
+      }
+    },
     "BitbucketServerConnection": {
       "type": "object",
       "additionalProperties": false,
@@ -790,6 +931,12 @@ const SiteSchemaJSON = `{
           "type": "string",
           "pattern": "^-----BEGIN CERTIFICATE-----\n"
         },
+        "webhookSecret": {
+          "description":
+            "The shared secret of the push webhooks configured on Bitbucket Server to notify Sourcegraph of new commits. Bitbucket Server signs the webhook payload with it (the \"Secret\" of the webhook). Repositories are updated immediately when a push webhook with this secret is received at Sourcegraph's /.api/webhooks/bitbucket-server endpoint, instead of waiting for the next periodic update.",
+          "type": "string",
+          "minLength": 1
+        },
         "repositoryPathPattern": {
           "description":
             "The pattern used to generate the corresponding Sourcegraph repository name for a Bitbucket Server repository.\n\n - \"{host}\" is replaced with the Bitbucket Server URL's host (such as bitbucket.example.com)\n - \"{projectKey}\" is replaced with the Bitbucket repository's parent project key (such as \"PRJ\")\n - \"{repositorySlug}\" is replaced with the Bitbucket repository's slug key (such as \"my-repo\").\n\nFor example, if your Bitbucket Server is https://bitbucket.example.com and your Sourcegraph is https://src.example.com, then a repositoryPathPattern of \"{host}/{projectKey}/{repositorySlug}\" would mean that a Bitbucket Server repository at https://bitbucket.example.com/projects/PRJ/repos/my-repo is available on Sourcegraph at https://src.example.com/bitbucket.example.com/PRJ/my-repo.\n\nIt is important that the Sourcegraph repository name generated with this pattern be unique to this code host. If different code hosts generate repository names that collide, Sourcegraph's behavior is undefined.",
@@ -801,6 +948,18 @@ const SiteSchemaJSON = `{
             "Whether or not personal repositories should be excluded or not. When true, Sourcegraph will ignore personal repositories it may have access to. See https://docs.sourcegraph.com/integration/bitbucket_server#excluding-personal-repositories for more information. Default: false.",
           "type": "boolean"
         },
+        "exclude": {
+          "description":
+            "Rules for repositories that should not be mirrored on Sourcegraph, even if they are returned by the code host. A repository is excluded if it matches any of the rules. Excluded repositories that were already mirrored are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
+        "include": {
+          "description":
+            "Rules for the only repositories that should be mirrored on Sourcegraph. If set, a repository returned by the code host is mirrored only if it matches any of the rules (and no rule in ` + "`" + `exclude` + "`" + `). Repositories that no longer match are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
         "initialRepositoryEnablement": {
           "description":
             "Defines whether repositories from this Bitbucket Server instance should be enabled and cloned when they are first seen by Sourcegraph. If false, the site admin must explicitly enable Bitbucket Server repositories (in the site admin area) to clone them and make them searchable on Sourcegraph. If true, they will be enabled and cloned immediately (subject to rate limiting by Bitbucket Server); site admins can still disable them explicitly, and they'll remain disabled.",
@@ -851,6 +1010,18 @@ const SiteSchemaJSON = `{
           "type": "string",
           "default": "{name}"
         },
+        "exclude": {
+          "description":
+            "Rules for repositories that should not be mirrored on Sourcegraph, even if they are returned by the code host. A repository is excluded if it matches any of the rules. Excluded repositories that were already mirrored are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
+        "include": {
+          "description":
+            "Rules for the only repositories that should be mirrored on Sourcegraph. If set, a repository returned by the code host is mirrored only if it matches any of the rules (and no rule in ` + "`" + `exclude` + "`" + `). Repositories that no longer match are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
         "initialRepositoryEnablement": {
           "description":
             "Defines whether repositories from AWS CodeCommit should be enabled and cloned when they are first seen by Sourcegraph. If false, the site admin must explicitly enable AWS CodeCommit repositories (in the site admin area) to clone them and make them searchable on Sourcegraph. If true, they will be enabled and cloned immediately (subject to rate limiting by AWS); site admins can still disable them explicitly, and they'll remain disabled.",
@@ -877,6 +1048,18 @@ const SiteSchemaJSON = `{
             "Regular expression to filter repositories from auto-discovery, so they will not get cloned automatically.",
           "type": "string"
         },
+        "exclude": {
+          "description":
+            "Rules for repositories that should not be mirrored on Sourcegraph, even if they are returned by the code host. A repository is excluded if it matches any of the rules. Excluded repositories that were already mirrored are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
+        "include": {
+          "description":
+            "Rules for the only repositories that should be mirrored on Sourcegraph. If set, a repository returned by the code host is mirrored only if it matches any of the rules (and no rule in ` + "`" + `exclude` + "`" + `). Repositories that no longer match are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
         "phabricatorMetadataCommand": {
           "description":
             "Bash command that prints out the Phabricator callsign for a Gitolite repository. This will be run with environment variable $REPO set to the name of the repository and used to obtain the Phabricator metadata for a Gitolite repository. (Note: this requires ` + "`" + `bash` + "`" + ` to be installed.)",
@@ -884,6 +1067,120 @@ const SiteSchemaJSON = `{
         }
       }
     },
+    "OtherExternalServiceConnection": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["url", "discovery"],
+      "properties": {
+        "url": {
+          "description":
+            "Base URL of the Git host. The clone URL of a discovered repository is this URL joined with the repository's path, such as https://git.example.com/cgit/ (for cgit), git://git.example.com/ (for git daemon) or ssh://git@git.example.com/srv/git/ (for SSH).",
+          "type": "string",
+          "pattern": "^(https?|git|ssh)://",
+          "format": "uri",
+          "examples": ["https://git.example.com/cgit/", "git://git.example.com/", "ssh://git@git.example.com/srv/git/"]
+        },
+        "discovery": {
+          "description":
+            "How to discover the repositories on the Git host:\n\n- ` + "`" + `cgit` + "`" + ` crawls the cgit repository index at the URL\n\n- ` + "`" + `exportList` + "`" + ` reads the repository paths from the text file at ` + "`" + `exportList` + "`" + `, one per line (such as a list of the repositories exported by git daemon, or gitweb's projects.list)\n\n- ` + "`" + `ssh` + "`" + ` runs ` + "`" + `sshCommand` + "`" + ` on the SSH host of the URL, which must print the repository paths, one per line\n\nThe SSH keys and known_hosts of gitserver are used for ` + "`" + `ssh` + "`" + ` discovery (see the documentation: https://docs.sourcegraph.com/admin/repo/add_from_git_repository#repositories-that-need-http-s-or-ssh-authentication).",
+          "type": "string",
+          "enum": ["cgit", "exportList", "ssh"]
+        },
+        "exportList": {
+          "description":
+            "For ` + "`" + `exportList` + "`" + ` discovery, the HTTP(S) URL of a text file listing the repository paths (relative to ` + "`" + `url` + "`" + `), one per line. Only the first space-separated field of each line is used, and it may be URL-encoded (as in gitweb's projects.list).",
+          "type": "string",
+          "pattern": "^https?://"
+        },
+        "sshCommand": {
+          "description":
+            "For ` + "`" + `ssh` + "`" + ` discovery, the command to run on the SSH host. It must print the repository paths (relative to the path of ` + "`" + `url` + "`" + `, or absolute), one per line.",
+          "type": "string",
+          "default": "ls",
+          "examples": ["cd /srv/git && find . -name '*.git' -type d -prune"]
+        },
+        "repositoryPathPattern": {
+          "description":
+            "The pattern used to generate the corresponding Sourcegraph repository name for a discovered repository. In the pattern, the variable \"{host}\" is replaced with the URL's host (such as git.example.com), and \"{repo}\" is replaced with the repository's path relative to the URL, without a \".git\" suffix (such as \"myteam/myrepo\").\n\nIt is important that the Sourcegraph repository name generated with this pattern be unique to this code host. If different code hosts generate repository names that collide, Sourcegraph's behavior is undefined.",
+          "type": "string",
+          "default": "{host}/{repo}"
+        },
+        "exclude": {
+          "description":
+            "Rules for repositories that should not be mirrored on Sourcegraph, even if they are returned by the code host. A repository is excluded if it matches any of the rules. Excluded repositories that were already mirrored are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
+        "include": {
+          "description":
+            "Rules for the only repositories that should be mirrored on Sourcegraph. If set, a repository returned by the code host is mirrored only if it matches any of the rules (and no rule in ` + "`" + `exclude` + "`" + `). Repositories that no longer match are removed from Sourcegraph.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/RepositoryFilter" }
+        },
+        "initialRepositoryEnablement": {
+          "description":
+            "Defines whether discovered repositories should be enabled and cloned when they are first seen by Sourcegraph. If false, the site admin must explicitly enable them (in the site admin area) to clone them and make them searchable on Sourcegraph. If true, they will be enabled and cloned immediately; site admins can still disable them explicitly, and they'll remain disabled.",
+          "type": "boolean"
+        }
+      }
+    },
+    "RepositoryFilter": {
+      "description":
+        "A rule matching repositories of a code host connection. A repository matches the rule if it matches all of the rule's properties.",
+      "type": "object",
+      "additionalProperties": false,
+      "minProperties": 1,
+      "properties": {
+        "name": {
+          "description":
+            "Regular expression matched against the Sourcegraph repository name (such as \"github.com/myorg/myrepo\").",
+          "type": "string",
+          "format": "regex",
+          "examples": ["^github\\.com/myorg/", "-(generated|vendor)$"]
+        },
+        "fork": {
+          "description": "If true, the rule matches only forks.",
+          "type": "boolean"
+        },
+        "archived": {
+          "description": "If true, the rule matches only archived repositories.",
+          "type": "boolean"
+        },
+        "sizeAboveMB": {
+          "description":
+            "The rule matches only repositories larger than this many megabytes, as reported by the code host. Only GitHub and Gitea report repository sizes; repositories on other code hosts never match this property.",
+          "type": "integer",
+          "minimum": 1
+        }
+      }
+    },
+    "GitCloneOptions": {
+      "description": "Options for cloning and fetching the repositories matching a pattern.",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["pattern"],
+      "properties": {
+        "pattern": {
+          "description": "Regular expression matched against the repository name (such as \"github.com/myorg/myrepo\").",
+          "type": "string",
+          "format": "regex",
+          "examples": ["^github\\.com/myorg/monorepo$"]
+        },
+        "filter": {
+          "description":
+            "Clone a partial repository, omitting the objects excluded by this filter (see the --filter option of git-rev-list). Omitted objects are fetched from the code host when they are first read. \"blob:none\" omits every file's contents, so only the contents of the default branch's tip are fetched up front.",
+          "type": "string",
+          "pattern": "^(blob:none|blob:limit=[0-9]+[kmg]?|tree:0)$",
+          "examples": ["blob:none", "blob:limit=1m"]
+        },
+        "depth": {
+          "description":
+            "Clone and fetch only this many commits of history on each branch and tag. Older commits are not available for search, blame or history. Removing the depth fetches the full history on the next update.",
+          "type": "integer",
+          "minimum": 1
+        }
+      }
+    },
     "CloneURLToRepositoryName": {
       "description":
         "Describes a mapping from clone URL to repository name. The ` + "`" + `from` + "`" + ` field contains a regular expression with named capturing groups. The ` + "`" + `to` + "`" + ` field contains a template string that references capturing group names. For instance, if ` + "`" + `from` + "`" + ` is \"^../(?P<name>\\w+)$\" and ` + "`" + `to` + "`" + ` is \"github.com/user/{name}\", the clone URL \"../myRepository\" would be mapped to the repository name \"github.com/user/myRepository\".",